@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+)
+
+const (
+	adminPathV2         = apiV2Path + "/admin"
+	serviceCachePathV2  = adminPathV2 + "/cache/services"
+	nodeCachePathV2     = adminPathV2 + "/cache/nodes"
+	serviceDiffSuffixV2 = "/diff"
+)
+
+// ServiceCacheV2 is a dump of the service cache
+type ServiceCacheV2 struct {
+	Items []service.SwarmServiceMini `json:"items"`
+	Total int                        `json:"total"`
+	// UpdatedAt is not set when the cache was never updated
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// NodeCacheV2 is a dump of the node cache
+type NodeCacheV2 struct {
+	Items     []service.NodeMini `json:"items"`
+	Total     int                `json:"total"`
+	UpdatedAt *time.Time         `json:"updatedAt,omitempty"`
+}
+
+// FlushedV2 is the response of cache flushes
+type FlushedV2 struct {
+	Flushed int `json:"flushed"`
+}
+
+// AdminServiceCache dumps the cached services with `GET` and flushes them
+// with `DELETE`
+func (m Serve) AdminServiceCache(w http.ResponseWriter, req *http.Request) {
+	if !allowMethodV2(w, req, http.MethodGet, http.MethodDelete) {
+		return
+	}
+	if req.Method == http.MethodDelete {
+		flushed := m.SwarmListener.FlushServiceCache()
+		m.Log.Info("Flushed the service cache", "services", flushed)
+		m.writeJSONV2(w, http.StatusOK, FlushedV2{Flushed: flushed}, "serveAdminServiceCache")
+		return
+	}
+
+	services, updatedAt, ok := m.SwarmListener.GetCachedServices(service.ServiceFilter{})
+	dump := ServiceCacheV2{Items: []service.SwarmServiceMini{}}
+	if ok {
+		dump.Items = services
+		dump.UpdatedAt = &updatedAt
+	}
+	dump.Total = len(dump.Items)
+	m.writeJSONV2(w, http.StatusOK, dump, "serveAdminServiceCache")
+}
+
+// AdminServiceCacheEntry returns the cached service with the name or ID at
+// the end of the path with `GET`, and evicts it with `DELETE`
+// The diff between the cached entry and the service in Docker is returned
+// for paths ending with `/diff`.
+func (m Serve) AdminServiceCacheEntry(w http.ResponseWriter, req *http.Request) {
+	nameOrID := strings.TrimPrefix(req.URL.Path, serviceCachePathV2+"/")
+	if len(nameOrID) == 0 {
+		m.AdminServiceCache(w, req)
+		return
+	}
+	if strings.HasSuffix(nameOrID, serviceDiffSuffixV2) {
+		m.diffService(w, req, strings.TrimSuffix(nameOrID, serviceDiffSuffixV2))
+		return
+	}
+	if !allowMethodV2(w, req, http.MethodGet, http.MethodDelete) {
+		return
+	}
+
+	if req.Method == http.MethodDelete {
+		if _, ok := m.SwarmListener.EvictCachedService(nameOrID); !ok {
+			writeErrorV2(w, http.StatusNotFound, errorCodeNotFound,
+				fmt.Sprintf("Service %s is not cached", nameOrID))
+			return
+		}
+		m.Log.Info("Evicted service from the cache", "service", nameOrID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	services, _, ok := m.SwarmListener.GetCachedServices(
+		service.ServiceFilter{NamesOrIDs: []string{nameOrID}})
+	if !ok || len(services) == 0 {
+		writeErrorV2(w, http.StatusNotFound, errorCodeNotFound,
+			fmt.Sprintf("Service %s is not cached", nameOrID))
+		return
+	}
+	m.writeJSONV2(w, http.StatusOK, services[0], "serveAdminServiceCacheEntry")
+}
+
+// diffService writes the diff of the cached service with `nameOrID`
+func (m Serve) diffService(w http.ResponseWriter, req *http.Request, nameOrID string) {
+	if !allowMethodV2(w, req, http.MethodGet) {
+		return
+	}
+	diff, err := m.SwarmListener.DiffService(req.Context(), nameOrID)
+	switch err {
+	case nil:
+		m.writeJSONV2(w, http.StatusOK, diff, "serveAdminServiceDiff")
+	case service.ErrServicesNotCached:
+		writeErrorV2(w, http.StatusNotFound, errorCodeNotFound, err.Error())
+	case service.ErrServiceNotFound:
+		writeErrorV2(w, http.StatusNotFound, errorCodeNotFound,
+			fmt.Sprintf("Service %s was not found", nameOrID))
+	default:
+		m.writeServerErrorV2(w, err, "serveAdminServiceDiff")
+	}
+}
+
+// AdminNodeCache dumps the cached nodes with `GET` and flushes them with
+// `DELETE`
+func (m Serve) AdminNodeCache(w http.ResponseWriter, req *http.Request) {
+	if !allowMethodV2(w, req, http.MethodGet, http.MethodDelete) {
+		return
+	}
+	if req.Method == http.MethodDelete {
+		flushed := m.SwarmListener.FlushNodeCache()
+		m.Log.Info("Flushed the node cache", "nodes", flushed)
+		m.writeJSONV2(w, http.StatusOK, FlushedV2{Flushed: flushed}, "serveAdminNodeCache")
+		return
+	}
+
+	nodes, updatedAt, ok := m.SwarmListener.GetCachedNodes(service.NodeFilter{})
+	dump := NodeCacheV2{Items: []service.NodeMini{}}
+	if ok {
+		dump.Items = nodes
+		dump.UpdatedAt = &updatedAt
+	}
+	dump.Total = len(dump.Items)
+	m.writeJSONV2(w, http.StatusOK, dump, "serveAdminNodeCache")
+}
+
+// AdminNodeCacheEntry returns the cached node with the hostname or ID at
+// the end of the path with `GET`, and evicts it with `DELETE`
+func (m Serve) AdminNodeCacheEntry(w http.ResponseWriter, req *http.Request) {
+	nameOrID := strings.TrimPrefix(req.URL.Path, nodeCachePathV2+"/")
+	if len(nameOrID) == 0 {
+		m.AdminNodeCache(w, req)
+		return
+	}
+	if !allowMethodV2(w, req, http.MethodGet, http.MethodDelete) {
+		return
+	}
+
+	if req.Method == http.MethodDelete {
+		if _, ok := m.SwarmListener.EvictCachedNode(nameOrID); !ok {
+			writeErrorV2(w, http.StatusNotFound, errorCodeNotFound,
+				fmt.Sprintf("Node %s is not cached", nameOrID))
+			return
+		}
+		m.Log.Info("Evicted node from the cache", "node", nameOrID)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	nodes, _, ok := m.SwarmListener.GetCachedNodes(service.NodeFilter{NamesOrIDs: []string{nameOrID}})
+	if !ok || len(nodes) == 0 {
+		writeErrorV2(w, http.StatusNotFound, errorCodeNotFound,
+			fmt.Sprintf("Node %s is not cached", nameOrID))
+		return
+	}
+	m.writeJSONV2(w, http.StatusOK, nodes[0], "serveAdminNodeCacheEntry")
+}
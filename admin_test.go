@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *ServerTestSuite) Test_RestAdmin_RoutesTo_AdminHandlers() {
+	sm := new(serverMock)
+	routes := map[string]string{
+		"/v2/docker-flow-swarm-listener/admin/cache/services":              "AdminServiceCache",
+		"/v2/docker-flow-swarm-listener/admin/cache/services/demo_go":      "AdminServiceCacheEntry",
+		"/v2/docker-flow-swarm-listener/admin/cache/services/demo_go/diff": "AdminServiceCacheEntry",
+		"/v2/docker-flow-swarm-listener/admin/cache/nodes":                 "AdminNodeCache",
+		"/v2/docker-flow-swarm-listener/admin/cache/nodes/node1":           "AdminNodeCacheEntry",
+	}
+	for _, handler := range routes {
+		sm.On(handler, mock.Anything, mock.Anything).Return(nil)
+	}
+	mux := attachRoutes(sm)
+
+	for path, handler := range routes {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		sm.AssertCalled(s.T(), handler, mock.Anything, mock.Anything)
+	}
+}
+
+func (s *ServerTestSuite) Test_AdminServiceCache_DumpsCachedServices() {
+	updatedAt := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	services := []service.SwarmServiceMini{
+		{ID: "serviceID1", Name: "demo_go", Labels: map[string]string{"com.df.notify": "true"}, Replicas: 2},
+	}
+	s.SLMock.On("GetCachedServices", service.ServiceFilter{}).Return(services, updatedAt, true)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/admin/cache/services", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.AdminServiceCache(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{
+		"items": [{
+			"ID": "serviceID1",
+			"Name": "demo_go",
+			"Labels": {"com.df.notify": "true"},
+			"Global": false,
+			"Replicas": 2,
+			"ContainerImage": "",
+			"NodeInfo": []
+		}],
+		"total": 1,
+		"updatedAt": "2018-01-01T00:00:00Z"
+	}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_AdminServiceCache_ReturnsEmptyDump_WhenServicesAreNotCached() {
+	s.SLMock.On("GetCachedServices", service.ServiceFilter{}).Return([]service.SwarmServiceMini(nil), time.Time{}, false)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/admin/cache/services", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.AdminServiceCache(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{"items": [], "total": 0}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_AdminServiceCache_FlushesServices() {
+	s.SLMock.On("FlushServiceCache").Return(3)
+	req := httptest.NewRequest("DELETE", "/v2/docker-flow-swarm-listener/admin/cache/services", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.AdminServiceCache(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{"flushed": 3}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_AdminServiceCache_ReturnsStatus405_WhenMethodIsPost() {
+	req := httptest.NewRequest("POST", "/v2/docker-flow-swarm-listener/admin/cache/services", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.AdminServiceCache(w, req)
+
+	s.Equal(http.StatusMethodNotAllowed, w.Code)
+	s.Equal("GET, DELETE", w.Header().Get("Allow"))
+	s.JSONEq(`{"error": {"status": 405, "code": "methodNotAllowed", "message": "Method must be GET or DELETE"}}`,
+		w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_AdminServiceCacheEntry_ReturnsCachedService() {
+	ssm := service.SwarmServiceMini{ID: "serviceID1", Name: "demo_go"}
+	s.SLMock.On("GetCachedServices", service.ServiceFilter{NamesOrIDs: []string{"demo_go"}}).Return(
+		[]service.SwarmServiceMini{ssm}, time.Now(), true)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/admin/cache/services/demo_go", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.AdminServiceCacheEntry(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	actual := service.SwarmServiceMini{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &actual))
+	s.Equal("serviceID1", actual.ID)
+}
+
+func (s *ServerTestSuite) Test_AdminServiceCacheEntry_EvictsService() {
+	s.SLMock.
+		On("EvictCachedService", "demo_go").Return(service.SwarmServiceMini{ID: "serviceID1"}, true).
+		On("EvictCachedService", "demo_api").Return(service.SwarmServiceMini{}, false)
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+
+	w := httptest.NewRecorder()
+	srv.AdminServiceCacheEntry(w,
+		httptest.NewRequest("DELETE", "/v2/docker-flow-swarm-listener/admin/cache/services/demo_go", nil))
+	s.Equal(http.StatusNoContent, w.Code)
+	s.Empty(w.Body.String())
+
+	w = httptest.NewRecorder()
+	srv.AdminServiceCacheEntry(w,
+		httptest.NewRequest("DELETE", "/v2/docker-flow-swarm-listener/admin/cache/services/demo_api", nil))
+	s.Equal(http.StatusNotFound, w.Code)
+	s.JSONEq(`{"error": {"status": 404, "code": "notFound", "message": "Service demo_api is not cached"}}`,
+		w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_AdminServiceCacheEntry_ReturnsDiff() {
+	cached := service.SwarmServiceMini{ID: "serviceID1", Name: "demo_go", Replicas: 1}
+	current := service.SwarmServiceMini{ID: "serviceID1", Name: "demo_go", Replicas: 2}
+	diff := service.ServiceDiff{
+		ID:      "serviceID1",
+		Cached:  &cached,
+		Current: &current,
+		Changes: []service.FieldChange{{Field: "Replicas", Cached: uint64(1), Current: uint64(2)}},
+		Notify:  true,
+		Reason:  "The service changed since it was cached",
+	}
+	s.SLMock.On("DiffService", mock.Anything, "demo_go").Return(diff, nil)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/admin/cache/services/demo_go/diff", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.AdminServiceCacheEntry(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	actual := struct {
+		ID      string                `json:"id"`
+		Changes []service.FieldChange `json:"changes"`
+		Notify  bool                  `json:"notify"`
+	}{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &actual))
+	s.Equal("serviceID1", actual.ID)
+	s.Equal([]service.FieldChange{{Field: "Replicas", Cached: float64(1), Current: float64(2)}}, actual.Changes)
+	s.True(actual.Notify)
+}
+
+func (s *ServerTestSuite) Test_AdminServiceCacheEntry_ReturnsDiffErrors() {
+	s.SLMock.
+		On("DiffService", mock.Anything, "demo_go").Return(service.ServiceDiff{}, service.ErrServiceNotFound).
+		On("DiffService", mock.Anything, "demo_api").Return(service.ServiceDiff{}, service.ErrServicesNotCached).
+		On("DiffService", mock.Anything, "demo_web").Return(service.ServiceDiff{}, fmt.Errorf("Docker is down"))
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+
+	tests := map[string]ErrorV2{
+		"demo_go":  {Status: 404, Code: "notFound", Message: "Service demo_go was not found"},
+		"demo_api": {Status: 404, Code: "notFound", Message: "Services are not cached"},
+		"demo_web": {Status: 500, Code: "internal", Message: "Docker is down"},
+	}
+	for name, expected := range tests {
+		req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/admin/cache/services/"+name+"/diff", nil)
+		w := httptest.NewRecorder()
+
+		srv.AdminServiceCacheEntry(w, req)
+
+		actual := ErrorResponseV2{}
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &actual))
+		s.Equal(expected.Status, w.Code, name)
+		s.Equal(expected, actual.Error, name)
+	}
+}
+
+func (s *ServerTestSuite) Test_AdminNodeCache_DumpsAndFlushesNodes() {
+	nodes := []service.NodeMini{{ID: "nodeID1", Hostname: "node1", State: swarm.NodeStateReady}}
+	s.SLMock.
+		On("GetCachedNodes", service.NodeFilter{}).Return(nodes, time.Now(), true).
+		On("FlushNodeCache").Return(1)
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+
+	w := httptest.NewRecorder()
+	srv.AdminNodeCache(w, httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/admin/cache/nodes", nil))
+	s.Equal(http.StatusOK, w.Code)
+	actual := NodeCacheV2{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &actual))
+	s.Equal(nodes, actual.Items)
+	s.Equal(1, actual.Total)
+	s.NotNil(actual.UpdatedAt)
+
+	w = httptest.NewRecorder()
+	srv.AdminNodeCache(w, httptest.NewRequest("DELETE", "/v2/docker-flow-swarm-listener/admin/cache/nodes", nil))
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{"flushed": 1}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_AdminNodeCacheEntry_EvictsNode() {
+	s.SLMock.On("EvictCachedNode", "node1").Return(service.NodeMini{ID: "nodeID1"}, true)
+	req := httptest.NewRequest("DELETE", "/v2/docker-flow-swarm-listener/admin/cache/nodes/node1", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.AdminNodeCacheEntry(w, req)
+
+	s.Equal(http.StatusNoContent, w.Code)
+	s.SLMock.AssertCalled(s.T(), "EvictCachedNode", "node1")
+}
+
+func (s *ServerTestSuite) Test_AdminNodeCacheEntry_ReturnsStatus404_WhenNodeIsNotCached() {
+	s.SLMock.On("GetCachedNodes", service.NodeFilter{NamesOrIDs: []string{"node1"}}).Return(
+		[]service.NodeMini{}, time.Now(), true)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/admin/cache/nodes/node1", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.AdminNodeCacheEntry(w, req)
+
+	s.Equal(http.StatusNotFound, w.Code)
+	s.JSONEq(`{"error": {"status": 404, "code": "notFound", "message": "Node node1 is not cached"}}`,
+		w.Body.String())
+}
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+)
+
+const (
+	apiV2Path            = "/v2/docker-flow-swarm-listener"
+	servicesPathV2       = apiV2Path + "/services"
+	nodesPathV2          = apiV2Path + "/nodes"
+	notifyServicesPathV2 = apiV2Path + "/notify/services"
+	notifyNodesPathV2    = apiV2Path + "/notify/nodes"
+	resyncPathV2         = apiV2Path + "/resync"
+	openAPIPathV2        = apiV2Path + "/openapi.json"
+)
+
+// Codes of v2 errors
+const (
+	errorCodeBadRequest       = "badRequest"
+	errorCodeUnauthorized     = "unauthorized"
+	errorCodeNotFound         = "notFound"
+	errorCodeMethodNotAllowed = "methodNotAllowed"
+	errorCodeInternal         = "internal"
+	errorCodeDeliveryFailed   = "deliveryFailed"
+	errorCodeTimeout          = "timeout"
+	errorCodeNotLeader        = "notLeader"
+)
+
+// ErrorV2 describes why a v2 request failed
+type ErrorV2 struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorResponseV2 is the body of failed v2 requests
+type ErrorResponseV2 struct {
+	Error ErrorV2 `json:"error"`
+}
+
+// ServiceV2 is a service returned by the v2 API
+type ServiceV2 struct {
+	ID string `json:"id"`
+	// Name is the name of the service in Docker
+	Name string `json:"name"`
+	// ServiceName is the name of the service sent to endpoints
+	// The stack prefix is removed when `com.df.shortName` is `true`.
+	ServiceName string `json:"serviceName"`
+	Stack       string `json:"stack,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Mode        string `json:"mode"`
+	// Replicas is only set for replicated services
+	Replicas *uint64           `json:"replicas,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	NodeInfo []service.NodeIP  `json:"nodeInfo"`
+}
+
+// ServiceListV2 is a page of services
+type ServiceListV2 struct {
+	Items []ServiceV2 `json:"items"`
+	Total int         `json:"total"`
+}
+
+// NodeV2 is a node returned by the v2 API
+type NodeV2 struct {
+	ID           string            `json:"id"`
+	Hostname     string            `json:"hostname"`
+	Address      string            `json:"address"`
+	Role         string            `json:"role"`
+	Availability string            `json:"availability"`
+	State        string            `json:"state"`
+	VersionIndex uint64            `json:"versionIndex"`
+	Labels       map[string]string `json:"labels"`
+	EngineLabels map[string]string `json:"engineLabels"`
+}
+
+// NodeListV2 is a page of nodes
+type NodeListV2 struct {
+	Items []NodeV2 `json:"items"`
+	Total int      `json:"total"`
+}
+
+// AcceptedV2 is the body of v2 requests that are processed in the
+// background
+type AcceptedV2 struct {
+	Status string `json:"status"`
+}
+
+// NotifyReportV2 is the response of v2 notify requests with `wait=true`
+// `Error` is set when a notification was not delivered.
+type NotifyReportV2 struct {
+	Status  string                   `json:"status"`
+	Results []service.DeliveryResult `json:"results"`
+	Error   *ErrorV2                 `json:"error,omitempty"`
+}
+
+// newServiceV2 converts `ssm` into the v2 model
+func newServiceV2(ssm service.SwarmServiceMini) ServiceV2 {
+	s := ServiceV2{
+		ID:          ssm.ID,
+		Name:        ssm.Name,
+		ServiceName: service.GetSwarmServiceMiniCreateParameters(ssm)["serviceName"],
+		Stack:       ssm.Labels["com.docker.stack.namespace"],
+		Image:       ssm.ContainerImage,
+		Mode:        "replicated",
+		Labels:      map[string]string{},
+		NodeInfo:    []service.NodeIP{},
+	}
+	if ssm.Global {
+		s.Mode = "global"
+	} else {
+		replicas := ssm.Replicas
+		s.Replicas = &replicas
+	}
+	for k, v := range ssm.Labels {
+		s.Labels[k] = v
+	}
+	for nodeIP := range ssm.NodeInfo {
+		s.NodeInfo = append(s.NodeInfo, nodeIP)
+	}
+	sort.Slice(s.NodeInfo, func(i, j int) bool {
+		if s.NodeInfo[i].Name != s.NodeInfo[j].Name {
+			return s.NodeInfo[i].Name < s.NodeInfo[j].Name
+		}
+		if s.NodeInfo[i].Addr != s.NodeInfo[j].Addr {
+			return s.NodeInfo[i].Addr < s.NodeInfo[j].Addr
+		}
+		return s.NodeInfo[i].ID < s.NodeInfo[j].ID
+	})
+	return s
+}
+
+// newServicesV2 converts `services` into v2 models sorted by service name
+func newServicesV2(services []service.SwarmServiceMini) []ServiceV2 {
+	items := make([]ServiceV2, 0, len(services))
+	for _, ssm := range services {
+		items = append(items, newServiceV2(ssm))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].ServiceName != items[j].ServiceName {
+			return items[i].ServiceName < items[j].ServiceName
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// newNodeV2 converts `nm` into the v2 model
+func newNodeV2(nm service.NodeMini) NodeV2 {
+	n := NodeV2{
+		ID:           nm.ID,
+		Hostname:     nm.Hostname,
+		Address:      nm.Addr,
+		Role:         string(nm.Role),
+		Availability: string(nm.Availability),
+		State:        string(nm.State),
+		VersionIndex: nm.VersionIndex,
+		Labels:       map[string]string{},
+		EngineLabels: map[string]string{},
+	}
+	for k, v := range nm.NodeLabels {
+		n.Labels[k] = v
+	}
+	for k, v := range nm.EngineLabels {
+		n.EngineLabels[k] = v
+	}
+	return n
+}
+
+// newNodesV2 converts `nodes` into v2 models sorted by hostname
+func newNodesV2(nodes []service.NodeMini) []NodeV2 {
+	items := make([]NodeV2, 0, len(nodes))
+	for _, nm := range nodes {
+		items = append(items, newNodeV2(nm))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Hostname != items[j].Hostname {
+			return items[i].Hostname < items[j].Hostname
+		}
+		return items[i].ID < items[j].ID
+	})
+	return items
+}
+
+// GetServicesV2 retrieves services with the `com.df.notify` label set to `true`
+// Services are retrieved from the cache unless `fresh=true`. They are
+// filtered, sorted by service name, and paged as requested in the query.
+func (m Serve) GetServicesV2(w http.ResponseWriter, req *http.Request) {
+	if !allowMethodV2(w, req, http.MethodGet) {
+		return
+	}
+	query := req.URL.Query()
+	filter, err := parseServiceFilter(query)
+	if err != nil {
+		writeBadRequestV2(w, err)
+		return
+	}
+	options, err := parseListOptions(query)
+	if err != nil {
+		writeBadRequestV2(w, err)
+		return
+	}
+
+	services, updatedAt, cached, err := m.getServices(req, filter)
+	if err != nil {
+		m.writeServerErrorV2(w, err, "serveGetServicesV2")
+		return
+	}
+	items := newServicesV2(services)
+	start, end := options.page(len(items))
+	if cached {
+		writeCacheHeaders(w, updatedAt)
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+	m.writeJSONV2(w, http.StatusOK,
+		ServiceListV2{Items: items[start:end], Total: len(items)}, "serveGetServicesV2")
+}
+
+// GetServiceV2 retrieves the service with the name or ID at the end of the
+// path
+func (m Serve) GetServiceV2(w http.ResponseWriter, req *http.Request) {
+	nameOrID := strings.TrimPrefix(req.URL.Path, servicesPathV2+"/")
+	if len(nameOrID) == 0 {
+		m.GetServicesV2(w, req)
+		return
+	}
+	if !allowMethodV2(w, req, http.MethodGet) {
+		return
+	}
+
+	services, updatedAt, cached, err := m.getServices(
+		req, service.ServiceFilter{NamesOrIDs: []string{nameOrID}})
+	if err != nil {
+		m.writeServerErrorV2(w, err, "serveGetServiceV2")
+		return
+	}
+	if len(services) == 0 {
+		writeErrorV2(w, http.StatusNotFound, errorCodeNotFound,
+			fmt.Sprintf("Service %s was not found", nameOrID))
+		return
+	}
+	if cached {
+		writeCacheHeaders(w, updatedAt)
+	}
+	m.writeJSONV2(w, http.StatusOK, newServicesV2(services)[0], "serveGetServiceV2")
+}
+
+// getServices returns the services selected by `filter` from the cache, or
+// from Docker when the cache is not used
+func (m Serve) getServices(
+	req *http.Request, filter service.ServiceFilter) ([]service.SwarmServiceMini, time.Time, bool, error) {
+	if !isFreshRequest(req) {
+		if services, updatedAt, ok := m.SwarmListener.GetCachedServices(filter); ok {
+			return services, updatedAt, true, nil
+		}
+	}
+	services, err := m.SwarmListener.GetServices(req.Context(), filter)
+	return services, time.Time{}, false, err
+}
+
+// GetNodesV2 retrieves all nodes
+// Nodes are retrieved from the cache unless `fresh=true`. They are
+// filtered, sorted by hostname, and paged as requested in the query.
+func (m Serve) GetNodesV2(w http.ResponseWriter, req *http.Request) {
+	if !allowMethodV2(w, req, http.MethodGet) {
+		return
+	}
+	query := req.URL.Query()
+	filter, err := parseNodeFilter(query)
+	if err != nil {
+		writeBadRequestV2(w, err)
+		return
+	}
+	options, err := parseListOptions(query)
+	if err != nil {
+		writeBadRequestV2(w, err)
+		return
+	}
+
+	nodes, updatedAt, cached, err := m.getNodes(req, filter)
+	if err != nil {
+		m.writeServerErrorV2(w, err, "serveGetNodesV2")
+		return
+	}
+	items := newNodesV2(nodes)
+	start, end := options.page(len(items))
+	if cached {
+		writeCacheHeaders(w, updatedAt)
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+	m.writeJSONV2(w, http.StatusOK,
+		NodeListV2{Items: items[start:end], Total: len(items)}, "serveGetNodesV2")
+}
+
+// GetNodeV2 retrieves the node with the hostname or ID at the end of the
+// path
+func (m Serve) GetNodeV2(w http.ResponseWriter, req *http.Request) {
+	nameOrID := strings.TrimPrefix(req.URL.Path, nodesPathV2+"/")
+	if len(nameOrID) == 0 {
+		m.GetNodesV2(w, req)
+		return
+	}
+	if !allowMethodV2(w, req, http.MethodGet) {
+		return
+	}
+
+	nodes, updatedAt, cached, err := m.getNodes(
+		req, service.NodeFilter{NamesOrIDs: []string{nameOrID}})
+	if err != nil {
+		m.writeServerErrorV2(w, err, "serveGetNodeV2")
+		return
+	}
+	if len(nodes) == 0 {
+		writeErrorV2(w, http.StatusNotFound, errorCodeNotFound,
+			fmt.Sprintf("Node %s was not found", nameOrID))
+		return
+	}
+	if cached {
+		writeCacheHeaders(w, updatedAt)
+	}
+	m.writeJSONV2(w, http.StatusOK, newNodesV2(nodes)[0], "serveGetNodeV2")
+}
+
+// getNodes returns the nodes selected by `filter` from the cache, or from
+// Docker when the cache is not used
+func (m Serve) getNodes(
+	req *http.Request, filter service.NodeFilter) ([]service.NodeMini, time.Time, bool, error) {
+	if !isFreshRequest(req) {
+		if nodes, updatedAt, ok := m.SwarmListener.GetCachedNodes(filter); ok {
+			return nodes, updatedAt, true, nil
+		}
+	}
+	nodes, err := m.SwarmListener.GetNodes(req.Context(), filter)
+	return nodes, time.Time{}, false, err
+}
+
+// NotifyServicesV2 notifies endpoints of services as selected by the same
+// query parameters as `NotifyServices`
+func (m Serve) NotifyServicesV2(w http.ResponseWriter, req *http.Request) {
+	if !allowMethodV2(w, req, http.MethodPost) {
+		return
+	}
+	options, n, err := m.parseNotifyServices(req.URL.Query(), "serveNotifyServicesV2")
+	if err != nil {
+		writeBadRequestV2(w, err)
+		return
+	}
+	m.notifyV2(w, req, options, n)
+}
+
+// NotifyNodesV2 notifies endpoints of nodes as selected by the same query
+// parameters as `NotifyNodes`
+func (m Serve) NotifyNodesV2(w http.ResponseWriter, req *http.Request) {
+	if !allowMethodV2(w, req, http.MethodPost) {
+		return
+	}
+	options, n, err := m.parseNotifyNodes(req.URL.Query(), "serveNotifyNodesV2")
+	if err != nil {
+		writeBadRequestV2(w, err)
+		return
+	}
+	m.notifyV2(w, req, options, n)
+}
+
+// notifyV2 sends notifications with `n` as selected by `options`
+// The request is accepted with `202` unless the delivery is waited for.
+func (m Serve) notifyV2(w http.ResponseWriter, req *http.Request, options notifyOptions, n notifyRequest) {
+	if !options.Wait {
+		m.notifyInBackground(options, n)
+		m.writeJSONV2(w, http.StatusAccepted, AcceptedV2{Status: "accepted"}, n.errorType)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), options.Timeout)
+	defer cancel()
+	results, err := n.notifySelected(ctx)
+	if err != nil {
+		m.writeServerErrorV2(w, err, n.errorType)
+		return
+	}
+
+	statusCode := deliveryStatusCode(results)
+	report := NotifyReportV2{Status: service.DeliveryStatusDelivered, Results: results}
+	switch statusCode {
+	case http.StatusGatewayTimeout:
+		report.Status = service.DeliveryStatusPending
+		report.Error = &ErrorV2{Status: statusCode, Code: errorCodeTimeout,
+			Message: "Timed out waiting for notifications to be delivered"}
+	case http.StatusBadGateway:
+		report.Status = service.DeliveryStatusFailed
+		report.Error = &ErrorV2{Status: statusCode, Code: errorCodeDeliveryFailed,
+			Message: "Notifications could not be delivered"}
+	}
+	m.writeJSONV2(w, statusCode, report, n.errorType)
+}
+
+// ResyncV2 sends out notifications for all services and nodes as it is
+// done on startup
+func (m Serve) ResyncV2(w http.ResponseWriter, req *http.Request) {
+	if !allowMethodV2(w, req, http.MethodPost) {
+		return
+	}
+	go m.SwarmListener.Resync()
+	m.writeJSONV2(w, http.StatusAccepted, AcceptedV2{Status: "accepted"}, "serveResyncV2")
+}
+
+// OpenAPI writes the OpenAPI document describing the v2 API
+func (m Serve) OpenAPI(w http.ResponseWriter, req *http.Request) {
+	if !allowMethodV2(w, req, http.MethodGet) {
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(openAPIDocument))
+}
+
+// NotFoundV2 writes the error of v2 paths without a route
+func (m Serve) NotFoundV2(w http.ResponseWriter, req *http.Request) {
+	writeErrorV2(w, http.StatusNotFound, errorCodeNotFound,
+		fmt.Sprintf("%s was not found", req.URL.Path))
+}
+
+// allowMethodV2 returns true when `req` uses one of `methods`
+// Otherwise, `405` is written with the allowed methods in the `Allow`
+// header.
+func allowMethodV2(w http.ResponseWriter, req *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if req.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeErrorV2(w, http.StatusMethodNotAllowed, errorCodeMethodNotAllowed,
+		fmt.Sprintf("Method must be %s", strings.Join(methods, " or ")))
+	return false
+}
+
+// writeJSONV2 writes `v` as json with `statusCode`
+func (m Serve) writeJSONV2(w http.ResponseWriter, statusCode int, v interface{}, errorType string) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		m.writeServerErrorV2(w, err, errorType)
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(js)
+}
+
+// writeServerErrorV2 logs `err` and writes it with status `500`
+func (m Serve) writeServerErrorV2(w http.ResponseWriter, err error, errorType string) {
+	m.Log.Error("Unable to prepare response", "error", err)
+	metrics.RecordError(errorType)
+	writeErrorV2(w, http.StatusInternalServerError, errorCodeInternal, err.Error())
+}
+
+// writeBadRequestV2 writes `err` with status `400`
+func writeBadRequestV2(w http.ResponseWriter, err error) {
+	writeErrorV2(w, http.StatusBadRequest, errorCodeBadRequest, err.Error())
+}
+
+// writeErrorV2 writes an error object with `statusCode`
+func writeErrorV2(w http.ResponseWriter, statusCode int, code, message string) {
+	js, _ := json.Marshal(ErrorResponseV2{
+		Error: ErrorV2{Status: statusCode, Code: code, Message: message},
+	})
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(js)
+}
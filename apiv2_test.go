@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *ServerTestSuite) Test_RestV2_RoutesTo_V2Handlers() {
+	sm := new(serverMock)
+	routes := map[string]string{
+		"/v2/docker-flow-swarm-listener/services":         "GetServicesV2",
+		"/v2/docker-flow-swarm-listener/services/demo_go": "GetServiceV2",
+		"/v2/docker-flow-swarm-listener/nodes":            "GetNodesV2",
+		"/v2/docker-flow-swarm-listener/nodes/node1":      "GetNodeV2",
+		"/v2/docker-flow-swarm-listener/notify/services":  "NotifyServicesV2",
+		"/v2/docker-flow-swarm-listener/notify/nodes":     "NotifyNodesV2",
+		"/v2/docker-flow-swarm-listener/resync":           "ResyncV2",
+		"/v2/docker-flow-swarm-listener/openapi.json":     "OpenAPI",
+		"/v2/docker-flow-swarm-listener/get-services":     "NotFoundV2",
+	}
+	for _, handler := range routes {
+		sm.On(handler, mock.Anything, mock.Anything).Return(nil)
+	}
+	mux := attachRoutes(sm)
+
+	for path, handler := range routes {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		sm.AssertCalled(s.T(), handler, mock.Anything, mock.Anything)
+	}
+}
+
+func (s *ServerTestSuite) Test_GetServicesV2_ReturnsCachedServices() {
+	updatedAt := time.Now().Add(-time.Minute)
+	s.SLMock.On("GetCachedServices", service.ServiceFilter{Stack: "demo"}).Return([]service.SwarmServiceMini{
+		{
+			ID:             "serviceID2",
+			Name:           "demo_web",
+			Replicas:       2,
+			ContainerImage: "nginx:1.15",
+			Labels: map[string]string{
+				"com.df.notify":              "true",
+				"com.df.shortName":           "true",
+				"com.docker.stack.namespace": "demo",
+			},
+			NodeInfo: service.NodeIPSet{
+				{Name: "node2", Addr: "10.0.0.2", ID: "nodeID2"}: {},
+				{Name: "node1", Addr: "10.0.0.1", ID: "nodeID1"}: {},
+			},
+		},
+		{
+			ID:     "serviceID1",
+			Name:   "demo_agent",
+			Global: true,
+			Labels: map[string]string{"com.df.notify": "true"},
+		},
+	}, updatedAt, true)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/services?stack=demo&limit=1&offset=1", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetServicesV2(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("application/json", w.Header().Get("Content-Type"))
+	s.Equal("2", w.Header().Get("X-Total-Count"))
+	s.Equal("60", w.Header().Get("Age"))
+	s.JSONEq(`{
+		"items": [{
+			"id": "serviceID2",
+			"name": "demo_web",
+			"serviceName": "web",
+			"stack": "demo",
+			"image": "nginx:1.15",
+			"mode": "replicated",
+			"replicas": 2,
+			"labels": {
+				"com.df.notify": "true",
+				"com.df.shortName": "true",
+				"com.docker.stack.namespace": "demo"
+			},
+			"nodeInfo": [
+				{"name": "node1", "addr": "10.0.0.1", "id": "nodeID1"},
+				{"name": "node2", "addr": "10.0.0.2", "id": "nodeID2"}
+			]
+		}],
+		"total": 2
+	}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_GetServicesV2_QueriesDocker_WhenFresh() {
+	s.SLMock.On("GetServices", mock.Anything, service.ServiceFilter{}).Return([]service.SwarmServiceMini{
+		{ID: "serviceID1", Name: "agent", Global: true},
+	}, nil)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/services?fresh=true", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetServicesV2(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Empty(w.Header().Get("Age"))
+	s.JSONEq(`{
+		"items": [{
+			"id": "serviceID1",
+			"name": "agent",
+			"serviceName": "agent",
+			"mode": "global",
+			"labels": {},
+			"nodeInfo": []
+		}],
+		"total": 1
+	}`, w.Body.String())
+	s.SLMock.AssertNotCalled(s.T(), "GetCachedServices", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_GetServicesV2_ReturnsErrorObjects() {
+	s.SLMock.On("GetCachedServices", mock.Anything).Return([]service.SwarmServiceMini{}, time.Time{}, false)
+	s.SLMock.On("GetServices", mock.Anything, mock.Anything).Return([]service.SwarmServiceMini{}, fmt.Errorf("Docker is down"))
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+
+	tests := []struct {
+		method   string
+		query    string
+		expected ErrorV2
+	}{
+		{"GET", "", ErrorV2{Status: 500, Code: "internal", Message: "Docker is down"}},
+		{"GET", "?label==x", ErrorV2{Status: 400, Code: "badRequest", Message: `label "=x" has no key`}},
+		{"GET", "?limit=-1", ErrorV2{Status: 400, Code: "badRequest", Message: "limit must be a non-negative integer"}},
+		{"POST", "", ErrorV2{Status: 405, Code: "methodNotAllowed", Message: "Method must be GET"}},
+	}
+	for _, t := range tests {
+		req := httptest.NewRequest(t.method, "/v2/docker-flow-swarm-listener/services"+t.query, nil)
+		w := httptest.NewRecorder()
+
+		srv.GetServicesV2(w, req)
+
+		actual := ErrorResponseV2{}
+		s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &actual))
+		s.Equal(t.expected.Status, w.Code)
+		s.Equal(t.expected, actual.Error)
+	}
+}
+
+func (s *ServerTestSuite) Test_GetServicesV2_SetsAllowHeader_WhenMethodIsNotAllowed() {
+	req := httptest.NewRequest("DELETE", "/v2/docker-flow-swarm-listener/services", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetServicesV2(w, req)
+
+	s.Equal(http.StatusMethodNotAllowed, w.Code)
+	s.Equal("GET", w.Header().Get("Allow"))
+}
+
+func (s *ServerTestSuite) Test_GetServiceV2_ReturnsService() {
+	s.SLMock.On("GetCachedServices", service.ServiceFilter{NamesOrIDs: []string{"demo_web"}}).Return(
+		[]service.SwarmServiceMini{{ID: "serviceID1", Name: "demo_web", Replicas: 1}}, time.Now(), true)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/services/demo_web", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetServiceV2(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	actual := ServiceV2{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &actual))
+	s.Equal("serviceID1", actual.ID)
+	s.Require().NotNil(actual.Replicas)
+	s.Equal(uint64(1), *actual.Replicas)
+}
+
+func (s *ServerTestSuite) Test_GetServiceV2_ReturnsStatus404_WhenServiceIsNotFound() {
+	s.SLMock.On("GetCachedServices", mock.Anything).Return([]service.SwarmServiceMini{}, time.Now(), true)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/services/demo_web", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetServiceV2(w, req)
+
+	s.Equal(http.StatusNotFound, w.Code)
+	s.JSONEq(`{"error": {"status": 404, "code": "notFound", "message": "Service demo_web was not found"}}`,
+		w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_GetNodesV2_ReturnsCachedNodes() {
+	filter := service.NodeFilter{Role: swarm.NodeRoleManager}
+	s.SLMock.On("GetCachedNodes", filter).Return([]service.NodeMini{
+		{
+			ID:           "nodeID1",
+			Hostname:     "node1",
+			VersionIndex: 10,
+			State:        swarm.NodeStateReady,
+			Addr:         "10.0.0.1",
+			NodeLabels:   map[string]string{"com.df.wow": "yup"},
+			Role:         swarm.NodeRoleManager,
+			Availability: swarm.NodeAvailabilityActive,
+		},
+	}, time.Now(), true)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/nodes?role=manager", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetNodesV2(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("1", w.Header().Get("X-Total-Count"))
+	s.JSONEq(`{
+		"items": [{
+			"id": "nodeID1",
+			"hostname": "node1",
+			"address": "10.0.0.1",
+			"role": "manager",
+			"availability": "active",
+			"state": "ready",
+			"versionIndex": 10,
+			"labels": {"com.df.wow": "yup"},
+			"engineLabels": {}
+		}],
+		"total": 1
+	}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_GetNodeV2_ReturnsStatus404_WhenNodeIsNotFound() {
+	s.SLMock.On("GetCachedNodes", service.NodeFilter{NamesOrIDs: []string{"node1"}}).Return(
+		[]service.NodeMini{}, time.Now(), true)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/nodes/node1", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetNodeV2(w, req)
+
+	s.Equal(http.StatusNotFound, w.Code)
+	s.JSONEq(`{"error": {"status": 404, "code": "notFound", "message": "Node node1 was not found"}}`,
+		w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_NotifyServicesV2_ReturnsStatus202() {
+	notified := make(chan struct{})
+	s.SLMock.On("NotifyServices", false).Run(func(args mock.Arguments) {
+		close(notified)
+	}).Return()
+	req := httptest.NewRequest("POST", "/v2/docker-flow-swarm-listener/notify/services", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServicesV2(w, req)
+
+	s.Equal(http.StatusAccepted, w.Code)
+	s.JSONEq(`{"status": "accepted"}`, w.Body.String())
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		s.FailNow("Timeout")
+	}
+}
+
+func (s *ServerTestSuite) Test_NotifyServicesV2_ReturnsStatus405_WhenMethodIsGet() {
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/notify/services", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServicesV2(w, req)
+
+	s.Equal(http.StatusMethodNotAllowed, w.Code)
+	s.Equal("POST", w.Header().Get("Allow"))
+	s.SLMock.AssertNotCalled(s.T(), "NotifyServices", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_NotifyServicesV2_ReportsFailedDeliveries() {
+	filter := service.ServiceFilter{NamesOrIDs: []string{"demo_web"}}
+	results := []service.DeliveryResult{
+		{ID: "serviceID1", Name: "demo_web", Status: service.DeliveryStatusFailed,
+			Endpoints: []service.EndpointDelivery{
+				{Host: "http://proxy", StatusCode: 500, Attempts: 2, Retries: 1, Error: "Status 500"},
+			}},
+	}
+	s.SLMock.On("NotifySelectedServices", mock.Anything, filter, []string(nil)).Return(results, nil)
+	req := httptest.NewRequest("POST", "/v2/docker-flow-swarm-listener/notify/services?service=demo_web&wait=true", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServicesV2(w, req)
+
+	s.Equal(http.StatusBadGateway, w.Code)
+	actual := NotifyReportV2{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &actual))
+	s.Equal(NotifyReportV2{
+		Status:  service.DeliveryStatusFailed,
+		Results: results,
+		Error: &ErrorV2{Status: 502, Code: "deliveryFailed",
+			Message: "Notifications could not be delivered"},
+	}, actual)
+}
+
+func (s *ServerTestSuite) Test_NotifyNodesV2_ReportsDeliveries() {
+	filter := service.NodeFilter{NamesOrIDs: []string{"node1"}}
+	results := []service.DeliveryResult{
+		{ID: "nodeID1", Name: "node1", Status: service.DeliveryStatusDelivered,
+			Endpoints: []service.EndpointDelivery{{Host: "http://proxy", StatusCode: 200, Attempts: 1}}},
+	}
+	s.SLMock.On("NotifySelectedNodes", mock.Anything, filter, []string(nil)).Return(results, nil)
+	req := httptest.NewRequest("POST", "/v2/docker-flow-swarm-listener/notify/nodes?node=node1&wait=true", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyNodesV2(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	actual := NotifyReportV2{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &actual))
+	s.Equal(NotifyReportV2{Status: service.DeliveryStatusDelivered, Results: results}, actual)
+}
+
+func (s *ServerTestSuite) Test_ResyncV2_ReturnsStatus202() {
+	resynced := make(chan struct{})
+	s.SLMock.On("Resync").Run(func(args mock.Arguments) {
+		close(resynced)
+	}).Return()
+	req := httptest.NewRequest("POST", "/v2/docker-flow-swarm-listener/resync", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.ResyncV2(w, req)
+
+	s.Equal(http.StatusAccepted, w.Code)
+	select {
+	case <-resynced:
+	case <-time.After(5 * time.Second):
+		s.FailNow("Timeout")
+	}
+}
+
+func (s *ServerTestSuite) Test_OpenAPI_DescribesV2Routes() {
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/openapi.json", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.OpenAPI(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("application/json", w.Header().Get("Content-Type"))
+	doc := struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}{}
+	s.Require().NoError(json.Unmarshal(w.Body.Bytes(), &doc))
+	s.Equal("3.0.3", doc.OpenAPI)
+
+	expected := map[string]string{
+		servicesPathV2:                          "get",
+		servicesPathV2 + "/{nameOrID}":          "get",
+		nodesPathV2:                             "get",
+		nodesPathV2 + "/{nameOrID}":             "get",
+		notifyServicesPathV2:                    "post",
+		notifyNodesPathV2:                       "post",
+		resyncPathV2:                            "post",
+		openAPIPathV2:                           "get",
+		serviceCachePathV2:                      "delete",
+		serviceCachePathV2 + "/{nameOrID}":      "delete",
+		serviceCachePathV2 + "/{nameOrID}/diff": "get",
+		nodeCachePathV2:                         "delete",
+		nodeCachePathV2 + "/{nameOrID}":         "delete",
+		notificationHistoryPathV2:               "get",
+	}
+	s.Len(doc.Paths, len(expected))
+	for path, method := range expected {
+		operations, ok := doc.Paths[strings.TrimPrefix(path, apiV2Path)]
+		s.Require().True(ok, path)
+		s.Contains(operations, method, path)
+	}
+}
+
+func (s *ServerTestSuite) Test_NotFoundV2_ReturnsErrorObject() {
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/get-services", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotFoundV2(w, req)
+
+	s.Equal(http.StatusNotFound, w.Code)
+	s.JSONEq(`{"error": {"status": 404, "code": "notFound",
+		"message": "/v2/docker-flow-swarm-listener/get-services was not found"}}`, w.Body.String())
+}
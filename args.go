@@ -1,30 +1,38 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
 )
 
 type args struct {
-	ServicePollingInterval int
-	NodePollingInterval    int
-	Retry                  int
-	RetryInterval          int
+	ConfigFile           string
+	ConfigReloadInterval int
+	Healthcheck          bool
 }
 
-func getArgs() *args {
-	return &args{
-		ServicePollingInterval: getValue(-1, "DF_SERVICE_POLLING_INTERVAL"),
-		NodePollingInterval:    getValue(-1, "DF_NODE_POLLING_INTERVAL"),
-		Retry:                  getValue(1, "DF_RETRY"),
-		RetryInterval:          getValue(0, "DF_RETRY_INTERVAL"),
+func getArgs(arguments []string) (*args, error) {
+	a := &args{}
+	flags := flag.NewFlagSet("docker-flow-swarm-listener", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.StringVar(&a.ConfigFile, "config", os.Getenv("DF_CONFIG_FILE"),
+		"Path to a YAML or JSON configuration file")
+	reloadInterval := 0
+	if value := os.Getenv("DF_CONFIG_RELOAD_INTERVAL"); len(value) > 0 {
+		var err error
+		if reloadInterval, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("DF_CONFIG_RELOAD_INTERVAL: invalid integer %q", value)
+		}
 	}
-}
-
-func getValue(defValue int, varName string) int {
-	value := defValue
-	if len(os.Getenv(varName)) > 0 {
-		value, _ = strconv.Atoi(os.Getenv(varName))
+	flags.IntVar(&a.ConfigReloadInterval, "config-reload-interval", reloadInterval,
+		"Seconds between checks of the configuration file for changes, 0 disables the check")
+	flags.BoolVar(&a.Healthcheck, "healthcheck", false,
+		"Request the health route of the running listener and exit with status 1 when it is not healthy")
+	if err := flags.Parse(arguments); err != nil {
+		return nil, err
 	}
-	return value
+	return a, nil
 }
@@ -1,9 +1,7 @@
 package main
 
 import (
-	"math/rand"
 	"os"
-	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -23,30 +21,74 @@ func TestArgsUnitTestSuite(t *testing.T) {
 // GetArgs
 
 func (s *ArgsTestSuite) Test_GetArgs_ReturnsDefaultValues() {
-	args := getArgs()
+	configFileOrig := os.Getenv("DF_CONFIG_FILE")
+	defer func() { os.Setenv("DF_CONFIG_FILE", configFileOrig) }()
+	os.Unsetenv("DF_CONFIG_FILE")
 
-	s.Equal(1, args.Retry)
-	s.Equal(0, args.RetryInterval)
+	args, err := getArgs([]string{})
+	s.Require().NoError(err)
+
+	s.Empty(args.ConfigFile)
+}
+
+func (s *ArgsTestSuite) Test_GetArgs_ReturnsConfigFileFromEnv() {
+	configFileOrig := os.Getenv("DF_CONFIG_FILE")
+	defer func() { os.Setenv("DF_CONFIG_FILE", configFileOrig) }()
+	os.Setenv("DF_CONFIG_FILE", "/etc/dfsl/config.yml")
+
+	args, err := getArgs([]string{})
+	s.Require().NoError(err)
+
+	s.Equal("/etc/dfsl/config.yml", args.ConfigFile)
 }
 
-func (s *ArgsTestSuite) Test_GetArgs_ReturnsRetryFromEnv() {
-	expected := rand.Int()
-	intervalOrig := os.Getenv("DF_RETRY")
-	defer func() { os.Setenv("DF_RETRY", intervalOrig) }()
-	os.Setenv("DF_RETRY", strconv.Itoa(expected))
+func (s *ArgsTestSuite) Test_GetArgs_ReturnsConfigFileFromFlag() {
+	configFileOrig := os.Getenv("DF_CONFIG_FILE")
+	defer func() { os.Setenv("DF_CONFIG_FILE", configFileOrig) }()
+	os.Setenv("DF_CONFIG_FILE", "/etc/dfsl/config.yml")
 
-	args := getArgs()
+	args, err := getArgs([]string{"-config", "/run/secrets/dfsl.json"})
+	s.Require().NoError(err)
 
-	s.Equal(expected, args.Retry)
+	s.Equal("/run/secrets/dfsl.json", args.ConfigFile)
 }
 
-func (s *ArgsTestSuite) Test_GetArgs_ReturnsRetryIntervalFromEnv() {
-	expected := rand.Int()
-	intervalOrig := os.Getenv("DF_RETRY_INTERVAL")
-	defer func() { os.Setenv("DF_RETRY_INTERVAL", intervalOrig) }()
-	os.Setenv("DF_RETRY_INTERVAL", strconv.Itoa(expected))
+func (s *ArgsTestSuite) Test_GetArgs_ReturnsConfigReloadInterval() {
+	intervalOrig := os.Getenv("DF_CONFIG_RELOAD_INTERVAL")
+	defer func() { os.Setenv("DF_CONFIG_RELOAD_INTERVAL", intervalOrig) }()
+	os.Setenv("DF_CONFIG_RELOAD_INTERVAL", "10")
+
+	args, err := getArgs([]string{})
+	s.Require().NoError(err)
+	s.Equal(10, args.ConfigReloadInterval)
+
+	args, err = getArgs([]string{"-config-reload-interval", "30"})
+	s.Require().NoError(err)
+	s.Equal(30, args.ConfigReloadInterval)
+}
+
+func (s *ArgsTestSuite) Test_GetArgs_ReturnsError_WhenConfigReloadIntervalIsNotAnInteger() {
+	intervalOrig := os.Getenv("DF_CONFIG_RELOAD_INTERVAL")
+	defer func() { os.Setenv("DF_CONFIG_RELOAD_INTERVAL", intervalOrig) }()
+	os.Setenv("DF_CONFIG_RELOAD_INTERVAL", "often")
+
+	_, err := getArgs([]string{})
+
+	s.Error(err)
+}
+
+func (s *ArgsTestSuite) Test_GetArgs_ReturnsError_WhenFlagIsUnknown() {
+	_, err := getArgs([]string{"-unknown"})
+
+	s.Error(err)
+}
 
-	args := getArgs()
+func (s *ArgsTestSuite) Test_GetArgs_ReturnsHealthcheck() {
+	args, err := getArgs([]string{})
+	s.Require().NoError(err)
+	s.False(args.Healthcheck)
 
-	s.Equal(expected, args.RetryInterval)
+	args, err = getArgs([]string{"-healthcheck"})
+	s.Require().NoError(err)
+	s.True(args.Healthcheck)
 }
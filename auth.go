@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+// routeAccess classifies routes for authentication
+type routeAccess int
+
+const (
+	routeRead routeAccess = iota
+	routeMutating
+	routePing
+	routeMetrics
+)
+
+// routeAccesses classifies routes that are not read only API routes
+var routeAccesses = map[string]routeAccess{
+	"/v1/docker-flow-swarm-listener/notify-services":  routeMutating,
+	"/v1/docker-flow-swarm-listener/notify-nodes":     routeMutating,
+	"/v1/docker-flow-swarm-listener/resync":           routeMutating,
+	"/v1/docker-flow-swarm-listener/reload-endpoints": routeMutating,
+	"/v1/docker-flow-swarm-listener/ping":             routePing,
+	"/v1/docker-flow-swarm-listener/health":           routePing,
+	"/v1/docker-flow-swarm-listener/ready":            routePing,
+	notifyServicesPathV2:                              routeMutating,
+	notifyNodesPathV2:                                 routeMutating,
+	resyncPathV2:                                      routeMutating,
+	"/metrics":                                        routeMetrics,
+}
+
+// routeAccessOf classifies `path`
+// Admin routes change internal state and are classified as mutating.
+func routeAccessOf(path string) routeAccess {
+	if strings.HasPrefix(path, adminPathV2+"/") {
+		return routeMutating
+	}
+	return routeAccesses[path]
+}
+
+// authenticator requires credentials for the routes selected by its
+// configuration
+type authenticator struct {
+	config  config.API
+	handler http.Handler
+}
+
+// newAuthenticator wraps `handler` with authentication
+// `handler` is returned when no credentials are configured
+func newAuthenticator(c config.API, handler http.Handler) http.Handler {
+	if !c.HasCredentials() {
+		return handler
+	}
+	return &authenticator{config: c, handler: handler}
+}
+
+func (a *authenticator) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !a.requiresAuthentication(req.URL.Path) || a.isAuthenticated(req) {
+		a.handler.ServeHTTP(w, req)
+		return
+	}
+	metrics.RecordError("serveUnauthorized")
+	if len(a.config.Username) > 0 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="docker-flow-swarm-listener"`)
+	}
+	if strings.HasPrefix(req.URL.Path, apiV2Path+"/") {
+		writeErrorV2(w, http.StatusUnauthorized, errorCodeUnauthorized, "Unauthorized")
+		return
+	}
+	js, _ := json.Marshal(Response{Status: "NOK", Message: "Unauthorized"})
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	w.Write(js)
+}
+
+func (a *authenticator) requiresAuthentication(path string) bool {
+	switch routeAccessOf(path) {
+	case routeMutating:
+		return true
+	case routePing:
+		return a.config.AuthenticatePing
+	case routeMetrics:
+		return a.config.AuthenticateMetrics
+	default:
+		return a.config.AuthenticateReads
+	}
+}
+
+// isAuthenticated returns true when `req` carries the configured token or
+// basic authentication credentials
+func (a *authenticator) isAuthenticated(req *http.Request) bool {
+	if len(a.config.Token) > 0 {
+		auth := req.Header.Get("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") &&
+			secureEqual(strings.TrimPrefix(auth, "Bearer "), a.config.Token) {
+			return true
+		}
+	}
+	if len(a.config.Username) > 0 {
+		username, password, ok := req.BasicAuth()
+		if ok && secureEqual(username, a.config.Username) &&
+			secureEqual(password, a.config.Password) {
+			return true
+		}
+	}
+	return false
+}
+
+// secureEqual compares `a` and `b` in constant time
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type AuthenticatorTestSuite struct {
+	suite.Suite
+	handler http.Handler
+	served  []string
+}
+
+func TestAuthenticatorUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthenticatorTestSuite))
+}
+
+func (s *AuthenticatorTestSuite) SetupTest() {
+	s.served = []string{}
+	s.handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.served = append(s.served, req.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func (s *AuthenticatorTestSuite) Test_NewAuthenticator_ReturnsHandler_WhenCredentialsAreNotConfigured() {
+	handler := newAuthenticator(config.API{}, s.handler)
+
+	rsp := s.serve(handler, "/v1/docker-flow-swarm-listener/notify-services", nil)
+
+	s.Equal(http.StatusOK, rsp.Code)
+}
+
+func (s *AuthenticatorTestSuite) Test_ServeHTTP_RequiresToken_ForMutatingRoutes() {
+	handler := newAuthenticator(config.API{Token: "my-token"}, s.handler)
+
+	for _, path := range []string{
+		"/v1/docker-flow-swarm-listener/notify-services",
+		"/v1/docker-flow-swarm-listener/notify-nodes",
+		"/v1/docker-flow-swarm-listener/resync",
+		"/v1/docker-flow-swarm-listener/reload-endpoints",
+	} {
+		rsp := s.serve(handler, path, nil)
+		s.Equal(http.StatusUnauthorized, rsp.Code, path)
+		s.JSONEq(`{"Status":"NOK","Message":"Unauthorized"}`, rsp.Body.String())
+
+		rsp = s.serve(handler, path, func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer other-token")
+		})
+		s.Equal(http.StatusUnauthorized, rsp.Code, path)
+
+		rsp = s.serve(handler, path, func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer my-token")
+		})
+		s.Equal(http.StatusOK, rsp.Code, path)
+	}
+	s.Equal([]string{
+		"/v1/docker-flow-swarm-listener/notify-services",
+		"/v1/docker-flow-swarm-listener/notify-nodes",
+		"/v1/docker-flow-swarm-listener/resync",
+		"/v1/docker-flow-swarm-listener/reload-endpoints",
+	}, s.served)
+}
+
+func (s *AuthenticatorTestSuite) Test_ServeHTTP_ReturnsErrorObject_ForV2Routes() {
+	handler := newAuthenticator(config.API{Token: "my-token"}, s.handler)
+
+	for _, path := range []string{
+		"/v2/docker-flow-swarm-listener/notify/services",
+		"/v2/docker-flow-swarm-listener/notify/nodes",
+		"/v2/docker-flow-swarm-listener/resync",
+	} {
+		rsp := s.serve(handler, path, nil)
+		s.Equal(http.StatusUnauthorized, rsp.Code, path)
+		s.JSONEq(`{"error":{"status":401,"code":"unauthorized","message":"Unauthorized"}}`, rsp.Body.String())
+	}
+	s.Empty(s.served)
+
+	rsp := s.serve(handler, "/v2/docker-flow-swarm-listener/services", nil)
+	s.Equal(http.StatusOK, rsp.Code)
+}
+
+func (s *AuthenticatorTestSuite) Test_ServeHTTP_RequiresToken_ForAdminRoutes() {
+	handler := newAuthenticator(config.API{Token: "my-token"}, s.handler)
+
+	for _, path := range []string{
+		"/v2/docker-flow-swarm-listener/admin/cache/services",
+		"/v2/docker-flow-swarm-listener/admin/cache/services/demo_go/diff",
+		"/v2/docker-flow-swarm-listener/admin/cache/nodes/node1",
+	} {
+		rsp := s.serve(handler, path, nil)
+		s.Equal(http.StatusUnauthorized, rsp.Code, path)
+
+		rsp = s.serve(handler, path, func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer my-token")
+		})
+		s.Equal(http.StatusOK, rsp.Code, path)
+	}
+}
+
+func (s *AuthenticatorTestSuite) Test_ServeHTTP_AcceptsBasicAuthentication() {
+	handler := newAuthenticator(config.API{Username: "admin", Password: "secret"}, s.handler)
+	path := "/v1/docker-flow-swarm-listener/notify-services"
+
+	rsp := s.serve(handler, path, nil)
+	s.Equal(http.StatusUnauthorized, rsp.Code)
+	s.Equal(`Basic realm="docker-flow-swarm-listener"`, rsp.Header().Get("WWW-Authenticate"))
+
+	rsp = s.serve(handler, path, func(req *http.Request) {
+		req.SetBasicAuth("admin", "wrong")
+	})
+	s.Equal(http.StatusUnauthorized, rsp.Code)
+
+	rsp = s.serve(handler, path, func(req *http.Request) {
+		req.SetBasicAuth("admin", "secret")
+	})
+	s.Equal(http.StatusOK, rsp.Code)
+}
+
+func (s *AuthenticatorTestSuite) Test_ServeHTTP_LeavesOtherRoutesOpen_ByDefault() {
+	handler := newAuthenticator(config.API{Token: "my-token"}, s.handler)
+
+	for _, path := range []string{
+		"/v1/docker-flow-swarm-listener/get-services",
+		"/v1/docker-flow-swarm-listener/ping",
+		"/metrics",
+	} {
+		rsp := s.serve(handler, path, nil)
+		s.Equal(http.StatusOK, rsp.Code, path)
+	}
+}
+
+func (s *AuthenticatorTestSuite) Test_ServeHTTP_RequiresToken_ForSelectedRoutes() {
+	testCases := []struct {
+		config config.API
+		open   string
+		closed []string
+	}{
+		{
+			config.API{Token: "my-token", AuthenticateReads: true},
+			"/metrics",
+			[]string{"/v1/docker-flow-swarm-listener/get-services", "/v1/docker-flow-swarm-listener/queue"},
+		},
+		{
+			config.API{Token: "my-token", AuthenticatePing: true},
+			"/v1/docker-flow-swarm-listener/get-nodes",
+			[]string{"/v1/docker-flow-swarm-listener/ping"},
+		},
+		{
+			config.API{Token: "my-token", AuthenticateMetrics: true},
+			"/v1/docker-flow-swarm-listener/ping",
+			[]string{"/metrics"},
+		},
+	}
+
+	for _, tc := range testCases {
+		handler := newAuthenticator(tc.config, s.handler)
+		s.Equal(http.StatusOK, s.serve(handler, tc.open, nil).Code, tc.open)
+		for _, path := range tc.closed {
+			s.Equal(http.StatusUnauthorized, s.serve(handler, path, nil).Code, path)
+		}
+	}
+}
+
+func (s *AuthenticatorTestSuite) serve(
+	handler http.Handler, path string, modify func(req *http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if modify != nil {
+		modify(req)
+	}
+	rsp := httptest.NewRecorder()
+	handler.ServeHTTP(rsp, req)
+	return rsp
+}
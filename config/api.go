@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// API configures the HTTP API of the swarm listener
+type API struct {
+	Address string `json:"address" yaml:"address"`
+	TLS     APITLS `json:"tls" yaml:"tls"`
+	// Token is accepted as `Authorization: Bearer <token>`
+	Token     string `json:"token,omitempty" yaml:"token,omitempty"`
+	TokenFile string `json:"tokenFile,omitempty" yaml:"tokenFile,omitempty"`
+	// Username and Password are accepted with basic authentication
+	Username     string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password     string `json:"password,omitempty" yaml:"password,omitempty"`
+	PasswordFile string `json:"passwordFile,omitempty" yaml:"passwordFile,omitempty"`
+	// Routes that change state always require authentication when
+	// credentials are configured. The other routes require it when enabled.
+	AuthenticateReads   bool `json:"authenticateReads" yaml:"authenticateReads"`
+	AuthenticatePing    bool `json:"authenticatePing" yaml:"authenticatePing"`
+	AuthenticateMetrics bool `json:"authenticateMetrics" yaml:"authenticateMetrics"`
+}
+
+// APITLS configures HTTPS for the API
+type APITLS struct {
+	CertFile string `json:"certFile,omitempty" yaml:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty" yaml:"keyFile,omitempty"`
+	// ClientCAFile enables mutual TLS. Clients must present a certificate
+	// issued by one of its certificate authorities.
+	ClientCAFile string `json:"clientCAFile,omitempty" yaml:"clientCAFile,omitempty"`
+	// MinVersion is one of 1.0, 1.1, 1.2, or 1.3
+	MinVersion string `json:"minVersion,omitempty" yaml:"minVersion,omitempty"`
+}
+
+// IsEnabled returns true when the API is served with HTTPS
+func (t APITLS) IsEnabled() bool {
+	return len(t.CertFile) > 0
+}
+
+// MinTLSVersion returns the `crypto/tls` value of `MinVersion` or zero
+// when it is not set
+func (t APITLS) MinTLSVersion() uint16 {
+	return tlsVersions[t.MinVersion]
+}
+
+// HasCredentials returns true when a token or basic authentication is
+// configured
+func (a API) HasCredentials() bool {
+	return len(a.Token) > 0 || len(a.Username) > 0
+}
+
+// Validate returns an error describing the first invalid value
+func (a API) Validate() error {
+	if len(a.Address) == 0 {
+		return fmt.Errorf("address: must not be empty")
+	}
+	if err := a.TLS.Validate(); err != nil {
+		return fmt.Errorf("tls.%v", err)
+	}
+	if len(a.Username) > 0 && len(a.Password) == 0 {
+		return fmt.Errorf("password: must be set with username")
+	}
+	if len(a.Password) > 0 && len(a.Username) == 0 {
+		return fmt.Errorf("username: must be set with password")
+	}
+	if !a.HasCredentials() && (a.AuthenticateReads || a.AuthenticatePing || a.AuthenticateMetrics) {
+		return fmt.Errorf("token: a token or username is required to authenticate requests")
+	}
+	return nil
+}
+
+// Validate returns an error describing the first invalid value
+func (t APITLS) Validate() error {
+	if len(t.CertFile) > 0 && len(t.KeyFile) == 0 {
+		return fmt.Errorf("keyFile: must be set with certFile")
+	}
+	if len(t.KeyFile) > 0 && len(t.CertFile) == 0 {
+		return fmt.Errorf("certFile: must be set with keyFile")
+	}
+	if len(t.ClientCAFile) > 0 && len(t.CertFile) == 0 {
+		return fmt.Errorf("certFile: must be set with clientCAFile")
+	}
+	if len(t.MinVersion) > 0 {
+		if _, ok := tlsVersions[t.MinVersion]; !ok {
+			return fmt.Errorf("minVersion: must be 1.0, 1.1, 1.2, or 1.3, got %q", t.MinVersion)
+		}
+	}
+	return nil
+}
+
+// readSecretFiles reads `TokenFile` and `PasswordFile`
+func (a *API) readSecretFiles() error {
+	files := []struct {
+		name     string
+		filename string
+		value    *string
+	}{
+		{"tokenFile", a.TokenFile, &a.Token},
+		{"passwordFile", a.PasswordFile, &a.Password},
+	}
+	for _, f := range files {
+		if len(f.filename) == 0 {
+			continue
+		}
+		content, err := readSecretFile(f.filename)
+		if err != nil {
+			return fmt.Errorf("api.%s: %v", f.name, err)
+		}
+		*f.value = content
+	}
+	return nil
+}
+
+// applyAPIEnv overrides `a` with Docker secrets and then with `DF_API_*`
+// environment variables
+func applyAPIEnv(a *API) error {
+	if token := readStringFromFile("/run/secrets/df_api_token"); len(token) > 0 {
+		a.Token = token
+	}
+	if password := readStringFromFile("/run/secrets/df_api_password"); len(password) > 0 {
+		a.Password = password
+	}
+	secrets := []struct {
+		filename string
+		value    *string
+	}{
+		{"/run/secrets/df_api_tls_cert", &a.TLS.CertFile},
+		{"/run/secrets/df_api_tls_key", &a.TLS.KeyFile},
+		{"/run/secrets/df_api_tls_client_ca", &a.TLS.ClientCAFile},
+	}
+	for _, secret := range secrets {
+		if _, err := os.Stat(secret.filename); err == nil {
+			*secret.value = secret.filename
+		}
+	}
+
+	lookupString("DF_API_ADDRESS", &a.Address)
+	lookupString("DF_API_TOKEN", &a.Token)
+	lookupString("DF_API_USERNAME", &a.Username)
+	lookupString("DF_API_PASSWORD", &a.Password)
+	lookupString("DF_API_TLS_CERT_FILE", &a.TLS.CertFile)
+	lookupString("DF_API_TLS_KEY_FILE", &a.TLS.KeyFile)
+	lookupString("DF_API_TLS_CLIENT_CA_FILE", &a.TLS.ClientCAFile)
+	lookupString("DF_API_TLS_MIN_VERSION", &a.TLS.MinVersion)
+
+	bools := []struct {
+		key   string
+		value *bool
+	}{
+		{"DF_API_AUTHENTICATE_READS", &a.AuthenticateReads},
+		{"DF_API_AUTHENTICATE_PING", &a.AuthenticatePing},
+		{"DF_API_AUTHENTICATE_METRICS", &a.AuthenticateMetrics},
+	}
+	for _, b := range bools {
+		if err := lookupBool(b.key, b.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
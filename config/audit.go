@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+)
+
+// Audit configures the audit log of notification deliveries
+// The audit log is disabled when `File` is empty.
+type Audit struct {
+	// File is the file deliveries are appended to
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// MaxSize is the size in megabytes after which the file is rotated
+	MaxSize int `json:"maxSize,omitempty" yaml:"maxSize,omitempty"`
+	// MaxBackups is the number of rotated files that are kept
+	MaxBackups int `json:"maxBackups,omitempty" yaml:"maxBackups,omitempty"`
+}
+
+// IsEnabled returns true when deliveries are audited
+func (a Audit) IsEnabled() bool {
+	return len(a.File) > 0
+}
+
+// Validate returns an error describing the first invalid value
+func (a Audit) Validate() error {
+	if a.MaxSize < 0 {
+		return fmt.Errorf("maxSize: must not be negative, got %d", a.MaxSize)
+	}
+	if a.MaxBackups < 0 {
+		return fmt.Errorf("maxBackups: must not be negative, got %d", a.MaxBackups)
+	}
+	return nil
+}
+
+// applyAuditEnv overrides `a` with `DF_AUDIT_*` environment variables
+func applyAuditEnv(a *Audit) error {
+	lookupString("DF_AUDIT_FILE", &a.File)
+	if err := lookupInt("DF_AUDIT_MAX_SIZE", &a.MaxSize); err != nil {
+		return err
+	}
+	return lookupInt("DF_AUDIT_MAX_BACKUPS", &a.MaxBackups)
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const defaultCacheSnapshotInterval = time.Second
+
+// CacheSnapshot configures the file the caches of services and nodes are
+// stored in, so that they are restored after a restart
+// Snapshots are disabled when `File` is empty.
+type CacheSnapshot struct {
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Interval is the time between checks for changes of the caches
+	Interval *Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+}
+
+// IsEnabled returns true when the caches are stored
+func (c CacheSnapshot) IsEnabled() bool {
+	return len(c.File) > 0
+}
+
+// WriteInterval returns `Interval` or one second when it is not set
+func (c CacheSnapshot) WriteInterval() time.Duration {
+	return durationOr(c.Interval, defaultCacheSnapshotInterval)
+}
+
+// Validate returns an error describing the first invalid value
+func (c CacheSnapshot) Validate() error {
+	if c.Interval != nil && c.Interval.Duration <= 0 {
+		return fmt.Errorf("interval: must be positive, got %s", c.Interval)
+	}
+	return nil
+}
+
+// applyCacheSnapshotEnv overrides `c` with `DF_CACHE_SNAPSHOT_*`
+// environment variables
+func applyCacheSnapshotEnv(c *CacheSnapshot) error {
+	lookupString("DF_CACHE_SNAPSHOT_FILE", &c.File)
+	return lookupDurationPtr("DF_CACHE_SNAPSHOT_INTERVAL", &c.Interval)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"fmt"
+)
+
+// CircuitBreaker configures the circuit breaker of notification endpoints
+// Unset values are inherited from the enclosing configuration
+type CircuitBreaker struct {
+	FailureThreshold *int      `json:"failureThreshold,omitempty" yaml:"failureThreshold,omitempty"`
+	OpenTimeout      *Duration `json:"openTimeout,omitempty" yaml:"openTimeout,omitempty"`
+}
+
+// Validate returns an error describing the first invalid value
+func (b CircuitBreaker) Validate() error {
+	if b.FailureThreshold != nil && *b.FailureThreshold < 0 {
+		return fmt.Errorf("failureThreshold: must not be negative, got %d", *b.FailureThreshold)
+	}
+	if b.OpenTimeout != nil && b.OpenTimeout.Duration < 0 {
+		return fmt.Errorf("openTimeout: must not be negative, got %s", b.OpenTimeout)
+	}
+	return nil
+}
+
+// MergeCircuitBreakers returns `base` with the values that are set in
+// `override`
+func MergeCircuitBreakers(base CircuitBreaker, override *CircuitBreaker) CircuitBreaker {
+	if override == nil {
+		return base
+	}
+	if override.FailureThreshold != nil {
+		base.FailureThreshold = override.FailureThreshold
+	}
+	if override.OpenTimeout != nil {
+		base.OpenTimeout = override.OpenTimeout
+	}
+	return base
+}
+
+// applyCircuitBreakerEnv overrides `b` with `DF_CIRCUIT_BREAKER_*`
+// environment variables
+func applyCircuitBreakerEnv(b *CircuitBreaker) error {
+	if err := lookupIntPtr("DF_CIRCUIT_BREAKER_FAILURE_THRESHOLD", &b.FailureThreshold); err != nil {
+		return err
+	}
+	return lookupDurationPtr("DF_CIRCUIT_BREAKER_OPEN_TIMEOUT", &b.OpenTimeout)
+}
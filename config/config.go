@@ -0,0 +1,752 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var httpMethods = map[string]struct{}{
+	http.MethodGet:    {},
+	http.MethodPost:   {},
+	http.MethodPut:    {},
+	http.MethodPatch:  {},
+	http.MethodDelete: {},
+}
+
+var payloadTypes = map[string]struct{}{
+	"query": {},
+	"json":  {},
+	"form":  {},
+}
+
+// Config holds the configuration of the swarm listener
+type Config struct {
+	DockerHost                     string         `json:"dockerHost" yaml:"dockerHost"`
+	NotifyLabel                    string         `json:"notifyLabel" yaml:"notifyLabel"`
+	ServiceNamePrefix              string         `json:"serviceNamePrefix" yaml:"serviceNamePrefix"`
+	IncludeNodeIPInfo              bool           `json:"includeNodeIPInfo" yaml:"includeNodeIPInfo"`
+	NodeIPInfoIncludesTaskAddress  bool           `json:"nodeIPInfoIncludesTaskAddress" yaml:"nodeIPInfoIncludesTaskAddress"`
+	UseDockerServiceEvents         bool           `json:"useDockerServiceEvents" yaml:"useDockerServiceEvents"`
+	UseDockerNodeEvents            bool           `json:"useDockerNodeEvents" yaml:"useDockerNodeEvents"`
+	NotifyCreateServiceImmediately bool           `json:"notifyCreateServiceImmediately" yaml:"notifyCreateServiceImmediately"`
+	ListenWithoutEndpoints         bool           `json:"listenWithoutEndpoints" yaml:"listenWithoutEndpoints"`
+	ServicePollingInterval         int            `json:"servicePollingInterval" yaml:"servicePollingInterval"`
+	NodePollingInterval            int            `json:"nodePollingInterval" yaml:"nodePollingInterval"`
+	Retry                          int            `json:"retry" yaml:"retry"`
+	RetryInterval                  int            `json:"retryInterval" yaml:"retryInterval"`
+	RetryPolicy                    RetryPolicy    `json:"retryPolicy" yaml:"retryPolicy"`
+	CircuitBreaker                 CircuitBreaker `json:"circuitBreaker" yaml:"circuitBreaker"`
+	QueueDir                       string         `json:"queueDir" yaml:"queueDir"`
+	QueueMaxAttempts               int            `json:"queueMaxAttempts" yaml:"queueMaxAttempts"`
+	QueueMaxAge                    Duration       `json:"queueMaxAge" yaml:"queueMaxAge"`
+	TLS                            TLS            `json:"tls" yaml:"tls"`
+	ConnectTimeout                 Duration       `json:"connectTimeout" yaml:"connectTimeout"`
+	ResponseTimeout                Duration       `json:"responseTimeout" yaml:"responseTimeout"`
+	RequestOptions                 `yaml:",inline"`
+	Endpoints                      []Endpoint     `json:"endpoints" yaml:"endpoints"`
+	API                            API            `json:"api" yaml:"api"`
+	Tracing                        Tracing        `json:"tracing" yaml:"tracing"`
+	Logging                        Logging        `json:"logging" yaml:"logging"`
+	Audit                          Audit          `json:"audit" yaml:"audit"`
+	LeaderElection                 LeaderElection `json:"leaderElection" yaml:"leaderElection"`
+	CacheSnapshot                  CacheSnapshot  `json:"cacheSnapshot" yaml:"cacheSnapshot"`
+}
+
+// Endpoint describes the urls notifications are sent to for a single host
+// `Retry` and `RetryInterval` override the global values when set
+type Endpoint struct {
+	CreateServiceURL     string       `json:"createServiceURL,omitempty" yaml:"createServiceURL,omitempty"`
+	RemoveServiceURL     string       `json:"removeServiceURL,omitempty" yaml:"removeServiceURL,omitempty"`
+	CreateNodeURL        string       `json:"createNodeURL,omitempty" yaml:"createNodeURL,omitempty"`
+	RemoveNodeURL        string       `json:"removeNodeURL,omitempty" yaml:"removeNodeURL,omitempty"`
+	CreateServiceMethod  string       `json:"createServiceMethod,omitempty" yaml:"createServiceMethod,omitempty"`
+	RemoveServiceMethod  string       `json:"removeServiceMethod,omitempty" yaml:"removeServiceMethod,omitempty"`
+	CreateNodeMethod     string       `json:"createNodeMethod,omitempty" yaml:"createNodeMethod,omitempty"`
+	RemoveNodeMethod     string       `json:"removeNodeMethod,omitempty" yaml:"removeNodeMethod,omitempty"`
+	CreateServicePayload string       `json:"createServicePayload,omitempty" yaml:"createServicePayload,omitempty"`
+	RemoveServicePayload string       `json:"removeServicePayload,omitempty" yaml:"removeServicePayload,omitempty"`
+	CreateNodePayload    string       `json:"createNodePayload,omitempty" yaml:"createNodePayload,omitempty"`
+	RemoveNodePayload    string       `json:"removeNodePayload,omitempty" yaml:"removeNodePayload,omitempty"`
+	Retry                *int         `json:"retry,omitempty" yaml:"retry,omitempty"`
+	RetryInterval        *int         `json:"retryInterval,omitempty" yaml:"retryInterval,omitempty"`
+	RetryPolicy          *RetryPolicy `json:"retryPolicy,omitempty" yaml:"retryPolicy,omitempty"`
+	// RetryPolicies are keyed by createService, removeService, createNode or removeNode
+	RetryPolicies  map[string]EventRetryPolicy `json:"retryPolicies,omitempty" yaml:"retryPolicies,omitempty"`
+	CircuitBreaker *CircuitBreaker             `json:"circuitBreaker,omitempty" yaml:"circuitBreaker,omitempty"`
+	// TLS replaces the global TLS settings when set
+	TLS             *TLS      `json:"tls,omitempty" yaml:"tls,omitempty"`
+	ConnectTimeout  *Duration `json:"connectTimeout,omitempty" yaml:"connectTimeout,omitempty"`
+	ResponseTimeout *Duration `json:"responseTimeout,omitempty" yaml:"responseTimeout,omitempty"`
+	RequestOptions  `yaml:",inline"`
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		DockerHost:                    "unix:///var/run/docker.sock",
+		NotifyLabel:                   "com.df.notify",
+		NodeIPInfoIncludesTaskAddress: true,
+		UseDockerServiceEvents:        true,
+		UseDockerNodeEvents:           true,
+		ServicePollingInterval:        -1,
+		NodePollingInterval:           -1,
+		Retry:                         50,
+		RetryInterval:                 5,
+		ConnectTimeout:                Duration{time.Second * 10},
+		ResponseTimeout:               Duration{time.Second * 30},
+		API: API{
+			Address: ":8080",
+		},
+	}
+}
+
+// Load returns the default configuration overridden by the configuration
+// file `filename` and then by `DF_*` environment variables.
+// An empty `filename` skips the configuration file.
+func Load(filename string) (*Config, error) {
+	c := Default()
+	if len(filename) > 0 {
+		if err := c.readFile(filename); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := c.readSecretFiles(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readFile reads YAML or JSON configuration from `filename`
+// Files with the `.json` extension are parsed as JSON, all others as YAML
+func (c *Config) readFile(filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("Unable to read config file: %v", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(c)
+	} else {
+		err = yaml.UnmarshalStrict(content, c)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to parse config file %s: %v", filename, err)
+	}
+	return nil
+}
+
+// readSecretFiles reads the secret files of the configuration and its
+// endpoints
+func (c *Config) readSecretFiles() error {
+	if err := c.RequestOptions.readSecretFiles(); err != nil {
+		return err
+	}
+	if err := c.API.readSecretFiles(); err != nil {
+		return err
+	}
+	for idx := range c.Endpoints {
+		if err := c.Endpoints[idx].RequestOptions.readSecretFiles(); err != nil {
+			return fmt.Errorf("endpoints[%d].%v", idx, err)
+		}
+	}
+	return nil
+}
+
+// applyEnv overrides configuration with `DF_*` environment variables
+func (c *Config) applyEnv() error {
+	lookupString("DF_DOCKER_HOST", &c.DockerHost)
+	lookupString("DF_NOTIFY_LABEL", &c.NotifyLabel)
+	lookupString("DF_SERVICE_NAME_PREFIX", &c.ServiceNamePrefix)
+	lookupString("DF_QUEUE_DIR", &c.QueueDir)
+	applyTLSEnv(&c.TLS)
+
+	bools := []struct {
+		key   string
+		value *bool
+	}{
+		{"DF_INCLUDE_NODE_IP_INFO", &c.IncludeNodeIPInfo},
+		{"DF_NODE_IP_INFO_INCLUDES_TASK_ADDRESS", &c.NodeIPInfoIncludesTaskAddress},
+		{"DF_USE_DOCKER_SERVICE_EVENTS", &c.UseDockerServiceEvents},
+		{"DF_USE_DOCKER_NODE_EVENTS", &c.UseDockerNodeEvents},
+		{"DF_NOTIFY_CREATE_SERVICE_IMMEDIATELY", &c.NotifyCreateServiceImmediately},
+		{"DF_LISTEN_WITHOUT_ENDPOINTS", &c.ListenWithoutEndpoints},
+	}
+	for _, b := range bools {
+		if err := lookupBool(b.key, b.value); err != nil {
+			return err
+		}
+	}
+
+	ints := []struct {
+		key   string
+		value *int
+	}{
+		{"DF_SERVICE_POLLING_INTERVAL", &c.ServicePollingInterval},
+		{"DF_NODE_POLLING_INTERVAL", &c.NodePollingInterval},
+		{"DF_RETRY", &c.Retry},
+		{"DF_RETRY_INTERVAL", &c.RetryInterval},
+		{"DF_QUEUE_MAX_ATTEMPTS", &c.QueueMaxAttempts},
+	}
+	for _, i := range ints {
+		if err := lookupInt(i.key, i.value); err != nil {
+			return err
+		}
+	}
+
+	if err := applyRetryPolicyEnv(&c.RetryPolicy); err != nil {
+		return err
+	}
+	if err := applyCircuitBreakerEnv(&c.CircuitBreaker); err != nil {
+		return err
+	}
+	if err := applyRequestOptionsEnv(&c.RequestOptions); err != nil {
+		return err
+	}
+	if err := applyAPIEnv(&c.API); err != nil {
+		return err
+	}
+	if err := applyTracingEnv(&c.Tracing); err != nil {
+		return err
+	}
+	applyLoggingEnv(&c.Logging)
+	if err := applyAuditEnv(&c.Audit); err != nil {
+		return err
+	}
+	if err := applyLeaderElectionEnv(&c.LeaderElection); err != nil {
+		return err
+	}
+	if err := applyCacheSnapshotEnv(&c.CacheSnapshot); err != nil {
+		return err
+	}
+	if err := lookupDuration("DF_QUEUE_MAX_AGE", &c.QueueMaxAge); err != nil {
+		return err
+	}
+	if err := lookupDuration("DF_NOTIFY_CONNECT_TIMEOUT", &c.ConnectTimeout); err != nil {
+		return err
+	}
+	if err := lookupDuration("DF_NOTIFY_RESPONSE_TIMEOUT", &c.ResponseTimeout); err != nil {
+		return err
+	}
+
+	envEndpoints := EndpointsFromEnv(
+		readStringFromFile("/run/secrets/df_notify_create_service_url"),
+		readStringFromFile("/run/secrets/df_notify_remove_service_url"),
+		readStringFromFile("/run/secrets/df_notify_create_node_url"),
+		readStringFromFile("/run/secrets/df_notify_remove_node_url"),
+	)
+	c.Endpoints = MergeEndpoints(c.Endpoints, envEndpoints)
+	return nil
+}
+
+// Validate returns an error describing the first invalid value
+func (c Config) Validate() error {
+	if len(c.DockerHost) == 0 {
+		return fmt.Errorf("dockerHost: must not be empty")
+	}
+	if len(c.NotifyLabel) == 0 {
+		return fmt.Errorf("notifyLabel: must not be empty")
+	}
+	if c.Retry < 0 {
+		return fmt.Errorf("retry: must not be negative, got %d", c.Retry)
+	}
+	if c.RetryInterval < 0 {
+		return fmt.Errorf("retryInterval: must not be negative, got %d", c.RetryInterval)
+	}
+	if err := c.RetryPolicy.Validate(); err != nil {
+		return fmt.Errorf("retryPolicy.%v", err)
+	}
+	if c.QueueMaxAttempts < 0 {
+		return fmt.Errorf("queueMaxAttempts: must not be negative, got %d", c.QueueMaxAttempts)
+	}
+	if c.QueueMaxAge.Duration < 0 {
+		return fmt.Errorf("queueMaxAge: must not be negative, got %s", c.QueueMaxAge)
+	}
+	if err := c.CircuitBreaker.Validate(); err != nil {
+		return fmt.Errorf("circuitBreaker.%v", err)
+	}
+	if err := c.RequestOptions.Validate(); err != nil {
+		return err
+	}
+	if err := c.TLS.Validate(); err != nil {
+		return fmt.Errorf("tls.%v", err)
+	}
+	if c.ConnectTimeout.Duration < 0 {
+		return fmt.Errorf("connectTimeout: must not be negative, got %s", c.ConnectTimeout)
+	}
+	if c.ResponseTimeout.Duration < 0 {
+		return fmt.Errorf("responseTimeout: must not be negative, got %s", c.ResponseTimeout)
+	}
+	if err := c.API.Validate(); err != nil {
+		return fmt.Errorf("api.%v", err)
+	}
+	if err := c.Tracing.Validate(); err != nil {
+		return fmt.Errorf("tracing.%v", err)
+	}
+	if err := c.Logging.Validate(); err != nil {
+		return fmt.Errorf("logging.%v", err)
+	}
+	if err := c.Audit.Validate(); err != nil {
+		return fmt.Errorf("audit.%v", err)
+	}
+	if err := c.LeaderElection.Validate(); err != nil {
+		return fmt.Errorf("leaderElection.%v", err)
+	}
+	if err := c.CacheSnapshot.Validate(); err != nil {
+		return fmt.Errorf("cacheSnapshot.%v", err)
+	}
+
+	hosts := map[string]int{}
+	for idx, ep := range c.Endpoints {
+		if err := ep.Validate(); err != nil {
+			return fmt.Errorf("endpoints[%d].%v", idx, err)
+		}
+		host := ep.Host()
+		if prevIdx, ok := hosts[host]; ok {
+			return fmt.Errorf("endpoints[%d]: host %s is already used by endpoints[%d]", idx, host, prevIdx)
+		}
+		hosts[host] = idx
+	}
+	return nil
+}
+
+// Validate returns an error describing the first invalid value of the endpoint
+func (ep Endpoint) Validate() error {
+	urls := []struct {
+		name  string
+		value string
+	}{
+		{"createServiceURL", ep.CreateServiceURL},
+		{"removeServiceURL", ep.RemoveServiceURL},
+		{"createNodeURL", ep.CreateNodeURL},
+		{"removeNodeURL", ep.RemoveNodeURL},
+	}
+
+	host := ""
+	for _, u := range urls {
+		if len(u.value) == 0 {
+			continue
+		}
+		urlObj, err := url.Parse(u.value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", u.name, err)
+		}
+		if urlObj.Scheme != "http" && urlObj.Scheme != "https" {
+			return fmt.Errorf("%s: scheme must be http or https, got %q", u.name, u.value)
+		}
+		if len(urlObj.Host) == 0 {
+			return fmt.Errorf("%s: host is missing in %q", u.name, u.value)
+		}
+		if len(host) == 0 {
+			host = urlObj.Host
+		} else if host != urlObj.Host {
+			return fmt.Errorf("%s: host %s differs from host %s of the endpoint", u.name, urlObj.Host, host)
+		}
+	}
+	if len(host) == 0 {
+		return fmt.Errorf("url: one of createServiceURL, removeServiceURL, createNodeURL or removeNodeURL must be set")
+	}
+
+	methods := []struct {
+		name  string
+		value string
+	}{
+		{"createServiceMethod", ep.CreateServiceMethod},
+		{"removeServiceMethod", ep.RemoveServiceMethod},
+		{"createNodeMethod", ep.CreateNodeMethod},
+		{"removeNodeMethod", ep.RemoveNodeMethod},
+	}
+	for _, m := range methods {
+		if len(m.value) == 0 {
+			continue
+		}
+		if _, ok := httpMethods[strings.ToUpper(m.value)]; !ok {
+			return fmt.Errorf("%s: invalid HTTP method %q", m.name, m.value)
+		}
+	}
+
+	payloads := []struct {
+		name  string
+		value string
+	}{
+		{"createServicePayload", ep.CreateServicePayload},
+		{"removeServicePayload", ep.RemoveServicePayload},
+		{"createNodePayload", ep.CreateNodePayload},
+		{"removeNodePayload", ep.RemoveNodePayload},
+	}
+	for _, p := range payloads {
+		if len(p.value) == 0 {
+			continue
+		}
+		if _, ok := payloadTypes[strings.ToLower(p.value)]; !ok {
+			return fmt.Errorf("%s: invalid payload type %q, must be query, json or form", p.name, p.value)
+		}
+	}
+
+	if ep.Retry != nil && *ep.Retry < 0 {
+		return fmt.Errorf("retry: must not be negative, got %d", *ep.Retry)
+	}
+	if ep.RetryInterval != nil && *ep.RetryInterval < 0 {
+		return fmt.Errorf("retryInterval: must not be negative, got %d", *ep.RetryInterval)
+	}
+	if ep.RetryPolicy != nil {
+		if err := ep.RetryPolicy.Validate(); err != nil {
+			return fmt.Errorf("retryPolicy.%v", err)
+		}
+	}
+	if ep.CircuitBreaker != nil {
+		if err := ep.CircuitBreaker.Validate(); err != nil {
+			return fmt.Errorf("circuitBreaker.%v", err)
+		}
+	}
+	if err := ep.RequestOptions.Validate(); err != nil {
+		return err
+	}
+	if ep.TLS != nil {
+		if err := ep.TLS.Validate(); err != nil {
+			return fmt.Errorf("tls.%v", err)
+		}
+	}
+	if ep.ConnectTimeout != nil && ep.ConnectTimeout.Duration < 0 {
+		return fmt.Errorf("connectTimeout: must not be negative, got %s", ep.ConnectTimeout)
+	}
+	if ep.ResponseTimeout != nil && ep.ResponseTimeout.Duration < 0 {
+		return fmt.Errorf("responseTimeout: must not be negative, got %s", ep.ResponseTimeout)
+	}
+	for key, p := range ep.RetryPolicies {
+		if _, ok := retryPolicyKeys[key]; !ok {
+			return fmt.Errorf(
+				"retryPolicies.%s: must be createService, removeService, createNode or removeNode", key)
+		}
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("retryPolicies.%s.%v", key, err)
+		}
+	}
+	return nil
+}
+
+// Host returns the host of the first url set in the endpoint
+func (ep Endpoint) Host() string {
+	for _, addr := range []string{
+		ep.CreateServiceURL, ep.RemoveServiceURL,
+		ep.CreateNodeURL, ep.RemoveNodeURL} {
+		if len(addr) == 0 {
+			continue
+		}
+		urlObj, err := url.Parse(addr)
+		if err != nil {
+			continue
+		}
+		if len(urlObj.Host) > 0 {
+			return urlObj.Host
+		}
+	}
+	return ""
+}
+
+// EndpointsFromEnv creates endpoints from the notification url and method
+// environment variables. The extra addresses are appended to their
+// corresponding environment variables.
+func EndpointsFromEnv(
+	extraCreateServiceAddr, extraRemoveServiceAddr,
+	extraCreateNodeAddr, extraRemoveNodeAddr string) []Endpoint {
+	var createServiceAddr, removeServiceAddr string
+	if len(os.Getenv("DF_NOTIF_CREATE_SERVICE_URL")) > 0 {
+		createServiceAddr = os.Getenv("DF_NOTIF_CREATE_SERVICE_URL")
+	} else if len(os.Getenv("DF_NOTIFY_CREATE_SERVICE_URL")) > 0 {
+		createServiceAddr = os.Getenv("DF_NOTIFY_CREATE_SERVICE_URL")
+	} else {
+		createServiceAddr = os.Getenv("DF_NOTIFICATION_URL")
+	}
+	if len(os.Getenv("DF_NOTIF_REMOVE_SERVICE_URL")) > 0 {
+		removeServiceAddr = os.Getenv("DF_NOTIF_REMOVE_SERVICE_URL")
+	} else if len(os.Getenv("DF_NOTIFY_REMOVE_SERVICE_URL")) > 0 {
+		removeServiceAddr = os.Getenv("DF_NOTIFY_REMOVE_SERVICE_URL")
+	} else {
+		removeServiceAddr = os.Getenv("DF_NOTIFICATION_URL")
+	}
+	createNodeAddr := os.Getenv("DF_NOTIFY_CREATE_NODE_URL")
+	removeNodeAddr := os.Getenv("DF_NOTIFY_REMOVE_NODE_URL")
+
+	createServiceMethods := strings.ToUpper(os.Getenv("DF_NOTIFY_CREATE_SERVICE_METHOD"))
+	removeServiceMethods := strings.ToUpper(os.Getenv("DF_NOTIFY_REMOVE_SERVICE_METHOD"))
+	createServicePayloads := strings.ToLower(os.Getenv("DF_NOTIFY_CREATE_SERVICE_PAYLOAD"))
+	removeServicePayloads := strings.ToLower(os.Getenv("DF_NOTIFY_REMOVE_SERVICE_PAYLOAD"))
+
+	if len(extraCreateServiceAddr) > 0 {
+		createServiceAddr = fmt.Sprintf("%s,%s", createServiceAddr, extraCreateServiceAddr)
+	}
+	if len(extraRemoveServiceAddr) > 0 {
+		removeServiceAddr = fmt.Sprintf("%s,%s", removeServiceAddr, extraRemoveServiceAddr)
+	}
+	if len(extraCreateNodeAddr) > 0 {
+		createNodeAddr = fmt.Sprintf("%s,%s", createNodeAddr, extraCreateNodeAddr)
+	}
+	if len(extraRemoveNodeAddr) > 0 {
+		removeNodeAddr = fmt.Sprintf("%s,%s", removeNodeAddr, extraRemoveNodeAddr)
+	}
+
+	return EndpointsFromStrings(
+		createServiceAddr, removeServiceAddr, createNodeAddr, removeNodeAddr,
+		createServiceMethods, removeServiceMethods,
+		createServicePayloads, removeServicePayloads)
+}
+
+// EndpointsFromStrings creates endpoints from comma separated lists of urls
+// Urls are grouped into endpoints by their host. Methods and payloads are
+// matched with urls by their position in the list. When there are less methods
+// or payloads than urls, the last one is used for the remaining urls.
+func EndpointsFromStrings(
+	serviceCreateAddrs, serviceRemoveAddrs, nodeCreateAddrs, nodeRemoveAddrs,
+	serviceCreateMethods, serviceRemoveMethods,
+	serviceCreatePayloads, serviceRemovePayloads string) []Endpoint {
+
+	hosts := []string{}
+	tempEP := map[string]*Endpoint{}
+
+	insert := func(addrs string, setter func(ep *Endpoint, addrIdx int, addr string)) {
+		for addrsIdx, v := range strings.Split(addrs, ",") {
+			urlObj, err := url.Parse(v)
+			if err != nil {
+				continue
+			}
+			host := urlObj.Host
+			if len(host) == 0 {
+				continue
+			}
+			if tempEP[host] == nil {
+				tempEP[host] = &Endpoint{}
+				hosts = append(hosts, host)
+			}
+			setter(tempEP[host], addrsIdx, v)
+		}
+	}
+
+	insert(serviceCreateAddrs, func(ep *Endpoint, idx int, addr string) {
+		ep.CreateServiceURL = addr
+		ep.CreateServiceMethod = listValue(serviceCreateMethods, idx)
+		ep.CreateServicePayload = listValue(serviceCreatePayloads, idx)
+	})
+	insert(serviceRemoveAddrs, func(ep *Endpoint, idx int, addr string) {
+		ep.RemoveServiceURL = addr
+		ep.RemoveServiceMethod = listValue(serviceRemoveMethods, idx)
+		ep.RemoveServicePayload = listValue(serviceRemovePayloads, idx)
+	})
+	insert(nodeCreateAddrs, func(ep *Endpoint, idx int, addr string) {
+		ep.CreateNodeURL = addr
+	})
+	insert(nodeRemoveAddrs, func(ep *Endpoint, idx int, addr string) {
+		ep.RemoveNodeURL = addr
+	})
+
+	endpoints := []Endpoint{}
+	for _, host := range hosts {
+		endpoints = append(endpoints, *tempEP[host])
+	}
+	return endpoints
+}
+
+// listValue returns the `idx` value of the comma separated list `values`
+// or the last value when `idx` is out of range
+func listValue(values string, idx int) string {
+	valuesList := strings.Split(values, ",")
+	if idx < len(valuesList) {
+		return valuesList[idx]
+	}
+	return valuesList[len(valuesList)-1]
+}
+
+// MergeEndpoints merges `overrides` into `base`. Endpoints with the same host
+// are merged with non empty values of `overrides` taking precedence.
+func MergeEndpoints(base []Endpoint, overrides []Endpoint) []Endpoint {
+	merged := make([]Endpoint, len(base))
+	copy(merged, base)
+
+	hostIdx := map[string]int{}
+	for idx, ep := range merged {
+		hostIdx[ep.Host()] = idx
+	}
+
+	for _, ep := range overrides {
+		idx, ok := hostIdx[ep.Host()]
+		if !ok {
+			hostIdx[ep.Host()] = len(merged)
+			merged = append(merged, ep)
+			continue
+		}
+		merged[idx] = mergeEndpoint(merged[idx], ep)
+	}
+	return merged
+}
+
+func mergeEndpoint(base, override Endpoint) Endpoint {
+	mergeString := func(b *string, o string) {
+		if len(o) > 0 {
+			*b = o
+		}
+	}
+	mergeString(&base.CreateServiceURL, override.CreateServiceURL)
+	mergeString(&base.RemoveServiceURL, override.RemoveServiceURL)
+	mergeString(&base.CreateNodeURL, override.CreateNodeURL)
+	mergeString(&base.RemoveNodeURL, override.RemoveNodeURL)
+	mergeString(&base.CreateServiceMethod, override.CreateServiceMethod)
+	mergeString(&base.RemoveServiceMethod, override.RemoveServiceMethod)
+	mergeString(&base.CreateNodeMethod, override.CreateNodeMethod)
+	mergeString(&base.RemoveNodeMethod, override.RemoveNodeMethod)
+	mergeString(&base.CreateServicePayload, override.CreateServicePayload)
+	mergeString(&base.RemoveServicePayload, override.RemoveServicePayload)
+	mergeString(&base.CreateNodePayload, override.CreateNodePayload)
+	mergeString(&base.RemoveNodePayload, override.RemoveNodePayload)
+	if override.Retry != nil {
+		base.Retry = override.Retry
+	}
+	if override.RetryInterval != nil {
+		base.RetryInterval = override.RetryInterval
+	}
+	if override.RetryPolicy != nil {
+		base.RetryPolicy = override.RetryPolicy
+	}
+	if override.RetryPolicies != nil {
+		base.RetryPolicies = override.RetryPolicies
+	}
+	if override.CircuitBreaker != nil {
+		base.CircuitBreaker = override.CircuitBreaker
+	}
+	if override.TLS != nil {
+		base.TLS = override.TLS
+	}
+	if override.ConnectTimeout != nil {
+		base.ConnectTimeout = override.ConnectTimeout
+	}
+	if override.ResponseTimeout != nil {
+		base.ResponseTimeout = override.ResponseTimeout
+	}
+	base.RequestOptions = MergeRequestOptions(base.RequestOptions, override.RequestOptions)
+	return base
+}
+
+func lookupString(key string, value *string) {
+	if v := os.Getenv(key); len(v) > 0 {
+		*value = v
+	}
+}
+
+func lookupBool(key string, value *bool) error {
+	v := os.Getenv(key)
+	if len(v) == 0 {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: invalid boolean %q", key, v)
+	}
+	*value = b
+	return nil
+}
+
+func lookupInt(key string, value *int) error {
+	v := os.Getenv(key)
+	if len(v) == 0 {
+		return nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: invalid integer %q", key, v)
+	}
+	*value = i
+	return nil
+}
+
+func lookupIntPtr(key string, value **int) error {
+	var i int
+	if err := lookupInt(key, &i); err != nil || len(os.Getenv(key)) == 0 {
+		return err
+	}
+	*value = &i
+	return nil
+}
+
+func lookupFloatPtr(key string, value **float64) error {
+	v := os.Getenv(key)
+	if len(v) == 0 {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("%s: invalid number %q", key, v)
+	}
+	*value = &f
+	return nil
+}
+
+func lookupDuration(key string, value *Duration) error {
+	var d *Duration
+	if err := lookupDurationPtr(key, &d); err != nil || d == nil {
+		return err
+	}
+	*value = *d
+	return nil
+}
+
+func lookupDurationPtr(key string, value **Duration) error {
+	v := os.Getenv(key)
+	if len(v) == 0 {
+		return nil
+	}
+	d, err := ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s: %v", key, err)
+	}
+	*value = &d
+	return nil
+}
+
+func lookupIntList(key string, value *[]int) error {
+	v := os.Getenv(key)
+	if len(v) == 0 {
+		return nil
+	}
+	list := []int{}
+	for _, item := range strings.Split(v, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			return fmt.Errorf("%s: invalid integer %q", key, item)
+		}
+		list = append(list, i)
+	}
+	*value = list
+	return nil
+}
+
+// readSecretFile returns the content of `filename` without surrounding
+// whitespace
+func readSecretFile(filename string) (string, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func readStringFromFile(filename string) string {
+	content, _ := readSecretFile(filename)
+	return content
+}
@@ -0,0 +1,767 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+	tempDir string
+	env     map[string]string
+}
+
+func TestConfigUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
+
+func (s *ConfigTestSuite) SetupTest() {
+	tempDir, err := ioutil.TempDir("", "dfsl-config")
+	s.Require().NoError(err)
+	s.tempDir = tempDir
+
+	s.env = map[string]string{}
+	for _, kv := range os.Environ() {
+		pair := strings.SplitN(kv, "=", 2)
+		if strings.HasPrefix(pair[0], "DF_") || strings.HasPrefix(pair[0], "OTEL_") {
+			s.env[pair[0]] = pair[1]
+			os.Unsetenv(pair[0])
+		}
+	}
+}
+
+func (s *ConfigTestSuite) TearDownTest() {
+	os.RemoveAll(s.tempDir)
+	for _, kv := range os.Environ() {
+		pair := strings.SplitN(kv, "=", 2)
+		if strings.HasPrefix(pair[0], "DF_") || strings.HasPrefix(pair[0], "OTEL_") {
+			os.Unsetenv(pair[0])
+		}
+	}
+	for k, v := range s.env {
+		os.Setenv(k, v)
+	}
+}
+
+// Load
+
+func (s *ConfigTestSuite) Test_Load_ReturnsDefaultValues() {
+	c, err := Load("")
+	s.Require().NoError(err)
+
+	s.Equal(50, c.Retry)
+	s.Equal(5, c.RetryInterval)
+	s.Equal("com.df.notify", c.NotifyLabel)
+	s.Empty(c.Endpoints)
+	s.Equal(":8080", c.API.Address)
+	s.False(c.API.HasCredentials())
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsRetryFromEnv() {
+	os.Setenv("DF_RETRY", "100")
+	os.Setenv("DF_RETRY_INTERVAL", "10")
+
+	c, err := Load("")
+	s.Require().NoError(err)
+
+	s.Equal(100, c.Retry)
+	s.Equal(10, c.RetryInterval)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsQueueDir() {
+	filename := s.writeFile("config.yml", "queueDir: /var/lib/dfsl\n")
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+	s.Equal("/var/lib/dfsl", c.QueueDir)
+
+	os.Setenv("DF_QUEUE_DIR", "/data/queue")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+	s.Equal("/data/queue", c.QueueDir)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsQueueLimits() {
+	filename := s.writeFile("config.yml", "queueMaxAttempts: 10\nqueueMaxAge: 1h\n")
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+	s.Equal(10, c.QueueMaxAttempts)
+	s.Equal(time.Hour, c.QueueMaxAge.Duration)
+
+	os.Setenv("DF_QUEUE_MAX_ATTEMPTS", "20")
+	os.Setenv("DF_QUEUE_MAX_AGE", "600")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+	s.Equal(20, c.QueueMaxAttempts)
+	s.Equal(time.Minute*10, c.QueueMaxAge.Duration)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsListenWithoutEndpoints() {
+	filename := s.writeFile("config.yml", "listenWithoutEndpoints: true\n")
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+	s.True(c.ListenWithoutEndpoints)
+
+	os.Setenv("DF_LISTEN_WITHOUT_ENDPOINTS", "false")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+	s.False(c.ListenWithoutEndpoints)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsError_WhenEnvIsNotAnInteger() {
+	os.Setenv("DF_RETRY_INTERVAL", "five")
+
+	_, err := Load("")
+	s.Require().Error(err)
+	s.Contains(err.Error(), "DF_RETRY_INTERVAL")
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsError_WhenEnvIsNotABoolean() {
+	os.Setenv("DF_USE_DOCKER_SERVICE_EVENTS", "yes please")
+
+	_, err := Load("")
+	s.Require().Error(err)
+	s.Contains(err.Error(), "DF_USE_DOCKER_SERVICE_EVENTS")
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsYAMLFile() {
+	filename := s.writeFile("config.yml", `
+notifyLabel: com.df.notifyDev
+retry: 10
+retryInterval: 2
+servicePollingInterval: 20
+endpoints:
+  - createServiceURL: http://proxy:8080/v1/docker-flow-proxy/reconfigure
+    removeServiceURL: http://proxy:8080/v1/docker-flow-proxy/remove
+    createServiceMethod: POST
+    createServicePayload: json
+    retry: 3
+  - createNodeURL: http://dns/create
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal("com.df.notifyDev", c.NotifyLabel)
+	s.Equal(10, c.Retry)
+	s.Equal(2, c.RetryInterval)
+	s.Equal(20, c.ServicePollingInterval)
+	s.Require().Len(c.Endpoints, 2)
+	s.Equal("http://proxy:8080/v1/docker-flow-proxy/reconfigure", c.Endpoints[0].CreateServiceURL)
+	s.Equal("POST", c.Endpoints[0].CreateServiceMethod)
+	s.Equal("json", c.Endpoints[0].CreateServicePayload)
+	s.Require().NotNil(c.Endpoints[0].Retry)
+	s.Equal(3, *c.Endpoints[0].Retry)
+	s.Nil(c.Endpoints[0].RetryInterval)
+	s.Equal("dns", c.Endpoints[1].Host())
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsRetryPolicies() {
+	filename := s.writeFile("config.yml", `
+retryPolicy:
+  multiplier: 2
+  maxDelay: 1m
+  jitter: 0.2
+endpoints:
+  - createServiceURL: http://proxy/reconfigure
+    retryPolicy:
+      maxElapsedTime: 90
+      retryableStatusCodes: [502, 503]
+    retryPolicies:
+      createService:
+        retry: 3
+        initialDelay: 500ms
+        multiplier: 1.5
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(2.0, *c.RetryPolicy.Multiplier)
+	s.Equal(time.Minute, c.RetryPolicy.MaxDelay.Duration)
+	s.Equal(0.2, *c.RetryPolicy.Jitter)
+	ep := c.Endpoints[0]
+	s.Require().NotNil(ep.RetryPolicy)
+	s.Equal(time.Second*90, ep.RetryPolicy.MaxElapsedTime.Duration)
+	s.Equal([]int{502, 503}, ep.RetryPolicy.RetryableStatusCodes)
+	createService := ep.RetryPolicies["createService"]
+	s.Equal(3, *createService.Retry)
+	s.Equal(time.Millisecond*500, createService.InitialDelay.Duration)
+	s.Equal(1.5, *createService.Multiplier)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsRetryPolicyFromEnv() {
+	os.Setenv("DF_RETRY_MULTIPLIER", "2")
+	os.Setenv("DF_RETRY_MAX_DELAY", "30s")
+	os.Setenv("DF_RETRY_JITTER", "0.1")
+	os.Setenv("DF_RETRY_MAX_ELAPSED_TIME", "300")
+	os.Setenv("DF_RETRY_STATUS_CODES", "502, 503,504")
+
+	c, err := Load("")
+	s.Require().NoError(err)
+
+	s.Equal(2.0, *c.RetryPolicy.Multiplier)
+	s.Equal(time.Second*30, c.RetryPolicy.MaxDelay.Duration)
+	s.Equal(0.1, *c.RetryPolicy.Jitter)
+	s.Equal(time.Minute*5, c.RetryPolicy.MaxElapsedTime.Duration)
+	s.Equal([]int{502, 503, 504}, c.RetryPolicy.RetryableStatusCodes)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsCircuitBreaker() {
+	filename := s.writeFile("config.yml", `
+circuitBreaker:
+  failureThreshold: 5
+  openTimeout: 1m
+endpoints:
+  - createServiceURL: http://proxy/reconfigure
+    circuitBreaker:
+      failureThreshold: 0
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(5, *c.CircuitBreaker.FailureThreshold)
+	s.Equal(time.Minute, c.CircuitBreaker.OpenTimeout.Duration)
+	s.Require().NotNil(c.Endpoints[0].CircuitBreaker)
+	s.Equal(0, *c.Endpoints[0].CircuitBreaker.FailureThreshold)
+
+	os.Setenv("DF_CIRCUIT_BREAKER_FAILURE_THRESHOLD", "3")
+	os.Setenv("DF_CIRCUIT_BREAKER_OPEN_TIMEOUT", "10")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(3, *c.CircuitBreaker.FailureThreshold)
+	s.Equal(time.Second*10, c.CircuitBreaker.OpenTimeout.Duration)
+}
+
+func (s *ConfigTestSuite) Test_MergeCircuitBreakers_OverridesSetValues() {
+	threshold, disabled := 5, 0
+	base := CircuitBreaker{FailureThreshold: &threshold, OpenTimeout: &Duration{time.Minute}}
+
+	s.Equal(base, MergeCircuitBreakers(base, nil))
+
+	merged := MergeCircuitBreakers(base, &CircuitBreaker{OpenTimeout: &Duration{time.Second}})
+	s.Equal(5, *merged.FailureThreshold)
+	s.Equal(time.Second, merged.OpenTimeout.Duration)
+
+	merged = MergeCircuitBreakers(base, &CircuitBreaker{FailureThreshold: &disabled})
+	s.Equal(0, *merged.FailureThreshold)
+	s.Equal(time.Minute, merged.OpenTimeout.Duration)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsTracing() {
+	filename := s.writeFile("config.yml", `
+tracing:
+  endpoint: http://collector:4318/v1/traces
+  sampleRatio: 0.5
+  headers:
+    Authorization: Bearer collector-token
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.True(c.Tracing.IsEnabled())
+	s.Equal("http://collector:4318/v1/traces", c.Tracing.Endpoint)
+	s.Equal(0.5, c.Tracing.Ratio())
+	s.Equal(map[string]string{"Authorization": "Bearer collector-token"}, c.Tracing.Headers)
+
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://otel:4318/")
+	os.Setenv("DF_TRACING_SERVICE_NAME", "dfsl-prod")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal("http://otel:4318/v1/traces", c.Tracing.Endpoint)
+	s.Equal("dfsl-prod", c.Tracing.ServiceName)
+
+	os.Setenv("DF_TRACING_ENDPOINT", "http://jaeger:4318/v1/traces")
+	os.Setenv("DF_TRACING_SAMPLE_RATIO", "1")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal("http://jaeger:4318/v1/traces", c.Tracing.Endpoint)
+	s.Equal(1.0, c.Tracing.Ratio())
+}
+
+func (s *ConfigTestSuite) Test_Load_DisablesTracing_ByDefault() {
+	c, err := Load("")
+	s.Require().NoError(err)
+
+	s.False(c.Tracing.IsEnabled())
+	s.Equal(1.0, c.Tracing.Ratio())
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsLogging() {
+	filename := s.writeFile("config.yml", `
+logging:
+  level: debug
+  redactLabels:
+    - com.df.usersPasswords
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(logging.LevelDebug, c.Logging.LogLevel())
+	s.Equal(logging.FormatLogfmt, c.Logging.LogFormat())
+	s.Equal([]string{"com.df.usersPasswords"}, c.Logging.RedactLabels)
+
+	os.Setenv("DF_LOG_LEVEL", "warn")
+	os.Setenv("DF_LOG_FORMAT", "json")
+	os.Setenv("DF_LOG_REDACT_LABELS", "com.df.usersPasswords, com.df.authToken")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(logging.LevelWarn, c.Logging.LogLevel())
+	s.Equal(logging.FormatJSON, c.Logging.LogFormat())
+	s.Equal([]string{"com.df.usersPasswords", "com.df.authToken"}, c.Logging.RedactLabels)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsAudit() {
+	filename := s.writeFile("config.yml", `
+audit:
+  file: /var/lib/dfsl/audit.log
+  maxSize: 5
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.True(c.Audit.IsEnabled())
+	s.Equal(Audit{File: "/var/lib/dfsl/audit.log", MaxSize: 5}, c.Audit)
+
+	os.Setenv("DF_AUDIT_FILE", "/tmp/audit.log")
+	os.Setenv("DF_AUDIT_MAX_BACKUPS", "2")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(Audit{File: "/tmp/audit.log", MaxSize: 5, MaxBackups: 2}, c.Audit)
+	s.False(Default().Audit.IsEnabled())
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsLeaderElection() {
+	filename := s.writeFile("config.yml", `
+leaderElection:
+  enabled: true
+  id: manager1
+  ttl: 30s
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.True(c.LeaderElection.IsEnabled())
+	s.Equal("manager1", c.LeaderElection.ID)
+	s.Equal("docker-flow-swarm-listener-leader", c.LeaderElection.Lock())
+	s.Equal(30*time.Second, c.LeaderElection.LockTTL())
+	s.Equal(5*time.Second, c.LeaderElection.LockRenewInterval())
+	s.Equal(30*time.Second, c.LeaderElection.CacheRefresh())
+
+	os.Setenv("DF_LEADER_LOCK_NAME", "dfsl-prod-leader")
+	os.Setenv("DF_LEADER_RENEW_INTERVAL", "10s")
+	os.Setenv("DF_LEADER_CACHE_REFRESH_INTERVAL", "1m")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal("dfsl-prod-leader", c.LeaderElection.Lock())
+	s.Equal(10*time.Second, c.LeaderElection.LockRenewInterval())
+	s.Equal(time.Minute, c.LeaderElection.CacheRefresh())
+	s.False(Default().LeaderElection.IsEnabled())
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsCacheSnapshot() {
+	filename := s.writeFile("config.yml", `
+cacheSnapshot:
+  file: /var/lib/dfsl/caches.json
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.True(c.CacheSnapshot.IsEnabled())
+	s.Equal("/var/lib/dfsl/caches.json", c.CacheSnapshot.File)
+	s.Equal(time.Second, c.CacheSnapshot.WriteInterval())
+
+	os.Setenv("DF_CACHE_SNAPSHOT_FILE", "/tmp/caches.json")
+	os.Setenv("DF_CACHE_SNAPSHOT_INTERVAL", "5s")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal("/tmp/caches.json", c.CacheSnapshot.File)
+	s.Equal(5*time.Second, c.CacheSnapshot.WriteInterval())
+	s.False(Default().CacheSnapshot.IsEnabled())
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsRequestOptions() {
+	tokenFile := s.writeFile("token", "proxy-token\n")
+	filename := s.writeFile("config.yml", `
+headers:
+  X-Env: prod
+signingSecret: global-secret
+endpoints:
+  - createServiceURL: http://proxy/reconfigure
+    headers:
+      x-team: ops
+    bearerTokenFile: `+tokenFile+`
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(map[string]string{"X-Env": "prod"}, c.Headers)
+	s.Equal("global-secret", c.SigningSecret)
+	ep := c.Endpoints[0]
+	s.Equal(map[string]string{"x-team": "ops"}, ep.Headers)
+	s.Equal("proxy-token", ep.BearerToken)
+
+	merged := MergeRequestOptions(c.RequestOptions, ep.RequestOptions)
+	s.Equal(map[string]string{"X-Env": "prod", "X-Team": "ops"}, merged.Headers)
+	s.Equal("proxy-token", merged.BearerToken)
+	s.Equal("global-secret", merged.SigningSecret)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsRequestOptionsFromEnv() {
+	os.Setenv("DF_NOTIFY_HEADERS", "X-Env: prod,x-team:ops")
+	os.Setenv("DF_NOTIFY_BEARER_TOKEN", "my-token")
+	os.Setenv("DF_NOTIFY_SIGNING_SECRET", "my-secret")
+
+	c, err := Load("")
+	s.Require().NoError(err)
+
+	s.Equal(map[string]string{"X-Env": "prod", "X-Team": "ops"}, c.Headers)
+	s.Equal("my-token", c.BearerToken)
+	s.Equal("my-secret", c.SigningSecret)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsError_WhenSecretFileDoesNotExist() {
+	filename := s.writeFile("config.yml",
+		"endpoints:\n  - createServiceURL: http://proxy/a\n    signingSecretFile: /does/not/exist\n")
+
+	_, err := Load(filename)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "endpoints[0].signingSecretFile:")
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsTLSAndTimeouts() {
+	filename := s.writeFile("config.yml", `
+tls:
+  caFile: /certs/ca.pem
+  minVersion: "1.2"
+connectTimeout: 5s
+endpoints:
+  - createServiceURL: https://proxy/reconfigure
+    tls:
+      caFile: /certs/proxy-ca.pem
+      certFile: /certs/client.pem
+      keyFile: /certs/client-key.pem
+      serverName: proxy.example.com
+    responseTimeout: 2m
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(TLS{CAFile: "/certs/ca.pem", MinVersion: "1.2"}, c.TLS)
+	s.Equal(time.Second*5, c.ConnectTimeout.Duration)
+	s.Equal(time.Second*30, c.ResponseTimeout.Duration)
+	ep := c.Endpoints[0]
+	s.Require().NotNil(ep.TLS)
+	s.Equal(TLS{
+		CAFile:     "/certs/proxy-ca.pem",
+		CertFile:   "/certs/client.pem",
+		KeyFile:    "/certs/client-key.pem",
+		ServerName: "proxy.example.com",
+	}, *ep.TLS)
+	s.Nil(ep.ConnectTimeout)
+	s.Equal(time.Minute*2, ep.ResponseTimeout.Duration)
+
+	os.Setenv("DF_NOTIFY_TLS_CA_FILE", "/run/secrets/ca")
+	os.Setenv("DF_NOTIFY_TLS_MIN_VERSION", "1.3")
+	os.Setenv("DF_NOTIFY_CONNECT_TIMEOUT", "1")
+	os.Setenv("DF_NOTIFY_RESPONSE_TIMEOUT", "10s")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal("/run/secrets/ca", c.TLS.CAFile)
+	s.Equal("1.3", c.TLS.MinVersion)
+	s.Equal(time.Second, c.ConnectTimeout.Duration)
+	s.Equal(time.Second*10, c.ResponseTimeout.Duration)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsAPI() {
+	passwordFile := s.writeFile("password", "secret\n")
+	filename := s.writeFile("config.yml", `
+api:
+  address: ":8443"
+  tls:
+    certFile: /certs/server.pem
+    keyFile: /certs/server-key.pem
+    clientCAFile: /certs/ca.pem
+  username: admin
+  passwordFile: `+passwordFile+`
+  authenticateReads: true
+`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(":8443", c.API.Address)
+	s.Equal(APITLS{
+		CertFile:     "/certs/server.pem",
+		KeyFile:      "/certs/server-key.pem",
+		ClientCAFile: "/certs/ca.pem",
+	}, c.API.TLS)
+	s.Equal("admin", c.API.Username)
+	s.Equal("secret", c.API.Password)
+	s.True(c.API.AuthenticateReads)
+	s.False(c.API.AuthenticateMetrics)
+
+	os.Setenv("DF_API_ADDRESS", ":9090")
+	os.Setenv("DF_API_TOKEN", "my-token")
+	os.Setenv("DF_API_AUTHENTICATE_METRICS", "true")
+	c, err = Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(":9090", c.API.Address)
+	s.Equal("my-token", c.API.Token)
+	s.True(c.API.AuthenticateMetrics)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReadsJSONFile() {
+	filename := s.writeFile("config.json", `{
+	"serviceNamePrefix": "dev1",
+	"endpoints": [
+		{"createServiceURL": "http://proxy:8080/reconfigure", "retryInterval": 1}
+	]
+}`)
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal("dev1", c.ServiceNamePrefix)
+	s.Equal(50, c.Retry)
+	s.Require().Len(c.Endpoints, 1)
+	s.Require().NotNil(c.Endpoints[0].RetryInterval)
+	s.Equal(1, *c.Endpoints[0].RetryInterval)
+}
+
+func (s *ConfigTestSuite) Test_Load_EnvOverridesFile() {
+	filename := s.writeFile("config.yml", `
+retry: 10
+endpoints:
+  - createServiceURL: http://proxy:8080/reconfigure
+    createServiceMethod: POST
+    removeServiceURL: http://proxy:8080/remove
+`)
+	os.Setenv("DF_RETRY", "20")
+	os.Setenv("DF_NOTIFY_CREATE_SERVICE_URL", "http://proxy:8080/v2/reconfigure,http://monitor/reconfigure")
+
+	c, err := Load(filename)
+	s.Require().NoError(err)
+
+	s.Equal(20, c.Retry)
+	s.Require().Len(c.Endpoints, 2)
+	s.Equal("http://proxy:8080/v2/reconfigure", c.Endpoints[0].CreateServiceURL)
+	s.Equal("POST", c.Endpoints[0].CreateServiceMethod)
+	s.Equal("http://proxy:8080/remove", c.Endpoints[0].RemoveServiceURL)
+	s.Equal("http://monitor/reconfigure", c.Endpoints[1].CreateServiceURL)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsError_WhenFileDoesNotExist() {
+	_, err := Load(filepath.Join(s.tempDir, "missing.yml"))
+	s.Error(err)
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsError_WhenFileHasUnknownFields() {
+	filename := s.writeFile("config.yml", "retries: 10\n")
+
+	_, err := Load(filename)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "retries")
+}
+
+func (s *ConfigTestSuite) Test_Load_ReturnsError_WhenEndpointIsInvalid() {
+	testCases := []struct {
+		content  string
+		expected string
+	}{
+		{
+			"endpoints:\n  - createServiceURL: http://proxy/reconfigure\n    createServiceMethod: FETCH\n",
+			"endpoints[0].createServiceMethod: invalid HTTP method",
+		},
+		{
+			"endpoints:\n  - createServiceURL: http://proxy/reconfigure\n    removeServicePayload: xml\n",
+			"endpoints[0].removeServicePayload: invalid payload type",
+		},
+		{
+			"endpoints:\n  - createServiceURL: http://proxy/reconfigure\n    removeServiceURL: http://other/remove\n",
+			"endpoints[0].removeServiceURL: host other differs",
+		},
+		{
+			"endpoints:\n  - createServiceURL: proxy/reconfigure\n",
+			"endpoints[0].createServiceURL: scheme must be http or https",
+		},
+		{
+			"endpoints:\n  - retry: 1\n",
+			"endpoints[0].url:",
+		},
+		{
+			"endpoints:\n  - createServiceURL: http://proxy/a\n  - removeServiceURL: http://proxy/b\n",
+			"endpoints[1]: host proxy is already used by endpoints[0]",
+		},
+		{
+			"retryInterval: -1\n",
+			"retryInterval: must not be negative",
+		},
+		{
+			"queueMaxAttempts: -1\n",
+			"queueMaxAttempts: must not be negative",
+		},
+		{
+			"queueMaxAge: -1s\n",
+			"queueMaxAge: must not be negative",
+		},
+		{
+			"retryPolicy:\n  jitter: 2\n",
+			"retryPolicy.jitter: must be between 0 and 1",
+		},
+		{
+			"retryPolicy:\n  maxDelay: soon\n",
+			"invalid duration",
+		},
+		{
+			"endpoints:\n  - createServiceURL: http://proxy/a\n    retryPolicy:\n      multiplier: 0.5\n",
+			"endpoints[0].retryPolicy.multiplier: must be at least 1",
+		},
+		{
+			"endpoints:\n  - createServiceURL: http://proxy/a\n    retryPolicies:\n      create: {}\n",
+			"endpoints[0].retryPolicies.create: must be createService",
+		},
+		{
+			"endpoints:\n  - createServiceURL: http://proxy/a\n    retryPolicies:\n      removeService:\n        retryableStatusCodes: [42]\n",
+			"endpoints[0].retryPolicies.removeService.retryableStatusCodes: invalid status code 42",
+		},
+		{
+			"headers:\n  X Env: prod\n",
+			"headers: invalid header name",
+		},
+		{
+			"endpoints:\n  - createServiceURL: http://proxy/a\n    headers:\n      X-Env: \"a\\nb\"\n",
+			"endpoints[0].headers.X-Env: must not contain line breaks",
+		},
+		{
+			"tls:\n  minVersion: \"1.4\"\n",
+			"tls.minVersion: must be 1.0, 1.1, 1.2, or 1.3",
+		},
+		{
+			"endpoints:\n  - createServiceURL: https://proxy/a\n    tls:\n      certFile: /certs/client.pem\n",
+			"endpoints[0].tls.keyFile: must be set with certFile",
+		},
+		{
+			"api:\n  username: admin\n",
+			"api.password: must be set with username",
+		},
+		{
+			"api:\n  authenticatePing: true\n",
+			"api.token: a token or username is required",
+		},
+		{
+			"api:\n  tls:\n    clientCAFile: /certs/ca.pem\n",
+			"api.tls.certFile: must be set with clientCAFile",
+		},
+		{
+			"responseTimeout: -1s\n",
+			"responseTimeout: must not be negative",
+		},
+		{
+			"circuitBreaker:\n  failureThreshold: -1\n",
+			"circuitBreaker.failureThreshold: must not be negative",
+		},
+		{
+			"endpoints:\n  - createServiceURL: http://proxy/a\n    circuitBreaker:\n      openTimeout: -1s\n",
+			"endpoints[0].circuitBreaker.openTimeout: must not be negative",
+		},
+		{
+			"tracing:\n  endpoint: collector:4318\n",
+			"tracing.endpoint: scheme must be http or https",
+		},
+		{
+			"tracing:\n  sampleRatio: 2\n",
+			"tracing.sampleRatio: must be between 0 and 1",
+		},
+		{
+			"logging:\n  level: verbose\n",
+			"logging.level: level must be debug, info, warn, or error",
+		},
+		{
+			"logging:\n  format: text\n",
+			"logging.format: format must be logfmt or json",
+		},
+		{
+			"audit:\n  maxSize: -1\n",
+			"audit.maxSize: must not be negative",
+		},
+		{
+			"leaderElection:\n  ttl: 0s\n",
+			"leaderElection.ttl: must be positive",
+		},
+		{
+			"leaderElection:\n  ttl: 10s\n  renewInterval: 10s\n",
+			"leaderElection.renewInterval: must be shorter than ttl",
+		},
+		{
+			"cacheSnapshot:\n  interval: -1s\n",
+			"cacheSnapshot.interval: must be positive",
+		},
+	}
+
+	for _, tc := range testCases {
+		filename := s.writeFile("config.yml", tc.content)
+		_, err := Load(filename)
+		s.Require().Error(err, tc.content)
+		s.Contains(err.Error(), tc.expected)
+	}
+}
+
+// EndpointsFromStrings
+
+func (s *ConfigTestSuite) Test_EndpointsFromStrings_GroupsByHost() {
+	endpoints := EndpointsFromStrings(
+		"http://host1:8080/reconfigure,http://host2/reconfigure",
+		"http://host1:8080/remove",
+		"", "http://host2/removenode",
+		"GET,POST", "DELETE",
+		"json", "")
+
+	s.Require().Len(endpoints, 2)
+	s.Equal(Endpoint{
+		CreateServiceURL:     "http://host1:8080/reconfigure",
+		RemoveServiceURL:     "http://host1:8080/remove",
+		CreateServiceMethod:  "GET",
+		RemoveServiceMethod:  "DELETE",
+		CreateServicePayload: "json",
+	}, endpoints[0])
+	s.Equal(Endpoint{
+		CreateServiceURL:     "http://host2/reconfigure",
+		RemoveNodeURL:        "http://host2/removenode",
+		CreateServiceMethod:  "POST",
+		CreateServicePayload: "json",
+	}, endpoints[1])
+}
+
+func (s *ConfigTestSuite) writeFile(name, content string) string {
+	filename := filepath.Join(s.tempDir, name)
+	err := ioutil.WriteFile(filename, []byte(content), 0600)
+	s.Require().NoError(err)
+	return filename
+}
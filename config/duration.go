@@ -0,0 +1,73 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Duration is a `time.Duration` that is configured either with a string
+// like `500ms` or `1m30s`, or with a number of seconds
+type Duration struct {
+	time.Duration
+}
+
+// ParseDuration parses a duration string or a number of seconds
+func ParseDuration(value string) (Duration, error) {
+	var d Duration
+	if err := d.set(value); err != nil {
+		return Duration{}, err
+	}
+	return d, nil
+}
+
+func (d *Duration) set(value interface{}) error {
+	switch v := value.(type) {
+	case int:
+		d.Duration = time.Duration(v) * time.Second
+	case float64:
+		d.Duration = time.Duration(v * float64(time.Second))
+	case string:
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			d.Duration = time.Duration(seconds * float64(time.Second))
+			return nil
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", v)
+		}
+		d.Duration = parsed
+	default:
+		return fmt.Errorf("invalid duration %v", value)
+	}
+	return nil
+}
+
+// UnmarshalYAML implements `yaml.Unmarshaler`
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value interface{}
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	return d.set(value)
+}
+
+// MarshalYAML implements `yaml.Marshaler`
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// UnmarshalJSON implements `json.Unmarshaler`
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	return d.set(value)
+}
+
+// MarshalJSON implements `json.Marshaler`
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultLeaderLockName             = "docker-flow-swarm-listener-leader"
+	defaultLeaderTTL                  = 15 * time.Second
+	defaultLeaderRenewInterval        = 5 * time.Second
+	defaultLeaderCacheRefreshInterval = 30 * time.Second
+)
+
+// LeaderElection configures running several replicas of the swarm listener
+// Only the replica that holds the lock stored in Docker sends
+// notifications. The others keep their caches warm and serve read-only
+// requests.
+type LeaderElection struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	// ID identifies the replica. It defaults to the hostname.
+	ID string `json:"id,omitempty" yaml:"id,omitempty"`
+	// LockName is the name of the Docker config that stores the lock
+	LockName string `json:"lockName,omitempty" yaml:"lockName,omitempty"`
+	// TTL is the time after which a lock that is not renewed expires
+	TTL *Duration `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	// RenewInterval is the time between attempts to acquire or renew the
+	// lock. It must be shorter than `TTL`.
+	RenewInterval *Duration `json:"renewInterval,omitempty" yaml:"renewInterval,omitempty"`
+	// CacheRefreshInterval is the time between refreshes of the caches of
+	// followers
+	CacheRefreshInterval *Duration `json:"cacheRefreshInterval,omitempty" yaml:"cacheRefreshInterval,omitempty"`
+}
+
+// IsEnabled returns true when replicas elect a leader
+func (l LeaderElection) IsEnabled() bool {
+	return l.Enabled
+}
+
+// Lock returns the name of the lock, `docker-flow-swarm-listener-leader`
+// unless `LockName` is set
+func (l LeaderElection) Lock() string {
+	if len(l.LockName) == 0 {
+		return defaultLeaderLockName
+	}
+	return l.LockName
+}
+
+// LockTTL returns `TTL` or 15 seconds when it is not set
+func (l LeaderElection) LockTTL() time.Duration {
+	return durationOr(l.TTL, defaultLeaderTTL)
+}
+
+// LockRenewInterval returns `RenewInterval` or 5 seconds when it is not set
+func (l LeaderElection) LockRenewInterval() time.Duration {
+	return durationOr(l.RenewInterval, defaultLeaderRenewInterval)
+}
+
+// CacheRefresh returns `CacheRefreshInterval` or 30 seconds when it is not
+// set
+func (l LeaderElection) CacheRefresh() time.Duration {
+	return durationOr(l.CacheRefreshInterval, defaultLeaderCacheRefreshInterval)
+}
+
+func durationOr(d *Duration, fallback time.Duration) time.Duration {
+	if d == nil {
+		return fallback
+	}
+	return d.Duration
+}
+
+// Validate returns an error describing the first invalid value
+func (l LeaderElection) Validate() error {
+	durations := []struct {
+		name  string
+		value *Duration
+	}{
+		{"ttl", l.TTL},
+		{"renewInterval", l.RenewInterval},
+		{"cacheRefreshInterval", l.CacheRefreshInterval},
+	}
+	for _, d := range durations {
+		if d.value != nil && d.value.Duration <= 0 {
+			return fmt.Errorf("%s: must be positive, got %s", d.name, d.value)
+		}
+	}
+	if l.LockRenewInterval() >= l.LockTTL() {
+		return fmt.Errorf("renewInterval: must be shorter than ttl %s, got %s",
+			l.LockTTL(), l.LockRenewInterval())
+	}
+	return nil
+}
+
+// applyLeaderElectionEnv overrides `l` with `DF_LEADER_*` environment
+// variables
+func applyLeaderElectionEnv(l *LeaderElection) error {
+	if err := lookupBool("DF_LEADER_ELECTION", &l.Enabled); err != nil {
+		return err
+	}
+	lookupString("DF_LEADER_ID", &l.ID)
+	lookupString("DF_LEADER_LOCK_NAME", &l.LockName)
+	durations := []struct {
+		key   string
+		value **Duration
+	}{
+		{"DF_LEADER_TTL", &l.TTL},
+		{"DF_LEADER_RENEW_INTERVAL", &l.RenewInterval},
+		{"DF_LEADER_CACHE_REFRESH_INTERVAL", &l.CacheRefreshInterval},
+	}
+	for _, d := range durations {
+		if err := lookupDurationPtr(d.key, d.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
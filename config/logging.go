@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+)
+
+// Logging configures the log lines of the swarm listener
+type Logging struct {
+	// Level is one of debug, info, warn, or error
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// Format is logfmt or json
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// RedactLabels are the labels whose values are not logged
+	RedactLabels []string `json:"redactLabels,omitempty" yaml:"redactLabels,omitempty"`
+}
+
+// LogLevel returns the parsed `Level`, info when it is not set
+func (l Logging) LogLevel() logging.Level {
+	level, _ := logging.ParseLevel(l.Level)
+	return level
+}
+
+// LogFormat returns the parsed `Format`, logfmt when it is not set
+func (l Logging) LogFormat() logging.Format {
+	format, _ := logging.ParseFormat(l.Format)
+	return format
+}
+
+// Validate returns an error describing the first invalid value
+func (l Logging) Validate() error {
+	if _, err := logging.ParseLevel(l.Level); err != nil {
+		return fmt.Errorf("level: %v", err)
+	}
+	if _, err := logging.ParseFormat(l.Format); err != nil {
+		return fmt.Errorf("format: %v", err)
+	}
+	return nil
+}
+
+// applyLoggingEnv overrides `l` with `DF_LOG_*` environment variables
+func applyLoggingEnv(l *Logging) {
+	lookupString("DF_LOG_LEVEL", &l.Level)
+	lookupString("DF_LOG_FORMAT", &l.Format)
+	if v := os.Getenv("DF_LOG_REDACT_LABELS"); len(v) > 0 {
+		l.RedactLabels = []string{}
+		for _, label := range strings.Split(v, ",") {
+			if label = strings.TrimSpace(label); len(label) > 0 {
+				l.RedactLabels = append(l.RedactLabels, label)
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// RequestOptions configures the headers and the signature of notification
+// requests. Endpoints inherit the options of the enclosing configuration,
+// headers are merged with the endpoint headers taking precedence.
+type RequestOptions struct {
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// BearerToken is sent in the `Authorization` header
+	BearerToken string `json:"bearerToken,omitempty" yaml:"bearerToken,omitempty"`
+	// BearerTokenFile is read into `BearerToken` when the configuration
+	// is loaded
+	BearerTokenFile string `json:"bearerTokenFile,omitempty" yaml:"bearerTokenFile,omitempty"`
+	// SigningSecret is used to sign requests with HMAC-SHA256
+	SigningSecret string `json:"signingSecret,omitempty" yaml:"signingSecret,omitempty"`
+	// SigningSecretFile is read into `SigningSecret` when the configuration
+	// is loaded
+	SigningSecretFile string `json:"signingSecretFile,omitempty" yaml:"signingSecretFile,omitempty"`
+}
+
+// Validate returns an error describing the first invalid value
+func (o RequestOptions) Validate() error {
+	for name, value := range o.Headers {
+		if !isHeaderName(name) {
+			return fmt.Errorf("headers: invalid header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("headers.%s: must not contain line breaks", name)
+		}
+	}
+	if strings.ContainsAny(o.BearerToken, "\r\n") {
+		return fmt.Errorf("bearerToken: must not contain line breaks")
+	}
+	return nil
+}
+
+// readSecretFiles reads `BearerTokenFile` and `SigningSecretFile`
+func (o *RequestOptions) readSecretFiles() error {
+	files := []struct {
+		name     string
+		filename string
+		value    *string
+	}{
+		{"bearerTokenFile", o.BearerTokenFile, &o.BearerToken},
+		{"signingSecretFile", o.SigningSecretFile, &o.SigningSecret},
+	}
+	for _, f := range files {
+		if len(f.filename) == 0 {
+			continue
+		}
+		content, err := readSecretFile(f.filename)
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.name, err)
+		}
+		*f.value = content
+	}
+	return nil
+}
+
+// MergeRequestOptions merges `override` into `base`
+// Headers are merged regardless of the case of their names. The token and
+// the secret of `override` replace those of `base` when set.
+func MergeRequestOptions(base, override RequestOptions) RequestOptions {
+	if len(override.Headers) > 0 {
+		headers := map[string]string{}
+		for name, value := range base.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+		for name, value := range override.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+		base.Headers = headers
+	}
+	if len(override.BearerToken) > 0 || len(override.BearerTokenFile) > 0 {
+		base.BearerToken = override.BearerToken
+		base.BearerTokenFile = override.BearerTokenFile
+	}
+	if len(override.SigningSecret) > 0 || len(override.SigningSecretFile) > 0 {
+		base.SigningSecret = override.SigningSecret
+		base.SigningSecretFile = override.SigningSecretFile
+	}
+	return base
+}
+
+// applyRequestOptionsEnv overrides `o` with Docker secrets and then with
+// `DF_NOTIFY_*` environment variables
+func applyRequestOptionsEnv(o *RequestOptions) error {
+	if token := readStringFromFile("/run/secrets/df_notify_bearer_token"); len(token) > 0 {
+		o.BearerToken = token
+	}
+	if secret := readStringFromFile("/run/secrets/df_notify_signing_secret"); len(secret) > 0 {
+		o.SigningSecret = secret
+	}
+	lookupString("DF_NOTIFY_BEARER_TOKEN", &o.BearerToken)
+	lookupString("DF_NOTIFY_SIGNING_SECRET", &o.SigningSecret)
+
+	v := os.Getenv("DF_NOTIFY_HEADERS")
+	if len(v) == 0 {
+		return nil
+	}
+	headers := map[string]string{}
+	for name, value := range o.Headers {
+		headers[http.CanonicalHeaderKey(name)] = value
+	}
+	for _, header := range strings.Split(v, ",") {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("DF_NOTIFY_HEADERS: header %q must be formatted as name:value", header)
+		}
+		headers[http.CanonicalHeaderKey(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	o.Headers = headers
+	return nil
+}
+
+// isHeaderName returns true when `name` is a valid HTTP header field name
+func isHeaderName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
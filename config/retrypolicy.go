@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+)
+
+// retryPolicyKeys are the notifications retry policies can be set for
+var retryPolicyKeys = map[string]struct{}{
+	"createService": {},
+	"removeService": {},
+	"createNode":    {},
+	"removeNode":    {},
+}
+
+// RetryPolicy configures the backoff between notification retries
+// Unset values are inherited from the enclosing configuration
+type RetryPolicy struct {
+	Multiplier           *float64  `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`
+	MaxDelay             *Duration `json:"maxDelay,omitempty" yaml:"maxDelay,omitempty"`
+	Jitter               *float64  `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+	MaxElapsedTime       *Duration `json:"maxElapsedTime,omitempty" yaml:"maxElapsedTime,omitempty"`
+	RetryableStatusCodes []int     `json:"retryableStatusCodes,omitempty" yaml:"retryableStatusCodes,omitempty"`
+}
+
+// EventRetryPolicy configures retries of a single kind of notification
+// `Retry` and `InitialDelay` override the `retry` and `retryInterval`
+// of the endpoint
+type EventRetryPolicy struct {
+	Retry        *int      `json:"retry,omitempty" yaml:"retry,omitempty"`
+	InitialDelay *Duration `json:"initialDelay,omitempty" yaml:"initialDelay,omitempty"`
+	RetryPolicy  `yaml:",inline"`
+}
+
+// Validate returns an error describing the first invalid value
+func (p RetryPolicy) Validate() error {
+	if p.Multiplier != nil && *p.Multiplier < 1 {
+		return fmt.Errorf("multiplier: must be at least 1, got %g", *p.Multiplier)
+	}
+	if p.MaxDelay != nil && p.MaxDelay.Duration < 0 {
+		return fmt.Errorf("maxDelay: must not be negative, got %s", p.MaxDelay)
+	}
+	if p.Jitter != nil && (*p.Jitter < 0 || *p.Jitter > 1) {
+		return fmt.Errorf("jitter: must be between 0 and 1, got %g", *p.Jitter)
+	}
+	if p.MaxElapsedTime != nil && p.MaxElapsedTime.Duration < 0 {
+		return fmt.Errorf("maxElapsedTime: must not be negative, got %s", p.MaxElapsedTime)
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("retryableStatusCodes: invalid status code %d", code)
+		}
+	}
+	return nil
+}
+
+// Validate returns an error describing the first invalid value
+func (p EventRetryPolicy) Validate() error {
+	if p.Retry != nil && *p.Retry < 0 {
+		return fmt.Errorf("retry: must not be negative, got %d", *p.Retry)
+	}
+	if p.InitialDelay != nil && p.InitialDelay.Duration < 0 {
+		return fmt.Errorf("initialDelay: must not be negative, got %s", p.InitialDelay)
+	}
+	return p.RetryPolicy.Validate()
+}
+
+// applyRetryPolicyEnv overrides `p` with `DF_RETRY_*` environment variables
+func applyRetryPolicyEnv(p *RetryPolicy) error {
+	if err := lookupFloatPtr("DF_RETRY_MULTIPLIER", &p.Multiplier); err != nil {
+		return err
+	}
+	if err := lookupDurationPtr("DF_RETRY_MAX_DELAY", &p.MaxDelay); err != nil {
+		return err
+	}
+	if err := lookupFloatPtr("DF_RETRY_JITTER", &p.Jitter); err != nil {
+		return err
+	}
+	if err := lookupDurationPtr("DF_RETRY_MAX_ELAPSED_TIME", &p.MaxElapsedTime); err != nil {
+		return err
+	}
+	return lookupIntList("DF_RETRY_STATUS_CODES", &p.RetryableStatusCodes)
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+)
+
+// tlsVersions are the supported values of `minVersion`
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLS configures connections to HTTPS notification endpoints
+// Files are read when notification endpoints are created
+type TLS struct {
+	// CAFile is a PEM bundle of the certificate authorities trusted in
+	// addition to the system ones
+	CAFile string `json:"caFile,omitempty" yaml:"caFile,omitempty"`
+	// CertFile and KeyFile are the PEM client certificate and key used
+	// for mutual TLS
+	CertFile   string `json:"certFile,omitempty" yaml:"certFile,omitempty"`
+	KeyFile    string `json:"keyFile,omitempty" yaml:"keyFile,omitempty"`
+	ServerName string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+	// MinVersion is one of 1.0, 1.1, 1.2, or 1.3
+	MinVersion string `json:"minVersion,omitempty" yaml:"minVersion,omitempty"`
+}
+
+// IsEmpty returns true when no TLS setting is configured
+func (t TLS) IsEmpty() bool {
+	return t == TLS{}
+}
+
+// MinTLSVersion returns the `crypto/tls` value of `MinVersion` or zero
+// when it is not set
+func (t TLS) MinTLSVersion() uint16 {
+	return tlsVersions[t.MinVersion]
+}
+
+// Validate returns an error describing the first invalid value
+func (t TLS) Validate() error {
+	if len(t.MinVersion) > 0 {
+		if _, ok := tlsVersions[t.MinVersion]; !ok {
+			return fmt.Errorf("minVersion: must be 1.0, 1.1, 1.2, or 1.3, got %q", t.MinVersion)
+		}
+	}
+	if len(t.CertFile) > 0 && len(t.KeyFile) == 0 {
+		return fmt.Errorf("keyFile: must be set with certFile")
+	}
+	if len(t.KeyFile) > 0 && len(t.CertFile) == 0 {
+		return fmt.Errorf("certFile: must be set with keyFile")
+	}
+	return nil
+}
+
+// applyTLSEnv overrides `t` with Docker secrets and then with
+// `DF_NOTIFY_TLS_*` environment variables
+func applyTLSEnv(t *TLS) {
+	secrets := []struct {
+		filename string
+		value    *string
+	}{
+		{"/run/secrets/df_notify_tls_ca", &t.CAFile},
+		{"/run/secrets/df_notify_tls_cert", &t.CertFile},
+		{"/run/secrets/df_notify_tls_key", &t.KeyFile},
+	}
+	for _, secret := range secrets {
+		if _, err := os.Stat(secret.filename); err == nil {
+			*secret.value = secret.filename
+		}
+	}
+
+	lookupString("DF_NOTIFY_TLS_CA_FILE", &t.CAFile)
+	lookupString("DF_NOTIFY_TLS_CERT_FILE", &t.CertFile)
+	lookupString("DF_NOTIFY_TLS_KEY_FILE", &t.KeyFile)
+	lookupString("DF_NOTIFY_TLS_SERVER_NAME", &t.ServerName)
+	lookupString("DF_NOTIFY_TLS_MIN_VERSION", &t.MinVersion)
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Tracing configures the export of traces to an OpenTelemetry collector
+// Tracing is disabled when `Endpoint` is empty.
+type Tracing struct {
+	// Endpoint is the OTLP/HTTP traces url of the collector
+	Endpoint    string            `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	ServiceName string            `json:"serviceName,omitempty" yaml:"serviceName,omitempty"`
+	SampleRatio *float64          `json:"sampleRatio,omitempty" yaml:"sampleRatio,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// IsEnabled returns true when traces are exported
+func (t Tracing) IsEnabled() bool {
+	return len(t.Endpoint) > 0
+}
+
+// Ratio returns the share of events that are traced, all of them unless
+// `SampleRatio` is set
+func (t Tracing) Ratio() float64 {
+	if t.SampleRatio == nil {
+		return 1
+	}
+	return *t.SampleRatio
+}
+
+// Validate returns an error describing the first invalid value
+func (t Tracing) Validate() error {
+	if len(t.Endpoint) > 0 {
+		urlObj, err := url.Parse(t.Endpoint)
+		if err != nil {
+			return fmt.Errorf("endpoint: %v", err)
+		}
+		if urlObj.Scheme != "http" && urlObj.Scheme != "https" {
+			return fmt.Errorf("endpoint: scheme must be http or https, got %q", t.Endpoint)
+		}
+	}
+	if t.SampleRatio != nil && (*t.SampleRatio < 0 || *t.SampleRatio > 1) {
+		return fmt.Errorf("sampleRatio: must be between 0 and 1, got %v", *t.SampleRatio)
+	}
+	return nil
+}
+
+// applyTracingEnv overrides `t` with `DF_TRACING_*` environment variables
+// The endpoint falls back to the standard OpenTelemetry variables.
+func applyTracingEnv(t *Tracing) error {
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); len(v) > 0 {
+		t.Endpoint = strings.TrimSuffix(v, "/") + "/v1/traces"
+	}
+	lookupString("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", &t.Endpoint)
+	lookupString("OTEL_SERVICE_NAME", &t.ServiceName)
+	lookupString("DF_TRACING_ENDPOINT", &t.Endpoint)
+	lookupString("DF_TRACING_SERVICE_NAME", &t.ServiceName)
+	return lookupFloatPtr("DF_TRACING_SAMPLE_RATIO", &t.SampleRatio)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+)
+
+// healthcheckTimeout is the time the health check waits for the API
+var healthcheckTimeout = 5 * time.Second
+
+// healthcheck requests the health route of the API configured in `c` on
+// the local host. It returns an error when the API cannot be reached or
+// reports that the listener is not healthy.
+func healthcheck(c config.API) error {
+	req, err := http.NewRequest(http.MethodGet, healthcheckURL(c), nil)
+	if err != nil {
+		return err
+	}
+	if len(c.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	} else if len(c.Username) > 0 {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	client := &http.Client{Timeout: healthcheckTimeout}
+	if c.TLS.IsEnabled() {
+		// The certificate of the API is not verified since the request
+		// does not leave the host and the certificate is rarely issued
+		// for localhost
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+		if len(c.TLS.ClientCAFile) > 0 {
+			cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+			if err != nil {
+				return fmt.Errorf("Unable to load the API certificate: %v", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Health check returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// healthcheckURL returns the url of the health route of the API that
+// listens on `c.Address`. Wildcard addresses are reached through localhost.
+func healthcheckURL(c config.API) string {
+	scheme := "http"
+	if c.TLS.IsEnabled() {
+		scheme = "https"
+	}
+	address := c.Address
+	if host, port, err := net.SplitHostPort(c.Address); err == nil {
+		if ip := net.ParseIP(host); len(host) == 0 || (ip != nil && ip.IsUnspecified()) {
+			host = "localhost"
+		}
+		address = net.JoinHostPort(host, port)
+	}
+	return fmt.Sprintf("%s://%s/v1/docker-flow-swarm-listener/health", scheme, address)
+}
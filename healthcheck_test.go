@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+)
+
+type HealthcheckTestSuite struct {
+	suite.Suite
+}
+
+func TestHealthcheckUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthcheckTestSuite))
+}
+
+func (s *HealthcheckTestSuite) Test_HealthcheckURL_UsesAddressAndScheme() {
+	testCases := []struct {
+		api      config.API
+		expected string
+	}{
+		{config.API{Address: ":8080"}, "http://localhost:8080/v1/docker-flow-swarm-listener/health"},
+		{config.API{Address: "0.0.0.0:9000"}, "http://localhost:9000/v1/docker-flow-swarm-listener/health"},
+		{config.API{Address: "[::]:9000"}, "http://localhost:9000/v1/docker-flow-swarm-listener/health"},
+		{config.API{Address: "127.0.0.1:9000"}, "http://127.0.0.1:9000/v1/docker-flow-swarm-listener/health"},
+		{
+			config.API{Address: ":8443", TLS: config.APITLS{CertFile: "cert.pem", KeyFile: "key.pem"}},
+			"https://localhost:8443/v1/docker-flow-swarm-listener/health",
+		},
+	}
+	for _, tc := range testCases {
+		s.Equal(tc.expected, healthcheckURL(tc.api), tc.api.Address)
+	}
+}
+
+func (s *HealthcheckTestSuite) Test_Healthcheck_ReturnsNil_WhenHealthy() {
+	var path, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	err := healthcheck(config.API{Address: strings.TrimPrefix(srv.URL, "http://"), Token: "my-token"})
+	s.Require().NoError(err)
+	s.Equal("/v1/docker-flow-swarm-listener/health", path)
+	s.Equal("Bearer my-token", auth)
+}
+
+func (s *HealthcheckTestSuite) Test_Healthcheck_ReturnsError_WhenNotHealthy() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	err := healthcheck(config.API{Address: strings.TrimPrefix(srv.URL, "http://")})
+	s.EqualError(err, "Health check returned status code 503")
+}
+
+func (s *HealthcheckTestSuite) Test_Healthcheck_UsesHTTPS_WhenTLSIsEnabled() {
+	var username, password string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ = r.BasicAuth()
+	}))
+	defer srv.Close()
+
+	err := healthcheck(config.API{
+		Address:  strings.TrimPrefix(srv.URL, "https://"),
+		TLS:      config.APITLS{CertFile: "cert.pem", KeyFile: "key.pem"},
+		Username: "admin",
+		Password: "secret",
+	})
+	s.Require().NoError(err)
+	s.Equal("admin", username)
+	s.Equal("secret", password)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+)
+
+const notificationHistoryPathV2 = apiV2Path + "/notifications/history"
+
+var auditStatuses = map[string]struct{}{
+	service.AuditStatusSuccess:  {},
+	service.AuditStatusFailure:  {},
+	service.AuditStatusCanceled: {},
+}
+
+// NotificationHistoryV2 is a page of audited deliveries
+type NotificationHistoryV2 struct {
+	Items []service.AuditRecord `json:"items"`
+	Total int                   `json:"total"`
+}
+
+// GetNotificationHistory retrieves the audited deliveries of notifications,
+// newest first. They are filtered by `service`, `endpoint`, `status`,
+// `since`, and `until`, and paged as requested in the query.
+func (m Serve) GetNotificationHistory(w http.ResponseWriter, req *http.Request) {
+	if !allowMethodV2(w, req, http.MethodGet) {
+		return
+	}
+	query := req.URL.Query()
+	filter, err := parseAuditFilter(query)
+	if err != nil {
+		writeBadRequestV2(w, err)
+		return
+	}
+	options, err := parseListOptions(query)
+	if err != nil {
+		writeBadRequestV2(w, err)
+		return
+	}
+
+	records, err := m.SwarmListener.GetNotificationHistory(filter)
+	if err == service.ErrAuditDisabled {
+		writeErrorV2(w, http.StatusNotFound, errorCodeNotFound, err.Error())
+		return
+	} else if err != nil {
+		m.writeServerErrorV2(w, err, "serveGetNotificationHistory")
+		return
+	}
+	if records == nil {
+		records = []service.AuditRecord{}
+	}
+	start, end := options.page(len(records))
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(records)))
+	m.writeJSONV2(w, http.StatusOK,
+		NotificationHistoryV2{Items: records[start:end], Total: len(records)}, "serveGetNotificationHistory")
+}
+
+// parseAuditFilter reads the filter of audited deliveries from `query`
+// `since` and `until` are RFC 3339 times.
+func parseAuditFilter(query url.Values) (service.AuditFilter, error) {
+	filter := service.AuditFilter{
+		Service:  query.Get("service"),
+		Endpoint: query.Get("endpoint"),
+		Status:   query.Get("status"),
+	}
+	if _, ok := auditStatuses[filter.Status]; len(filter.Status) > 0 && !ok {
+		return filter, fmt.Errorf("status must be success, failure, or canceled")
+	}
+	for _, t := range []struct {
+		key   string
+		value *time.Time
+	}{
+		{"since", &filter.Since},
+		{"until", &filter.Until},
+	} {
+		value := query.Get(t.key)
+		if len(value) == 0 {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be a RFC 3339 time", t.key)
+		}
+		*t.value = parsed
+	}
+	return filter, nil
+}
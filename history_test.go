@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+	"github.com/stretchr/testify/mock"
+)
+
+func (s *ServerTestSuite) Test_RestHistory_RoutesTo_GetNotificationHistory() {
+	sm := new(serverMock)
+	sm.On("GetNotificationHistory", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	mux.ServeHTTP(httptest.NewRecorder(),
+		httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/notifications/history", nil))
+
+	sm.AssertCalled(s.T(), "GetNotificationHistory", mock.Anything, mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_GetNotificationHistory_ReturnsFilteredRecords() {
+	since := time.Date(2018, 10, 27, 10, 0, 0, 0, time.UTC)
+	records := []service.AuditRecord{
+		{
+			Time:       since.Add(2 * time.Minute),
+			RequestID:  2,
+			Kind:       service.QueueKindService,
+			EventType:  service.EventTypeCreate,
+			ID:         "sid1",
+			Name:       "go",
+			Parameters: map[string]string{"serviceName": "go"},
+			Endpoint:   "proxy:8080",
+			Attempts:   3,
+			StatusCode: 500,
+			Status:     service.AuditStatusFailure,
+			Error:      "status code 500",
+		},
+		{
+			Time:         since.Add(time.Minute),
+			RequestID:    1,
+			Kind:         service.QueueKindService,
+			EventType:    service.EventTypeCreate,
+			ID:           "sid1",
+			Name:         "go",
+			Endpoint:     "proxy:8080",
+			Attempts:     1,
+			StatusCode:   200,
+			Status:       service.AuditStatusSuccess,
+			ResponseBody: "OK",
+		},
+	}
+	s.SLMock.On("GetNotificationHistory", service.AuditFilter{
+		Service:  "go",
+		Endpoint: "proxy",
+		Since:    since,
+	}).Return(records, nil)
+	req := httptest.NewRequest("GET",
+		"/v2/docker-flow-swarm-listener/notifications/history?service=go&endpoint=proxy&since=2018-10-27T10:00:00Z&limit=1",
+		nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetNotificationHistory(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("2", w.Header().Get("X-Total-Count"))
+	s.JSONEq(`{
+		"items": [{
+			"time": "2018-10-27T10:02:00Z",
+			"requestId": 2,
+			"kind": "service",
+			"eventType": "create",
+			"id": "sid1",
+			"name": "go",
+			"parameters": {"serviceName": "go"},
+			"endpoint": "proxy:8080",
+			"attempts": 3,
+			"statusCode": 500,
+			"status": "failure",
+			"error": "status code 500"
+		}],
+		"total": 2
+	}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_GetNotificationHistory_ReturnsStatus400_WhenFilterIsInvalid() {
+	for _, query := range []string{"status=sent", "since=yesterday", "until=2018-10-27", "limit=-1"} {
+		req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/notifications/history?"+query, nil)
+		w := httptest.NewRecorder()
+
+		srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+		srv.GetNotificationHistory(w, req)
+
+		s.Equal(http.StatusBadRequest, w.Code, query)
+	}
+	s.SLMock.AssertNotCalled(s.T(), "GetNotificationHistory", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_GetNotificationHistory_ReturnsStatus404_WhenAuditIsDisabled() {
+	s.SLMock.On("GetNotificationHistory", service.AuditFilter{}).Return([]service.AuditRecord(nil), service.ErrAuditDisabled)
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/notifications/history", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetNotificationHistory(w, req)
+
+	s.Equal(http.StatusNotFound, w.Code)
+	s.JSONEq(`{"error": {"status": 404, "code": "notFound", "message": "Notification history is disabled"}}`,
+		w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_GetNotificationHistory_ReturnsStatus500_WhenAuditLogCannotBeRead() {
+	s.SLMock.On("GetNotificationHistory", service.AuditFilter{Status: "success"}).
+		Return([]service.AuditRecord(nil), errors.New("Unable to read audit file"))
+	req := httptest.NewRequest("GET", "/v2/docker-flow-swarm-listener/notifications/history?status=success", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetNotificationHistory(w, req)
+
+	s.Equal(http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+// leaderRoutes are the routes that send notifications, which only the
+// leader does
+var leaderRoutes = map[string]struct{}{
+	"/v1/docker-flow-swarm-listener/notify-services": {},
+	"/v1/docker-flow-swarm-listener/notify-nodes":    {},
+	"/v1/docker-flow-swarm-listener/resync":          {},
+	notifyServicesPathV2:                             {},
+	notifyNodesPathV2:                                {},
+	resyncPathV2:                                     {},
+}
+
+// leading reports whether the replica is the leader
+type leading interface {
+	IsLeader() bool
+}
+
+// followerGuard rejects requests that followers do not serve
+// Followers serve read only requests and reload their endpoints, so that
+// they are ready to lead.
+type followerGuard struct {
+	leader  leading
+	handler http.Handler
+}
+
+// newFollowerGuard wraps `handler` with a guard for followers
+func newFollowerGuard(leader leading, handler http.Handler) http.Handler {
+	return &followerGuard{leader: leader, handler: handler}
+}
+
+func (g *followerGuard) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !requiresLeader(req) || g.leader.IsLeader() {
+		g.handler.ServeHTTP(w, req)
+		return
+	}
+	metrics.RecordError("serveNotLeader")
+	message := "This replica is not the leader"
+	if strings.HasPrefix(req.URL.Path, apiV2Path+"/") {
+		writeErrorV2(w, http.StatusServiceUnavailable, errorCodeNotLeader, message)
+		return
+	}
+	js, _ := json.Marshal(Response{Status: "NOK", Message: message})
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write(js)
+}
+
+// requiresLeader returns true when `req` sends notifications or changes
+// the caches, which are refreshed from Docker on followers
+func requiresLeader(req *http.Request) bool {
+	if strings.HasPrefix(req.URL.Path, adminPathV2+"/") {
+		return req.Method != http.MethodGet && req.Method != http.MethodHead
+	}
+	_, ok := leaderRoutes[req.URL.Path]
+	return ok
+}
+
+// IsLeader returns true when the swarm listener is the leader
+func (m Serve) IsLeader() bool {
+	return m.SwarmListener.IsLeader()
+}
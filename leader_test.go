@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FollowerGuardTestSuite struct {
+	suite.Suite
+	handler http.Handler
+	served  []string
+	sm      *serverMock
+}
+
+func TestFollowerGuardUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(FollowerGuardTestSuite))
+}
+
+func (s *FollowerGuardTestSuite) SetupTest() {
+	s.served = []string{}
+	s.handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.served = append(s.served, req.Method+" "+req.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	})
+	s.sm = new(serverMock)
+}
+
+func (s *FollowerGuardTestSuite) Test_ServeHTTP_ServesAllRoutes_OnTheLeader() {
+	s.sm.On("IsLeader").Return(true)
+	guard := newFollowerGuard(s.sm, s.handler)
+
+	rsp := s.serve(guard, "POST", "/v2/docker-flow-swarm-listener/notify/services")
+
+	s.Equal(http.StatusOK, rsp.Code)
+	s.Equal([]string{"POST /v2/docker-flow-swarm-listener/notify/services"}, s.served)
+}
+
+func (s *FollowerGuardTestSuite) Test_ServeHTTP_RejectsNotifications_OnFollowers() {
+	s.sm.On("IsLeader").Return(false)
+	guard := newFollowerGuard(s.sm, s.handler)
+
+	for _, path := range []string{
+		"/v1/docker-flow-swarm-listener/notify-services",
+		"/v1/docker-flow-swarm-listener/notify-nodes",
+		"/v1/docker-flow-swarm-listener/resync",
+	} {
+		rsp := s.serve(guard, "GET", path)
+		s.Equal(http.StatusServiceUnavailable, rsp.Code, path)
+		s.JSONEq(`{"Status":"NOK","Message":"This replica is not the leader"}`, rsp.Body.String())
+	}
+	for _, r := range []struct{ method, path string }{
+		{"POST", "/v2/docker-flow-swarm-listener/notify/services"},
+		{"POST", "/v2/docker-flow-swarm-listener/notify/nodes"},
+		{"POST", "/v2/docker-flow-swarm-listener/resync"},
+		{"DELETE", "/v2/docker-flow-swarm-listener/admin/cache/services"},
+		{"DELETE", "/v2/docker-flow-swarm-listener/admin/cache/nodes/node1"},
+	} {
+		rsp := s.serve(guard, r.method, r.path)
+		s.Equal(http.StatusServiceUnavailable, rsp.Code, r.path)
+		s.JSONEq(`{"error": {"status": 503, "code": "notLeader", "message": "This replica is not the leader"}}`,
+			rsp.Body.String())
+	}
+	s.Empty(s.served)
+}
+
+func (s *FollowerGuardTestSuite) Test_ServeHTTP_ServesReadOnlyRoutes_OnFollowers() {
+	s.sm.On("IsLeader").Return(false)
+	guard := newFollowerGuard(s.sm, s.handler)
+	requests := []string{
+		"GET /v1/docker-flow-swarm-listener/get-services",
+		"GET /v1/docker-flow-swarm-listener/reload-endpoints",
+		"GET /v1/docker-flow-swarm-listener/health",
+		"GET /v2/docker-flow-swarm-listener/services",
+		"GET /v2/docker-flow-swarm-listener/admin/cache/services",
+		"GET /v2/docker-flow-swarm-listener/admin/cache/services/web/diff",
+		"GET /metrics",
+	}
+
+	for _, r := range requests {
+		method, path := r[:3], r[4:]
+		rsp := s.serve(guard, method, path)
+		s.Equal(http.StatusOK, rsp.Code, path)
+	}
+	s.Equal(requests, s.served)
+}
+
+func (s *FollowerGuardTestSuite) serve(handler http.Handler, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	rsp := httptest.NewRecorder()
+	handler.ServeHTTP(rsp, req)
+	return rsp
+}
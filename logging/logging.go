@@ -0,0 +1,308 @@
+// Package logging writes leveled, structured log lines in the logfmt or
+// JSON format.
+//
+// A line has a time, a level, a message and fields given as alternating
+// keys and values:
+//
+//	logger.Info("Sending notification", "endpoint", host, "attempt", 1)
+//
+// writes, in the logfmt format,
+//
+//	time=2018-10-27T10:00:00.000Z level=info msg="Sending notification" endpoint=proxy:8080 attempt=1
+//
+// Loggers returned by `With` add fields to every line, and fields stored
+// in a context with `ContextWithFields` are added by `WithContext`.
+// Credentials in urls and the values of sensitive labels are redacted
+// before a line is written.
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Level is the severity of a log line
+type Level int
+
+const (
+	// LevelDebug is used for details that help to trace the work done
+	LevelDebug Level = iota
+	// LevelInfo is used for the normal operation
+	LevelInfo
+	// LevelWarn is used for failures that are retried or recovered from
+	LevelWarn
+	// LevelError is used for failures
+	LevelError
+)
+
+// String returns the name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// ParseLevel returns the level called `name`
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("level must be debug, info, warn, or error, got %q", name)
+}
+
+// Format is the encoding of log lines
+type Format string
+
+const (
+	// FormatLogfmt writes lines as space separated `key=value` pairs
+	FormatLogfmt Format = "logfmt"
+	// FormatJSON writes lines as JSON objects
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the format called `name`
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatLogfmt, "":
+		return FormatLogfmt, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	}
+	return FormatLogfmt, fmt.Errorf("format must be logfmt or json, got %q", name)
+}
+
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// output is the destination shared by a logger and the loggers derived
+// from it
+type output struct {
+	w      io.Writer
+	format Format
+	level  Level
+	now    func() time.Time
+	mux    sync.Mutex
+}
+
+// Logger writes log lines with fields
+// A nil `*Logger` discards all lines.
+type Logger struct {
+	out      *output
+	redactor *redactor
+	fields   []interface{}
+}
+
+// New returns a logger that writes lines of `level` and above to `w`
+func New(w io.Writer, format Format, level Level) *Logger {
+	return &Logger{
+		out: &output{
+			w:      w,
+			format: format,
+			level:  level,
+			now:    time.Now,
+		},
+		redactor: &redactor{},
+	}
+}
+
+// With returns a logger that adds the fields `keyvals` to every line
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	if l == nil || len(keyvals) == 0 {
+		return l
+	}
+	fields := make([]interface{}, 0, len(l.fields)+len(keyvals))
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+	return &Logger{out: l.out, redactor: l.redactor, fields: fields}
+}
+
+// WithContext returns a logger that adds the fields stored in `ctx` to
+// every line
+func (l *Logger) WithContext(ctx context.Context) *Logger {
+	return l.With(FieldsFromContext(ctx)...)
+}
+
+// WithRedactedLabels returns a logger that redacts the values of `labels`
+// in urls and url values. The `com.df.` prefix of a label is optional, so
+// that the parameters of notifications are redacted as well.
+func (l *Logger) WithRedactedLabels(labels []string) *Logger {
+	if l == nil {
+		return l
+	}
+	return &Logger{out: l.out, redactor: newRedactor(labels), fields: l.fields}
+}
+
+// RedactValues returns a copy of `values` with redacted values of
+// sensitive labels, so that they are stored like they are logged
+func (l *Logger) RedactValues(values url.Values) url.Values {
+	if l == nil {
+		return values
+	}
+	return l.redactor.Values(values)
+}
+
+// RedactString returns `s` with redacted urls
+func (l *Logger) RedactString(s string) string {
+	if l == nil {
+		return s
+	}
+	return l.redactor.String(s)
+}
+
+// Enabled returns true when lines of `level` are written
+func (l *Logger) Enabled(level Level) bool {
+	return l != nil && level >= l.out.level
+}
+
+// Debug writes `msg` with the fields `keyvals` at the debug level
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.log(LevelDebug, msg, keyvals)
+}
+
+// Info writes `msg` with the fields `keyvals` at the info level
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.log(LevelInfo, msg, keyvals)
+}
+
+// Warn writes `msg` with the fields `keyvals` at the warn level
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.log(LevelWarn, msg, keyvals)
+}
+
+// Error writes `msg` with the fields `keyvals` at the error level
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.log(LevelError, msg, keyvals)
+}
+
+func (l *Logger) log(level Level, msg string, keyvals []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	fields := make([]interface{}, 0, 6+len(l.fields)+len(keyvals))
+	fields = append(fields,
+		"time", l.out.now().Format(timeFormat),
+		"level", level.String(),
+		"msg", msg)
+	fields = append(fields, l.fields...)
+	fields = append(fields, keyvals...)
+	if len(fields)%2 != 0 {
+		fields = append(fields, nil)
+	}
+
+	var buf bytes.Buffer
+	if l.out.format == FormatJSON {
+		l.encodeJSON(&buf, fields)
+	} else {
+		l.encodeLogfmt(&buf, fields)
+	}
+	buf.WriteByte('\n')
+
+	l.out.mux.Lock()
+	defer l.out.mux.Unlock()
+	l.out.w.Write(buf.Bytes())
+}
+
+func (l *Logger) encodeLogfmt(buf *bytes.Buffer, fields []interface{}) {
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(logfmtKey(fmt.Sprint(fields[i])))
+		buf.WriteByte('=')
+		switch value := l.redactor.value(fields[i+1]).(type) {
+		case nil:
+			buf.WriteString("null")
+		case string:
+			buf.WriteString(logfmtValue(value))
+		default:
+			buf.WriteString(fmt.Sprint(value))
+		}
+	}
+}
+
+func (l *Logger) encodeJSON(buf *bytes.Buffer, fields []interface{}) {
+	buf.WriteByte('{')
+	for i := 0; i < len(fields); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(fmt.Sprint(fields[i]))
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(l.redactor.value(fields[i+1]))
+		if err != nil {
+			// NaN and infinite floats
+			value, _ = json.Marshal(fmt.Sprint(fields[i+1]))
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+}
+
+// logfmtKey removes the characters that are not allowed in keys
+func logfmtKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue quotes `value` when it is empty or contains spaces, quotes,
+// equal signs, or control characters
+func logfmtValue(value string) string {
+	if len(value) == 0 {
+		return `""`
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r) {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+type fieldsKey struct{}
+
+// ContextWithFields returns a copy of `ctx` that stores the fields
+// `keyvals` in addition to the fields already stored in `ctx`
+func ContextWithFields(ctx context.Context, keyvals ...interface{}) context.Context {
+	fields := FieldsFromContext(ctx)
+	merged := make([]interface{}, 0, len(fields)+len(keyvals))
+	merged = append(merged, fields...)
+	merged = append(merged, keyvals...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FieldsFromContext returns the fields stored in `ctx`
+func FieldsFromContext(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).([]interface{})
+	return fields
+}
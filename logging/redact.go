@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Redacted replaces the values that are not logged
+	Redacted = "REDACTED"
+
+	labelPrefix = "com.df."
+)
+
+// urlPattern matches urls inside of messages and errors
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// redactor removes credentials and the values of sensitive labels from
+// field values
+type redactor struct {
+	labels map[string]struct{}
+}
+
+// newRedactor returns a redactor of the values of `labels`
+func newRedactor(labels []string) *redactor {
+	r := &redactor{labels: map[string]struct{}{}}
+	for _, label := range labels {
+		label = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(label)), labelPrefix)
+		if len(label) > 0 {
+			r.labels[label] = struct{}{}
+		}
+	}
+	return r
+}
+
+// isSensitive returns true when the value of the label or parameter `key`
+// is redacted
+func (r *redactor) isSensitive(key string) bool {
+	_, ok := r.labels[strings.TrimPrefix(strings.ToLower(key), labelPrefix)]
+	return ok
+}
+
+// value returns `v` as a string with redacted urls and label values
+// Booleans and numbers are returned as they are.
+func (r *redactor) value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case bool, int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v
+	case string:
+		return r.String(v)
+	case *url.URL:
+		if v == nil {
+			return nil
+		}
+		return r.URL(v)
+	case url.Values:
+		return r.Values(v).Encode()
+	case map[string]string:
+		redacted := make(map[string]string, len(v))
+		for key, value := range v {
+			if r.isSensitive(key) {
+				value = Redacted
+			}
+			redacted[key] = value
+		}
+		return fmt.Sprint(redacted)
+	case error:
+		return r.String(v.Error())
+	case fmt.Stringer:
+		return r.String(v.String())
+	}
+	return r.String(fmt.Sprint(v))
+}
+
+// String redacts the urls found in `s`
+func (r *redactor) String(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	return urlPattern.ReplaceAllStringFunc(s, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return raw
+		}
+		return r.URL(u)
+	})
+}
+
+// URL returns `u` without the password and with redacted values of
+// sensitive query parameters
+func (r *redactor) URL(u *url.URL) string {
+	redacted := *u
+	if _, ok := u.User.Password(); ok {
+		redacted.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	if len(u.RawQuery) > 0 && len(r.labels) > 0 {
+		if query, err := url.ParseQuery(u.RawQuery); err == nil && r.hasSensitive(query) {
+			redacted.RawQuery = r.Values(query).Encode()
+		}
+	}
+	return redacted.String()
+}
+
+// Values returns a copy of `values` with redacted sensitive values
+func (r *redactor) Values(values url.Values) url.Values {
+	if !r.hasSensitive(values) {
+		return values
+	}
+	redacted := url.Values{}
+	for key, vs := range values {
+		if !r.isSensitive(key) {
+			redacted[key] = vs
+			continue
+		}
+		for range vs {
+			redacted.Add(key, Redacted)
+		}
+	}
+	return redacted
+}
+
+func (r *redactor) hasSensitive(values url.Values) bool {
+	for key := range values {
+		if r.isSensitive(key) {
+			return true
+		}
+	}
+	return false
+}
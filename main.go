@@ -1,31 +1,103 @@
 package main
 
 import (
-	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 	"github.com/docker-flow/docker-flow-swarm-listener/service"
+	"github.com/docker-flow/docker-flow-swarm-listener/tracing"
 )
 
 func main() {
-	l := log.New(os.Stdout, "", log.LstdFlags)
+	// Lines are written with the default settings until the configuration
+	// is loaded
+	l := logging.New(os.Stdout, logging.FormatLogfmt, logging.LevelInfo)
 
-	l.Printf("Starting Docker Flow: Swarm Listener")
-	args := getArgs()
-	swarmListener, err := service.NewSwarmListenerFromEnv(
-		args.Retry, args.RetryInterval,
-		args.ServicePollingInterval, args.NodePollingInterval, l)
+	args, err := getArgs(os.Args[1:])
 	if err != nil {
-		l.Printf("Failed to initialize Docker Flow: Swarm Listener")
-		l.Printf("ERROR: %v", err)
+		l.Error("Invalid arguments", "error", err)
+		os.Exit(1)
+	}
+	if !args.Healthcheck {
+		l.Info("Starting Docker Flow: Swarm Listener")
+	}
+	c, err := config.Load(args.ConfigFile)
+	if err != nil {
+		l.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+	if args.Healthcheck {
+		if err := healthcheck(c.API); err != nil {
+			l.Error("Health check failed", "error", err)
+			os.Exit(1)
+		}
 		return
 	}
+	l = logging.New(os.Stdout, c.Logging.LogFormat(), c.Logging.LogLevel()).
+		WithRedactedLabels(c.Logging.RedactLabels)
+	if c.Tracing.IsEnabled() {
+		serviceName := c.Tracing.ServiceName
+		if len(serviceName) == 0 {
+			serviceName = tracing.DefaultServiceName
+		}
+		exporter := tracing.NewOTLPExporter(c.Tracing.Endpoint, serviceName, c.Tracing.Headers)
+		tracing.SetTracer(tracing.NewTracer(exporter, c.Tracing.Ratio(), l))
+		l.Info("Exporting traces", "endpoint", c.Tracing.Endpoint)
+	}
+	swarmListener, err := service.NewSwarmListenerFromConfig(c, l)
+	if err != nil {
+		l.Error("Failed to initialize Docker Flow: Swarm Listener", "error", err)
+		os.Exit(1)
+	}
+
+	restored := swarmListener.RestoreCaches()
+	if swarmListener.Elector != nil {
+		l.Info("Following until elected as the leader")
+		swarmListener.Follow()
+		go runElection(swarmListener, l)
+	} else {
+		if restored {
+			l.Info("Sending notifications for services and nodes that changed since the cache snapshot")
+			go swarmListener.NotifyChanges()
+		} else {
+			l.Info("Sending notifications for running services and nodes")
+			go swarmListener.Resync()
+		}
+
+		swarmListener.Run()
+	}
+
+	reloader := NewReloader(args.ConfigFile, swarmListener, l)
+	reloader.ReloadOnSignal()
+	reloader.ReloadOnChange(args.ConfigReloadInterval)
+
+	serve := NewServe(swarmListener, reloader, l)
+	l.Info("Serving the API", "address", c.API.Address)
+	l.Error("Stopped serving the API", "error", Run(serve, c.API))
+	os.Exit(1)
+}
 
-	l.Printf("Sending notifications for running services and nodes")
-	go swarmListener.CompletelyNotifyServices()
-	go swarmListener.NotifyNodes(false)
+// runElection leads when the swarm listener is elected as the leader
+// The process exits when the leadership is lost, so that the replica
+// restarts as a follower, and releases the lock when it is terminated.
+func runElection(swarmListener *service.SwarmListener, l *logging.Logger) {
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		swarmListener.Elector.Run(stop, swarmListener.Lead, func() {
+			l.Error("Exiting after losing the leadership")
+			os.Exit(1)
+		})
+		close(stopped)
+	}()
 
-	swarmListener.Run()
-	serve := NewServe(swarmListener, l)
-	l.Fatal(Run(serve))
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	<-signals
+	close(stop)
+	<-stopped
+	os.Exit(0)
 }
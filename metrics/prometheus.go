@@ -1,10 +1,22 @@
 package metrics
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var serviceName = "swarm_listener"
+
+// Outcomes of notification requests
+const (
+	NotificationSuccess = "success"
+	NotificationRetry   = "retry"
+	NotificationCancel  = "cancel"
+	NotificationFailure = "failure"
+)
+
 var errorCounter = prometheus.NewCounterVec(
 	prometheus.CounterOpts{
 		Subsystem: "docker_flow",
@@ -23,8 +35,86 @@ var serviceGauge = prometheus.NewGaugeVec(
 	[]string{"service"},
 )
 
+var circuitBreakerGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Subsystem: "docker_flow",
+		Name:      "circuit_breaker_state",
+		Help:      "Circuit breaker state of notification endpoints (0 closed, 1 half-open, 2 open)",
+	},
+	[]string{"service", "endpoint"},
+)
+
+var nodeGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Subsystem: "docker_flow",
+		Name:      "node_count",
+		Help:      "Node gauge",
+	},
+	[]string{"service"},
+)
+
+var notificationLatencyHistogram = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Subsystem: "docker_flow",
+		Name:      "notification_latency_seconds",
+		Help:      "Round-trip latency of notification requests",
+		Buckets:   prometheus.DefBuckets,
+	},
+	[]string{"service", "endpoint", "notify_type", "event_type"},
+)
+
+var notificationCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "docker_flow",
+		Name:      "notification",
+		Help:      "Notification counter by outcome (success, retry, cancel, failure) and status code",
+	},
+	[]string{"service", "endpoint", "notify_type", "event_type", "outcome", "status_code"},
+)
+
+var notificationsInFlightGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Subsystem: "docker_flow",
+		Name:      "notifications_in_flight",
+		Help:      "Notifications that are being sent",
+	},
+	[]string{"service", "notify_type"},
+)
+
+var cancelContextGauge = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Subsystem: "docker_flow",
+		Name:      "cancel_contexts_open",
+		Help:      "Open contexts of the cancel managers",
+	},
+	[]string{"service"},
+)
+
+var eventStreamReconnectCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "docker_flow",
+		Name:      "event_stream_reconnect",
+		Help:      "Reconnects of the Docker event streams",
+	},
+	[]string{"service", "stream"},
+)
+
+var convergeHistogram = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Subsystem: "docker_flow",
+		Name:      "service_converge_seconds",
+		Help:      "Time until the tasks of a service converged",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 10),
+	},
+	[]string{"service", "result"},
+)
+
 func init() {
-	prometheus.MustRegister(errorCounter, serviceGauge)
+	prometheus.MustRegister(
+		errorCounter, serviceGauge, circuitBreakerGauge, nodeGauge,
+		notificationLatencyHistogram, notificationCounter,
+		notificationsInFlightGauge, cancelContextGauge,
+		eventStreamReconnectCounter, convergeHistogram)
 }
 
 // RecordError stores error information as Prometheus metric.
@@ -42,3 +132,79 @@ func RecordService(count int) {
 		"service": serviceName,
 	}).Set(float64(count))
 }
+
+// RecordCircuitBreakerState stores the circuit breaker state of a
+// notification endpoint as Prometheus metric.
+func RecordCircuitBreakerState(endpoint string, state int) {
+	circuitBreakerGauge.With(prometheus.Labels{
+		"service":  serviceName,
+		"endpoint": endpoint,
+	}).Set(float64(state))
+}
+
+// RecordNode stores the number of nodes as Prometheus metric.
+func RecordNode(count int) {
+	nodeGauge.With(prometheus.Labels{
+		"service": serviceName,
+	}).Set(float64(count))
+}
+
+// RecordNotificationLatency stores the round-trip latency of a
+// notification request to `endpoint` as Prometheus metric.
+func RecordNotificationLatency(endpoint, notifyType, eventType string, latency time.Duration) {
+	notificationLatencyHistogram.With(prometheus.Labels{
+		"service":     serviceName,
+		"endpoint":    endpoint,
+		"notify_type": notifyType,
+		"event_type":  eventType,
+	}).Observe(latency.Seconds())
+}
+
+// RecordNotification stores the outcome of a notification request to
+// `endpoint` as Prometheus metric.
+// `statusCode` is 0 when no response was received.
+func RecordNotification(endpoint, notifyType, eventType, outcome string, statusCode int) {
+	notificationCounter.With(prometheus.Labels{
+		"service":     serviceName,
+		"endpoint":    endpoint,
+		"notify_type": notifyType,
+		"event_type":  eventType,
+		"outcome":     outcome,
+		"status_code": strconv.Itoa(statusCode),
+	}).Inc()
+}
+
+// AddNotificationsInFlight adds `delta` to the number of notifications
+// that are being sent.
+func AddNotificationsInFlight(notifyType string, delta int) {
+	notificationsInFlightGauge.With(prometheus.Labels{
+		"service":     serviceName,
+		"notify_type": notifyType,
+	}).Add(float64(delta))
+}
+
+// AddCancelContexts adds `delta` to the number of open contexts of the
+// cancel managers.
+func AddCancelContexts(delta int) {
+	cancelContextGauge.With(prometheus.Labels{
+		"service": serviceName,
+	}).Add(float64(delta))
+}
+
+// RecordEventStreamReconnect counts reconnects of the Docker event
+// `stream` as Prometheus metric.
+func RecordEventStreamReconnect(stream string) {
+	eventStreamReconnectCounter.With(prometheus.Labels{
+		"service": serviceName,
+		"stream":  stream,
+	}).Inc()
+}
+
+// RecordConverge stores the time until the tasks of a service converged
+// as Prometheus metric. The `result` argument is `converged` or `failed`.
+func RecordConverge(result string, duration time.Duration) {
+	convergeHistogram.With(prometheus.Labels{
+		"service": serviceName,
+		"result":  result,
+	}).Observe(duration.Seconds())
+}
@@ -0,0 +1,580 @@
+package main
+
+// openAPIDocument describes the v2 API
+// It is served from `openAPIPathV2` and must be updated together with the
+// v2 routes and models.
+const openAPIDocument = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Docker Flow Swarm Listener",
+    "description": "Notifies endpoints about Docker Swarm services and nodes.",
+    "version": "2.0.0"
+  },
+  "servers": [
+    {"url": "/v2/docker-flow-swarm-listener"}
+  ],
+  "paths": {
+    "/services": {
+      "get": {
+        "summary": "List services",
+        "description": "Services with the com.df.notify label are returned from the cache unless fresh is true.",
+        "operationId": "getServices",
+        "parameters": [
+          {"$ref": "#/components/parameters/fresh"},
+          {"$ref": "#/components/parameters/stack"},
+          {"$ref": "#/components/parameters/name"},
+          {"$ref": "#/components/parameters/label"},
+          {"$ref": "#/components/parameters/limit"},
+          {"$ref": "#/components/parameters/offset"}
+        ],
+        "responses": {
+          "200": {
+            "description": "A page of services sorted by service name",
+            "headers": {
+              "X-Total-Count": {"$ref": "#/components/headers/X-Total-Count"},
+              "Age": {"$ref": "#/components/headers/Age"},
+              "X-Cache-Updated-At": {"$ref": "#/components/headers/X-Cache-Updated-At"}
+            },
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ServiceList"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "500": {"$ref": "#/components/responses/Internal"}
+        }
+      }
+    },
+    "/services/{nameOrID}": {
+      "get": {
+        "summary": "Get a service",
+        "operationId": "getService",
+        "parameters": [
+          {"name": "nameOrID", "in": "path", "required": true, "schema": {"type": "string"}},
+          {"$ref": "#/components/parameters/fresh"}
+        ],
+        "responses": {
+          "200": {
+            "description": "The service",
+            "headers": {
+              "Age": {"$ref": "#/components/headers/Age"},
+              "X-Cache-Updated-At": {"$ref": "#/components/headers/X-Cache-Updated-At"}
+            },
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Service"}}}
+          },
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "500": {"$ref": "#/components/responses/Internal"}
+        }
+      }
+    },
+    "/nodes": {
+      "get": {
+        "summary": "List nodes",
+        "description": "Nodes are returned from the cache unless fresh is true.",
+        "operationId": "getNodes",
+        "parameters": [
+          {"$ref": "#/components/parameters/fresh"},
+          {"$ref": "#/components/parameters/role"},
+          {"$ref": "#/components/parameters/availability"},
+          {"$ref": "#/components/parameters/state"},
+          {"$ref": "#/components/parameters/limit"},
+          {"$ref": "#/components/parameters/offset"}
+        ],
+        "responses": {
+          "200": {
+            "description": "A page of nodes sorted by hostname",
+            "headers": {
+              "X-Total-Count": {"$ref": "#/components/headers/X-Total-Count"},
+              "Age": {"$ref": "#/components/headers/Age"},
+              "X-Cache-Updated-At": {"$ref": "#/components/headers/X-Cache-Updated-At"}
+            },
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NodeList"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "500": {"$ref": "#/components/responses/Internal"}
+        }
+      }
+    },
+    "/nodes/{nameOrID}": {
+      "get": {
+        "summary": "Get a node",
+        "operationId": "getNode",
+        "parameters": [
+          {"name": "nameOrID", "in": "path", "required": true, "schema": {"type": "string"}},
+          {"$ref": "#/components/parameters/fresh"}
+        ],
+        "responses": {
+          "200": {
+            "description": "The node",
+            "headers": {
+              "Age": {"$ref": "#/components/headers/Age"},
+              "X-Cache-Updated-At": {"$ref": "#/components/headers/X-Cache-Updated-At"}
+            },
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Node"}}}
+          },
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "500": {"$ref": "#/components/responses/Internal"}
+        }
+      }
+    },
+    "/notify/services": {
+      "post": {
+        "summary": "Notify endpoints of services",
+        "description": "Without a selection, all services are notified.",
+        "operationId": "notifyServices",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "parameters": [
+          {"name": "service", "in": "query", "description": "Comma separated service names or IDs", "schema": {"type": "string"}},
+          {"$ref": "#/components/parameters/stack"},
+          {"$ref": "#/components/parameters/name"},
+          {"$ref": "#/components/parameters/label"},
+          {"$ref": "#/components/parameters/host"},
+          {"$ref": "#/components/parameters/wait"},
+          {"$ref": "#/components/parameters/timeout"}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Delivered"},
+          "202": {"$ref": "#/components/responses/Accepted"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "500": {"$ref": "#/components/responses/Internal"},
+          "502": {"$ref": "#/components/responses/NotDelivered"},
+          "503": {"$ref": "#/components/responses/NotLeader"},
+          "504": {"$ref": "#/components/responses/Pending"}
+        }
+      }
+    },
+    "/notify/nodes": {
+      "post": {
+        "summary": "Notify endpoints of nodes",
+        "description": "Without a selection, all nodes are notified.",
+        "operationId": "notifyNodes",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "parameters": [
+          {"name": "node", "in": "query", "description": "Comma separated node hostnames or IDs", "schema": {"type": "string"}},
+          {"$ref": "#/components/parameters/role"},
+          {"$ref": "#/components/parameters/availability"},
+          {"$ref": "#/components/parameters/state"},
+          {"$ref": "#/components/parameters/host"},
+          {"$ref": "#/components/parameters/wait"},
+          {"$ref": "#/components/parameters/timeout"}
+        ],
+        "responses": {
+          "200": {"$ref": "#/components/responses/Delivered"},
+          "202": {"$ref": "#/components/responses/Accepted"},
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "500": {"$ref": "#/components/responses/Internal"},
+          "502": {"$ref": "#/components/responses/NotDelivered"},
+          "503": {"$ref": "#/components/responses/NotLeader"},
+          "504": {"$ref": "#/components/responses/Pending"}
+        }
+      }
+    },
+    "/resync": {
+      "post": {
+        "summary": "Notify endpoints of all services and nodes as on startup",
+        "operationId": "resync",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "responses": {
+          "202": {"$ref": "#/components/responses/Accepted"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "503": {"$ref": "#/components/responses/NotLeader"}
+        }
+      }
+    },
+    "/admin/cache/services": {
+      "get": {
+        "summary": "Dump the service cache",
+        "operationId": "getServiceCache",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "responses": {
+          "200": {"description": "The cached services sorted by ID", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ServiceCache"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"}
+        }
+      },
+      "delete": {
+        "summary": "Flush the service cache",
+        "description": "The next event of every service is notified.",
+        "operationId": "flushServiceCache",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "responses": {
+          "200": {"description": "The number of flushed services", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Flushed"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "503": {"$ref": "#/components/responses/NotLeader"}
+        }
+      }
+    },
+    "/admin/cache/services/{nameOrID}": {
+      "parameters": [
+        {"name": "nameOrID", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "get": {
+        "summary": "Get a cached service",
+        "operationId": "getCachedService",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "responses": {
+          "200": {"description": "The cached service", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/SwarmServiceMini"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      },
+      "delete": {
+        "summary": "Evict a cached service",
+        "description": "The next event of the service is notified. The removal of an evicted service is not notified.",
+        "operationId": "evictCachedService",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "responses": {
+          "204": {"description": "The service was evicted"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "503": {"$ref": "#/components/responses/NotLeader"}
+        }
+      }
+    },
+    "/admin/cache/services/{nameOrID}/diff": {
+      "get": {
+        "summary": "Compare a cached service with the service in Docker",
+        "operationId": "diffCachedService",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "parameters": [
+          {"name": "nameOrID", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {"description": "The diff", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ServiceDiff"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "500": {"$ref": "#/components/responses/Internal"}
+        }
+      }
+    },
+    "/admin/cache/nodes": {
+      "get": {
+        "summary": "Dump the node cache",
+        "operationId": "getNodeCache",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "responses": {
+          "200": {"description": "The cached nodes sorted by ID", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NodeCache"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"}
+        }
+      },
+      "delete": {
+        "summary": "Flush the node cache",
+        "description": "The next event of every node is notified.",
+        "operationId": "flushNodeCache",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "responses": {
+          "200": {"description": "The number of flushed nodes", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Flushed"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "503": {"$ref": "#/components/responses/NotLeader"}
+        }
+      }
+    },
+    "/admin/cache/nodes/{nameOrID}": {
+      "parameters": [
+        {"name": "nameOrID", "in": "path", "required": true, "schema": {"type": "string"}}
+      ],
+      "get": {
+        "summary": "Get a cached node",
+        "operationId": "getCachedNode",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "responses": {
+          "200": {"description": "The cached node", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NodeMini"}}}},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"}
+        }
+      },
+      "delete": {
+        "summary": "Evict a cached node",
+        "description": "The next event of the node is notified.",
+        "operationId": "evictCachedNode",
+        "security": [{"basicAuth": []}, {"bearerAuth": []}],
+        "responses": {
+          "204": {"description": "The node was evicted"},
+          "401": {"$ref": "#/components/responses/Unauthorized"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "503": {"$ref": "#/components/responses/NotLeader"}
+        }
+      }
+    },
+    "/notifications/history": {
+      "get": {
+        "summary": "List delivered notifications",
+        "description": "Deliveries are read from the audit log, newest first. The audit log is enabled with DF_AUDIT_FILE.",
+        "operationId": "getNotificationHistory",
+        "parameters": [
+          {"name": "service", "in": "query", "description": "Service name or ID", "schema": {"type": "string"}},
+          {"name": "endpoint", "in": "query", "description": "Endpoint host, with or without the port", "schema": {"type": "string"}},
+          {"name": "status", "in": "query", "schema": {"type": "string", "enum": ["success", "failure", "canceled"]}},
+          {"name": "since", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"name": "until", "in": "query", "schema": {"type": "string", "format": "date-time"}},
+          {"$ref": "#/components/parameters/limit"},
+          {"$ref": "#/components/parameters/offset"}
+        ],
+        "responses": {
+          "200": {
+            "description": "A page of deliveries",
+            "headers": {
+              "X-Total-Count": {"$ref": "#/components/headers/X-Total-Count"}
+            },
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NotificationHistory"}}}
+          },
+          "400": {"$ref": "#/components/responses/BadRequest"},
+          "404": {"$ref": "#/components/responses/NotFound"},
+          "405": {"$ref": "#/components/responses/MethodNotAllowed"},
+          "500": {"$ref": "#/components/responses/Internal"}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "Get this document",
+        "operationId": "getOpenAPI",
+        "responses": {
+          "200": {"description": "The OpenAPI document", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "basicAuth": {"type": "http", "scheme": "basic"},
+      "bearerAuth": {"type": "http", "scheme": "bearer"}
+    },
+    "parameters": {
+      "fresh": {"name": "fresh", "in": "query", "description": "Query Docker instead of the cache", "schema": {"type": "boolean"}},
+      "stack": {"name": "stack", "in": "query", "schema": {"type": "string"}},
+      "name": {"name": "name", "in": "query", "description": "Service name pattern", "schema": {"type": "string"}},
+      "label": {"name": "label", "in": "query", "description": "key or key=value, can be repeated", "schema": {"type": "array", "items": {"type": "string"}}, "explode": true},
+      "role": {"name": "role", "in": "query", "schema": {"type": "string", "enum": ["worker", "manager"]}},
+      "availability": {"name": "availability", "in": "query", "schema": {"type": "string", "enum": ["active", "pause", "drain"]}},
+      "state": {"name": "state", "in": "query", "schema": {"type": "string", "enum": ["unknown", "down", "ready", "disconnected"]}},
+      "limit": {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 0}},
+      "offset": {"name": "offset", "in": "query", "schema": {"type": "integer", "minimum": 0}},
+      "host": {"name": "host", "in": "query", "description": "Comma separated endpoint hosts", "schema": {"type": "string"}},
+      "wait": {"name": "wait", "in": "query", "description": "Wait for the notifications to be delivered", "schema": {"type": "boolean"}},
+      "timeout": {"name": "timeout", "in": "query", "description": "Time to wait, e.g. 10s", "schema": {"type": "string", "default": "30s"}}
+    },
+    "headers": {
+      "X-Total-Count": {"description": "Number of items before paging", "schema": {"type": "integer"}},
+      "Age": {"description": "Seconds since the cache was updated", "schema": {"type": "integer"}},
+      "X-Cache-Updated-At": {"description": "Time the cache was updated", "schema": {"type": "string", "format": "date-time"}}
+    },
+    "responses": {
+      "Accepted": {"description": "The request is processed in the background", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Accepted"}}}},
+      "Delivered": {"description": "All notifications were delivered", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NotifyReport"}}}},
+      "NotDelivered": {"description": "A notification was not delivered", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NotifyReport"}}}},
+      "Pending": {"description": "Notifications were pending when the wait timed out", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/NotifyReport"}}}},
+      "BadRequest": {"description": "The request is invalid", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorResponse"}}}},
+      "Unauthorized": {"description": "Credentials are missing or invalid", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorResponse"}}}},
+      "NotFound": {"description": "The item was not found", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorResponse"}}}},
+      "MethodNotAllowed": {"description": "The method is not allowed, the Allow header lists the allowed method", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorResponse"}}}},
+      "Internal": {"description": "Docker could not be queried", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorResponse"}}}},
+      "NotLeader": {"description": "The replica is a follower, only the leader sends notifications and changes its caches", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/ErrorResponse"}}}}
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "required": ["status", "code", "message"],
+        "properties": {
+          "status": {"type": "integer"},
+          "code": {"type": "string", "enum": ["badRequest", "unauthorized", "notFound", "methodNotAllowed", "internal", "deliveryFailed", "timeout", "notLeader"]},
+          "message": {"type": "string"}
+        }
+      },
+      "ErrorResponse": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {"error": {"$ref": "#/components/schemas/Error"}}
+      },
+      "NodeIP": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "addr": {"type": "string"},
+          "id": {"type": "string"}
+        }
+      },
+      "Service": {
+        "type": "object",
+        "required": ["id", "name", "serviceName", "mode", "labels", "nodeInfo"],
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "serviceName": {"type": "string", "description": "Name sent to endpoints"},
+          "stack": {"type": "string"},
+          "image": {"type": "string"},
+          "mode": {"type": "string", "enum": ["replicated", "global"]},
+          "replicas": {"type": "integer", "description": "Only set for replicated services"},
+          "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+          "nodeInfo": {"type": "array", "items": {"$ref": "#/components/schemas/NodeIP"}}
+        }
+      },
+      "ServiceList": {
+        "type": "object",
+        "required": ["items", "total"],
+        "properties": {
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/Service"}},
+          "total": {"type": "integer"}
+        }
+      },
+      "Node": {
+        "type": "object",
+        "required": ["id", "hostname", "address", "role", "availability", "state", "versionIndex", "labels", "engineLabels"],
+        "properties": {
+          "id": {"type": "string"},
+          "hostname": {"type": "string"},
+          "address": {"type": "string"},
+          "role": {"type": "string"},
+          "availability": {"type": "string"},
+          "state": {"type": "string"},
+          "versionIndex": {"type": "integer"},
+          "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+          "engineLabels": {"type": "object", "additionalProperties": {"type": "string"}}
+        }
+      },
+      "NodeList": {
+        "type": "object",
+        "required": ["items", "total"],
+        "properties": {
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/Node"}},
+          "total": {"type": "integer"}
+        }
+      },
+      "Accepted": {
+        "type": "object",
+        "properties": {"status": {"type": "string", "enum": ["accepted"]}}
+      },
+      "EndpointDelivery": {
+        "type": "object",
+        "properties": {
+          "host": {"type": "string"},
+          "statusCode": {"type": "integer"},
+          "attempts": {"type": "integer"},
+          "retries": {"type": "integer"},
+          "canceled": {"type": "boolean"},
+          "queued": {"type": "boolean"},
+          "error": {"type": "string"}
+        }
+      },
+      "DeliveryResult": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "name": {"type": "string"},
+          "status": {"type": "string", "enum": ["delivered", "failed", "canceled", "pending", "notRunning"]},
+          "endpoints": {"type": "array", "items": {"$ref": "#/components/schemas/EndpointDelivery"}}
+        }
+      },
+      "SwarmServiceMini": {
+        "type": "object",
+        "description": "A cache entry as compared to decide whether a service changed",
+        "properties": {
+          "ID": {"type": "string"},
+          "Name": {"type": "string"},
+          "Labels": {"type": "object", "additionalProperties": {"type": "string"}},
+          "Global": {"type": "boolean"},
+          "Replicas": {"type": "integer"},
+          "ContainerImage": {"type": "string"},
+          "NodeInfo": {"type": "array", "description": "Name, address, and ID of each node", "items": {"type": "array", "items": {"type": "string"}}}
+        }
+      },
+      "NodeMini": {
+        "type": "object",
+        "properties": {
+          "ID": {"type": "string"},
+          "Hostname": {"type": "string"},
+          "VersionIndex": {"type": "integer"},
+          "State": {"type": "string"},
+          "Addr": {"type": "string"},
+          "NodeLabels": {"type": "object", "additionalProperties": {"type": "string"}},
+          "EngineLabels": {"type": "object", "additionalProperties": {"type": "string"}},
+          "Role": {"type": "string"},
+          "Availability": {"type": "string"}
+        }
+      },
+      "ServiceCache": {
+        "type": "object",
+        "required": ["items", "total"],
+        "properties": {
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/SwarmServiceMini"}},
+          "total": {"type": "integer"},
+          "updatedAt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "NodeCache": {
+        "type": "object",
+        "required": ["items", "total"],
+        "properties": {
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/NodeMini"}},
+          "total": {"type": "integer"},
+          "updatedAt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "Flushed": {
+        "type": "object",
+        "properties": {"flushed": {"type": "integer"}}
+      },
+      "FieldChange": {
+        "type": "object",
+        "properties": {
+          "field": {"type": "string", "description": "The field, or Labels[key] for labels"},
+          "cached": {"description": "Omitted when a label is not cached"},
+          "current": {"description": "Omitted when a label is not set in Docker"}
+        }
+      },
+      "ServiceDiff": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "cached": {"allOf": [{"$ref": "#/components/schemas/SwarmServiceMini"}], "nullable": true},
+          "current": {"allOf": [{"$ref": "#/components/schemas/SwarmServiceMini"}], "nullable": true},
+          "changes": {"type": "array", "items": {"$ref": "#/components/schemas/FieldChange"}},
+          "notify": {"type": "boolean", "description": "Whether the next event of the service is notified"},
+          "reason": {"type": "string"}
+        }
+      },
+      "AuditRecord": {
+        "type": "object",
+        "required": ["time", "requestId", "kind", "eventType", "id", "endpoint", "attempts", "status"],
+        "properties": {
+          "time": {"type": "string", "format": "date-time"},
+          "requestId": {"type": "integer", "description": "Time of the event in nanoseconds"},
+          "kind": {"type": "string", "enum": ["service", "node"]},
+          "eventType": {"type": "string", "enum": ["create", "remove"]},
+          "id": {"type": "string"},
+          "name": {"type": "string", "description": "Service name or node hostname"},
+          "parameters": {"type": "object", "additionalProperties": {"type": "string"}},
+          "endpoint": {"type": "string"},
+          "attempts": {"type": "integer"},
+          "statusCode": {"type": "integer", "description": "Status code of the last response"},
+          "status": {"type": "string", "enum": ["success", "failure", "canceled"]},
+          "error": {"type": "string"},
+          "responseBody": {"type": "string", "description": "The first 512 bytes of the last response"}
+        }
+      },
+      "NotificationHistory": {
+        "type": "object",
+        "required": ["items", "total"],
+        "properties": {
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/AuditRecord"}},
+          "total": {"type": "integer"}
+        }
+      },
+      "NotifyReport": {
+        "type": "object",
+        "required": ["status", "results"],
+        "properties": {
+          "status": {"type": "string", "enum": ["delivered", "failed", "pending"]},
+          "results": {"type": "array", "items": {"$ref": "#/components/schemas/DeliveryResult"}},
+          "error": {"$ref": "#/components/schemas/Error"}
+        }
+      }
+    }
+  }
+}
+`
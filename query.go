@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+	"github.com/docker/docker/api/types/swarm"
+)
+
+var nodeRoles = map[swarm.NodeRole]struct{}{
+	swarm.NodeRoleWorker:  {},
+	swarm.NodeRoleManager: {},
+}
+
+var nodeAvailabilities = map[swarm.NodeAvailability]struct{}{
+	swarm.NodeAvailabilityActive: {},
+	swarm.NodeAvailabilityPause:  {},
+	swarm.NodeAvailabilityDrain:  {},
+}
+
+var nodeStates = map[swarm.NodeState]struct{}{
+	swarm.NodeStateUnknown:      {},
+	swarm.NodeStateDown:         {},
+	swarm.NodeStateReady:        {},
+	swarm.NodeStateDisconnected: {},
+}
+
+// listOptions select the fields and the page of items returned by list
+// routes
+type listOptions struct {
+	Fields []string
+	Limit  int
+	Offset int
+}
+
+// parseListOptions reads the `fields`, `limit`, and `offset` query
+// parameters
+func parseListOptions(query url.Values) (listOptions, error) {
+	options := listOptions{}
+	for _, field := range strings.Split(query.Get("fields"), ",") {
+		field = strings.TrimSpace(field)
+		if len(field) > 0 {
+			options.Fields = append(options.Fields, field)
+		}
+	}
+
+	var err error
+	if options.Limit, err = parseNonNegativeInt(query, "limit"); err != nil {
+		return options, err
+	}
+	if options.Offset, err = parseNonNegativeInt(query, "offset"); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
+// parseWait reads the `wait` and `timeout` query parameters
+// `defaultTimeout` is returned when no timeout is set.
+func parseWait(query url.Values, defaultTimeout time.Duration) (bool, time.Duration, error) {
+	wait := false
+	if value := query.Get("wait"); len(value) > 0 {
+		var err error
+		if wait, err = strconv.ParseBool(value); err != nil {
+			return false, 0, fmt.Errorf("wait must be true or false")
+		}
+	}
+	value := query.Get("timeout")
+	if len(value) == 0 {
+		return wait, defaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return false, 0, fmt.Errorf("timeout must be a positive duration")
+	}
+	return wait, timeout, nil
+}
+
+func parseNonNegativeInt(query url.Values, key string) (int, error) {
+	value := query.Get(key)
+	if len(value) == 0 {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return i, nil
+}
+
+// apply sorts `params` by `sortKeys` and returns the selected fields of the
+// requested page together with the total number of items
+func (o listOptions) apply(params []map[string]string, sortKeys ...string) ([]map[string]string, int) {
+	sort.SliceStable(params, func(i, j int) bool {
+		for _, key := range sortKeys {
+			if params[i][key] != params[j][key] {
+				return params[i][key] < params[j][key]
+			}
+		}
+		return false
+	})
+
+	total := len(params)
+	start, end := o.page(total)
+	page := make([]map[string]string, 0, end-start)
+	for _, p := range params[start:end] {
+		page = append(page, o.selectFields(p))
+	}
+	return page, total
+}
+
+// page returns the bounds of the requested page of `total` items
+func (o listOptions) page(total int) (int, int) {
+	start := o.Offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if o.Limit > 0 && start+o.Limit < end {
+		end = start + o.Limit
+	}
+	return start, end
+}
+
+// selectFields returns the requested fields of `params`
+// All fields are returned when no fields were requested.
+func (o listOptions) selectFields(params map[string]string) map[string]string {
+	if len(o.Fields) == 0 {
+		return params
+	}
+	selected := map[string]string{}
+	for _, field := range o.Fields {
+		if value, ok := params[field]; ok {
+			selected[field] = value
+		}
+	}
+	return selected
+}
+
+// splitList returns the comma separated items of `values`
+func splitList(values []string) []string {
+	items := []string{}
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if len(item) > 0 {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// parseServiceFilter reads the `stack`, `name`, and `label` query parameters
+// Labels are selected with `key` or `key=value` and can be repeated.
+func parseServiceFilter(query url.Values) (service.ServiceFilter, error) {
+	filter := service.ServiceFilter{
+		Stack: query.Get("stack"),
+		Name:  query.Get("name"),
+	}
+	if len(filter.Name) > 0 {
+		if _, err := path.Match(filter.Name, ""); err != nil {
+			return filter, fmt.Errorf("name is not a valid pattern: %v", err)
+		}
+	}
+	for _, label := range query["label"] {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts[0]) == 0 {
+			return filter, fmt.Errorf("label %q has no key", label)
+		}
+		selector := service.LabelSelector{Key: parts[0]}
+		if len(parts) == 2 {
+			selector.Value = parts[1]
+			selector.HasValue = true
+		}
+		filter.Labels = append(filter.Labels, selector)
+	}
+	return filter, nil
+}
+
+// parseNodeFilter reads the `role`, `availability`, and `state` query
+// parameters
+func parseNodeFilter(query url.Values) (service.NodeFilter, error) {
+	filter := service.NodeFilter{
+		Role:         swarm.NodeRole(query.Get("role")),
+		Availability: swarm.NodeAvailability(query.Get("availability")),
+		State:        swarm.NodeState(query.Get("state")),
+	}
+	if _, ok := nodeRoles[filter.Role]; len(filter.Role) > 0 && !ok {
+		return filter, fmt.Errorf("role must be worker or manager")
+	}
+	if _, ok := nodeAvailabilities[filter.Availability]; len(filter.Availability) > 0 && !ok {
+		return filter, fmt.Errorf("availability must be active, pause, or drain")
+	}
+	if _, ok := nodeStates[filter.State]; len(filter.State) > 0 && !ok {
+		return filter, fmt.Errorf("state must be unknown, down, ready, or disconnected")
+	}
+	return filter, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/suite"
+)
+
+type QueryTestSuite struct {
+	suite.Suite
+}
+
+func TestQueryUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(QueryTestSuite))
+}
+
+func (s *QueryTestSuite) Test_ParseListOptions() {
+	query, _ := url.ParseQuery("fields=serviceName,%20servicePath&limit=10&offset=20")
+
+	options, err := parseListOptions(query)
+
+	s.Require().NoError(err)
+	s.Equal(listOptions{Fields: []string{"serviceName", "servicePath"}, Limit: 10, Offset: 20}, options)
+}
+
+func (s *QueryTestSuite) Test_ParseListOptions_ReturnsError_WhenLimitIsInvalid() {
+	for _, q := range []string{"limit=ten", "limit=-1", "offset=-5"} {
+		query, _ := url.ParseQuery(q)
+		_, err := parseListOptions(query)
+		s.Error(err, q)
+	}
+}
+
+func (s *QueryTestSuite) Test_ListOptionsApply_SortsPagesAndSelectsFields() {
+	params := []map[string]string{
+		{"serviceName": "c", "port": "3"},
+		{"serviceName": "a", "port": "1"},
+		{"serviceName": "b", "port": "2"},
+	}
+
+	page, total := listOptions{Fields: []string{"serviceName", "missing"}, Limit: 1, Offset: 1}.
+		apply(params, "serviceName")
+
+	s.Equal(3, total)
+	s.Equal([]map[string]string{{"serviceName": "b"}}, page)
+}
+
+func (s *QueryTestSuite) Test_ListOptionsApply_ReturnsEmptyPage_WhenOffsetIsBeyondTheEnd() {
+	page, total := listOptions{Offset: 5}.apply([]map[string]string{{"id": "1"}}, "id")
+
+	s.Equal(1, total)
+	s.Empty(page)
+}
+
+func (s *QueryTestSuite) Test_ParseServiceFilter() {
+	query, _ := url.ParseQuery(
+		"stack=shop&name=web*&label=com.df.servicePath&label=com.df.port=8080&label=com.df.empty=")
+
+	filter, err := parseServiceFilter(query)
+
+	s.Require().NoError(err)
+	s.Equal(service.ServiceFilter{
+		Stack: "shop",
+		Name:  "web*",
+		Labels: []service.LabelSelector{
+			{Key: "com.df.servicePath"},
+			{Key: "com.df.port", Value: "8080", HasValue: true},
+			{Key: "com.df.empty", Value: "", HasValue: true},
+		},
+	}, filter)
+}
+
+func (s *QueryTestSuite) Test_ParseServiceFilter_ReturnsError_WhenInvalid() {
+	for _, q := range []string{"name=%5B", "label==value"} {
+		query, _ := url.ParseQuery(q)
+		_, err := parseServiceFilter(query)
+		s.Error(err, q)
+	}
+}
+
+func (s *QueryTestSuite) Test_ParseNodeFilter() {
+	query, _ := url.ParseQuery("role=manager&availability=active&state=ready")
+
+	filter, err := parseNodeFilter(query)
+
+	s.Require().NoError(err)
+	s.Equal(service.NodeFilter{
+		Role:         swarm.NodeRoleManager,
+		Availability: swarm.NodeAvailabilityActive,
+		State:        swarm.NodeStateReady,
+	}, filter)
+}
+
+func (s *QueryTestSuite) Test_ParseNodeFilter_ReturnsError_WhenInvalid() {
+	for _, q := range []string{"role=leader", "availability=paused", "state=up"} {
+		query, _ := url.ParseQuery(q)
+		_, err := parseNodeFilter(query)
+		s.Error(err, q)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+)
+
+// Reloading reloads the notification endpoints
+type Reloading interface {
+	Reload() error
+}
+
+// Reloader reloads notification endpoints from the configuration
+type Reloader struct {
+	ConfigFile    string
+	SwarmListener service.SwarmListening
+	Log           *logging.Logger
+	mux           sync.Mutex
+}
+
+// NewReloader returns a new instance of the `Reloader`
+func NewReloader(configFile string, swarmListener service.SwarmListening, logger *logging.Logger) *Reloader {
+	return &Reloader{
+		ConfigFile:    configFile,
+		SwarmListener: swarmListener,
+		Log:           logger,
+	}
+}
+
+// Reload loads the configuration and replaces the notification endpoints
+func (r *Reloader) Reload() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	c, err := config.Load(r.ConfigFile)
+	if err != nil {
+		return err
+	}
+	if err := r.SwarmListener.UpdateNotifyEndpoints(c); err != nil {
+		return err
+	}
+	r.Log.Info("Reloaded notification endpoints")
+	return nil
+}
+
+// ReloadOnSignal reloads endpoints every time SIGHUP is received
+func (r *Reloader) ReloadOnSignal() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	go func() {
+		for range sigChan {
+			r.Log.Info("Received SIGHUP, reloading notification endpoints")
+			if err := r.Reload(); err != nil {
+				r.Log.Error("Unable to reload notification endpoints", "error", err)
+			}
+		}
+	}()
+}
+
+// ReloadOnChange reloads endpoints when the modification time of the
+// configuration file changes. The file is checked every `interval` seconds.
+func (r *Reloader) ReloadOnChange(interval int) {
+	if interval <= 0 || len(r.ConfigFile) == 0 {
+		return
+	}
+	modTime := r.configModTime()
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			current := r.configModTime()
+			if current.Equal(modTime) {
+				continue
+			}
+			modTime = current
+			r.Log.Info("Configuration file changed, reloading notification endpoints", "file", r.ConfigFile)
+			if err := r.Reload(); err != nil {
+				r.Log.Error("Unable to reload notification endpoints", "error", err)
+			}
+		}
+	}()
+}
+
+func (r *Reloader) configModTime() time.Time {
+	info, err := os.Stat(r.ConfigFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReloaderTestSuite struct {
+	suite.Suite
+	Log     *logging.Logger
+	SLMock  *SwarmListeningMock
+	tempDir string
+}
+
+func TestReloaderUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ReloaderTestSuite))
+}
+
+func (s *ReloaderTestSuite) SetupTest() {
+	s.Log = logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelDebug)
+	s.SLMock = new(SwarmListeningMock)
+	tempDir, err := ioutil.TempDir("", "dfsl-reload")
+	s.Require().NoError(err)
+	s.tempDir = tempDir
+}
+
+func (s *ReloaderTestSuite) TearDownTest() {
+	os.RemoveAll(s.tempDir)
+}
+
+func (s *ReloaderTestSuite) Test_Reload_UpdatesEndpointsFromConfigFile() {
+	configFile := filepath.Join(s.tempDir, "config.yml")
+	err := ioutil.WriteFile(configFile, []byte(`
+endpoints:
+  - createServiceURL: http://proxy/reconfigure
+`), 0600)
+	s.Require().NoError(err)
+
+	s.SLMock.On("UpdateNotifyEndpoints", mock.MatchedBy(func(c *config.Config) bool {
+		return len(c.Endpoints) == 1 && c.Endpoints[0].CreateServiceURL == "http://proxy/reconfigure"
+	})).Return(nil)
+
+	r := NewReloader(configFile, s.SLMock, s.Log)
+	s.NoError(r.Reload())
+	s.SLMock.AssertExpectations(s.T())
+}
+
+func (s *ReloaderTestSuite) Test_Reload_ReturnsError_WhenConfigIsInvalid() {
+	configFile := filepath.Join(s.tempDir, "config.yml")
+	err := ioutil.WriteFile(configFile, []byte("retries: 10\n"), 0600)
+	s.Require().NoError(err)
+
+	r := NewReloader(configFile, s.SLMock, s.Log)
+	s.Error(r.Reload())
+	s.SLMock.AssertNotCalled(s.T(), "UpdateNotifyEndpoints", mock.Anything)
+}
+
+func (s *ReloaderTestSuite) Test_Reload_ReturnsError_WhenUpdateFails() {
+	s.SLMock.On("UpdateNotifyEndpoints", mock.Anything).Return(fmt.Errorf("restart required"))
+
+	r := NewReloader("", s.SLMock, s.Log)
+	s.EqualError(r.Reload(), "restart required")
+}
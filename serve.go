@@ -1,113 +1,676 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
-	"log"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
 	"github.com/docker-flow/docker-flow-swarm-listener/service"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-var httpListenAndServe = http.ListenAndServe
+var httpListenAndServe = func(srv *http.Server, certFile, keyFile string) error {
+	if len(certFile) > 0 {
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	}
+	return srv.ListenAndServe()
+}
+
+const getServicesPath = "/v1/docker-flow-swarm-listener/get-services"
+
+// notifyWaitTimeout is the default time notify requests with `wait=true`
+// wait for notifications to be delivered
+var notifyWaitTimeout = 30 * time.Second
+
+// healthCheckTimeout is the time health checks wait for Docker
+var healthCheckTimeout = 3 * time.Second
+
+// eventStreamKeepAliveInterval is the time between comments sent to keep
+// idle event streams open
+var eventStreamKeepAliveInterval = 15 * time.Second
+
 var httpWriterSetContentType = func(w http.ResponseWriter, value string) {
 	w.Header().Set("Content-Type", value)
 }
 
-//Response message
+// Response message
 type Response struct {
-	Status string
+	Status  string
+	Message string `json:",omitempty"`
+}
+
+// NotifyReport is the response of notify requests with `wait=true`
+type NotifyReport struct {
+	Status  string
+	Message string `json:",omitempty"`
+	Results []service.DeliveryResult
 }
 
 // Serve is the instance structure
 type Serve struct {
 	SwarmListener service.SwarmListening
-	Log           *log.Logger
+	Reloader      Reloading
+	Log           *logging.Logger
 }
 
 type server interface {
 	NotifyServices(w http.ResponseWriter, req *http.Request)
+	NotifyNodes(w http.ResponseWriter, req *http.Request)
+	Resync(w http.ResponseWriter, req *http.Request)
 	GetServices(w http.ResponseWriter, req *http.Request)
+	GetService(w http.ResponseWriter, req *http.Request)
 	GetNodes(w http.ResponseWriter, req *http.Request)
 	PingHandler(w http.ResponseWriter, req *http.Request)
+	Health(w http.ResponseWriter, req *http.Request)
+	Ready(w http.ResponseWriter, req *http.Request)
+	ReloadEndpoints(w http.ResponseWriter, req *http.Request)
+	GetQueue(w http.ResponseWriter, req *http.Request)
+	GetCircuitBreakers(w http.ResponseWriter, req *http.Request)
+	StreamEvents(w http.ResponseWriter, req *http.Request)
+	GetServicesV2(w http.ResponseWriter, req *http.Request)
+	GetServiceV2(w http.ResponseWriter, req *http.Request)
+	GetNodesV2(w http.ResponseWriter, req *http.Request)
+	GetNodeV2(w http.ResponseWriter, req *http.Request)
+	NotifyServicesV2(w http.ResponseWriter, req *http.Request)
+	NotifyNodesV2(w http.ResponseWriter, req *http.Request)
+	ResyncV2(w http.ResponseWriter, req *http.Request)
+	OpenAPI(w http.ResponseWriter, req *http.Request)
+	NotFoundV2(w http.ResponseWriter, req *http.Request)
+	AdminServiceCache(w http.ResponseWriter, req *http.Request)
+	AdminServiceCacheEntry(w http.ResponseWriter, req *http.Request)
+	AdminNodeCache(w http.ResponseWriter, req *http.Request)
+	AdminNodeCacheEntry(w http.ResponseWriter, req *http.Request)
+	GetNotificationHistory(w http.ResponseWriter, req *http.Request)
+	IsLeader() bool
 }
 
 // NewServe returns a new instance of the `Serve`
-func NewServe(swarmListener service.SwarmListening, logger *log.Logger) *Serve {
+func NewServe(swarmListener service.SwarmListening, reloader Reloading, logger *logging.Logger) *Serve {
 	return &Serve{
 		SwarmListener: swarmListener,
+		Reloader:      reloader,
 		Log:           logger,
 	}
 }
 
-// Run executes a server
-func Run(s server) error {
-	mux := attachRoutes(s)
-	return httpListenAndServe(":8080", mux)
+// Run executes a server on the address of `c`
+// Routes are served with HTTPS and require authentication as configured
+// in `c`. Followers reject the routes that send notifications.
+func Run(s server, c config.API) error {
+	srv := &http.Server{
+		Addr:    c.Address,
+		Handler: newAuthenticator(c, newFollowerGuard(s, attachRoutes(s))),
+	}
+	if !c.TLS.IsEnabled() {
+		return httpListenAndServe(srv, "", "")
+	}
+	tlsConfig, err := newAPITLSConfig(c.TLS)
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig = tlsConfig
+	return httpListenAndServe(srv, c.TLS.CertFile, c.TLS.KeyFile)
+}
+
+// newAPITLSConfig returns the TLS configuration of the API server
+// Client certificates are required when a client CA is configured
+func newAPITLSConfig(c config.APITLS) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: c.MinTLSVersion(),
+	}
+	if len(c.ClientCAFile) > 0 {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read client CA bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Unable to read client CA bundle: no certificates found in %s", c.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
 }
 
 // attachRoutes attaches routes to services and returns the mux
 func attachRoutes(s server) *http.ServeMux {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/docker-flow-swarm-listener/notify-services", s.NotifyServices)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/notify-nodes", s.NotifyNodes)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/resync", s.Resync)
 	mux.HandleFunc("/v1/docker-flow-swarm-listener/get-nodes", s.GetNodes)
-	mux.HandleFunc("/v1/docker-flow-swarm-listener/get-services", s.GetServices)
+	mux.HandleFunc(getServicesPath, s.GetServices)
+	mux.HandleFunc(getServicesPath+"/", s.GetService)
 	mux.HandleFunc("/v1/docker-flow-swarm-listener/ping", s.PingHandler)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/health", s.Health)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/ready", s.Ready)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/reload-endpoints", s.ReloadEndpoints)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/queue", s.GetQueue)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/circuit-breakers", s.GetCircuitBreakers)
+	mux.HandleFunc("/v1/docker-flow-swarm-listener/events", s.StreamEvents)
+	mux.HandleFunc(servicesPathV2, s.GetServicesV2)
+	mux.HandleFunc(servicesPathV2+"/", s.GetServiceV2)
+	mux.HandleFunc(nodesPathV2, s.GetNodesV2)
+	mux.HandleFunc(nodesPathV2+"/", s.GetNodeV2)
+	mux.HandleFunc(notifyServicesPathV2, s.NotifyServicesV2)
+	mux.HandleFunc(notifyNodesPathV2, s.NotifyNodesV2)
+	mux.HandleFunc(resyncPathV2, s.ResyncV2)
+	mux.HandleFunc(openAPIPathV2, s.OpenAPI)
+	mux.HandleFunc(serviceCachePathV2, s.AdminServiceCache)
+	mux.HandleFunc(serviceCachePathV2+"/", s.AdminServiceCacheEntry)
+	mux.HandleFunc(nodeCachePathV2, s.AdminNodeCache)
+	mux.HandleFunc(nodeCachePathV2+"/", s.AdminNodeCacheEntry)
+	mux.HandleFunc(notificationHistoryPathV2, s.GetNotificationHistory)
+	mux.HandleFunc(apiV2Path+"/", s.NotFoundV2)
 	mux.Handle("/metrics", prometheus.Handler())
 	return mux
 }
 
 // NotifyServices notifies all configured endpoints of new, updated, or removed services
+// Services are selected with `service`, `stack`, `name`, and `label`, and
+// endpoints with `host`. Without a selection, all services are notified.
+// With `wait=true`, the delivery of the notifications is reported.
 func (m Serve) NotifyServices(w http.ResponseWriter, req *http.Request) {
-	go m.SwarmListener.NotifyServices(false)
+	options, n, err := m.parseNotifyServices(req.URL.Query(), "serveNotifyServices")
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	m.notify(w, req, options, n)
+}
+
+// NotifyNodes notifies all configured endpoints of nodes
+// Nodes are selected with `node`, `role`, `availability`, and `state`, and
+// endpoints with `host`. Without a selection, all nodes are notified.
+// With `wait=true`, the delivery of the notifications is reported.
+func (m Serve) NotifyNodes(w http.ResponseWriter, req *http.Request) {
+	options, n, err := m.parseNotifyNodes(req.URL.Query(), "serveNotifyNodes")
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	m.notify(w, req, options, n)
+}
+
+// parseNotifyServices reads the services and endpoints selected by a
+// notify request
+func (m Serve) parseNotifyServices(query url.Values, errorType string) (notifyOptions, notifyRequest, error) {
+	filter, err := parseServiceFilter(query)
+	if err != nil {
+		return notifyOptions{}, notifyRequest{}, err
+	}
+	filter.NamesOrIDs = splitList(query["service"])
+	options, err := m.parseNotifyOptions(query)
+	if err != nil {
+		return options, notifyRequest{}, err
+	}
+
+	return options, notifyRequest{
+		kind:     "services",
+		selected: !filter.IsEmpty(),
+		notifyAll: func() {
+			m.SwarmListener.NotifyServices(false)
+		},
+		notifySelected: func(ctx context.Context) ([]service.DeliveryResult, error) {
+			return m.SwarmListener.NotifySelectedServices(ctx, filter, options.Hosts)
+		},
+		errorType: errorType,
+	}, nil
+}
+
+// parseNotifyNodes reads the nodes and endpoints selected by a notify
+// request
+func (m Serve) parseNotifyNodes(query url.Values, errorType string) (notifyOptions, notifyRequest, error) {
+	filter, err := parseNodeFilter(query)
+	if err != nil {
+		return notifyOptions{}, notifyRequest{}, err
+	}
+	filter.NamesOrIDs = splitList(query["node"])
+	options, err := m.parseNotifyOptions(query)
+	if err != nil {
+		return options, notifyRequest{}, err
+	}
+
+	return options, notifyRequest{
+		kind:     "nodes",
+		selected: !filter.IsEmpty(),
+		notifyAll: func() {
+			m.SwarmListener.NotifyNodes(false)
+		},
+		notifySelected: func(ctx context.Context) ([]service.DeliveryResult, error) {
+			return m.SwarmListener.NotifySelectedNodes(ctx, filter, options.Hosts)
+		},
+		errorType: errorType,
+	}, nil
+}
+
+// Resync sends out create and remove notifications for all services and
+// create notifications for all nodes, as it is done on startup
+func (m Serve) Resync(w http.ResponseWriter, req *http.Request) {
+	go m.SwarmListener.Resync()
 	js, _ := json.Marshal(Response{Status: "OK"})
 	httpWriterSetContentType(w, "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(js)
 }
 
+// notifyOptions select the endpoints notify requests are sent to and
+// whether the delivery is waited for
+type notifyOptions struct {
+	Hosts   []string
+	Wait    bool
+	Timeout time.Duration
+}
+
+// parseNotifyOptions reads the `host`, `wait`, and `timeout` query
+// parameters
+func (m Serve) parseNotifyOptions(query url.Values) (notifyOptions, error) {
+	options := notifyOptions{}
+	if targets := splitList(query["host"]); len(targets) > 0 {
+		hosts, err := m.SwarmListener.ResolveNotifyHosts(targets)
+		if err != nil {
+			return options, err
+		}
+		options.Hosts = hosts
+	}
+	var err error
+	options.Wait, options.Timeout, err = parseWait(query, notifyWaitTimeout)
+	return options, err
+}
+
+// notifyRequest sends the notifications of a notify request
+type notifyRequest struct {
+	kind string
+	// selected is true when only some of the items are notified
+	selected       bool
+	notifyAll      func()
+	notifySelected func(ctx context.Context) ([]service.DeliveryResult, error)
+	errorType      string
+}
+
+// notify sends notifications with `n` as selected by `options`
+// All items are notified with `notifyAll` unless items or endpoints are
+// selected, or the delivery is waited for.
+func (m Serve) notify(w http.ResponseWriter, req *http.Request, options notifyOptions, n notifyRequest) {
+	if options.Wait {
+		ctx, cancel := context.WithTimeout(req.Context(), options.Timeout)
+		defer cancel()
+		results, err := n.notifySelected(ctx)
+		if err != nil {
+			m.writeServerError(w, err, n.errorType)
+			return
+		}
+		m.writeNotifyReport(w, results)
+		return
+	}
+
+	m.notifyInBackground(options, n)
+	js, _ := json.Marshal(Response{Status: "OK"})
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
+}
+
+// notifyInBackground sends notifications with `n` without waiting for
+// them to be delivered
+func (m Serve) notifyInBackground(options notifyOptions, n notifyRequest) {
+	if !n.selected && len(options.Hosts) == 0 {
+		go n.notifyAll()
+		return
+	}
+	go func() {
+		if _, err := n.notifySelected(context.Background()); err != nil {
+			m.Log.Error("Unable to notify", "kind", n.kind, "error", err)
+			metrics.RecordError(n.errorType)
+		}
+	}()
+}
+
+// deliveryStatusCode returns the status code describing delivery `results`
+// It is `504` when notifications are still pending, and `502` when a
+// notification was not delivered.
+func deliveryStatusCode(results []service.DeliveryResult) int {
+	statusCode := http.StatusOK
+	for _, result := range results {
+		switch result.Status {
+		case service.DeliveryStatusPending:
+			return http.StatusGatewayTimeout
+		case service.DeliveryStatusFailed, service.DeliveryStatusCanceled:
+			statusCode = http.StatusBadGateway
+		}
+	}
+	return statusCode
+}
+
+// writeNotifyReport writes the delivery `results` of a notify request
+// The status is `502` when a notification was not delivered, and `504`
+// when notifications were still pending when the wait timed out.
+func (m Serve) writeNotifyReport(w http.ResponseWriter, results []service.DeliveryResult) {
+	report := NotifyReport{Status: "OK", Results: results}
+	statusCode := deliveryStatusCode(results)
+	switch statusCode {
+	case http.StatusGatewayTimeout:
+		report.Status = "NOK"
+		report.Message = "Timed out waiting for notifications to be delivered"
+	case http.StatusBadGateway:
+		report.Status = "NOK"
+		report.Message = "Notifications could not be delivered"
+	}
+
+	js, _ := json.Marshal(report)
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(js)
+}
+
 // GetServices retrieves all services with the `com.df.notify` label set to `true`
+// Services are retrieved from the cache unless `fresh=true`. They are
+// filtered, sorted by name, and paged as requested in the query.
 func (m Serve) GetServices(w http.ResponseWriter, req *http.Request) {
-	parameters, err := m.SwarmListener.GetServicesParameters(req.Context())
+	query := req.URL.Query()
+	filter, err := parseServiceFilter(query)
 	if err != nil {
-		m.Log.Printf("ERROR: Unable to prepare response: %s", err)
-		metrics.RecordError("serveGetServices")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeBadRequest(w, err)
+		return
 	}
-	bytes, err := json.Marshal(parameters)
+	options, err := parseListOptions(query)
 	if err != nil {
-		m.Log.Printf("ERROR: Unable to prepare response: %s", err)
-		metrics.RecordError("serveGetServices")
-		w.WriteHeader(http.StatusInternalServerError)
-	} else {
-		// NOTE: For an unknown reason, `httpWriterSetContentType` does not work so the header is set directly
-		w.Header().Set("Content-Type", "application/json")
+		writeBadRequest(w, err)
+		return
+	}
+
+	parameters, updatedAt, cached, err := m.getServicesParameters(req, filter)
+	if err != nil {
+		m.writeServerError(w, err, "serveGetServices")
+		return
+	}
+	parameters, total := options.apply(parameters, "serviceName")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	m.writeParameters(w, parameters, updatedAt, cached, "serveGetServices")
+}
+
+// GetService retrieves the service with the name or ID at the end of the path
+func (m Serve) GetService(w http.ResponseWriter, req *http.Request) {
+	nameOrID := strings.TrimPrefix(req.URL.Path, getServicesPath+"/")
+	if len(nameOrID) == 0 {
+		m.GetServices(w, req)
+		return
+	}
+	options, err := parseListOptions(req.URL.Query())
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	parameters, updatedAt, cached, err := m.getServicesParameters(
+		req, service.ServiceFilter{NamesOrIDs: []string{nameOrID}})
+	if err != nil {
+		m.writeServerError(w, err, "serveGetService")
+		return
+	}
+	if len(parameters) == 0 {
 		httpWriterSetContentType(w, "application/json")
-		w.Write(bytes)
+		js, _ := json.Marshal(Response{Status: "NOK", Message: fmt.Sprintf("Service %s was not found", nameOrID)})
+		w.WriteHeader(http.StatusNotFound)
+		w.Write(js)
+		return
+	}
+	// Paging does not apply to a single service
+	parameters, _ = listOptions{Fields: options.Fields}.apply(parameters, "serviceName")
+	m.writeParameters(w, parameters[0], updatedAt, cached, "serveGetService")
+}
+
+// getServicesParameters returns the parameters of the services selected by
+// `filter` from the cache, or from Docker when the cache is not used
+func (m Serve) getServicesParameters(
+	req *http.Request, filter service.ServiceFilter) ([]map[string]string, time.Time, bool, error) {
+	if !isFreshRequest(req) {
+		if parameters, updatedAt, ok := m.SwarmListener.GetCachedServicesParameters(filter); ok {
+			return parameters, updatedAt, true, nil
+		}
 	}
+	parameters, err := m.SwarmListener.GetServicesParameters(req.Context(), filter)
+	return parameters, time.Time{}, false, err
 }
 
 // GetNodes retrieves all nodes
+// Nodes are retrieved from the cache unless `fresh=true`. They are
+// filtered, sorted by hostname, and paged as requested in the query.
 func (m Serve) GetNodes(w http.ResponseWriter, req *http.Request) {
-	parameters, err := m.SwarmListener.GetNodesParameters(req.Context())
+	query := req.URL.Query()
+	filter, err := parseNodeFilter(query)
 	if err != nil {
-		m.Log.Printf("ERROR: Unable to prepare response: %s", err)
-		metrics.RecordError("serveGetNodes")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeBadRequest(w, err)
+		return
+	}
+	options, err := parseListOptions(query)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	var parameters []map[string]string
+	var updatedAt time.Time
+	cached := false
+	if !isFreshRequest(req) {
+		parameters, updatedAt, cached = m.SwarmListener.GetCachedNodesParameters(filter)
 	}
+	if !cached {
+		parameters, err = m.SwarmListener.GetNodesParameters(req.Context(), filter)
+		if err != nil {
+			m.writeServerError(w, err, "serveGetNodes")
+			return
+		}
+	}
+	parameters, total := options.apply(parameters, "hostname", "id")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	m.writeParameters(w, parameters, updatedAt, cached, "serveGetNodes")
+}
+
+// isFreshRequest returns true when `req` asks for a live query instead of
+// cached data
+func isFreshRequest(req *http.Request) bool {
+	return req.URL.Query().Get("fresh") == "true"
+}
+
+// writeParameters writes `parameters` as json
+// The age of cached parameters is written in the `Age` and
+// `X-Cache-Updated-At` headers.
+func (m Serve) writeParameters(
+	w http.ResponseWriter, parameters interface{}, updatedAt time.Time, cached bool, errorType string) {
 	bytes, err := json.Marshal(parameters)
 	if err != nil {
-		m.Log.Printf("ERROR: Unable to prepare response: %s", err)
-		metrics.RecordError("serveGetNodes")
+		m.Log.Error("Unable to prepare response", "error", err)
+		metrics.RecordError(errorType)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if cached {
+		writeCacheHeaders(w, updatedAt)
+	}
+	// NOTE: For an unknown reason, `httpWriterSetContentType` does not work so the header is set directly
+	w.Header().Set("Content-Type", "application/json")
+	httpWriterSetContentType(w, "application/json")
+	w.Write(bytes)
+}
+
+// writeCacheHeaders writes the age of data cached at `updatedAt` in the
+// `Age` and `X-Cache-Updated-At` headers
+func writeCacheHeaders(w http.ResponseWriter, updatedAt time.Time) {
+	age := time.Since(updatedAt)
+	if age < 0 {
+		age = 0
+	}
+	w.Header().Set("Age", fmt.Sprintf("%d", int64(age/time.Second)))
+	w.Header().Set("X-Cache-Updated-At", updatedAt.UTC().Format(time.RFC3339))
+}
+
+// writeServerError logs `err` and writes it with status `500`
+func (m Serve) writeServerError(w http.ResponseWriter, err error, errorType string) {
+	m.Log.Error("Unable to prepare response", "error", err)
+	metrics.RecordError(errorType)
+	httpWriterSetContentType(w, "application/json")
+	js, _ := json.Marshal(Response{Status: "NOK", Message: err.Error()})
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Write(js)
+}
+
+// writeBadRequest writes `err` with status `400`
+func writeBadRequest(w http.ResponseWriter, err error) {
+	httpWriterSetContentType(w, "application/json")
+	js, _ := json.Marshal(Response{Status: "NOK", Message: err.Error()})
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(js)
+}
+
+// GetQueue retrieves notifications waiting in durable queues keyed by endpoint host
+func (m Serve) GetQueue(w http.ResponseWriter, req *http.Request) {
+	bytes, err := json.Marshal(m.SwarmListener.GetQueuedNotifications())
+	if err != nil {
+		m.Log.Error("Unable to prepare response", "error", err)
+		metrics.RecordError("serveGetQueue")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	w.Write(bytes)
+}
+
+// GetCircuitBreakers retrieves the state of circuit breakers keyed by endpoint host
+func (m Serve) GetCircuitBreakers(w http.ResponseWriter, req *http.Request) {
+	bytes, err := json.Marshal(m.SwarmListener.GetCircuitBreakers())
+	if err != nil {
+		m.Log.Error("Unable to prepare response", "error", err)
+		metrics.RecordError("serveGetCircuitBreakers")
 		w.WriteHeader(http.StatusInternalServerError)
-	} else {
-		// NOTE: For an unknown reason, `httpWriterSetContentType` does not work so the header is set directly
-		w.Header().Set("Content-Type", "application/json")
+		return
+	}
+	httpWriterSetContentType(w, "application/json")
+	w.Write(bytes)
+}
+
+// StreamEvents streams service and node changes as server-sent events
+// Streams that do not resume from the `Last-Event-ID` header or the
+// `lastEventId` query parameter start with a snapshot of the cached
+// services and nodes, unless `snapshot=false`. `kind` limits the stream to
+// `service` or `node` events.
+func (m Serve) StreamEvents(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
 		httpWriterSetContentType(w, "application/json")
-		w.Write(bytes)
+		js, _ := json.Marshal(Response{Status: "NOK", Message: "Streaming is not supported"})
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write(js)
+		return
+	}
+
+	query := req.URL.Query()
+	kind := query.Get("kind")
+	lastEventID := req.Header.Get("Last-Event-ID")
+	if len(lastEventID) == 0 {
+		lastEventID = query.Get("lastEventId")
+	}
+
+	sub := m.SwarmListener.SubscribeEvents(lastEventID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if sub.Resumed {
+		for _, e := range sub.Missed {
+			if err := writeStreamEvent(w, sub.EventID(e.Seq), kind, e); err != nil {
+				return
+			}
+		}
+	} else if query.Get("snapshot") != "false" {
+		for _, e := range m.SwarmListener.GetEventSnapshot() {
+			if err := writeStreamEvent(w, "", kind, e); err != nil {
+				return
+			}
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: snapshot-complete\ndata: {}\n\n", sub.EventID(sub.LastSeq)); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventStreamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			if err := writeStreamEvent(w, sub.EventID(e.Seq), kind, e); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case <-req.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeStreamEvent writes `e` as a server-sent event unless it is filtered
+// out by `kind`
+func writeStreamEvent(w http.ResponseWriter, id, kind string, e service.StreamEvent) error {
+	if len(kind) > 0 && e.Kind != kind {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
 	}
+	if len(id) > 0 {
+		if _, err := fmt.Fprintf(w, "id: %s\n", id); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, data)
+	return err
+}
+
+// ReloadEndpoints reloads notification endpoints from the configuration
+func (m Serve) ReloadEndpoints(w http.ResponseWriter, req *http.Request) {
+	httpWriterSetContentType(w, "application/json")
+	if req.Method != http.MethodPost {
+		js, _ := json.Marshal(Response{Status: "NOK", Message: "Method must be POST"})
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write(js)
+		return
+	}
+	if err := m.Reloader.Reload(); err != nil {
+		m.Log.Error("Unable to reload notification endpoints", "error", err)
+		metrics.RecordError("serveReloadEndpoints")
+		js, _ := json.Marshal(Response{Status: "NOK", Message: err.Error()})
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(js)
+		return
+	}
+	js, _ := json.Marshal(Response{Status: "OK"})
+	w.WriteHeader(http.StatusOK)
+	w.Write(js)
 }
 
 // PingHandler is used for health checks
@@ -117,3 +680,38 @@ func (m Serve) PingHandler(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(js)
 }
+
+// Health reports the health of the listener
+// The status is `503` when the listener is not healthy.
+func (m Serve) Health(w http.ResponseWriter, req *http.Request) {
+	health := m.checkHealth(req)
+	m.writeHealth(w, health, health.Healthy)
+}
+
+// Ready reports the health of the listener
+// The status is `503` when the listener is not ready.
+func (m Serve) Ready(w http.ResponseWriter, req *http.Request) {
+	health := m.checkHealth(req)
+	m.writeHealth(w, health, health.Ready)
+}
+
+func (m Serve) checkHealth(req *http.Request) service.Health {
+	ctx, cancel := context.WithTimeout(req.Context(), healthCheckTimeout)
+	defer cancel()
+	return m.SwarmListener.CheckHealth(ctx)
+}
+
+func (m Serve) writeHealth(w http.ResponseWriter, health service.Health, ok bool) {
+	bytes, err := json.Marshal(health)
+	if err != nil {
+		m.writeServerError(w, err, "serveHealth")
+		return
+	}
+	statusCode := http.StatusOK
+	if !ok {
+		statusCode = http.StatusServiceUnavailable
+	}
+	httpWriterSetContentType(w, "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(bytes)
+}
@@ -1,24 +1,31 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/docker-flow/docker-flow-swarm-listener/service"
+	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
 type ServerTestSuite struct {
 	suite.Suite
-	Log    *log.Logger
-	RWMock *ResponseWriterMock
-	SLMock *SwarmListeningMock
+	Log          *logging.Logger
+	RWMock       *ResponseWriterMock
+	SLMock       *SwarmListeningMock
+	ReloaderMock *ReloadingMock
 }
 
 func TestServerUnitTestSuite(t *testing.T) {
@@ -26,27 +33,84 @@ func TestServerUnitTestSuite(t *testing.T) {
 }
 
 func (s *ServerTestSuite) SetupTest() {
-	s.Log = log.New(os.Stdout, "", 0)
+	s.Log = logging.New(os.Stdout, logging.FormatLogfmt, logging.LevelDebug)
 
 	s.RWMock = new(ResponseWriterMock)
 	s.RWMock.On("Header").Return(nil)
 	s.RWMock.On("Write", mock.Anything).Return(0, nil)
 	s.RWMock.On("WriteHeader", mock.Anything)
 	s.SLMock = new(SwarmListeningMock)
+	s.ReloaderMock = new(ReloadingMock)
 }
 
 func (s *ServerTestSuite) Test_Run_InvokesHTTPListenAndServe() {
-	var actual string
-	expected := fmt.Sprintf(":8080")
-	httpListenAndServe = func(addr string, handler http.Handler) error {
-		actual = addr
+	orig := httpListenAndServe
+	defer func() {
+		httpListenAndServe = orig
+	}()
+	var actual *http.Server
+	var actualCertFile string
+	httpListenAndServe = func(srv *http.Server, certFile, keyFile string) error {
+		actual = srv
+		actualCertFile = certFile
+		return nil
+	}
+
+	serve := Serve{}
+	Run(serve, config.API{Address: ":8080"})
+
+	s.Require().NotNil(actual)
+	s.Equal(":8080", actual.Addr)
+	s.Nil(actual.TLSConfig)
+	s.Empty(actualCertFile)
+}
+
+func (s *ServerTestSuite) Test_Run_ServesTLS_WhenCertificateIsConfigured() {
+	orig := httpListenAndServe
+	defer func() {
+		httpListenAndServe = orig
+	}()
+	var actual *http.Server
+	var actualCertFile, actualKeyFile string
+	httpListenAndServe = func(srv *http.Server, certFile, keyFile string) error {
+		actual = srv
+		actualCertFile = certFile
+		actualKeyFile = keyFile
 		return nil
 	}
 
 	serve := Serve{}
-	Run(serve)
+	err := Run(serve, config.API{
+		Address: ":8443",
+		TLS: config.APITLS{
+			CertFile:   "/certs/server.pem",
+			KeyFile:    "/certs/server-key.pem",
+			MinVersion: "1.2",
+		},
+	})
 
-	s.Equal(expected, actual)
+	s.Require().NoError(err)
+	s.Equal(":8443", actual.Addr)
+	s.Equal("/certs/server.pem", actualCertFile)
+	s.Equal("/certs/server-key.pem", actualKeyFile)
+	s.Require().NotNil(actual.TLSConfig)
+	s.Equal(uint16(tls.VersionTLS12), actual.TLSConfig.MinVersion)
+	s.Equal(tls.NoClientCert, actual.TLSConfig.ClientAuth)
+}
+
+func (s *ServerTestSuite) Test_Run_ReturnsError_WhenClientCAFileCannotBeRead() {
+	serve := Serve{}
+	err := Run(serve, config.API{
+		Address: ":8443",
+		TLS: config.APITLS{
+			CertFile:     "/certs/server.pem",
+			KeyFile:      "/certs/server-key.pem",
+			ClientCAFile: "/does/not/exist.pem",
+		},
+	})
+
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Unable to read client CA bundle")
 }
 
 func (s *ServerTestSuite) Test_Run_ReturnsError_WhenHTTPListenAndServeFails() {
@@ -54,12 +118,12 @@ func (s *ServerTestSuite) Test_Run_ReturnsError_WhenHTTPListenAndServeFails() {
 	defer func() {
 		httpListenAndServe = orig
 	}()
-	httpListenAndServe = func(addr string, handler http.Handler) error {
+	httpListenAndServe = func(srv *http.Server, certFile, keyFile string) error {
 		return fmt.Errorf("This is an error")
 	}
 
 	serve := Serve{}
-	actual := Run(serve)
+	actual := Run(serve, config.API{Address: ":8080"})
 
 	s.Error(actual)
 }
@@ -76,6 +140,46 @@ func (s *ServerTestSuite) Test_RestNotifyServices_RoutesTo_NotifyServices() {
 	sm.AssertExpectations(s.T())
 }
 
+func (s *ServerTestSuite) Test_RestNotifyNodes_RoutesTo_NotifyNodes() {
+	sm := new(serverMock)
+	sm.On("NotifyNodes", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-nodes", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	sm.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_RestResync_RoutesTo_Resync() {
+	sm := new(serverMock)
+	sm.On("Resync", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	req := httptest.NewRequest("POST", "/v1/docker-flow-swarm-listener/resync", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	sm.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_RestHealth_RoutesTo_Health() {
+	sm := new(serverMock)
+	sm.On("Health", mock.Anything, mock.Anything).Return(nil)
+	sm.On("Ready", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	for _, path := range []string{
+		"/v1/docker-flow-swarm-listener/health",
+		"/v1/docker-flow-swarm-listener/ready",
+	} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	sm.AssertExpectations(s.T())
+}
+
 func (s *ServerTestSuite) Test_RestGetNodes_RoutesTo_GetNodes() {
 
 	sm := new(serverMock)
@@ -121,7 +225,7 @@ func (s *ServerTestSuite) Test_NotifyServices_ReturnsStatus200() {
 	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-services", nil)
 	expected, _ := json.Marshal(Response{Status: "OK"})
 
-	srv := NewServe(s.SLMock, s.Log)
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
 	srv.NotifyServices(s.RWMock, req)
 
 	s.RWMock.AssertCalled(s.T(), "WriteHeader", 200)
@@ -138,12 +242,235 @@ func (s *ServerTestSuite) Test_NotifyServices_SetsContentTypeToJSON() {
 	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-services", nil)
 	s.SLMock.On("NotifyServices", false).Return()
 
-	srv := NewServe(s.SLMock, s.Log)
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
 	srv.NotifyServices(s.RWMock, req)
 
 	s.Equal("application/json", actual)
 }
 
+func (s *ServerTestSuite) Test_NotifyServices_NotifiesSelectedServicesToSelectedHosts() {
+	filter := service.ServiceFilter{
+		NamesOrIDs: []string{"web", "sid2"},
+		Stack:      "shop",
+		Labels:     []service.LabelSelector{{Key: "com.df.servicePath"}},
+	}
+	notified := make(chan struct{})
+	s.SLMock.On("ResolveNotifyHosts", []string{"proxy"}).Return([]string{"proxy:8080"}, nil)
+	s.SLMock.On("NotifySelectedServices", mock.Anything, filter, []string{"proxy:8080"}).
+		Return([]service.DeliveryResult{}, nil).
+		Run(func(args mock.Arguments) { close(notified) })
+	req := httptest.NewRequest("GET",
+		"/v1/docker-flow-swarm-listener/notify-services?service=web,sid2&stack=shop&label=com.df.servicePath&host=proxy", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServices(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	select {
+	case <-notified:
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+	s.SLMock.AssertNotCalled(s.T(), "NotifyServices", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_NotifyServices_ReturnsStatus400_WhenHostDoesNotMatchAnEndpoint() {
+	s.SLMock.On("ResolveNotifyHosts", []string{"unknown"}).
+		Return([]string(nil), fmt.Errorf("unknown does not match a notification endpoint"))
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-services?host=unknown", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServices(w, req)
+
+	s.Equal(http.StatusBadRequest, w.Code)
+	s.JSONEq(`{"Status":"NOK","Message":"unknown does not match a notification endpoint"}`, w.Body.String())
+	s.SLMock.AssertNotCalled(s.T(), "NotifySelectedServices", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_NotifyServices_WaitsAndReportsDeliveries() {
+	results := []service.DeliveryResult{
+		{
+			ID:     "sid1",
+			Name:   "web",
+			Status: service.DeliveryStatusDelivered,
+			Endpoints: []service.EndpointDelivery{
+				{Host: "proxy:8080", StatusCode: 200, Attempts: 2, Retries: 1},
+			},
+		},
+	}
+	s.SLMock.On("NotifySelectedServices", mock.Anything, service.ServiceFilter{NamesOrIDs: []string{}, Stack: "shop"}, []string(nil)).
+		Return(results, nil)
+	req := httptest.NewRequest("GET",
+		"/v1/docker-flow-swarm-listener/notify-services?stack=shop&wait=true", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServices(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{"Status":"OK","Results":[{"id":"sid1","name":"web","status":"delivered",
+		"endpoints":[{"host":"proxy:8080","statusCode":200,"attempts":2,"retries":1}]}]}`, w.Body.String())
+	s.SLMock.AssertNotCalled(s.T(), "NotifyServices", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_NotifyServices_WaitsForAllServices_WhenNothingIsSelected() {
+	s.SLMock.On("NotifySelectedServices", mock.Anything, service.ServiceFilter{NamesOrIDs: []string{}}, []string(nil)).
+		Return([]service.DeliveryResult{}, nil).
+		Run(func(args mock.Arguments) {
+			deadline, ok := args.Get(0).(context.Context).Deadline()
+			s.True(ok)
+			s.WithinDuration(time.Now().Add(time.Second*5), deadline, time.Second)
+		})
+	req := httptest.NewRequest("GET",
+		"/v1/docker-flow-swarm-listener/notify-services?wait=true&timeout=5s", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServices(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.SLMock.AssertNotCalled(s.T(), "NotifyServices", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_NotifyServices_ReturnsStatus502_WhenDeliveryFailed() {
+	results := []service.DeliveryResult{
+		{ID: "sid1", Name: "web", Status: service.DeliveryStatusFailed,
+			Endpoints: []service.EndpointDelivery{{Host: "proxy", StatusCode: 500, Attempts: 1, Error: "failed"}}},
+		{ID: "sid2", Name: "api", Status: service.DeliveryStatusNotRunning, Endpoints: []service.EndpointDelivery{}},
+	}
+	s.SLMock.On("NotifySelectedServices", mock.Anything, mock.Anything, mock.Anything).Return(results, nil)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-services?wait=true", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServices(w, req)
+
+	s.Equal(http.StatusBadGateway, w.Code)
+	s.Contains(w.Body.String(), `"Message":"Notifications could not be delivered"`)
+}
+
+func (s *ServerTestSuite) Test_NotifyServices_ReturnsStatus504_WhenDeliveryIsPending() {
+	results := []service.DeliveryResult{
+		{ID: "sid1", Status: service.DeliveryStatusFailed, Endpoints: []service.EndpointDelivery{}},
+		{ID: "sid2", Status: service.DeliveryStatusPending, Endpoints: []service.EndpointDelivery{}},
+	}
+	s.SLMock.On("NotifySelectedServices", mock.Anything, mock.Anything, mock.Anything).Return(results, nil)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-services?wait=true", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServices(w, req)
+
+	s.Equal(http.StatusGatewayTimeout, w.Code)
+	s.Contains(w.Body.String(), `"Message":"Timed out waiting for notifications to be delivered"`)
+}
+
+func (s *ServerTestSuite) Test_NotifyServices_ReturnsStatus400_WhenTimeoutIsInvalid() {
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-services?wait=true&timeout=soon", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyServices(w, req)
+
+	s.Equal(http.StatusBadRequest, w.Code)
+	s.JSONEq(`{"Status":"NOK","Message":"timeout must be a positive duration"}`, w.Body.String())
+	s.SLMock.AssertNotCalled(s.T(), "NotifySelectedServices", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// NotifyNodes
+
+func (s *ServerTestSuite) Test_NotifyNodes_NotifiesAllNodes_WhenNothingIsSelected() {
+	notified := make(chan struct{})
+	s.SLMock.On("NotifyNodes", false).Return().Run(func(args mock.Arguments) { close(notified) })
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-nodes", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyNodes(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{"Status":"OK"}`, w.Body.String())
+	select {
+	case <-notified:
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+}
+
+func (s *ServerTestSuite) Test_NotifyNodes_NotifiesSelectedNodesToSelectedHosts() {
+	filter := service.NodeFilter{NamesOrIDs: []string{"node1"}, Role: swarm.NodeRoleManager}
+	notified := make(chan struct{})
+	s.SLMock.On("ResolveNotifyHosts", []string{"dns"}).Return([]string{"dns:53"}, nil)
+	s.SLMock.On("NotifySelectedNodes", mock.Anything, filter, []string{"dns:53"}).
+		Return([]service.DeliveryResult{}, nil).
+		Run(func(args mock.Arguments) { close(notified) })
+	req := httptest.NewRequest("GET",
+		"/v1/docker-flow-swarm-listener/notify-nodes?node=node1&role=manager&host=dns", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyNodes(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	select {
+	case <-notified:
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+	s.SLMock.AssertNotCalled(s.T(), "NotifyNodes", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_NotifyNodes_WaitsAndReportsDeliveries() {
+	results := []service.DeliveryResult{
+		{ID: "nodeID1", Name: "node1", Status: service.DeliveryStatusDelivered,
+			Endpoints: []service.EndpointDelivery{{Host: "dns:53", StatusCode: 200, Attempts: 1}}},
+	}
+	s.SLMock.On("NotifySelectedNodes", mock.Anything, service.NodeFilter{NamesOrIDs: []string{}}, []string(nil)).
+		Return(results, nil)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-nodes?wait=true", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyNodes(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{"Status":"OK","Results":[{"id":"nodeID1","name":"node1","status":"delivered",
+		"endpoints":[{"host":"dns:53","statusCode":200,"attempts":1,"retries":0}]}]}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_NotifyNodes_ReturnsStatus400_WhenRoleIsInvalid() {
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/notify-nodes?role=leader", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.NotifyNodes(w, req)
+
+	s.Equal(http.StatusBadRequest, w.Code)
+	s.JSONEq(`{"Status":"NOK","Message":"role must be worker or manager"}`, w.Body.String())
+}
+
+// Resync
+
+func (s *ServerTestSuite) Test_Resync_ResyncsServicesAndNodes() {
+	resynced := make(chan struct{})
+	s.SLMock.On("Resync").Return().Run(func(args mock.Arguments) { close(resynced) })
+	req := httptest.NewRequest("POST", "/v1/docker-flow-swarm-listener/resync", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.Resync(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{"Status":"OK"}`, w.Body.String())
+	select {
+	case <-resynced:
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+}
+
 // GetServices
 
 func (s *ServerTestSuite) Test_GetServices_ReturnsServices() {
@@ -155,9 +482,10 @@ func (s *ServerTestSuite) Test_GetServices_ReturnsServices() {
 			"distribute":  "true",
 		},
 	}
-	s.SLMock.On("GetServicesParameters", mock.Anything).Return(mapParam, nil)
+	s.SLMock.On("GetCachedServicesParameters", service.ServiceFilter{}).Return([]map[string]string(nil), time.Time{}, false)
+	s.SLMock.On("GetServicesParameters", mock.Anything, service.ServiceFilter{}).Return(mapParam, nil)
 	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-services", nil)
-	srv := NewServe(s.SLMock, s.Log)
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
 	srv.GetServices(s.RWMock, req)
 
 	call := s.RWMock.GetLastMethodCall("Write")
@@ -167,6 +495,126 @@ func (s *ServerTestSuite) Test_GetServices_ReturnsServices() {
 	s.Equal(mapParam, rsp)
 }
 
+func (s *ServerTestSuite) Test_GetServices_ReturnsCachedServices() {
+	mapParam := []map[string]string{{"serviceName": "demo"}}
+	updatedAt := time.Now().UTC().Add(-time.Minute)
+	s.SLMock.On("GetCachedServicesParameters", service.ServiceFilter{}).Return(mapParam, updatedAt, true)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-services", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetServices(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("60", w.Header().Get("Age"))
+	s.Equal(updatedAt.Format(time.RFC3339), w.Header().Get("X-Cache-Updated-At"))
+	s.JSONEq(`[{"serviceName":"demo"}]`, w.Body.String())
+	s.SLMock.AssertNotCalled(s.T(), "GetServicesParameters", mock.Anything, mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_GetServices_QueriesServices_WhenFresh() {
+	mapParam := []map[string]string{{"serviceName": "demo"}}
+	s.SLMock.On("GetServicesParameters", mock.Anything, service.ServiceFilter{}).Return(mapParam, nil)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-services?fresh=true", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetServices(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Empty(w.Header().Get("Age"))
+	s.JSONEq(`[{"serviceName":"demo"}]`, w.Body.String())
+	s.SLMock.AssertNotCalled(s.T(), "GetCachedServicesParameters", mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_GetServices_FiltersAndPagesServices() {
+	filter := service.ServiceFilter{
+		Stack:  "shop",
+		Labels: []service.LabelSelector{{Key: "com.df.servicePath"}},
+	}
+	mapParam := []map[string]string{
+		{"serviceName": "web", "servicePath": "/web"},
+		{"serviceName": "api", "servicePath": "/api"},
+		{"serviceName": "cart", "servicePath": "/cart"},
+	}
+	s.SLMock.On("GetCachedServicesParameters", filter).Return(mapParam, time.Now(), true)
+	req := httptest.NewRequest("GET",
+		"/v1/docker-flow-swarm-listener/get-services?stack=shop&label=com.df.servicePath&fields=serviceName&limit=2&offset=1", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetServices(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("3", w.Header().Get("X-Total-Count"))
+	s.JSONEq(`[{"serviceName":"cart"},{"serviceName":"web"}]`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_GetServices_ReturnsStatus400_WhenQueryIsInvalid() {
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-services?limit=all", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetServices(w, req)
+
+	s.Equal(http.StatusBadRequest, w.Code)
+	s.JSONEq(`{"Status":"NOK","Message":"limit must be a non-negative integer"}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_RestGetService_RoutesTo_GetService() {
+
+	sm := new(serverMock)
+	sm.On("GetService", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-services/demo", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	sm.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_GetService_ReturnsService() {
+	filter := service.ServiceFilter{NamesOrIDs: []string{"demo"}}
+	mapParam := []map[string]string{{"serviceName": "demo", "servicePath": "/demo"}}
+	s.SLMock.On("GetCachedServicesParameters", filter).Return(mapParam, time.Now(), true)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-services/demo?fields=servicePath", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetService(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{"servicePath":"/demo"}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_GetService_IgnoresPaging() {
+	filter := service.ServiceFilter{NamesOrIDs: []string{"demo"}}
+	mapParam := []map[string]string{{"serviceName": "demo", "servicePath": "/demo"}}
+	s.SLMock.On("GetCachedServicesParameters", filter).Return(mapParam, time.Now(), true)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-services/demo?offset=1&limit=1", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetService(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.JSONEq(`{"serviceName":"demo","servicePath":"/demo"}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_GetService_ReturnsStatus404_WhenServiceDoesNotExist() {
+	filter := service.ServiceFilter{NamesOrIDs: []string{"demo"}}
+	s.SLMock.On("GetServicesParameters", mock.Anything, filter).Return([]map[string]string{}, nil)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-services/demo?fresh=true", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetService(w, req)
+
+	s.Equal(http.StatusNotFound, w.Code)
+	s.JSONEq(`{"Status":"NOK","Message":"Service demo was not found"}`, w.Body.String())
+}
+
 // GetNodes
 
 func (s *ServerTestSuite) Test_GetNodes_ReturnNodes() {
@@ -181,9 +629,10 @@ func (s *ServerTestSuite) Test_GetNodes_ReturnNodes() {
 			"availability": "active",
 		},
 	}
-	s.SLMock.On("GetNodesParameters", mock.Anything).Return(mapParam, nil)
+	s.SLMock.On("GetCachedNodesParameters", service.NodeFilter{}).Return([]map[string]string(nil), time.Time{}, false)
+	s.SLMock.On("GetNodesParameters", mock.Anything, service.NodeFilter{}).Return(mapParam, nil)
 	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-nodes", nil)
-	srv := NewServe(s.SLMock, s.Log)
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
 	srv.GetNodes(s.RWMock, req)
 	call := s.RWMock.GetLastMethodCall("Write")
 	value, _ := call.Arguments.Get(0).([]byte)
@@ -192,6 +641,262 @@ func (s *ServerTestSuite) Test_GetNodes_ReturnNodes() {
 	s.Equal(mapParam, rsp)
 }
 
+func (s *ServerTestSuite) Test_GetNodes_ReturnsCachedNodes() {
+	mapParam := []map[string]string{{"id": "node1"}}
+	updatedAt := time.Now().UTC()
+	s.SLMock.On("GetCachedNodesParameters", service.NodeFilter{}).Return(mapParam, updatedAt, true)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/get-nodes", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetNodes(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("0", w.Header().Get("Age"))
+	s.JSONEq(`[{"id":"node1"}]`, w.Body.String())
+	s.SLMock.AssertNotCalled(s.T(), "GetNodesParameters", mock.Anything, mock.Anything)
+}
+
+func (s *ServerTestSuite) Test_GetNodes_FiltersNodes() {
+	filter := service.NodeFilter{Role: "manager", Availability: "active"}
+	mapParam := []map[string]string{
+		{"id": "node2", "hostname": "manager", "role": "manager"},
+		{"id": "node1", "hostname": "manager", "role": "manager"},
+	}
+	s.SLMock.On("GetNodesParameters", mock.Anything, filter).Return(mapParam, nil)
+	req := httptest.NewRequest("GET",
+		"/v1/docker-flow-swarm-listener/get-nodes?fresh=true&role=manager&availability=active&fields=id", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetNodes(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("2", w.Header().Get("X-Total-Count"))
+	s.JSONEq(`[{"id":"node1"},{"id":"node2"}]`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_RestReloadEndpoints_RoutesTo_ReloadEndpoints() {
+
+	sm := new(serverMock)
+	sm.On("ReloadEndpoints", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	req := httptest.NewRequest("POST", "/v1/docker-flow-swarm-listener/reload-endpoints", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	sm.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_RestQueue_RoutesTo_GetQueue() {
+
+	sm := new(serverMock)
+	sm.On("GetQueue", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/queue", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	sm.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_RestCircuitBreakers_RoutesTo_GetCircuitBreakers() {
+
+	sm := new(serverMock)
+	sm.On("GetCircuitBreakers", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/circuit-breakers", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	sm.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_RestEvents_RoutesTo_StreamEvents() {
+
+	sm := new(serverMock)
+	sm.On("StreamEvents", mock.Anything, mock.Anything).Return(nil)
+	mux := attachRoutes(sm)
+
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/events", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	sm.AssertExpectations(s.T())
+}
+
+// GetQueue
+
+func (s *ServerTestSuite) Test_GetQueue_ReturnsQueuedNotifications() {
+	queued := map[string][]service.QueueEntry{
+		"proxy": {
+			{Seq: 3, Kind: "service", EventType: service.EventTypeRemove, ID: "sid1",
+				Parameters: "serviceName=demo", Attempts: 2, LastError: "connection refused"},
+		},
+	}
+	s.SLMock.On("GetQueuedNotifications").Return(queued)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/queue", nil)
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetQueue(s.RWMock, req)
+
+	call := s.RWMock.GetLastMethodCall("Write")
+	value, _ := call.Arguments.Get(0).([]byte)
+	rsp := map[string][]service.QueueEntry{}
+	json.Unmarshal(value, &rsp)
+	s.Equal(queued, rsp)
+}
+
+// GetCircuitBreakers
+
+func (s *ServerTestSuite) Test_GetCircuitBreakers_ReturnsCircuitBreakers() {
+	breakers := map[string]service.CircuitBreakerStatus{
+		"proxy":   {State: service.CircuitClosed},
+		"monitor": {State: service.CircuitOpen, ConsecutiveFailures: 5},
+	}
+	s.SLMock.On("GetCircuitBreakers").Return(breakers)
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/circuit-breakers", nil)
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.GetCircuitBreakers(s.RWMock, req)
+
+	call := s.RWMock.GetLastMethodCall("Write")
+	value, _ := call.Arguments.Get(0).([]byte)
+	rsp := map[string]service.CircuitBreakerStatus{}
+	json.Unmarshal(value, &rsp)
+	s.Equal(breakers, rsp)
+}
+
+// StreamEvents
+
+func (s *ServerTestSuite) Test_StreamEvents_SendsSnapshotAndChanges() {
+	stream := service.NewEventStream(10)
+	stream.Publish(service.StreamEvent{Kind: "service", EventType: service.EventTypeCreate, ID: "sid0"})
+	sub := stream.Subscribe("")
+	s.SLMock.On("SubscribeEvents", "").Return(sub)
+	s.SLMock.On("GetEventSnapshot").Return([]service.StreamEvent{
+		{Kind: "service", EventType: service.EventTypeCreate, ID: "sid1",
+			Parameters: map[string]string{"serviceName": "demo"}},
+		{Kind: "node", EventType: service.EventTypeCreate, ID: "nid1",
+			Parameters: map[string]string{"id": "nid1"}},
+	})
+
+	next, stop := s.streamEvents("")
+	defer stop()
+	s.Equal("event: service", next())
+	s.Equal(`data: {"kind":"service","eventType":"create","id":"sid1","parameters":{"serviceName":"demo"}}`, next())
+	s.Equal("", next())
+	s.Equal("event: node", next())
+	s.Equal(`data: {"kind":"node","eventType":"create","id":"nid1","parameters":{"id":"nid1"}}`, next())
+	s.Equal("", next())
+	s.Equal("id: "+sub.EventID(1), next())
+	s.Equal("event: snapshot-complete", next())
+	s.Equal("data: {}", next())
+	s.Equal("", next())
+
+	stream.Publish(service.StreamEvent{Kind: "service", EventType: service.EventTypeRemove, ID: "sid1",
+		Parameters: map[string]string{"serviceName": "demo"}})
+	s.Equal("id: "+sub.EventID(2), next())
+	s.Equal("event: service", next())
+	s.Equal(`data: {"seq":2,"kind":"service","eventType":"remove","id":"sid1","parameters":{"serviceName":"demo"}}`, next())
+}
+
+func (s *ServerTestSuite) Test_StreamEvents_ResumesFromLastEventID() {
+	stream := service.NewEventStream(10)
+	first := stream.Subscribe("")
+	lastEventID := first.EventID(1)
+	first.Close()
+	stream.Publish(service.StreamEvent{Kind: "service", EventType: service.EventTypeCreate, ID: "sid1"})
+	stream.Publish(service.StreamEvent{Kind: "node", EventType: service.EventTypeCreate, ID: "nid1"})
+	stream.Publish(service.StreamEvent{Kind: "service", EventType: service.EventTypeCreate, ID: "sid2"})
+	sub := stream.Subscribe(lastEventID)
+	s.SLMock.On("SubscribeEvents", lastEventID).Return(sub)
+
+	next, stop := s.streamEvents("?kind=service&lastEventId=" + lastEventID)
+	defer stop()
+	s.Equal("id: "+sub.EventID(3), next())
+	s.Equal("event: service", next())
+	s.Equal(`data: {"seq":3,"kind":"service","eventType":"create","id":"sid2","parameters":null}`, next())
+	s.SLMock.AssertNotCalled(s.T(), "GetEventSnapshot")
+}
+
+// streamEvents requests the event stream and returns a function that
+// reads the next line of the response
+func (s *ServerTestSuite) streamEvents(query string) (func() string, func()) {
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	ts := httptest.NewServer(http.HandlerFunc(srv.StreamEvents))
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", ts.URL+query, nil)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	s.Require().NoError(err)
+	s.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := make(chan string, 100)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(time.Second * 5):
+			s.FailNow("Timeout")
+		}
+		return ""
+	}
+	stop := func() {
+		cancel()
+		resp.Body.Close()
+		ts.Close()
+	}
+	return next, stop
+}
+
+// ReloadEndpoints
+
+func (s *ServerTestSuite) Test_ReloadEndpoints_ReturnsStatus200() {
+	s.ReloaderMock.On("Reload").Return(nil)
+
+	req, _ := http.NewRequest("POST", "/v1/docker-flow-swarm-listener/reload-endpoints", nil)
+	expected, _ := json.Marshal(Response{Status: "OK"})
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.ReloadEndpoints(s.RWMock, req)
+
+	s.ReloaderMock.AssertExpectations(s.T())
+	s.RWMock.AssertCalled(s.T(), "WriteHeader", 200)
+	s.RWMock.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ReloadEndpoints_ReturnsStatus400_WhenReloadFails() {
+	s.ReloaderMock.On("Reload").Return(fmt.Errorf("invalid configuration"))
+
+	req, _ := http.NewRequest("POST", "/v1/docker-flow-swarm-listener/reload-endpoints", nil)
+	expected, _ := json.Marshal(Response{Status: "NOK", Message: "invalid configuration"})
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.ReloadEndpoints(s.RWMock, req)
+
+	s.RWMock.AssertCalled(s.T(), "WriteHeader", 400)
+	s.RWMock.AssertCalled(s.T(), "Write", []byte(expected))
+}
+
+func (s *ServerTestSuite) Test_ReloadEndpoints_ReturnsStatus405_WhenMethodIsNotPost() {
+	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/reload-endpoints", nil)
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.ReloadEndpoints(s.RWMock, req)
+
+	s.ReloaderMock.AssertNotCalled(s.T(), "Reload")
+	s.RWMock.AssertCalled(s.T(), "WriteHeader", 405)
+}
+
 // PingHandler
 
 func (s *ServerTestSuite) Test_PingHandler_ReturnsStatus200() {
@@ -204,7 +909,7 @@ func (s *ServerTestSuite) Test_PingHandler_ReturnsStatus200() {
 	req, _ := http.NewRequest("GET", "/v1/docker-flow-swarm-listener/ping", nil)
 	expected, _ := json.Marshal(Response{Status: "OK"})
 
-	srv := NewServe(s.SLMock, s.Log)
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
 	srv.PingHandler(s.RWMock, req)
 
 	s.Equal("application/json", actual)
@@ -212,6 +917,62 @@ func (s *ServerTestSuite) Test_PingHandler_ReturnsStatus200() {
 	s.RWMock.AssertCalled(s.T(), "Write", []byte(expected))
 }
 
+// Health
+
+func (s *ServerTestSuite) Test_Health_ReturnsStatus200_WhenHealthy() {
+	health := service.Health{
+		Healthy:      true,
+		Ready:        true,
+		Docker:       service.DockerHealth{Connected: true, APIVersion: "1.38", ClientVersion: "1.37"},
+		EventStreams: map[string]service.EventStreamHealth{"services": {Connected: true}},
+		Pollers:      map[string]service.PollerHealth{},
+		Caches:       map[string]int{"services": 2},
+		Endpoints:    map[string]service.EndpointHealth{"proxy": {ConsecutiveFailures: 1}},
+	}
+	s.SLMock.On("CheckHealth", mock.Anything).Return(health)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/health", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.Health(w, req)
+
+	s.Equal(http.StatusOK, w.Code)
+	s.Equal("application/json", w.Header().Get("Content-Type"))
+	s.JSONEq(`{"healthy":true,"ready":true,
+		"docker":{"connected":true,"apiVersion":"1.38","clientVersion":"1.37"},
+		"eventStreams":{"services":{"connected":true,"restarts":0}},
+		"pollers":{},
+		"caches":{"services":2},
+		"endpoints":{"proxy":{"consecutiveFailures":1,"queued":0}}}`, w.Body.String())
+}
+
+func (s *ServerTestSuite) Test_Health_ReturnsStatus503_WhenNotHealthy() {
+	health := service.Health{Ready: true, Problems: []string{"The services poller did not succeed"}}
+	s.SLMock.On("CheckHealth", mock.Anything).Return(health)
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+
+	w := httptest.NewRecorder()
+	srv.Health(w, httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/health", nil))
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+	s.Contains(w.Body.String(), "The services poller did not succeed")
+
+	w = httptest.NewRecorder()
+	srv.Ready(w, httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/ready", nil))
+	s.Equal(http.StatusOK, w.Code)
+}
+
+func (s *ServerTestSuite) Test_Ready_ReturnsStatus503_WhenNotReady() {
+	health := service.Health{Problems: []string{"Unable to connect to Docker: connection refused"}}
+	s.SLMock.On("CheckHealth", mock.Anything).Return(health)
+	req := httptest.NewRequest("GET", "/v1/docker-flow-swarm-listener/ready", nil)
+	w := httptest.NewRecorder()
+
+	srv := NewServe(s.SLMock, s.ReloaderMock, s.Log)
+	srv.Ready(w, req)
+
+	s.Equal(http.StatusServiceUnavailable, w.Code)
+}
+
 // Mocks
 
 type ResponseWriterMock struct {
@@ -248,21 +1009,109 @@ type SwarmListeningMock struct {
 func (m *SwarmListeningMock) Run() {
 	m.Called()
 }
+func (m *SwarmListeningMock) IsLeader() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
 func (m *SwarmListeningMock) NotifyServices(consultCache bool) {
 	m.Called(consultCache)
 }
 func (m *SwarmListeningMock) NotifyNodes(consultCache bool) {
 	m.Called(consultCache)
 }
-func (m *SwarmListeningMock) GetServicesParameters(ctx context.Context) ([]map[string]string, error) {
-	args := m.Called(ctx)
+func (m *SwarmListeningMock) NotifySelectedNodes(
+	ctx context.Context, filter service.NodeFilter, hosts []string) ([]service.DeliveryResult, error) {
+	args := m.Called(ctx, filter, hosts)
+	return args.Get(0).([]service.DeliveryResult), args.Error(1)
+}
+func (m *SwarmListeningMock) Resync() {
+	m.Called()
+}
+func (m *SwarmListeningMock) NotifySelectedServices(
+	ctx context.Context, filter service.ServiceFilter, hosts []string) ([]service.DeliveryResult, error) {
+	args := m.Called(ctx, filter, hosts)
+	return args.Get(0).([]service.DeliveryResult), args.Error(1)
+}
+func (m *SwarmListeningMock) ResolveNotifyHosts(targets []string) ([]string, error) {
+	args := m.Called(targets)
+	return args.Get(0).([]string), args.Error(1)
+}
+func (m *SwarmListeningMock) GetServicesParameters(ctx context.Context, filter service.ServiceFilter) ([]map[string]string, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]map[string]string), args.Error(1)
 }
-func (m *SwarmListeningMock) GetNodesParameters(ctx context.Context) ([]map[string]string, error) {
-	args := m.Called(ctx)
+func (m *SwarmListeningMock) GetNodesParameters(ctx context.Context, filter service.NodeFilter) ([]map[string]string, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).([]map[string]string), args.Error(1)
 }
 
+func (m *SwarmListeningMock) GetCachedServicesParameters(filter service.ServiceFilter) ([]map[string]string, time.Time, bool) {
+	args := m.Called(filter)
+	return args.Get(0).([]map[string]string), args.Get(1).(time.Time), args.Bool(2)
+}
+
+func (m *SwarmListeningMock) GetCachedNodesParameters(filter service.NodeFilter) ([]map[string]string, time.Time, bool) {
+	args := m.Called(filter)
+	return args.Get(0).([]map[string]string), args.Get(1).(time.Time), args.Bool(2)
+}
+func (m *SwarmListeningMock) UpdateNotifyEndpoints(c *config.Config) error {
+	return m.Called(c).Error(0)
+}
+
+func (m *SwarmListeningMock) GetQueuedNotifications() map[string][]service.QueueEntry {
+	return m.Called().Get(0).(map[string][]service.QueueEntry)
+}
+
+func (m *SwarmListeningMock) GetNotificationHistory(filter service.AuditFilter) ([]service.AuditRecord, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]service.AuditRecord), args.Error(1)
+}
+
+func (m *SwarmListeningMock) GetCircuitBreakers() map[string]service.CircuitBreakerStatus {
+	return m.Called().Get(0).(map[string]service.CircuitBreakerStatus)
+}
+
+func (m *SwarmListeningMock) SubscribeEvents(lastEventID string) *service.EventSubscription {
+	return m.Called(lastEventID).Get(0).(*service.EventSubscription)
+}
+
+func (m *SwarmListeningMock) GetEventSnapshot() []service.StreamEvent {
+	return m.Called().Get(0).([]service.StreamEvent)
+}
+
+func (m *SwarmListeningMock) GetServices(
+	ctx context.Context, filter service.ServiceFilter) ([]service.SwarmServiceMini, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]service.SwarmServiceMini), args.Error(1)
+}
+
+func (m *SwarmListeningMock) GetNodes(ctx context.Context, filter service.NodeFilter) ([]service.NodeMini, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]service.NodeMini), args.Error(1)
+}
+
+func (m *SwarmListeningMock) GetCachedServices(filter service.ServiceFilter) ([]service.SwarmServiceMini, time.Time, bool) {
+	args := m.Called(filter)
+	return args.Get(0).([]service.SwarmServiceMini), args.Get(1).(time.Time), args.Bool(2)
+}
+
+func (m *SwarmListeningMock) GetCachedNodes(filter service.NodeFilter) ([]service.NodeMini, time.Time, bool) {
+	args := m.Called(filter)
+	return args.Get(0).([]service.NodeMini), args.Get(1).(time.Time), args.Bool(2)
+}
+
+func (m *SwarmListeningMock) CheckHealth(ctx context.Context) service.Health {
+	return m.Called(ctx).Get(0).(service.Health)
+}
+
+type ReloadingMock struct {
+	mock.Mock
+}
+
+func (m *ReloadingMock) Reload() error {
+	return m.Called().Error(0)
+}
+
 type serverMock struct {
 	mock.Mock
 }
@@ -271,13 +1120,133 @@ func (m *serverMock) NotifyServices(w http.ResponseWriter, req *http.Request) {
 	m.Called(w, req)
 }
 
+func (m *serverMock) IsLeader() bool {
+	args := m.Called()
+	return args.Bool(0)
+}
+
+func (m *serverMock) NotifyNodes(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) Resync(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
 func (m *serverMock) GetServices(w http.ResponseWriter, req *http.Request) {
 	m.Called(w, req)
 }
 
+func (m *serverMock) GetService(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
 func (m *serverMock) GetNodes(w http.ResponseWriter, req *http.Request) {
 	m.Called(w, req)
 }
 func (m *serverMock) PingHandler(w http.ResponseWriter, req *http.Request) {
 	m.Called(w, req)
 }
+
+func (m *serverMock) Health(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) Ready(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) ReloadEndpoints(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) GetQueue(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) GetCircuitBreakers(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) StreamEvents(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) GetServicesV2(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) GetServiceV2(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) GetNodesV2(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) GetNodeV2(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) NotifyServicesV2(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) NotifyNodesV2(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) ResyncV2(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) OpenAPI(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) NotFoundV2(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) AdminServiceCache(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) AdminServiceCacheEntry(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) AdminNodeCache(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) AdminNodeCacheEntry(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *serverMock) GetNotificationHistory(w http.ResponseWriter, req *http.Request) {
+	m.Called(w, req)
+}
+
+func (m *SwarmListeningMock) EvictCachedService(nameOrID string) (service.SwarmServiceMini, bool) {
+	args := m.Called(nameOrID)
+	return args.Get(0).(service.SwarmServiceMini), args.Bool(1)
+}
+
+func (m *SwarmListeningMock) EvictCachedNode(nameOrID string) (service.NodeMini, bool) {
+	args := m.Called(nameOrID)
+	return args.Get(0).(service.NodeMini), args.Bool(1)
+}
+
+func (m *SwarmListeningMock) FlushServiceCache() int {
+	return m.Called().Int(0)
+}
+
+func (m *SwarmListeningMock) FlushNodeCache() int {
+	return m.Called().Int(0)
+}
+
+func (m *SwarmListeningMock) DiffService(ctx context.Context, nameOrID string) (service.ServiceDiff, error) {
+	args := m.Called(ctx, nameOrID)
+	return args.Get(0).(service.ServiceDiff), args.Error(1)
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// AuditStatusSuccess is the status of deliveries accepted by the endpoint
+	AuditStatusSuccess = "success"
+	// AuditStatusFailure is the status of deliveries that failed after all
+	// attempts
+	AuditStatusFailure = "failure"
+	// AuditStatusCanceled is the status of deliveries canceled by a newer
+	// notification
+	AuditStatusCanceled = "canceled"
+
+	// auditResponseBodyLimit is the number of bytes of the response body
+	// that are recorded
+	auditResponseBodyLimit = 512
+	// defaultAuditMaxSize is the size in megabytes after which the audit
+	// file is rotated
+	defaultAuditMaxSize = 10
+	// defaultAuditMaxBackups is the number of rotated audit files that are
+	// kept
+	defaultAuditMaxBackups = 5
+)
+
+// ErrAuditDisabled is returned when the history of notifications is
+// requested without an audit log
+var ErrAuditDisabled = errors.New("Notification history is disabled")
+
+// AuditRecord is the result of delivering a notification to an endpoint
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// RequestID is the time of the event that caused the notification
+	RequestID int64     `json:"requestId"`
+	Kind      string    `json:"kind"`
+	EventType EventType `json:"eventType"`
+	ID        string    `json:"id"`
+	// Name is the name of the service or the hostname of the node
+	Name       string            `json:"name,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Endpoint   string            `json:"endpoint"`
+	Attempts   int               `json:"attempts"`
+	// StatusCode is the status code of the last response
+	// It is zero when no response was received.
+	StatusCode   int    `json:"statusCode,omitempty"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	ResponseBody string `json:"responseBody,omitempty"`
+}
+
+// AuditFilter selects audit records
+// Zero values match all records.
+type AuditFilter struct {
+	// Service matches the ID or name of the service of service
+	// notifications
+	Service  string
+	Endpoint string
+	Status   string
+	Since    time.Time
+	Until    time.Time
+}
+
+// Match returns true when `r` is selected by the filter
+func (f AuditFilter) Match(r AuditRecord) bool {
+	if len(f.Service) > 0 &&
+		(r.Kind != QueueKindService || (r.ID != f.Service && r.Name != f.Service)) {
+		return false
+	}
+	if len(f.Endpoint) > 0 && !matchEndpointHost(r.Endpoint, f.Endpoint) {
+		return false
+	}
+	if len(f.Status) > 0 && r.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && r.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && r.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// AuditLog is an append-only file of `AuditRecord`s
+// The file is rotated when it grows beyond its maximum size. Rotated files
+// are kept next to it with the suffixes `.1` (newest) to `.<maxBackups>`.
+type AuditLog struct {
+	filename   string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	mux        sync.Mutex
+}
+
+// OpenAuditLog opens or creates the audit log stored in `filename`
+// `maxSize` is in megabytes. Zero values of `maxSize` and `maxBackups`
+// are replaced with defaults.
+func OpenAuditLog(filename string, maxSize, maxBackups int) (*AuditLog, error) {
+	if maxSize <= 0 {
+		maxSize = defaultAuditMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = defaultAuditMaxBackups
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create audit directory: %v", err)
+	}
+	a := &AuditLog{
+		filename:   filename,
+		maxSize:    int64(maxSize) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AuditLog) open() error {
+	file, err := os.OpenFile(a.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to open audit file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Unable to open audit file: %v", err)
+	}
+	a.file = file
+	a.size = info.Size()
+	return nil
+}
+
+// Record appends `r` to the audit log
+func (a *AuditLog) Record(r AuditRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.file == nil {
+		return errors.New("audit log closed")
+	}
+	if a.size > 0 && a.size+int64(len(line)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	return err
+}
+
+// rotate renames the audit file to the first backup, shifts the other
+// backups, and removes the oldest one
+func (a *AuditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	a.file = nil
+	os.Remove(a.backupFilename(a.maxBackups))
+	for i := a.maxBackups - 1; i > 0; i-- {
+		os.Rename(a.backupFilename(i), a.backupFilename(i+1))
+	}
+	if err := os.Rename(a.filename, a.backupFilename(1)); err != nil {
+		return fmt.Errorf("Unable to rotate audit file: %v", err)
+	}
+	return a.open()
+}
+
+func (a *AuditLog) backupFilename(i int) string {
+	return fmt.Sprintf("%s.%d", a.filename, i)
+}
+
+// Query returns the records selected by `filter`, newest first
+// Rotated files are read as well.
+func (a *AuditLog) Query(filter AuditFilter) ([]AuditRecord, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	records := []AuditRecord{}
+	filenames := []string{a.filename}
+	for i := 1; i <= a.maxBackups; i++ {
+		filenames = append(filenames, a.backupFilename(i))
+	}
+	for _, filename := range filenames {
+		fileRecords, err := readAuditFile(filename, filter)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, fileRecords...)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.After(records[j].Time)
+	})
+	return records, nil
+}
+
+// readAuditFile returns the records of `filename` selected by `filter`
+// Missing files have no records and lines that cannot be decoded, such as
+// a line cut short by a crash, are skipped.
+func readAuditFile(filename string, filter AuditFilter) ([]AuditRecord, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Unable to read audit file: %v", err)
+	}
+	defer file.Close()
+
+	records := []AuditRecord{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			continue
+		}
+		if filter.Match(r) {
+			records = append(records, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to read audit file: %v", err)
+	}
+	return records, nil
+}
+
+// Close closes the audit file
+func (a *AuditLog) Close() error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// responseExcerpt returns the beginning of `body` that is recorded in the
+// audit log
+func responseExcerpt(body []byte) string {
+	if len(body) <= auditResponseBodyLimit {
+		return strings.TrimSpace(string(body))
+	}
+	body = body[:auditResponseBodyLimit]
+	// Drop a rune that was cut in half
+	for i := 0; i < utf8.UTFMax-1 && len(body) > 0; i++ {
+		if r, size := utf8.DecodeLastRune(body); r != utf8.RuneError || size != 1 {
+			break
+		}
+		body = body[:len(body)-1]
+	}
+	return strings.TrimSpace(string(body)) + "..."
+}
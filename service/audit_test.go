@@ -0,0 +1,146 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AuditLogTestSuite struct {
+	suite.Suite
+	tempDir  string
+	filename string
+}
+
+func TestAuditLogUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(AuditLogTestSuite))
+}
+
+func (s *AuditLogTestSuite) SetupTest() {
+	tempDir, err := ioutil.TempDir("", "dfsl-audit")
+	s.Require().NoError(err)
+	s.tempDir = tempDir
+	s.filename = filepath.Join(tempDir, "audit", "audit.log")
+}
+
+func (s *AuditLogTestSuite) TearDownTest() {
+	os.RemoveAll(s.tempDir)
+}
+
+func (s *AuditLogTestSuite) record(minute int, kind, id, name, endpoint, status string) AuditRecord {
+	return AuditRecord{
+		Time:      time.Date(2018, 10, 27, 10, minute, 0, 0, time.UTC),
+		RequestID: int64(minute),
+		Kind:      kind,
+		EventType: EventTypeCreate,
+		ID:        id,
+		Name:      name,
+		Endpoint:  endpoint,
+		Attempts:  1,
+		Status:    status,
+	}
+}
+
+func (s *AuditLogTestSuite) Test_Query_ReturnsRecordsNewestFirst_AfterReopening() {
+	a, err := OpenAuditLog(s.filename, 0, 0)
+	s.Require().NoError(err)
+	r1 := s.record(1, QueueKindService, "sid1", "go", "proxy:8080", AuditStatusSuccess)
+	r2 := s.record(2, QueueKindNode, "nid1", "node1", "proxy:8080", AuditStatusFailure)
+	s.Require().NoError(a.Record(r1))
+	s.Require().NoError(a.Record(r2))
+	s.Require().NoError(a.Close())
+
+	a, err = OpenAuditLog(s.filename, 0, 0)
+	s.Require().NoError(err)
+	defer a.Close()
+	records, err := a.Query(AuditFilter{})
+
+	s.Require().NoError(err)
+	s.Equal([]AuditRecord{r2, r1}, records)
+}
+
+func (s *AuditLogTestSuite) Test_Query_FiltersRecords() {
+	a, err := OpenAuditLog(s.filename, 0, 0)
+	s.Require().NoError(err)
+	defer a.Close()
+	r1 := s.record(1, QueueKindService, "sid1", "go", "proxy:8080", AuditStatusSuccess)
+	r2 := s.record(2, QueueKindService, "sid2", "web", "proxy:8080", AuditStatusFailure)
+	r3 := s.record(3, QueueKindService, "sid1", "go", "monitor:9090", AuditStatusCanceled)
+	r4 := s.record(4, QueueKindNode, "go", "go", "proxy:8080", AuditStatusSuccess)
+	for _, r := range []AuditRecord{r1, r2, r3, r4} {
+		s.Require().NoError(a.Record(r))
+	}
+
+	testCases := []struct {
+		filter   AuditFilter
+		expected []AuditRecord
+	}{
+		{AuditFilter{Service: "go"}, []AuditRecord{r3, r1}},
+		{AuditFilter{Service: "sid2"}, []AuditRecord{r2}},
+		{AuditFilter{Endpoint: "proxy"}, []AuditRecord{r4, r2, r1}},
+		{AuditFilter{Endpoint: "monitor:9090"}, []AuditRecord{r3}},
+		{AuditFilter{Status: AuditStatusSuccess}, []AuditRecord{r4, r1}},
+		{AuditFilter{Since: r2.Time, Until: r3.Time}, []AuditRecord{r3, r2}},
+		{AuditFilter{Service: "web", Status: AuditStatusSuccess}, []AuditRecord{}},
+	}
+	for _, tc := range testCases {
+		records, err := a.Query(tc.filter)
+		s.Require().NoError(err)
+		s.Equal(tc.expected, records, "%+v", tc.filter)
+	}
+}
+
+func (s *AuditLogTestSuite) Test_Record_RotatesFile() {
+	a, err := OpenAuditLog(s.filename, 0, 2)
+	s.Require().NoError(err)
+	defer a.Close()
+	a.maxSize = 300
+
+	records := []AuditRecord{}
+	for minute := 1; minute <= 6; minute++ {
+		r := s.record(minute, QueueKindService, "sid1", "go", "proxy:8080", AuditStatusSuccess)
+		s.Require().NoError(a.Record(r))
+		records = append([]AuditRecord{r}, records...)
+	}
+
+	s.FileExists(s.filename + ".1")
+	s.FileExists(s.filename + ".2")
+	_, err = os.Stat(s.filename + ".3")
+	s.True(os.IsNotExist(err))
+	current, err := a.Query(AuditFilter{})
+	s.Require().NoError(err)
+	s.NotEmpty(current)
+	s.True(len(current) < len(records))
+	s.Equal(records[:len(current)], current)
+}
+
+func (s *AuditLogTestSuite) Test_Query_SkipsTruncatedLines() {
+	a, err := OpenAuditLog(s.filename, 0, 0)
+	s.Require().NoError(err)
+	defer a.Close()
+	r1 := s.record(1, QueueKindService, "sid1", "go", "proxy:8080", AuditStatusSuccess)
+	s.Require().NoError(a.Record(r1))
+	file, err := os.OpenFile(s.filename, os.O_APPEND|os.O_WRONLY, 0600)
+	s.Require().NoError(err)
+	file.WriteString(`{"time":"2018-10-27T10:02:00Z","kind":"serv`)
+	file.Close()
+
+	records, err := a.Query(AuditFilter{})
+
+	s.Require().NoError(err)
+	s.Equal([]AuditRecord{r1}, records)
+}
+
+func (s *AuditLogTestSuite) Test_ResponseExcerpt_TruncatesBody() {
+	s.Equal("OK", responseExcerpt([]byte("OK\n")))
+
+	body := strings.Repeat("a", auditResponseBodyLimit-1) + "é" + "tail"
+	excerpt := responseExcerpt([]byte(body))
+
+	s.Equal(strings.Repeat("a", auditResponseBodyLimit-1)+"...", excerpt)
+}
@@ -0,0 +1,111 @@
+package service
+
+import "fmt"
+
+// FieldChange is a field of a cached service that differs from the service
+// in Docker
+type FieldChange struct {
+	Field   string      `json:"field"`
+	Cached  interface{} `json:"cached,omitempty"`
+	Current interface{} `json:"current,omitempty"`
+}
+
+// ServiceDiff compares the cached entry of a service with the service in
+// Docker
+type ServiceDiff struct {
+	ID string `json:"id"`
+	// Cached is nil when the service is not cached
+	Cached *SwarmServiceMini `json:"cached"`
+	// Current is nil when the service is not in Docker
+	Current *SwarmServiceMini `json:"current"`
+	Changes []FieldChange     `json:"changes"`
+	// Notify is true when the next event of the service is notified, even
+	// when the event consults the cache
+	Notify bool   `json:"notify"`
+	Reason string `json:"reason"`
+}
+
+// newServiceDiff compares `cached` and `current`
+// Either of them is nil when the service is not cached or not in Docker.
+func newServiceDiff(cached, current *SwarmServiceMini) ServiceDiff {
+	diff := ServiceDiff{Cached: cached, Current: current, Changes: []FieldChange{}}
+	switch {
+	case cached == nil && current == nil:
+	case cached == nil:
+		diff.ID = current.ID
+		diff.Notify = true
+		diff.Reason = "The service is not cached, its next event is notified"
+	case current == nil:
+		diff.ID = cached.ID
+		diff.Notify = true
+		diff.Reason = "The service is cached but was not found in Docker with the notify label, its removal is notified"
+	default:
+		diff.ID = current.ID
+		diff.Changes = DiffSwarmServiceMini(*cached, *current)
+		diff.Notify = len(diff.Changes) > 0
+		if diff.Notify {
+			diff.Reason = "The service changed since it was cached"
+		} else {
+			diff.Reason = "The service did not change since it was cached, events that consult the cache are not notified"
+		}
+	}
+	return diff
+}
+
+// DiffSwarmServiceMini returns the fields that differ between `cached` and
+// `current`
+// A service is notified when `Equal` is false, which is the case when
+// changes are returned. Labels are compared one by one.
+func DiffSwarmServiceMini(cached, current SwarmServiceMini) []FieldChange {
+	changes := []FieldChange{}
+	if cached.ID != current.ID {
+		changes = append(changes, FieldChange{"ID", cached.ID, current.ID})
+	}
+	if cached.Name != current.Name {
+		changes = append(changes, FieldChange{"Name", cached.Name, current.Name})
+	}
+	changes = append(changes, diffLabels(cached.Labels, current.Labels)...)
+	if cached.Global != current.Global {
+		changes = append(changes, FieldChange{"Global", cached.Global, current.Global})
+	}
+	if cached.Replicas != current.Replicas {
+		changes = append(changes, FieldChange{"Replicas", cached.Replicas, current.Replicas})
+	}
+	if cached.ContainerImage != current.ContainerImage {
+		changes = append(changes, FieldChange{"ContainerImage", cached.ContainerImage, current.ContainerImage})
+	}
+	if !EqualNodeIPSet(cached.NodeInfo, current.NodeInfo) {
+		changes = append(changes, FieldChange{"NodeInfo", cached.NodeInfo, current.NodeInfo})
+	}
+	return changes
+}
+
+// diffLabels returns the labels that were added, removed, or changed
+// A missing label has no value in the change.
+func diffLabels(cached, current map[string]string) []FieldChange {
+	keys := map[string]struct{}{}
+	for k := range cached {
+		keys[k] = struct{}{}
+	}
+	for k := range current {
+		keys[k] = struct{}{}
+	}
+
+	changes := []FieldChange{}
+	for _, k := range sortedKeys(keys) {
+		cachedValue, cachedOK := cached[k]
+		currentValue, currentOK := current[k]
+		if cachedOK == currentOK && cachedValue == currentValue {
+			continue
+		}
+		change := FieldChange{Field: fmt.Sprintf("Labels[%s]", k)}
+		if cachedOK {
+			change.Cached = cachedValue
+		}
+		if currentOK {
+			change.Current = currentValue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
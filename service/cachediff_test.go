@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CacheDiffTestSuite struct {
+	suite.Suite
+}
+
+func TestCacheDiffUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheDiffTestSuite))
+}
+
+func (s *CacheDiffTestSuite) Test_DiffSwarmServiceMini_ReturnsChangedFields() {
+	cached := getNewSwarmServiceMini()
+	current := getNewSwarmServiceMini()
+	current.Replicas = cached.Replicas + 1
+	current.ContainerImage = "nginx:1.16"
+	current.Labels = map[string]string{"com.df.hello": "world2", "com.df.port": "8080"}
+	cached.Labels = map[string]string{"com.df.hello": "world", "com.df.servicePath": "/demo"}
+
+	changes := DiffSwarmServiceMini(cached, current)
+
+	s.Equal([]FieldChange{
+		{Field: "Labels[com.df.hello]", Cached: "world", Current: "world2"},
+		{Field: "Labels[com.df.port]", Current: "8080"},
+		{Field: "Labels[com.df.servicePath]", Cached: "/demo"},
+		{Field: "Replicas", Cached: cached.Replicas, Current: current.Replicas},
+		{Field: "ContainerImage", Cached: cached.ContainerImage, Current: "nginx:1.16"},
+	}, changes)
+	s.False(cached.Equal(current))
+}
+
+func (s *CacheDiffTestSuite) Test_DiffSwarmServiceMini_ReturnsNoChanges_WhenServicesAreEqual() {
+	ssm := getNewSwarmServiceMini()
+
+	s.Empty(DiffSwarmServiceMini(ssm, getNewSwarmServiceMini()))
+	s.True(ssm.Equal(getNewSwarmServiceMini()))
+}
+
+func (s *CacheDiffTestSuite) Test_DiffSwarmServiceMini_ReturnsNodeInfo() {
+	cached := getNewSwarmServiceMini()
+	current := getNewSwarmServiceMini()
+	current.NodeInfo = NodeIPSet{}
+	current.NodeInfo.Add("node-3", "1.0.2.1", "id3")
+
+	changes := DiffSwarmServiceMini(cached, current)
+
+	s.Equal([]FieldChange{{Field: "NodeInfo", Cached: cached.NodeInfo, Current: current.NodeInfo}}, changes)
+}
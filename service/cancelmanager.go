@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"sync"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
 )
 
 // CancelManaging manages canceling of contexts
@@ -40,6 +42,8 @@ func (m *CancelManager) Add(rootCtx context.Context, id string, reqID int64) con
 	if ok {
 		pair.Cancel()
 		delete(m.v, id)
+	} else {
+		metrics.AddCancelContexts(1)
 	}
 
 	ctx, cancel := context.WithCancel(rootCtx)
@@ -66,5 +70,6 @@ func (m *CancelManager) Delete(id string, reqID int64) bool {
 
 	pair.Cancel()
 	delete(m.v, id)
+	metrics.AddCancelContexts(-1)
 	return true
 }
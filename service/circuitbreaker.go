@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+)
+
+// CircuitState is the state of a `CircuitBreaker`
+type CircuitState string
+
+const (
+	// CircuitClosed lets all requests through
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen fails requests without sending them
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single probe request through
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// defaultCircuitBreakerOpenTimeout is used when the open timeout is not
+// configured
+const defaultCircuitBreakerOpenTimeout = time.Second * 30
+
+// circuitStateMetrics are the values of states in metrics
+var circuitStateMetrics = map[CircuitState]int{
+	CircuitClosed:   0,
+	CircuitHalfOpen: 1,
+	CircuitOpen:     2,
+}
+
+// CircuitBreakerStatus describes the state of a `CircuitBreaker`
+type CircuitBreakerStatus struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	OpenedAt            *time.Time   `json:"openedAt,omitempty"`
+}
+
+// CircuitBreaker stops requests to an endpoint after `failureThreshold`
+// consecutive failures. After `openTimeout` a single probe request is let
+// through. The breaker closes when the probe succeeds and opens again
+// when it fails.
+type CircuitBreaker struct {
+	host             string
+	failureThreshold int
+	openTimeout      time.Duration
+	state            CircuitState
+	failures         int
+	openedAt         time.Time
+	probing          bool
+	now              func() time.Time
+	log              *logging.Logger
+	mux              sync.Mutex
+}
+
+// NewCircuitBreaker returns a closed `CircuitBreaker` for `host`
+func NewCircuitBreaker(
+	host string, failureThreshold int, openTimeout time.Duration,
+	logger *logging.Logger) *CircuitBreaker {
+	b := &CircuitBreaker{
+		host:             host,
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		state:            CircuitClosed,
+		now:              time.Now,
+		log:              logger,
+	}
+	metrics.RecordCircuitBreakerState(host, circuitStateMetrics[b.state])
+	return b
+}
+
+// Allow returns an error when a request must not be sent
+// A request that is allowed must be followed by `RecordSuccess`,
+// `RecordFailure`, or `Release`
+func (b *CircuitBreaker) Allow() error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return fmt.Errorf("Circuit breaker for %s is open", b.host)
+		}
+		b.setState(CircuitHalfOpen)
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("Circuit breaker for %s is half-open", b.host)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// RecordSuccess closes the breaker
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.probing = false
+	b.failures = 0
+	if b.state != CircuitClosed {
+		b.log.Info("Closing circuit breaker", "endpoint", b.host)
+		b.setState(CircuitClosed)
+	}
+}
+
+// RecordFailure counts a failure and opens the breaker when the threshold
+// is reached or the probe failed
+func (b *CircuitBreaker) RecordFailure() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.probing = false
+	b.failures++
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.failures >= b.failureThreshold) {
+		b.log.Warn("Opening circuit breaker", "endpoint", b.host, "failures", b.failures)
+		b.openedAt = b.now()
+		b.setState(CircuitOpen)
+	}
+}
+
+// Release ends an allowed request without a result
+func (b *CircuitBreaker) Release() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	b.probing = false
+}
+
+// Status returns the current state of the breaker
+func (b *CircuitBreaker) Status() CircuitBreakerStatus {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	status := CircuitBreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+func (b *CircuitBreaker) setState(state CircuitState) {
+	b.state = state
+	metrics.RecordCircuitBreakerState(b.host, circuitStateMetrics[state])
+}
+
+// newCircuitBreakerFromConfig returns the `CircuitBreaker` of `host` or nil
+// when `c` does not enable it
+func newCircuitBreakerFromConfig(
+	host string, c config.CircuitBreaker, logger *logging.Logger) *CircuitBreaker {
+	if c.FailureThreshold == nil || *c.FailureThreshold <= 0 {
+		return nil
+	}
+	openTimeout := defaultCircuitBreakerOpenTimeout
+	if c.OpenTimeout != nil {
+		openTimeout = c.OpenTimeout.Duration
+	}
+	return NewCircuitBreaker(host, *c.FailureThreshold, openTimeout, logger)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+type CircuitBreakerTestSuite struct {
+	suite.Suite
+	log     *logging.Logger
+	now     time.Time
+	breaker *CircuitBreaker
+}
+
+func TestCircuitBreakerUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(CircuitBreakerTestSuite))
+}
+
+func (s *CircuitBreakerTestSuite) SetupTest() {
+	s.log = logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelDebug)
+	s.now = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.breaker = NewCircuitBreaker("proxy", 3, time.Minute, s.log)
+	s.breaker.now = func() time.Time { return s.now }
+}
+
+func (s *CircuitBreakerTestSuite) Test_Allow_ReturnsNil_WhenClosed() {
+	s.NoError(s.breaker.Allow())
+	s.Equal(CircuitBreakerStatus{State: CircuitClosed}, s.breaker.Status())
+}
+
+func (s *CircuitBreakerTestSuite) Test_RecordFailure_OpensAfterThreshold() {
+	s.fail(2)
+	s.Equal(CircuitBreakerStatus{State: CircuitClosed, ConsecutiveFailures: 2}, s.breaker.Status())
+
+	s.fail(1)
+	status := s.breaker.Status()
+	s.Equal(CircuitOpen, status.State)
+	s.Equal(3, status.ConsecutiveFailures)
+	s.Require().NotNil(status.OpenedAt)
+	s.Equal(s.now, *status.OpenedAt)
+	s.EqualError(s.breaker.Allow(), "Circuit breaker for proxy is open")
+}
+
+func (s *CircuitBreakerTestSuite) Test_RecordSuccess_ResetsFailures() {
+	s.fail(2)
+	s.Require().NoError(s.breaker.Allow())
+	s.breaker.RecordSuccess()
+	s.fail(2)
+
+	s.Equal(CircuitClosed, s.breaker.Status().State)
+}
+
+func (s *CircuitBreakerTestSuite) Test_Allow_LetsSingleProbeThrough_AfterOpenTimeout() {
+	s.fail(3)
+	s.now = s.now.Add(time.Minute)
+
+	s.NoError(s.breaker.Allow())
+	s.Equal(CircuitHalfOpen, s.breaker.Status().State)
+	s.EqualError(s.breaker.Allow(), "Circuit breaker for proxy is half-open")
+}
+
+func (s *CircuitBreakerTestSuite) Test_RecordSuccess_ClosesHalfOpenBreaker() {
+	s.fail(3)
+	s.now = s.now.Add(time.Minute)
+	s.Require().NoError(s.breaker.Allow())
+
+	s.breaker.RecordSuccess()
+
+	s.Equal(CircuitBreakerStatus{State: CircuitClosed}, s.breaker.Status())
+	s.NoError(s.breaker.Allow())
+}
+
+func (s *CircuitBreakerTestSuite) Test_RecordFailure_ReopensHalfOpenBreaker() {
+	s.fail(3)
+	s.now = s.now.Add(time.Minute)
+	s.Require().NoError(s.breaker.Allow())
+
+	s.breaker.RecordFailure()
+
+	status := s.breaker.Status()
+	s.Equal(CircuitOpen, status.State)
+	s.Equal(s.now, *status.OpenedAt)
+	s.EqualError(s.breaker.Allow(), "Circuit breaker for proxy is open")
+}
+
+func (s *CircuitBreakerTestSuite) Test_Release_LetsAnotherProbeThrough() {
+	s.fail(3)
+	s.now = s.now.Add(time.Minute)
+	s.Require().NoError(s.breaker.Allow())
+
+	s.breaker.Release()
+
+	s.NoError(s.breaker.Allow())
+}
+
+func (s *CircuitBreakerTestSuite) Test_NewCircuitBreakerFromConfig_ReturnsNil_WhenThresholdIsNotSet() {
+	zero := 0
+	s.Nil(newCircuitBreakerFromConfig("proxy", config.CircuitBreaker{}, s.log))
+	s.Nil(newCircuitBreakerFromConfig("proxy", config.CircuitBreaker{FailureThreshold: &zero}, s.log))
+}
+
+func (s *CircuitBreakerTestSuite) Test_NewCircuitBreakerFromConfig_UsesDefaultOpenTimeout() {
+	threshold := 5
+	b := newCircuitBreakerFromConfig("proxy", config.CircuitBreaker{FailureThreshold: &threshold}, s.log)
+
+	s.Require().NotNil(b)
+	s.Equal(5, b.failureThreshold)
+	s.Equal(defaultCircuitBreakerOpenTimeout, b.openTimeout)
+}
+
+func (s *CircuitBreakerTestSuite) fail(count int) {
+	for i := 0; i < count; i++ {
+		s.Require().NoError(s.breaker.Allow())
+		s.breaker.RecordFailure()
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+const (
+	// DeliveryStatusDelivered is the status of notifications delivered to
+	// all endpoints
+	DeliveryStatusDelivered = "delivered"
+	// DeliveryStatusFailed is the status of notifications that could not be
+	// delivered to at least one endpoint
+	DeliveryStatusFailed = "failed"
+	// DeliveryStatusCanceled is the status of notifications canceled by a
+	// newer notification
+	DeliveryStatusCanceled = "canceled"
+	// DeliveryStatusPending is the status of notifications that were not
+	// delivered before the wait timed out
+	DeliveryStatusPending = "pending"
+	// DeliveryStatusNotRunning is the status of services that were not
+	// notified because they are not running
+	DeliveryStatusNotRunning = "notRunning"
+)
+
+// EndpointDelivery is the result of delivering a notification to an endpoint
+type EndpointDelivery struct {
+	Host string `json:"host"`
+	// StatusCode is the status code of the last response
+	// It is zero when no response was received.
+	StatusCode int  `json:"statusCode,omitempty"`
+	Attempts   int  `json:"attempts"`
+	Retries    int  `json:"retries"`
+	Canceled   bool `json:"canceled,omitempty"`
+	// Queued is true when the notification was stored in the endpoint queue
+	// Only the first delivery attempt is reported.
+	Queued bool   `json:"queued,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// ResponseBody is an excerpt of the body of the last response
+	// It is only recorded in the audit log.
+	ResponseBody string `json:"-"`
+}
+
+// DeliveryReport collects the results of delivering a notification
+// It is filled by the `NotifyDistributor` before `ErrorChan` is signaled.
+type DeliveryReport struct {
+	mux        sync.Mutex
+	deliveries []EndpointDelivery
+}
+
+func (r *DeliveryReport) add(d EndpointDelivery) {
+	if d.Attempts > 0 {
+		d.Retries = d.Attempts - 1
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.deliveries = append(r.deliveries, d)
+}
+
+// Deliveries returns the reported deliveries sorted by host
+func (r *DeliveryReport) Deliveries() []EndpointDelivery {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	deliveries := make([]EndpointDelivery, len(r.deliveries))
+	copy(deliveries, r.deliveries)
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].Host < deliveries[j].Host
+	})
+	return deliveries
+}
+
+// Status returns the status of a finished notification
+func (r *DeliveryReport) Status() string {
+	status := DeliveryStatusDelivered
+	for _, d := range r.Deliveries() {
+		if len(d.Error) > 0 {
+			return DeliveryStatusFailed
+		}
+		if d.Canceled {
+			status = DeliveryStatusCanceled
+		}
+	}
+	return status
+}
+
+// DeliveryResult is the result of notifying endpoints about a service or
+// a node
+type DeliveryResult struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name,omitempty"`
+	Status    string             `json:"status"`
+	Endpoints []EndpointDelivery `json:"endpoints"`
+}
+
+type endpointDeliveryKey struct{}
+
+// withEndpointDelivery returns a context the `Notifier` records the
+// attempts, status code, and cancelation of a notification to
+func withEndpointDelivery(ctx context.Context, d *EndpointDelivery) context.Context {
+	return context.WithValue(ctx, endpointDeliveryKey{}, d)
+}
+
+// endpointDeliveryFromContext returns the delivery recorded to in `ctx` or
+// nil
+func endpointDeliveryFromContext(ctx context.Context) *EndpointDelivery {
+	d, _ := ctx.Value(endpointDeliveryKey{}).(*EndpointDelivery)
+	return d
+}
@@ -16,6 +16,11 @@ func NewDockerClientFromEnv() (*client.Client, error) {
 	if len(os.Getenv("DF_DOCKER_HOST")) > 0 {
 		host = os.Getenv("DF_DOCKER_HOST")
 	}
+	return NewDockerClient(host)
+}
+
+// NewDockerClient returns a `*client.Client` struct connected to `host`
+func NewDockerClient(host string) (*client.Client, error) {
 	defaultHeaders := map[string]string{"User-Agent": "engine-api-cli-1.0"}
 	cli, err := client.NewClient(host, dockerAPIVersion, nil, defaultHeaders)
 	if err != nil {
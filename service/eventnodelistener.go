@@ -2,7 +2,6 @@ package service
 
 import (
 	"context"
-	"log"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -10,6 +9,7 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
 )
 
@@ -21,11 +21,13 @@ type NodeListening interface {
 // NodeListener listens for docker node events
 type NodeListener struct {
 	dockerClient *client.Client
-	log          *log.Logger
+	log          *logging.Logger
+	// Health records the state of the event stream
+	Health *HealthRecorder
 }
 
 // NewNodeListener creates a `NodeListener``
-func NewNodeListener(c *client.Client, logger *log.Logger) *NodeListener {
+func NewNodeListener(c *client.Client, logger *logging.Logger) *NodeListener {
 	return &NodeListener{dockerClient: c, log: logger}
 }
 
@@ -38,10 +40,12 @@ func (s NodeListener) ListenForNodeEvents(
 		filter.Add("type", "node")
 		msgStream, msgErrs := s.dockerClient.Events(
 			context.Background(), types.EventsOptions{Filters: filter})
+		s.Health.streamConnected(nodesHealthName)
 
 		for {
 			select {
 			case msg := <-msgStream:
+				s.Health.streamEvent(nodesHealthName, msg.TimeNano)
 				if !s.validEventNode(msg) {
 					continue
 				}
@@ -53,12 +57,15 @@ func (s NodeListener) ListenForNodeEvents(
 					ConsultCache: true,
 				}
 			case err := <-msgErrs:
-				s.log.Printf("%v, Restarting docker event stream", err)
+				s.log.Warn("Restarting docker event stream", "stream", nodesHealthName, "error", err)
+				s.Health.streamFailed(nodesHealthName, err)
 				metrics.RecordError("ListenForNodeEvents")
 				time.Sleep(time.Second)
+				metrics.RecordEventStreamReconnect(nodesHealthName)
 				// Reopen event stream
 				msgStream, msgErrs = s.dockerClient.Events(
 					context.Background(), types.EventsOptions{Filters: filter})
+				s.Health.streamConnected(nodesHealthName)
 			}
 		}
 	}()
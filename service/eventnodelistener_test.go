@@ -3,18 +3,19 @@ package service
 import (
 	"bytes"
 	"fmt"
-	"log"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/client"
 	"github.com/stretchr/testify/suite"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 )
 
 type EventListenerNodeTestSuite struct {
 	suite.Suite
 	DockerClient   *client.Client
-	Logger         *log.Logger
+	Logger         *logging.Logger
 	LogBytes       *bytes.Buffer
 	NetworkName    string
 	Node0          string
@@ -27,7 +28,7 @@ func TestEventListenerNodeTestSuite(t *testing.T) {
 
 func (s *EventListenerNodeTestSuite) SetupSuite() {
 	s.LogBytes = new(bytes.Buffer)
-	s.Logger = log.New(s.LogBytes, "", 0)
+	s.Logger = logging.New(s.LogBytes, logging.FormatLogfmt, logging.LevelDebug)
 
 	// Assumes running test with docker-compose.yml
 	network, err := getNetworkNameWithSuffix("dfsl_network")
@@ -2,13 +2,13 @@ package service
 
 import (
 	"context"
-	"log"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
 )
 
@@ -20,11 +20,13 @@ type SwarmServiceListening interface {
 // SwarmServiceListener listens for docker service events
 type SwarmServiceListener struct {
 	dockerClient *client.Client
-	log          *log.Logger
+	log          *logging.Logger
+	// Health records the state of the event stream
+	Health *HealthRecorder
 }
 
 // NewSwarmServiceListener creates a `SwarmServiceListener`
-func NewSwarmServiceListener(c *client.Client, logger *log.Logger) *SwarmServiceListener {
+func NewSwarmServiceListener(c *client.Client, logger *logging.Logger) *SwarmServiceListener {
 	return &SwarmServiceListener{dockerClient: c, log: logger}
 }
 
@@ -35,10 +37,12 @@ func (s SwarmServiceListener) ListenForServiceEvents(eventChan chan<- Event) {
 		filter.Add("type", "service")
 		msgStream, msgErrs := s.dockerClient.Events(
 			context.Background(), types.EventsOptions{Filters: filter})
+		s.Health.streamConnected(servicesHealthName)
 
 		for {
 			select {
 			case msg := <-msgStream:
+				s.Health.streamEvent(servicesHealthName, msg.TimeNano)
 				if !s.validEventNode(msg) {
 					continue
 				}
@@ -53,12 +57,15 @@ func (s SwarmServiceListener) ListenForServiceEvents(eventChan chan<- Event) {
 					ConsultCache: true,
 				}
 			case err := <-msgErrs:
-				s.log.Printf("%v, Restarting docker event stream", err)
+				s.log.Warn("Restarting docker event stream", "stream", servicesHealthName, "error", err)
+				s.Health.streamFailed(servicesHealthName, err)
 				metrics.RecordError("ListenForServiceEvents")
 				time.Sleep(time.Second)
+				metrics.RecordEventStreamReconnect(servicesHealthName)
 				// Reopen event stream
 				msgStream, msgErrs = s.dockerClient.Events(
 					context.Background(), types.EventsOptions{Filters: filter})
+				s.Health.streamConnected(servicesHealthName)
 			}
 		}
 	}()
@@ -2,20 +2,21 @@ package service
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/client"
 	"github.com/stretchr/testify/suite"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 )
 
 type SwarmServiceListenerTestSuite struct {
 	suite.Suite
 	ServiceName  string
 	DockerClient *client.Client
-	Logger       *log.Logger
+	Logger       *logging.Logger
 }
 
 func TestSwarmServiceListenerTestSuite(t *testing.T) {
@@ -27,7 +28,7 @@ func (s *SwarmServiceListenerTestSuite) SetupSuite() {
 	client, err := NewDockerClientFromEnv()
 	s.Require().NoError(err)
 	s.DockerClient = client
-	s.Logger = log.New(os.Stdout, "", 0)
+	s.Logger = logging.New(os.Stdout, logging.FormatLogfmt, logging.LevelDebug)
 }
 
 func (s *SwarmServiceListenerTestSuite) Test_ListenForServiceEvents_CreateService() {
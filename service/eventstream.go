@@ -0,0 +1,158 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// eventStreamBufferSize is the number of events kept to resume
+	// subscriptions
+	eventStreamBufferSize = 1000
+	// eventSubscriptionBufferSize is the number of events a subscriber can
+	// fall behind before it is closed
+	eventSubscriptionBufferSize = 100
+)
+
+// StreamEvent is a service or node change published to subscribers
+type StreamEvent struct {
+	Seq        uint64            `json:"seq,omitempty"`
+	Kind       string            `json:"kind"`
+	EventType  EventType         `json:"eventType"`
+	ID         string            `json:"id"`
+	Parameters map[string]string `json:"parameters"`
+	TimeNano   int64             `json:"timeNano,omitempty"`
+}
+
+// EventSubscription receives the events published after it was created
+// `Events` is closed when the subscription is closed or falls too far
+// behind. Subscribers resume with the id of the last event they received.
+type EventSubscription struct {
+	// Missed are the buffered events after the requested event id
+	Missed []StreamEvent
+	// Resumed is true when all events after the requested event id were
+	// still buffered
+	Resumed bool
+	// LastSeq is the sequence number of the last event published before
+	// the subscription was created
+	LastSeq uint64
+	Events  <-chan StreamEvent
+
+	events chan StreamEvent
+	stream *EventStream
+}
+
+// EventID returns the id of the event with sequence number `seq`
+// Ids include the start time of the stream, so that ids of a previous run
+// of the listener are not resumed.
+func (s *EventSubscription) EventID(seq uint64) string {
+	return fmt.Sprintf("%d-%d", s.stream.epoch, seq)
+}
+
+// Close stops the subscription
+func (s *EventSubscription) Close() {
+	s.stream.unsubscribe(s)
+}
+
+// EventStream publishes events with increasing sequence numbers to
+// subscribers. The latest events are buffered so that subscribers can
+// resume after reconnecting.
+type EventStream struct {
+	epoch         int64
+	buffer        []StreamEvent
+	bufferSize    int
+	lastSeq       uint64
+	subscriptions map[*EventSubscription]struct{}
+	mux           sync.Mutex
+}
+
+// NewEventStream returns an `EventStream` that buffers `bufferSize` events
+func NewEventStream(bufferSize int) *EventStream {
+	return &EventStream{
+		epoch:         time.Now().UnixNano(),
+		bufferSize:    bufferSize,
+		subscriptions: map[*EventSubscription]struct{}{},
+	}
+}
+
+// Publish assigns the next sequence number to `event` and sends it to
+// all subscribers. Subscribers that cannot keep up are closed.
+func (s *EventStream) Publish(event StreamEvent) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.lastSeq++
+	event.Seq = s.lastSeq
+	s.buffer = append(s.buffer, event)
+	if len(s.buffer) > s.bufferSize {
+		s.buffer = s.buffer[len(s.buffer)-s.bufferSize:]
+	}
+
+	for sub := range s.subscriptions {
+		select {
+		case sub.events <- event:
+		default:
+			delete(s.subscriptions, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// Subscribe returns a subscription to events published from now on
+// When `lastEventID` is set, buffered events after it are returned in
+// `Missed`.
+func (s *EventStream) Subscribe(lastEventID string) *EventSubscription {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	lastSeq, resume := s.parseEventID(lastEventID)
+
+	events := make(chan StreamEvent, eventSubscriptionBufferSize)
+	sub := &EventSubscription{
+		LastSeq: s.lastSeq,
+		Events:  events,
+		events:  events,
+		stream:  s,
+	}
+	if resume && lastSeq <= s.lastSeq {
+		oldestSeq := s.lastSeq - uint64(len(s.buffer)) + 1
+		if lastSeq+1 >= oldestSeq {
+			sub.Resumed = true
+			sub.Missed = make([]StreamEvent, 0, s.lastSeq-lastSeq)
+			for _, e := range s.buffer {
+				if e.Seq > lastSeq {
+					sub.Missed = append(sub.Missed, e)
+				}
+			}
+		}
+	}
+	s.subscriptions[sub] = struct{}{}
+	return sub
+}
+
+// parseEventID returns the sequence number of `eventID` and whether it
+// belongs to this stream
+func (s *EventStream) parseEventID(eventID string) (uint64, bool) {
+	parts := strings.SplitN(eventID, "-", 2)
+	if len(parts) != 2 || parts[0] != strconv.FormatInt(s.epoch, 10) {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
+
+func (s *EventStream) unsubscribe(sub *EventSubscription) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if _, ok := s.subscriptions[sub]; ok {
+		delete(s.subscriptions, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EventStreamTestSuite struct {
+	suite.Suite
+}
+
+func TestEventStreamUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EventStreamTestSuite))
+}
+
+func (s *EventStreamTestSuite) Test_Publish_SendsEventsToSubscribers() {
+	stream := NewEventStream(10)
+	sub := stream.Subscribe("")
+	defer sub.Close()
+
+	stream.Publish(StreamEvent{Kind: QueueKindService, EventType: EventTypeCreate, ID: "sid1"})
+	stream.Publish(StreamEvent{Kind: QueueKindNode, EventType: EventTypeRemove, ID: "nid1"})
+
+	s.False(sub.Resumed)
+	s.Equal(uint64(0), sub.LastSeq)
+	e := <-sub.Events
+	s.Equal(uint64(1), e.Seq)
+	s.Equal("sid1", e.ID)
+	e = <-sub.Events
+	s.Equal(uint64(2), e.Seq)
+	s.Equal("nid1", e.ID)
+}
+
+func (s *EventStreamTestSuite) Test_Subscribe_ResumesBufferedEvents() {
+	stream := NewEventStream(10)
+	first := stream.Subscribe("")
+	stream.Publish(StreamEvent{ID: "sid1"})
+	stream.Publish(StreamEvent{ID: "sid2"})
+	stream.Publish(StreamEvent{ID: "sid3"})
+	first.Close()
+
+	sub := stream.Subscribe(first.EventID(1))
+	defer sub.Close()
+
+	s.True(sub.Resumed)
+	s.Equal(uint64(3), sub.LastSeq)
+	s.Require().Len(sub.Missed, 2)
+	s.Equal("sid2", sub.Missed[0].ID)
+	s.Equal("sid3", sub.Missed[1].ID)
+}
+
+func (s *EventStreamTestSuite) Test_Subscribe_DoesNotResume_WhenEventsWereDropped() {
+	stream := NewEventStream(2)
+	first := stream.Subscribe("")
+	first.Close()
+	for _, id := range []string{"sid1", "sid2", "sid3", "sid4"} {
+		stream.Publish(StreamEvent{ID: id})
+	}
+
+	sub := stream.Subscribe(first.EventID(1))
+	defer sub.Close()
+
+	s.False(sub.Resumed)
+	s.Empty(sub.Missed)
+	s.Equal(uint64(4), sub.LastSeq)
+}
+
+func (s *EventStreamTestSuite) Test_Subscribe_DoesNotResume_WhenEventIDIsFromAnotherStream() {
+	other := NewEventStream(10).Subscribe("")
+	stream := NewEventStream(10)
+	stream.epoch++
+	stream.Publish(StreamEvent{ID: "sid1"})
+
+	for _, id := range []string{other.EventID(0), "1", "invalid", "-1"} {
+		sub := stream.Subscribe(id)
+		s.False(sub.Resumed, id)
+		sub.Close()
+	}
+}
+
+func (s *EventStreamTestSuite) Test_Publish_ClosesSlowSubscribers() {
+	stream := NewEventStream(10)
+	sub := stream.Subscribe("")
+
+	for i := 0; i <= eventSubscriptionBufferSize; i++ {
+		stream.Publish(StreamEvent{ID: "sid1"})
+	}
+
+	count := 0
+	for range sub.Events {
+		count++
+	}
+	s.Equal(eventSubscriptionBufferSize, count)
+	sub.Close()
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"path"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// LabelSelector matches services with label `Key`
+// When `HasValue` is true, the label must also be equal to `Value`.
+type LabelSelector struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// Match returns true when `labels` satisfy the selector
+func (s LabelSelector) Match(labels map[string]string) bool {
+	value, ok := labels[s.Key]
+	if !ok {
+		return false
+	}
+	return !s.HasValue || value == s.Value
+}
+
+// ServiceFilter selects services
+// Empty fields match all services.
+type ServiceFilter struct {
+	// NamesOrIDs match the ID, the name, or the service name used in
+	// notifications
+	NamesOrIDs []string
+	// Stack matches the stack namespace
+	Stack string
+	// Name is a glob pattern matched against the name or the service name
+	// used in notifications
+	Name string
+	// Labels must all match
+	Labels []LabelSelector
+}
+
+// Match returns true when `ssm` is selected by the filter
+// `stackLabel` is the label holding the stack namespace.
+func (f ServiceFilter) Match(ssm SwarmServiceMini, stackLabel string) bool {
+	serviceName := GetSwarmServiceMiniCreateParameters(ssm)["serviceName"]
+	if len(f.NamesOrIDs) > 0 && !matchNameOrID(f.NamesOrIDs, ssm, serviceName) {
+		return false
+	}
+	if len(f.Stack) > 0 && ssm.Labels[stackLabel] != f.Stack {
+		return false
+	}
+	if len(f.Name) > 0 && !matchGlob(f.Name, ssm.Name) && !matchGlob(f.Name, serviceName) {
+		return false
+	}
+	for _, selector := range f.Labels {
+		if !selector.Match(ssm.Labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsEmpty returns true when the filter matches all services
+func (f ServiceFilter) IsEmpty() bool {
+	return len(f.NamesOrIDs) == 0 && len(f.Stack) == 0 && len(f.Name) == 0 && len(f.Labels) == 0
+}
+
+// NodeFilter selects nodes
+// Empty fields match all nodes.
+type NodeFilter struct {
+	// NamesOrIDs match the ID or the hostname
+	NamesOrIDs   []string
+	Role         swarm.NodeRole
+	Availability swarm.NodeAvailability
+	State        swarm.NodeState
+}
+
+// Match returns true when `nm` is selected by the filter
+func (f NodeFilter) Match(nm NodeMini) bool {
+	if len(f.NamesOrIDs) > 0 && !matchNodeNameOrID(f.NamesOrIDs, nm) {
+		return false
+	}
+	if len(f.Role) > 0 && nm.Role != f.Role {
+		return false
+	}
+	if len(f.Availability) > 0 && nm.Availability != f.Availability {
+		return false
+	}
+	if len(f.State) > 0 && nm.State != f.State {
+		return false
+	}
+	return true
+}
+
+// IsEmpty returns true when the filter matches all nodes
+func (f NodeFilter) IsEmpty() bool {
+	return len(f.NamesOrIDs) == 0 && len(f.Role) == 0 && len(f.Availability) == 0 && len(f.State) == 0
+}
+
+func matchNameOrID(namesOrIDs []string, ssm SwarmServiceMini, serviceName string) bool {
+	for _, nameOrID := range namesOrIDs {
+		if nameOrID == ssm.ID || nameOrID == ssm.Name || nameOrID == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+func matchNodeNameOrID(namesOrIDs []string, nm NodeMini) bool {
+	for _, nameOrID := range namesOrIDs {
+		if nameOrID == nm.ID || nameOrID == nm.Hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob returns true when `name` matches `pattern`
+// Invalid patterns do not match.
+func matchGlob(pattern, name string) bool {
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/suite"
+)
+
+type FilterTestSuite struct {
+	suite.Suite
+	SSMini SwarmServiceMini
+}
+
+func TestFilterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(FilterTestSuite))
+}
+
+func (s *FilterTestSuite) SetupTest() {
+	s.SSMini = SwarmServiceMini{
+		ID:   "serviceID",
+		Name: "shop_web",
+		Labels: map[string]string{
+			"com.df.notify":              "true",
+			"com.df.servicePath":         "/web",
+			"com.df.shortName":           "true",
+			"com.docker.stack.namespace": "shop",
+		},
+	}
+}
+
+func (s *FilterTestSuite) Test_ServiceFilter_MatchesAll_WhenEmpty() {
+	s.True(ServiceFilter{}.Match(s.SSMini, "com.docker.stack.namespace"))
+}
+
+func (s *FilterTestSuite) Test_ServiceFilter_MatchesNameOrID() {
+	for _, nameOrID := range []string{"serviceID", "shop_web", "web"} {
+		filter := ServiceFilter{NamesOrIDs: []string{"other", nameOrID}}
+		s.True(filter.Match(s.SSMini, "com.docker.stack.namespace"), nameOrID)
+	}
+	s.False(ServiceFilter{NamesOrIDs: []string{"shop"}}.Match(s.SSMini, "com.docker.stack.namespace"))
+}
+
+func (s *FilterTestSuite) Test_ServiceFilter_MatchesStack() {
+	s.True(ServiceFilter{Stack: "shop"}.Match(s.SSMini, "com.docker.stack.namespace"))
+	s.False(ServiceFilter{Stack: "blog"}.Match(s.SSMini, "com.docker.stack.namespace"))
+}
+
+func (s *FilterTestSuite) Test_ServiceFilter_MatchesNameGlob() {
+	for _, pattern := range []string{"shop_*", "w?b", "*"} {
+		s.True(ServiceFilter{Name: pattern}.Match(s.SSMini, "com.docker.stack.namespace"), pattern)
+	}
+	for _, pattern := range []string{"blog_*", "[", "web_*"} {
+		s.False(ServiceFilter{Name: pattern}.Match(s.SSMini, "com.docker.stack.namespace"), pattern)
+	}
+}
+
+func (s *FilterTestSuite) Test_ServiceFilter_MatchesAllLabels() {
+	filter := ServiceFilter{Labels: []LabelSelector{
+		{Key: "com.df.servicePath"},
+		{Key: "com.df.notify", Value: "true", HasValue: true},
+	}}
+	s.True(filter.Match(s.SSMini, "com.docker.stack.namespace"))
+
+	filter.Labels = append(filter.Labels, LabelSelector{Key: "com.df.port"})
+	s.False(filter.Match(s.SSMini, "com.docker.stack.namespace"))
+
+	filter = ServiceFilter{Labels: []LabelSelector{{Key: "com.df.servicePath", Value: "/api", HasValue: true}}}
+	s.False(filter.Match(s.SSMini, "com.docker.stack.namespace"))
+}
+
+func (s *FilterTestSuite) Test_NodeFilter_Match() {
+	nm := NodeMini{
+		ID:           "nodeID",
+		Hostname:     "node1",
+		Role:         swarm.NodeRoleManager,
+		Availability: swarm.NodeAvailabilityActive,
+		State:        swarm.NodeStateReady,
+	}
+
+	s.True(NodeFilter{}.Match(nm))
+	s.True(NodeFilter{
+		Role:         swarm.NodeRoleManager,
+		Availability: swarm.NodeAvailabilityActive,
+		State:        swarm.NodeStateReady,
+	}.Match(nm))
+	s.False(NodeFilter{Role: swarm.NodeRoleWorker}.Match(nm))
+	s.False(NodeFilter{Availability: swarm.NodeAvailabilityDrain}.Match(nm))
+	s.False(NodeFilter{State: swarm.NodeStateDown}.Match(nm))
+	s.True(NodeFilter{NamesOrIDs: []string{"node2", "nodeID"}}.Match(nm))
+	s.True(NodeFilter{NamesOrIDs: []string{"node1"}}.Match(nm))
+	s.False(NodeFilter{NamesOrIDs: []string{"node2"}}.Match(nm))
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Event streams and pollers are reported with these names
+const (
+	servicesHealthName = "services"
+	nodesHealthName    = "nodes"
+)
+
+// healthErrorWindow is the time an event stream is failing after an error
+var healthErrorWindow = 10 * time.Second
+
+// pollerHealthIntervals is the number of polling intervals without a
+// successful poll after which a poller is failing
+const pollerHealthIntervals = 3
+
+// DockerPinging checks the connection to the Docker API
+type DockerPinging interface {
+	Ping(ctx context.Context) (types.Ping, error)
+	ClientVersion() string
+}
+
+// EventStreamHealth describes a Docker event stream
+type EventStreamHealth struct {
+	Connected   bool       `json:"connected"`
+	LastEventAt *time.Time `json:"lastEventAt,omitempty"`
+	Restarts    int        `json:"restarts"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+// problem describes why the stream is failing at `now`
+// An empty string is returned when the stream is healthy.
+func (s EventStreamHealth) problem(name string, now time.Time) string {
+	if !s.Connected {
+		return fmt.Sprintf("The %s event stream is not connected", name)
+	}
+	if s.LastErrorAt != nil && now.Sub(*s.LastErrorAt) < healthErrorWindow {
+		return fmt.Sprintf("The %s event stream is restarting: %s", name, s.LastError)
+	}
+	return ""
+}
+
+// PollerHealth describes a poller
+type PollerHealth struct {
+	IntervalSeconds int        `json:"intervalSeconds"`
+	LastPollAt      *time.Time `json:"lastPollAt,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+	LastErrorAt     *time.Time `json:"lastErrorAt,omitempty"`
+	startedAt       time.Time
+}
+
+// problem describes why the poller is failing at `now`
+// A poller fails when it did not poll successfully for
+// `pollerHealthIntervals` intervals.
+func (p PollerHealth) problem(name string, now time.Time) string {
+	since := p.startedAt
+	if p.LastPollAt != nil {
+		since = *p.LastPollAt
+	}
+	// The first poll happens after an interval
+	limit := time.Duration((pollerHealthIntervals+1)*p.IntervalSeconds) * time.Second
+	if now.Sub(since) <= limit {
+		return ""
+	}
+	if len(p.LastError) > 0 {
+		return fmt.Sprintf("The %s poller did not succeed since %s: %s",
+			name, since.Format(time.RFC3339), p.LastError)
+	}
+	return fmt.Sprintf("The %s poller did not succeed since %s", name, since.Format(time.RFC3339))
+}
+
+// HealthRecorder records the health of event streams and pollers
+// A nil recorder records nothing.
+type HealthRecorder struct {
+	streams map[string]*EventStreamHealth
+	pollers map[string]*PollerHealth
+	mux     sync.RWMutex
+}
+
+// NewHealthRecorder creates a `HealthRecorder`
+func NewHealthRecorder() *HealthRecorder {
+	return &HealthRecorder{
+		streams: map[string]*EventStreamHealth{},
+		pollers: map[string]*PollerHealth{},
+	}
+}
+
+func (h *HealthRecorder) stream(name string) *EventStreamHealth {
+	s, ok := h.streams[name]
+	if !ok {
+		s = &EventStreamHealth{}
+		h.streams[name] = s
+	}
+	return s
+}
+
+func (h *HealthRecorder) streamConnected(name string) {
+	if h == nil {
+		return
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.stream(name).Connected = true
+}
+
+func (h *HealthRecorder) streamEvent(name string, timeNano int64) {
+	if h == nil {
+		return
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	t := time.Unix(0, timeNano).UTC()
+	h.stream(name).LastEventAt = &t
+}
+
+func (h *HealthRecorder) streamFailed(name string, err error) {
+	if h == nil {
+		return
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	now := time.Now().UTC()
+	s := h.stream(name)
+	s.Connected = false
+	s.Restarts++
+	s.LastError = err.Error()
+	s.LastErrorAt = &now
+}
+
+func (h *HealthRecorder) pollerStarted(name string, intervalSeconds int) {
+	if h == nil {
+		return
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	h.pollers[name] = &PollerHealth{
+		IntervalSeconds: intervalSeconds,
+		startedAt:       time.Now().UTC(),
+	}
+}
+
+func (h *HealthRecorder) pollSucceeded(name string) {
+	if h == nil {
+		return
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if p, ok := h.pollers[name]; ok {
+		now := time.Now().UTC()
+		p.LastPollAt = &now
+	}
+}
+
+func (h *HealthRecorder) pollFailed(name string, err error) {
+	if h == nil {
+		return
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if p, ok := h.pollers[name]; ok {
+		now := time.Now().UTC()
+		p.LastError = err.Error()
+		p.LastErrorAt = &now
+	}
+}
+
+// EventStreams returns the health of the event streams keyed by name
+// Only streams that were started are returned.
+func (h *HealthRecorder) EventStreams() map[string]EventStreamHealth {
+	streams := map[string]EventStreamHealth{}
+	if h == nil {
+		return streams
+	}
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	for name, s := range h.streams {
+		streams[name] = *s
+	}
+	return streams
+}
+
+// Pollers returns the health of the pollers keyed by name
+// Only pollers that were started are returned.
+func (h *HealthRecorder) Pollers() map[string]PollerHealth {
+	pollers := map[string]PollerHealth{}
+	if h == nil {
+		return pollers
+	}
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+	for name, p := range h.pollers {
+		pollers[name] = *p
+	}
+	return pollers
+}
+
+// DockerHealth describes the connection to the Docker API
+type DockerHealth struct {
+	Connected bool `json:"connected"`
+	// APIVersion is the API version of the Docker daemon
+	APIVersion string `json:"apiVersion,omitempty"`
+	// ClientVersion is the API version negotiated by the client
+	ClientVersion string `json:"clientVersion,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Health describes whether the listener can do its job
+// The listener is ready when Docker is reachable and the event streams
+// are connected. It is healthy when it is ready and the pollers succeed.
+// Endpoint failures do not make the listener unhealthy.
+type Health struct {
+	Healthy      bool                         `json:"healthy"`
+	Ready        bool                         `json:"ready"`
+	Problems     []string                     `json:"problems,omitempty"`
+	Docker       DockerHealth                 `json:"docker"`
+	EventStreams map[string]EventStreamHealth `json:"eventStreams"`
+	Pollers      map[string]PollerHealth      `json:"pollers"`
+	Caches       map[string]int               `json:"caches"`
+	Endpoints    map[string]EndpointHealth    `json:"endpoints"`
+	// Leader is set when replicas elect a leader
+	Leader *LeaderStatus `json:"leader,omitempty"`
+}
+
+// checkHealth returns the health described by the arguments at `now`
+func checkHealth(
+	docker DockerHealth, streams map[string]EventStreamHealth,
+	pollers map[string]PollerHealth, now time.Time) Health {
+	health := Health{
+		Docker:       docker,
+		EventStreams: streams,
+		Pollers:      pollers,
+		Ready:        true,
+	}
+	if !docker.Connected {
+		health.Ready = false
+		health.Problems = append(health.Problems,
+			fmt.Sprintf("Unable to connect to Docker: %s", docker.Error))
+	}
+	for _, name := range sortedHealthNames(streams) {
+		if problem := streams[name].problem(name, now); len(problem) > 0 {
+			health.Ready = false
+			health.Problems = append(health.Problems, problem)
+		}
+	}
+	problems := []string{}
+	for name, p := range pollers {
+		if problem := p.problem(name, now); len(problem) > 0 {
+			problems = append(problems, problem)
+		}
+	}
+	sort.Strings(problems)
+	health.Problems = append(health.Problems, problems...)
+	health.Healthy = len(health.Problems) == 0
+	return health
+}
+
+func sortedHealthNames(streams map[string]EventStreamHealth) []string {
+	names := []string{}
+	for name := range streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
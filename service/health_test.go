@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HealthTestSuite struct {
+	suite.Suite
+	now    time.Time
+	docker DockerHealth
+}
+
+func TestHealthUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
+
+func (s *HealthTestSuite) SetupTest() {
+	s.now = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.docker = DockerHealth{Connected: true, APIVersion: "1.37", ClientVersion: "1.37"}
+}
+
+func (s *HealthTestSuite) Test_CheckHealth_IsHealthy() {
+	lastEventAt := s.now.Add(-time.Hour)
+	lastPollAt := s.now.Add(-time.Second * 10)
+	streams := map[string]EventStreamHealth{
+		"services": {Connected: true, LastEventAt: &lastEventAt},
+	}
+	pollers := map[string]PollerHealth{
+		"services": {IntervalSeconds: 10, LastPollAt: &lastPollAt},
+	}
+
+	health := checkHealth(s.docker, streams, pollers, s.now)
+
+	s.True(health.Healthy)
+	s.True(health.Ready)
+	s.Empty(health.Problems)
+}
+
+func (s *HealthTestSuite) Test_CheckHealth_IsNotReady_WhenDockerIsNotConnected() {
+	docker := DockerHealth{Error: "connection refused"}
+
+	health := checkHealth(docker, map[string]EventStreamHealth{}, map[string]PollerHealth{}, s.now)
+
+	s.False(health.Healthy)
+	s.False(health.Ready)
+	s.Equal([]string{"Unable to connect to Docker: connection refused"}, health.Problems)
+}
+
+func (s *HealthTestSuite) Test_CheckHealth_IsNotReady_WhenEventStreamIsRestarting() {
+	lastErrorAt := s.now.Add(-time.Second)
+	oldErrorAt := s.now.Add(-time.Hour)
+	streams := map[string]EventStreamHealth{
+		"nodes":    {Connected: true, Restarts: 1, LastError: "EOF", LastErrorAt: &oldErrorAt},
+		"services": {Connected: true, Restarts: 3, LastError: "EOF", LastErrorAt: &lastErrorAt},
+	}
+
+	health := checkHealth(s.docker, streams, map[string]PollerHealth{}, s.now)
+
+	s.False(health.Healthy)
+	s.False(health.Ready)
+	s.Equal([]string{"The services event stream is restarting: EOF"}, health.Problems)
+
+	streams["services"] = EventStreamHealth{}
+	health = checkHealth(s.docker, streams, map[string]PollerHealth{}, s.now)
+	s.Equal([]string{"The services event stream is not connected"}, health.Problems)
+}
+
+func (s *HealthTestSuite) Test_CheckHealth_IsNotHealthy_WhenPollerDoesNotSucceed() {
+	lastPollAt := s.now.Add(-time.Minute)
+	lastErrorAt := s.now.Add(-time.Second)
+	pollers := map[string]PollerHealth{
+		"services": {IntervalSeconds: 10, LastPollAt: &lastPollAt,
+			LastError: "timeout", LastErrorAt: &lastErrorAt},
+		"nodes": {IntervalSeconds: 10, startedAt: s.now.Add(-time.Second * 30)},
+	}
+
+	health := checkHealth(s.docker, map[string]EventStreamHealth{}, pollers, s.now)
+
+	s.False(health.Healthy)
+	s.True(health.Ready)
+	s.Equal([]string{
+		"The services poller did not succeed since 2017-12-31T23:59:00Z: timeout",
+	}, health.Problems)
+}
+
+func (s *HealthTestSuite) Test_HealthRecorder_RecordsStreamsAndPollers() {
+	h := NewHealthRecorder()
+
+	h.streamConnected("services")
+	h.streamEvent("services", s.now.UnixNano())
+	h.streamFailed("services", fmt.Errorf("EOF"))
+	h.pollerStarted("nodes", 10)
+	h.pollFailed("nodes", fmt.Errorf("timeout"))
+	h.pollSucceeded("nodes")
+	h.pollSucceeded("services")
+
+	stream := h.EventStreams()["services"]
+	s.False(stream.Connected)
+	s.Equal(1, stream.Restarts)
+	s.Equal(s.now, *stream.LastEventAt)
+	s.Equal("EOF", stream.LastError)
+	s.NotNil(stream.LastErrorAt)
+
+	pollers := h.Pollers()
+	s.Len(pollers, 1)
+	s.Equal(10, pollers["nodes"].IntervalSeconds)
+	s.Equal("timeout", pollers["nodes"].LastError)
+	s.NotNil(pollers["nodes"].LastPollAt)
+}
+
+func (s *HealthTestSuite) Test_HealthRecorder_RecordsNothing_WhenNil() {
+	var h *HealthRecorder
+
+	h.streamConnected("services")
+	h.pollerStarted("nodes", 10)
+
+	s.Empty(h.EventStreams())
+	s.Empty(h.Pollers())
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+)
+
+// newHTTPClient returns a client for a notification endpoint
+// `connectTimeout` limits dialing and the TLS handshake, `responseTimeout`
+// limits the wait for response headers. Zero timeouts are unlimited.
+func newHTTPClient(
+	tlsSettings config.TLS,
+	connectTimeout, responseTimeout time.Duration) (*http.Client, error) {
+
+	tlsConfig, err := newTLSConfig(tlsSettings)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: time.Second * 30,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: responseTimeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       time.Second * 90,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// newTLSConfig loads the certificates of `settings`
+// nil is returned when no TLS setting is configured
+func newTLSConfig(settings config.TLS) (*tls.Config, error) {
+	if settings.IsEmpty() {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		ServerName: settings.ServerName,
+		MinVersion: settings.MinTLSVersion(),
+	}
+	if len(settings.CAFile) > 0 {
+		pem, err := ioutil.ReadFile(settings.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read CA bundle: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Unable to read CA bundle: no certificates found in %s", settings.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(settings.CertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(settings.CertFile, settings.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type HTTPClientTestSuite struct {
+	suite.Suite
+	tempDir string
+	ca      *x509.Certificate
+	caKey   *ecdsa.PrivateKey
+	caFile  string
+}
+
+func TestHTTPClientUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(HTTPClientTestSuite))
+}
+
+func (s *HTTPClientTestSuite) SetupTest() {
+	tempDir, err := ioutil.TempDir("", "dfsl-tls")
+	s.Require().NoError(err)
+	s.tempDir = tempDir
+
+	s.caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &s.caKey.PublicKey, s.caKey)
+	s.Require().NoError(err)
+	s.ca, err = x509.ParseCertificate(der)
+	s.Require().NoError(err)
+	s.caFile = s.writePEM("ca.pem", "CERTIFICATE", der)
+}
+
+func (s *HTTPClientTestSuite) TearDownTest() {
+	os.RemoveAll(s.tempDir)
+}
+
+func (s *HTTPClientTestSuite) Test_NewHTTPClient_TrustsCABundle() {
+	srv := s.newTLSServer(tls.NoClientCert)
+	defer srv.Close()
+
+	client, err := newHTTPClient(config.TLS{CAFile: s.caFile}, time.Second, time.Second)
+	s.Require().NoError(err)
+
+	resp, err := client.Get(srv.URL)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func (s *HTTPClientTestSuite) Test_NewHTTPClient_ReturnsError_WhenServerIsNotTrusted() {
+	srv := s.newTLSServer(tls.NoClientCert)
+	defer srv.Close()
+
+	client, err := newHTTPClient(config.TLS{}, time.Second, time.Second)
+	s.Require().NoError(err)
+
+	_, err = client.Get(srv.URL)
+	s.Error(err)
+}
+
+func (s *HTTPClientTestSuite) Test_NewHTTPClient_SendsClientCertificate() {
+	srv := s.newTLSServer(tls.RequireAndVerifyClientCert)
+	defer srv.Close()
+	certFile, keyFile := s.writeCertificate("client", x509.ExtKeyUsageClientAuth)
+
+	client, err := newHTTPClient(config.TLS{CAFile: s.caFile}, time.Second, time.Second)
+	s.Require().NoError(err)
+	_, err = client.Get(srv.URL)
+	s.Error(err)
+
+	client, err = newHTTPClient(config.TLS{
+		CAFile:     s.caFile,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		MinVersion: "1.2",
+	}, time.Second, time.Second)
+	s.Require().NoError(err)
+	resp, err := client.Get(srv.URL)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.Equal(http.StatusOK, resp.StatusCode)
+}
+
+func (s *HTTPClientTestSuite) Test_NewHTTPClient_ReturnsError_WhenFilesCannotBeRead() {
+	_, err := newHTTPClient(config.TLS{CAFile: filepath.Join(s.tempDir, "missing.pem")}, 0, 0)
+	s.Error(err)
+
+	invalidCA := filepath.Join(s.tempDir, "invalid.pem")
+	s.Require().NoError(ioutil.WriteFile(invalidCA, []byte("not a certificate"), 0600))
+	_, err = newHTTPClient(config.TLS{CAFile: invalidCA}, 0, 0)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "no certificates found")
+
+	_, err = newHTTPClient(config.TLS{CertFile: s.caFile, KeyFile: invalidCA}, 0, 0)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Unable to read client certificate")
+}
+
+func (s *HTTPClientTestSuite) Test_NewHTTPClient_TimesOutWaitingForResponse() {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client, err := newHTTPClient(config.TLS{}, time.Second, time.Millisecond*50)
+	s.Require().NoError(err)
+
+	_, err = client.Get(srv.URL)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "timeout")
+}
+
+func (s *HTTPClientTestSuite) newTLSServer(clientAuth tls.ClientAuthType) *httptest.Server {
+	certFile, keyFile := s.writeCertificate("server", x509.ExtKeyUsageServerAuth)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	s.Require().NoError(err)
+	pool := x509.NewCertPool()
+	pool.AddCert(s.ca)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   clientAuth,
+		ClientCAs:    pool,
+	}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	return srv
+}
+
+// writeCertificate writes a certificate signed by the test CA and its key
+func (s *HTTPClientTestSuite) writeCertificate(name string, usage x509.ExtKeyUsage) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, s.ca, &key.PublicKey, s.caKey)
+	s.Require().NoError(err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	s.Require().NoError(err)
+
+	return s.writePEM(name+".pem", "CERTIFICATE", der),
+		s.writePEM(name+"-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func (s *HTTPClientTestSuite) writePEM(name, blockType string, der []byte) string {
+	filename := filepath.Join(s.tempDir, name)
+	content := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	s.Require().NoError(ioutil.WriteFile(filename, content, 0600))
+	return filename
+}
@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+)
+
+// Labels of the Docker config that stores the leader lock
+const (
+	leaderHolderLabel    = "com.df.leader.holder"
+	leaderExpiresAtLabel = "com.df.leader.expiresAt"
+)
+
+// LeaderLock is a lock that is held by one replica at a time
+type LeaderLock interface {
+	// Acquire acquires the lock for `id` or renews it when `id` already
+	// holds it. The lock expires unless it is renewed within `ttl`.
+	// It returns the holder of the lock, which is empty when it is unknown.
+	Acquire(ctx context.Context, id string, ttl time.Duration) (string, error)
+	// Release lets the lock expire when `id` holds it
+	Release(ctx context.Context, id string) error
+}
+
+// LeaderStatus describes the leadership of a replica
+type LeaderStatus struct {
+	ID     string `json:"id"`
+	Leader bool   `json:"leader"`
+	// Holder is the replica that held the lock when it was last checked
+	Holder    string     `json:"holder,omitempty"`
+	RenewedAt *time.Time `json:"renewedAt,omitempty"`
+}
+
+// LeaderElector acquires and renews a `LeaderLock` so that one of
+// several replicas is the leader
+type LeaderElector struct {
+	Lock          LeaderLock
+	ID            string
+	TTL           time.Duration
+	RenewInterval time.Duration
+	Log           *logging.Logger
+
+	leader      bool
+	holder      string
+	renewedAt   time.Time
+	expiryTimer *time.Timer
+	now         func() time.Time
+	mux         sync.RWMutex
+}
+
+// NewLeaderElector creates a `LeaderElector` for the replica `id`
+func NewLeaderElector(
+	lock LeaderLock, id string, ttl, renewInterval time.Duration, log *logging.Logger) *LeaderElector {
+	return &LeaderElector{
+		Lock:          lock,
+		ID:            id,
+		TTL:           ttl,
+		RenewInterval: renewInterval,
+		Log:           log,
+		now:           time.Now,
+	}
+}
+
+// Run tries to acquire the lock every `RenewInterval` until `stop` is
+// closed. `onElected` is called when the replica becomes the leader and
+// `onDeposed` when another replica holds the lock or its lease is about
+// to expire without a renewal. The lock is released when `stop` is closed.
+func (e *LeaderElector) Run(stop <-chan struct{}, onElected, onDeposed func()) {
+	e.Log.Info("Electing a leader", "leader_id", e.ID, "ttl", e.TTL, "renew_interval", e.RenewInterval)
+
+	ticker := time.NewTicker(e.RenewInterval)
+	defer ticker.Stop()
+	for {
+		e.try(onElected, onDeposed)
+		select {
+		case <-ticker.C:
+		case <-stop:
+			e.release()
+			return
+		}
+	}
+}
+
+// try acquires or renews the lock once
+// The lease is counted from before the request, since the lock expires
+// `TTL` after the time it was written.
+func (e *LeaderElector) try(onElected, onDeposed func()) {
+	leaseStart := e.now()
+	ctx, cancel := context.WithTimeout(context.Background(), e.RenewInterval)
+	holder, err := e.Lock.Acquire(ctx, e.ID, e.TTL)
+	cancel()
+	now := e.now()
+
+	e.mux.Lock()
+	wasLeader := e.leader
+	if err != nil {
+		e.Log.Warn("Unable to acquire the leader lock", "leader_id", e.ID, "error", err)
+		if e.leader && !now.Before(e.leaseDeadline(e.renewedAt)) {
+			e.leader = false
+		}
+	} else {
+		e.holder = holder
+		e.leader = holder == e.ID && now.Before(e.leaseDeadline(leaseStart))
+		if e.leader {
+			e.renewedAt = leaseStart
+			e.scheduleExpiry(now, onDeposed)
+		}
+	}
+	isLeader := e.leader
+	e.mux.Unlock()
+
+	if !wasLeader && isLeader {
+		e.Log.Info("Elected as the leader", "leader_id", e.ID)
+		onElected()
+	} else if wasLeader && !isLeader {
+		e.Log.Error("Lost the leadership", "leader_id", e.ID, "leader_holder", holder)
+		onDeposed()
+	}
+}
+
+// leaseDeadline returns the time a leader that renewed the lock at
+// `leaseStart` steps down unless it renews it again. It is a tenth of
+// `TTL` before the lease expires, to allow for clock differences between
+// the managers.
+func (e *LeaderElector) leaseDeadline(leaseStart time.Time) time.Time {
+	return leaseStart.Add(e.TTL - e.TTL/10)
+}
+
+// scheduleExpiry deposes the leader at the deadline of its lease, even
+// while a renewal is still waiting for Docker
+func (e *LeaderElector) scheduleExpiry(now time.Time, onDeposed func()) {
+	if e.expiryTimer != nil {
+		e.expiryTimer.Stop()
+	}
+	e.expiryTimer = time.AfterFunc(e.leaseDeadline(e.renewedAt).Sub(now), func() {
+		e.expire(onDeposed)
+	})
+}
+
+// expire deposes the leader when its lease was not renewed before the
+// deadline
+func (e *LeaderElector) expire(onDeposed func()) {
+	e.mux.Lock()
+	if !e.leader || e.now().Before(e.leaseDeadline(e.renewedAt)) {
+		e.mux.Unlock()
+		return
+	}
+	e.leader = false
+	e.mux.Unlock()
+
+	e.Log.Error("Lost the leadership since the leader lock was not renewed", "leader_id", e.ID)
+	onDeposed()
+}
+
+// release releases the lock when the replica is the leader
+func (e *LeaderElector) release() {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.expiryTimer != nil {
+		e.expiryTimer.Stop()
+	}
+	if !e.leader {
+		return
+	}
+	e.leader = false
+	ctx, cancel := context.WithTimeout(context.Background(), e.RenewInterval)
+	defer cancel()
+	if err := e.Lock.Release(ctx, e.ID); err != nil {
+		e.Log.Warn("Unable to release the leader lock", "leader_id", e.ID, "error", err)
+	}
+}
+
+// IsLeader returns true when the replica holds the lock
+func (e *LeaderElector) IsLeader() bool {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+	return e.leader
+}
+
+// Status returns the leadership of the replica
+func (e *LeaderElector) Status() LeaderStatus {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+	status := LeaderStatus{ID: e.ID, Leader: e.leader, Holder: e.holder}
+	if !e.renewedAt.IsZero() {
+		renewedAt := e.renewedAt.UTC()
+		status.RenewedAt = &renewedAt
+	}
+	return status
+}
+
+// DockerConfigClient manages Docker configs
+type DockerConfigClient interface {
+	ConfigInspectWithRaw(ctx context.Context, name string) (swarm.Config, []byte, error)
+	ConfigCreate(ctx context.Context, config swarm.ConfigSpec) (types.ConfigCreateResponse, error)
+	ConfigUpdate(ctx context.Context, id string, version swarm.Version, config swarm.ConfigSpec) error
+}
+
+// DockerConfigLock is a `LeaderLock` stored in the labels of a Docker
+// config. The version of the config guards updates, so that only one of
+// the replicas that see an expired lock acquires it.
+// Expiry times are compared with the local clock, so the clocks of the
+// managers must be synchronized.
+type DockerConfigLock struct {
+	Client DockerConfigClient
+	Name   string
+	now    func() time.Time
+}
+
+// NewDockerConfigLock creates a `DockerConfigLock` stored in the config
+// `name`
+func NewDockerConfigLock(c DockerConfigClient, name string) *DockerConfigLock {
+	return &DockerConfigLock{
+		Client: c,
+		Name:   name,
+		now:    time.Now,
+	}
+}
+
+// Acquire implements `LeaderLock`
+func (l DockerConfigLock) Acquire(ctx context.Context, id string, ttl time.Duration) (string, error) {
+	cfg, _, err := l.Client.ConfigInspectWithRaw(ctx, l.Name)
+	expiresAt := l.now().Add(ttl)
+	if client.IsErrNotFound(err) {
+		_, err := l.Client.ConfigCreate(ctx, swarm.ConfigSpec{
+			Annotations: swarm.Annotations{
+				Name:   l.Name,
+				Labels: leaderLabels(nil, id, expiresAt),
+			},
+			Data: []byte("docker-flow-swarm-listener leader lock"),
+		})
+		if isLockConflict(err) {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		return id, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	holder := cfg.Spec.Labels[leaderHolderLabel]
+	if holder != id && !l.expired(cfg) {
+		return holder, nil
+	}
+	spec := cfg.Spec
+	spec.Labels = leaderLabels(cfg.Spec.Labels, id, expiresAt)
+	err = l.Client.ConfigUpdate(ctx, cfg.ID, cfg.Version, spec)
+	if isLockConflict(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Release implements `LeaderLock`
+func (l DockerConfigLock) Release(ctx context.Context, id string) error {
+	cfg, _, err := l.Client.ConfigInspectWithRaw(ctx, l.Name)
+	if client.IsErrNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if cfg.Spec.Labels[leaderHolderLabel] != id {
+		return nil
+	}
+	spec := cfg.Spec
+	spec.Labels = leaderLabels(cfg.Spec.Labels, id, l.now())
+	err = l.Client.ConfigUpdate(ctx, cfg.ID, cfg.Version, spec)
+	if isLockConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// expired returns true when the lock stored in `cfg` was not renewed in
+// time. Locks without a valid expiry time are expired.
+func (l DockerConfigLock) expired(cfg swarm.Config) bool {
+	expiresAt, err := time.Parse(time.RFC3339Nano, cfg.Spec.Labels[leaderExpiresAtLabel])
+	return err != nil || !l.now().Before(expiresAt)
+}
+
+// leaderLabels returns a copy of `labels` with the holder `id` and the
+// expiry time `expiresAt`
+func leaderLabels(labels map[string]string, id string, expiresAt time.Time) map[string]string {
+	copied := map[string]string{}
+	for k, v := range labels {
+		copied[k] = v
+	}
+	copied[leaderHolderLabel] = id
+	copied[leaderExpiresAtLabel] = expiresAt.UTC().Format(time.RFC3339Nano)
+	return copied
+}
+
+// isLockConflict returns true when another replica created or updated the
+// config first
+func isLockConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "already exists") || strings.Contains(msg, "out of sequence")
+}
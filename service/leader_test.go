@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/suite"
+)
+
+type LeaderTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func TestLeaderUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LeaderTestSuite))
+}
+
+func (s *LeaderTestSuite) SetupTest() {
+	s.now = time.Date(2018, 10, 27, 10, 0, 0, 0, time.UTC)
+}
+
+// fakeLeaderLock returns the holders and errors it is given in turn
+type fakeLeaderLock struct {
+	holders  []string
+	errs     []error
+	released []string
+	mux      sync.Mutex
+}
+
+func (l *fakeLeaderLock) Acquire(ctx context.Context, id string, ttl time.Duration) (string, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	holder, err := l.holders[0], l.errs[0]
+	if len(l.holders) > 1 {
+		l.holders, l.errs = l.holders[1:], l.errs[1:]
+	}
+	return holder, err
+}
+
+func (l *fakeLeaderLock) Release(ctx context.Context, id string) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.released = append(l.released, id)
+	return nil
+}
+
+// leaderLockFunc is a `LeaderLock` that acquires the lock with a function
+type leaderLockFunc func(ctx context.Context) (string, error)
+
+func (f leaderLockFunc) Acquire(ctx context.Context, id string, ttl time.Duration) (string, error) {
+	return f(ctx)
+}
+
+func (f leaderLockFunc) Release(ctx context.Context, id string) error {
+	return nil
+}
+
+func (s *LeaderTestSuite) elector(lock LeaderLock) *LeaderElector {
+	e := NewLeaderElector(lock, "replica1", 15*time.Second, 5*time.Second, nil)
+	e.now = func() time.Time { return s.now }
+	return e
+}
+
+func (s *LeaderTestSuite) Test_Try_ElectsAndDeposes() {
+	lock := &fakeLeaderLock{
+		holders: []string{"replica2", "replica1", "replica1", "replica2"},
+		errs:    []error{nil, nil, nil, nil},
+	}
+	e := s.elector(lock)
+	elected, deposed := 0, 0
+	onElected := func() { elected++ }
+	onDeposed := func() { deposed++ }
+
+	e.try(onElected, onDeposed)
+	s.False(e.IsLeader())
+	s.Equal("replica2", e.Status().Holder)
+
+	e.try(onElected, onDeposed)
+	e.try(onElected, onDeposed)
+	s.True(e.IsLeader())
+	s.Equal(1, elected)
+	renewedAt := s.now
+	s.Equal(LeaderStatus{ID: "replica1", Leader: true, Holder: "replica1", RenewedAt: &renewedAt}, e.Status())
+
+	e.try(onElected, onDeposed)
+	s.False(e.IsLeader())
+	s.Equal(1, deposed)
+}
+
+func (s *LeaderTestSuite) Test_Try_DeposesLeader_WhenLockIsNotRenewedWithinTTL() {
+	lock := &fakeLeaderLock{
+		holders: []string{"replica1", "", ""},
+		errs:    []error{nil, errors.New("connection refused"), errors.New("connection refused")},
+	}
+	e := s.elector(lock)
+	deposed := 0
+	onDeposed := func() { deposed++ }
+
+	e.try(func() {}, onDeposed)
+	s.now = s.now.Add(10 * time.Second)
+	e.try(func() {}, onDeposed)
+	s.True(e.IsLeader())
+
+	s.now = s.now.Add(5 * time.Second)
+	e.try(func() {}, onDeposed)
+	s.False(e.IsLeader())
+	s.Equal(1, deposed)
+}
+
+func (s *LeaderTestSuite) Test_Expire_DeposesLeader_WhenLeaseExpiresBetweenTicks() {
+	lock := &fakeLeaderLock{
+		holders: []string{"replica1", ""},
+		errs:    []error{nil, errors.New("connection refused")},
+	}
+	e := s.elector(lock)
+	deposed := 0
+	onDeposed := func() { deposed++ }
+
+	e.try(func() {}, onDeposed)
+	s.now = s.now.Add(5 * time.Second)
+	e.try(func() {}, onDeposed)
+	e.expire(onDeposed)
+	s.True(e.IsLeader())
+
+	s.now = s.now.Add(9 * time.Second)
+	e.expire(onDeposed)
+	s.False(e.IsLeader())
+	s.Equal(1, deposed)
+
+	e.try(func() {}, onDeposed)
+	s.Equal(1, deposed)
+}
+
+func (s *LeaderTestSuite) Test_Try_IgnoresRenewal_ThatReturnsAfterTheLeaseDeadline() {
+	e := s.elector(leaderLockFunc(func(ctx context.Context) (string, error) {
+		s.now = s.now.Add(14 * time.Second)
+		return "replica1", nil
+	}))
+	elected := 0
+
+	e.try(func() { elected++ }, func() {})
+	s.False(e.IsLeader())
+	s.Equal(0, elected)
+}
+
+func (s *LeaderTestSuite) Test_Run_DeposesLeader_BeforeTheNextRenewal() {
+	lock := leaderLockFunc(func(ctx context.Context) (string, error) {
+		return "replica1", nil
+	})
+	e := NewLeaderElector(lock, "replica1", 100*time.Millisecond, time.Hour, nil)
+	deposed := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go e.Run(stop, func() {}, func() { close(deposed) })
+
+	select {
+	case <-deposed:
+	case <-time.After(5 * time.Second):
+		s.FailNow("Timeout")
+	}
+	s.False(e.IsLeader())
+}
+
+func (s *LeaderTestSuite) Test_Run_ReleasesLock_WhenStopped() {
+	lock := &fakeLeaderLock{holders: []string{"replica1"}, errs: []error{nil}}
+	e := s.elector(lock)
+	elected := make(chan struct{})
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		e.Run(stop, func() { close(elected) }, func() {})
+		close(stopped)
+	}()
+
+	select {
+	case <-elected:
+	case <-time.After(5 * time.Second):
+		s.FailNow("Timeout")
+	}
+	close(stop)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		s.FailNow("Timeout")
+	}
+	s.False(e.IsLeader())
+	s.Equal([]string{"replica1"}, lock.released)
+}
+
+// fakeConfigClient stores configs like Docker and rejects updates of
+// outdated versions
+type fakeConfigClient struct {
+	configs map[string]swarm.Config
+	nextID  int
+}
+
+func (c *fakeConfigClient) ConfigInspectWithRaw(ctx context.Context, name string) (swarm.Config, []byte, error) {
+	cfg, ok := c.configs[name]
+	if !ok {
+		return swarm.Config{}, nil, fakeNotFoundError{}
+	}
+	return cfg, nil, nil
+}
+
+func (c *fakeConfigClient) ConfigCreate(ctx context.Context, spec swarm.ConfigSpec) (types.ConfigCreateResponse, error) {
+	if _, ok := c.configs[spec.Name]; ok {
+		return types.ConfigCreateResponse{}, fmt.Errorf(
+			"Error response from daemon: rpc error: code = AlreadyExists desc = config %s already exists", spec.Name)
+	}
+	c.nextID++
+	cfg := swarm.Config{ID: fmt.Sprintf("configID%d", c.nextID), Spec: spec}
+	cfg.Version.Index = 1
+	c.configs[spec.Name] = cfg
+	return types.ConfigCreateResponse{ID: cfg.ID}, nil
+}
+
+func (c *fakeConfigClient) ConfigUpdate(ctx context.Context, id string, version swarm.Version, spec swarm.ConfigSpec) error {
+	cfg, ok := c.configs[spec.Name]
+	if !ok || cfg.ID != id {
+		return fakeNotFoundError{}
+	}
+	if cfg.Version.Index != version.Index {
+		return errors.New("Error response from daemon: rpc error: code = Unknown desc = update out of sequence")
+	}
+	cfg.Spec = spec
+	cfg.Version.Index++
+	c.configs[spec.Name] = cfg
+	return nil
+}
+
+type fakeNotFoundError struct{}
+
+func (fakeNotFoundError) Error() string  { return "Error: No such config" }
+func (fakeNotFoundError) NotFound() bool { return true }
+
+func (s *LeaderTestSuite) lock(c DockerConfigClient) *DockerConfigLock {
+	l := NewDockerConfigLock(c, "dfsl-leader")
+	l.now = func() time.Time { return s.now }
+	return l
+}
+
+func (s *LeaderTestSuite) Test_DockerConfigLock_IsHeldUntilItExpires() {
+	c := &fakeConfigClient{configs: map[string]swarm.Config{}}
+	lock := s.lock(c)
+	ctx := context.Background()
+
+	holder, err := lock.Acquire(ctx, "replica1", 15*time.Second)
+	s.Require().NoError(err)
+	s.Equal("replica1", holder)
+	s.Equal("2018-10-27T10:00:15Z", c.configs["dfsl-leader"].Spec.Labels[leaderExpiresAtLabel])
+
+	holder, err = lock.Acquire(ctx, "replica2", 15*time.Second)
+	s.Require().NoError(err)
+	s.Equal("replica1", holder)
+
+	s.now = s.now.Add(10 * time.Second)
+	holder, err = lock.Acquire(ctx, "replica1", 15*time.Second)
+	s.Require().NoError(err)
+	s.Equal("replica1", holder)
+
+	s.now = s.now.Add(15 * time.Second)
+	holder, err = lock.Acquire(ctx, "replica2", 15*time.Second)
+	s.Require().NoError(err)
+	s.Equal("replica2", holder)
+	s.Equal("replica2", c.configs["dfsl-leader"].Spec.Labels[leaderHolderLabel])
+}
+
+func (s *LeaderTestSuite) Test_DockerConfigLock_ReturnsEmptyHolder_WhenAnotherReplicaUpdatesFirst() {
+	c := &fakeConfigClient{configs: map[string]swarm.Config{}}
+	lock := s.lock(c)
+	ctx := context.Background()
+	lock.Acquire(ctx, "replica1", 15*time.Second)
+	s.now = s.now.Add(time.Minute)
+
+	raced := &racingConfigClient{fakeConfigClient: c, racer: s.lock(c)}
+	holder, err := s.lock(raced).Acquire(ctx, "replica2", 15*time.Second)
+
+	s.Require().NoError(err)
+	s.Empty(holder)
+	s.Equal("replica3", c.configs["dfsl-leader"].Spec.Labels[leaderHolderLabel])
+}
+
+func (s *LeaderTestSuite) Test_DockerConfigLock_Release_ExpiresLock() {
+	c := &fakeConfigClient{configs: map[string]swarm.Config{}}
+	lock := s.lock(c)
+	ctx := context.Background()
+	lock.Acquire(ctx, "replica1", 15*time.Second)
+
+	s.Require().NoError(lock.Release(ctx, "replica2"))
+	holder, _ := lock.Acquire(ctx, "replica2", 15*time.Second)
+	s.Equal("replica1", holder)
+
+	s.Require().NoError(lock.Release(ctx, "replica1"))
+	holder, err := lock.Acquire(ctx, "replica2", 15*time.Second)
+	s.Require().NoError(err)
+	s.Equal("replica2", holder)
+}
+
+// racingConfigClient lets replica3 acquire the lock right before the
+// first update
+type racingConfigClient struct {
+	*fakeConfigClient
+	racer *DockerConfigLock
+	raced bool
+}
+
+func (c *racingConfigClient) ConfigUpdate(ctx context.Context, id string, version swarm.Version, spec swarm.ConfigSpec) error {
+	if !c.raced {
+		c.raced = true
+		c.racer.Acquire(ctx, "replica3", 15*time.Second)
+	}
+	return c.fakeConfigClient.ConfigUpdate(ctx, id, version, spec)
+}
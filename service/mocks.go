@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/mock"
 )
@@ -96,6 +98,14 @@ func (m *swarmServiceCacherMock) Keys() map[string]struct{} {
 	return args.Get(0).(map[string]struct{})
 }
 
+func (m *swarmServiceCacherMock) UpdatedAt() time.Time {
+	return m.Called().Get(0).(time.Time)
+}
+
+func (m *swarmServiceCacherMock) Flush() int {
+	return m.Called().Int(0)
+}
+
 type nodeListeningMock struct {
 	mock.Mock
 }
@@ -146,6 +156,14 @@ func (m *nodeCacherMock) Keys() map[string]struct{} {
 	return args.Get(0).(map[string]struct{})
 }
 
+func (m *nodeCacherMock) UpdatedAt() time.Time {
+	return m.Called().Get(0).(time.Time)
+}
+
+func (m *nodeCacherMock) Flush() int {
+	return m.Called().Int(0)
+}
+
 type notifyDistributorMock struct {
 	mock.Mock
 }
@@ -162,6 +180,32 @@ func (m *notifyDistributorMock) HasNodeListeners() bool {
 	return m.Called().Bool(0)
 }
 
+func (m *notifyDistributorMock) QueuedNotifications() map[string][]QueueEntry {
+	return m.Called().Get(0).(map[string][]QueueEntry)
+}
+
+func (m *notifyDistributorMock) Hosts() []string {
+	return m.Called().Get(0).([]string)
+}
+
+func (m *notifyDistributorMock) NotificationHistory(filter AuditFilter) ([]AuditRecord, error) {
+	args := m.Called(filter)
+	return args.Get(0).([]AuditRecord), args.Error(1)
+}
+
+func (m *notifyDistributorMock) CircuitBreakers() map[string]CircuitBreakerStatus {
+	return m.Called().Get(0).(map[string]CircuitBreakerStatus)
+}
+
+func (m *notifyDistributorMock) EndpointHealth() map[string]EndpointHealth {
+	return m.Called().Get(0).(map[string]EndpointHealth)
+}
+
+func (m *notifyDistributorMock) UpdateEndpoints(notifyEndpoints map[string]NotifyEndpoint) []string {
+	args := m.Called(notifyEndpoints)
+	return args.Get(0).([]string)
+}
+
 type swarmServicePollingMock struct {
 	mock.Mock
 }
@@ -177,3 +221,16 @@ type nodePollingMock struct {
 func (m *nodePollingMock) Run(eventChan chan<- Event) {
 	m.Called(eventChan)
 }
+
+type dockerPingingMock struct {
+	mock.Mock
+}
+
+func (m *dockerPingingMock) Ping(ctx context.Context) (types.Ping, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(types.Ping), args.Error(1)
+}
+
+func (m *dockerPingingMock) ClientVersion() string {
+	return m.Called().String(0)
+}
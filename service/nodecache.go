@@ -1,6 +1,9 @@
 package service
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 // NodeCacher caches sevices
 type NodeCacher interface {
@@ -9,13 +12,16 @@ type NodeCacher interface {
 	Delete(ID string)
 	Get(ID string) (NodeMini, bool)
 	Keys() map[string]struct{}
+	UpdatedAt() time.Time
+	Flush() int
 }
 
 // NodeCache implements `NodeCacher`
 // Not threadsafe!
 type NodeCache struct {
-	cache map[string]NodeMini
-	mux   sync.RWMutex
+	cache     map[string]NodeMini
+	updatedAt time.Time
+	mux       sync.RWMutex
 }
 
 // NewNodeCache creates a new `NewNodeCache`
@@ -33,6 +39,7 @@ func (c *NodeCache) InsertAndCheck(n NodeMini) bool {
 
 	cachedNode, ok := c.cache[n.ID]
 	c.cache[n.ID] = n
+	c.updatedAt = time.Now().UTC()
 
 	return !ok || !n.Equal(cachedNode)
 }
@@ -43,6 +50,7 @@ func (c *NodeCache) Delete(ID string) {
 	defer c.mux.Unlock()
 
 	delete(c.cache, ID)
+	c.updatedAt = time.Now().UTC()
 }
 
 // Get gets node from cache
@@ -73,3 +81,21 @@ func (c *NodeCache) Keys() map[string]struct{} {
 	}
 	return output
 }
+
+// UpdatedAt returns the time a node was last inserted or deleted
+// It is zero when the cache was never updated.
+func (c *NodeCache) UpdatedAt() time.Time {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.updatedAt
+}
+
+// Flush removes all nodes from the cache and returns their number
+func (c *NodeCache) Flush() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	count := len(c.cache)
+	c.cache = map[string]NodeMini{}
+	c.updatedAt = time.Now().UTC()
+	return count
+}
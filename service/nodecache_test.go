@@ -2,6 +2,7 @@ package service
 
 import (
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/suite"
@@ -186,6 +187,28 @@ func (s *NodeCacheTestSuite) Test_Keys() {
 
 }
 
+func (s *NodeCacheTestSuite) Test_UpdatedAt() {
+	s.True(s.Cache.UpdatedAt().IsZero())
+
+	before := time.Now().UTC()
+	s.Cache.InsertAndCheck(s.NMini)
+	inserted := s.Cache.UpdatedAt()
+	s.False(inserted.Before(before))
+
+	s.Cache.Delete(s.NMini.ID)
+	s.False(s.Cache.UpdatedAt().Before(inserted))
+}
+
+func (s *NodeCacheTestSuite) Test_Flush_RemovesAllNodes() {
+	s.Cache.InsertAndCheck(s.NMini)
+
+	s.Equal(1, s.Cache.Flush())
+
+	s.AssertNotInCache(s.NMini)
+	s.Empty(s.Cache.Keys())
+	s.True(s.Cache.InsertAndCheck(s.NMini))
+}
+
 func (s *NodeCacheTestSuite) AssertInCache(nm NodeMini) {
 	ss, ok := s.Cache.Get(nm.ID)
 	s.True(ok)
@@ -2,10 +2,11 @@ package service
 
 import (
 	"context"
-	"log"
 	"time"
 
 	"github.com/docker/docker/api/types/swarm"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 )
 
 // NodePolling provides an interface for polling node changes
@@ -19,7 +20,9 @@ type NodePoller struct {
 	Cache           NodeCacher
 	PollingInterval int
 	MinifyFunc      func(swarm.Node) NodeMini
-	Log             *log.Logger
+	Log             *logging.Logger
+	// Health records the last successful poll
+	Health *HealthRecorder
 }
 
 // NewNodePoller creates a new `NodePoller`
@@ -28,7 +31,7 @@ func NewNodePoller(
 	cache NodeCacher,
 	pollingInterval int,
 	minifyFunc func(swarm.Node) NodeMini,
-	log *log.Logger,
+	log *logging.Logger,
 ) *NodePoller {
 	return &NodePoller{
 		Client:          client,
@@ -48,14 +51,17 @@ func (n NodePoller) Run(eventChan chan<- Event) {
 
 	ctx := context.Background()
 
-	n.Log.Printf("Polling for Node Changes")
+	n.Log.Info("Polling for Node Changes", "interval_seconds", n.PollingInterval)
+	n.Health.pollerStarted(nodesHealthName, n.PollingInterval)
 	time.Sleep(time.Duration(n.PollingInterval) * time.Second)
 
 	for {
 		nodes, err := n.Client.NodeList(ctx)
 		if err != nil {
-			n.Log.Printf("ERROR (NodePoller): %v", err)
+			n.Log.Error("Unable to poll nodes", "error", err)
+			n.Health.pollFailed(nodesHealthName, err)
 		} else {
+			n.Health.pollSucceeded(nodesHealthName)
 			nowTimeNano := time.Now().UTC().UnixNano()
 			keys := n.Cache.Keys()
 			for _, node := range nodes {
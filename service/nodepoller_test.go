@@ -2,13 +2,14 @@ package service
 
 import (
 	"bytes"
-	"log"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 )
 
 type NodePollerTestSuite struct {
@@ -17,7 +18,7 @@ type NodePollerTestSuite struct {
 	NodeCacheMock  *nodeCacherMock
 
 	NodePoller *NodePoller
-	Logger     *log.Logger
+	Logger     *logging.Logger
 	LogBytes   *bytes.Buffer
 }
 
@@ -30,7 +31,7 @@ func (s *NodePollerTestSuite) SetupTest() {
 	s.NodeCacheMock = new(nodeCacherMock)
 
 	s.LogBytes = new(bytes.Buffer)
-	s.Logger = log.New(s.LogBytes, "", 0)
+	s.Logger = logging.New(s.LogBytes, logging.FormatLogfmt, logging.LevelDebug)
 
 	s.NodePoller = NewNodePoller(
 		s.NodeClientMock,
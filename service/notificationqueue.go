@@ -0,0 +1,521 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+)
+
+const (
+	// QueueKindService is used for service notifications
+	QueueKindService = "service"
+	// QueueKindNode is used for node notifications
+	QueueKindNode = "node"
+
+	queueOpPush    = "push"
+	queueOpAck     = "ack"
+	queueOpAttempt = "attempt"
+
+	// queueCompactRecords is the number of ack and attempt records after
+	// which the queue file is rewritten
+	queueCompactRecords = 1000
+)
+
+var errQueueClosed = errors.New("notification queue closed")
+
+var queueFilenameReplacer = regexp.MustCompile("[^A-Za-z0-9.-]")
+
+// QueueEntry is a notification waiting to be delivered
+type QueueEntry struct {
+	Seq        uint64    `json:"seq"`
+	Kind       string    `json:"kind"`
+	EventType  EventType `json:"eventType"`
+	ID         string    `json:"id"`
+	Parameters string    `json:"parameters"`
+	TimeNano   int64     `json:"timeNano"`
+	QueuedAt   time.Time `json:"queuedAt"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"lastError,omitempty"`
+	// Traceparent continues the trace of the notification when it is
+	// delivered
+	Traceparent string `json:"traceparent,omitempty"`
+}
+
+// queueRecord is a line in the queue file
+type queueRecord struct {
+	Op          string    `json:"op"`
+	Seq         uint64    `json:"seq"`
+	Kind        string    `json:"kind,omitempty"`
+	EventType   EventType `json:"eventType,omitempty"`
+	ID          string    `json:"id,omitempty"`
+	Parameters  string    `json:"parameters,omitempty"`
+	TimeNano    int64     `json:"timeNano,omitempty"`
+	QueuedNano  int64     `json:"queuedNano,omitempty"`
+	Attempts    int       `json:"attempts,omitempty"`
+	Traceparent string    `json:"traceparent,omitempty"`
+}
+
+type queuedNotification struct {
+	QueueEntry
+	waiter chan QueueResult
+	// delivery records the first delivery attempt, which is sent to the
+	// waiter
+	delivery   *EndpointDelivery
+	cancel     context.CancelFunc
+	superseded bool
+}
+
+// QueueResult is the result of the first delivery attempt of a pushed
+// notification
+type QueueResult struct {
+	Err error
+	// Delivery holds the attempts, status code, and response of the
+	// delivery
+	Delivery EndpointDelivery
+}
+
+// QueueDeliverFunc delivers a queued notification
+type QueueDeliverFunc func(ctx context.Context, entry QueueEntry) error
+
+// NotificationQueue is a durable queue of notifications stored in an
+// append-only file. Notifications are delivered in order and redelivered
+// until they are acknowledged, including after a restart.
+// A notification supersedes pending notifications of the same kind and ID.
+// Notifications that fail permanently, or that reach the maximum number
+// of attempts or the maximum age, are dropped.
+type NotificationQueue struct {
+	filename           string
+	file               *os.File
+	entries            []*queuedNotification
+	nextSeq            uint64
+	staleRecords       int
+	deliver            QueueDeliverFunc
+	redeliveryInterval time.Duration
+	maxAttempts        int
+	maxAge             time.Duration
+	log                *logging.Logger
+	mux                sync.Mutex
+	pushChan           chan struct{}
+	closeChan          chan struct{}
+	doneChan           chan struct{}
+	closeOnce          sync.Once
+}
+
+// queueFilename returns the file used to store notifications to `host`
+// in `dir`
+func queueFilename(dir, host string) string {
+	return filepath.Join(dir, queueFilenameReplacer.ReplaceAllString(host, "_")+".queue")
+}
+
+// OpenNotificationQueue opens or creates the queue stored in `filename`
+// and starts delivering its notifications with `deliver`
+// `maxAttempts` and `maxAge` are unlimited when zero.
+func OpenNotificationQueue(
+	filename string, deliver QueueDeliverFunc,
+	redeliveryInterval time.Duration, maxAttempts int, maxAge time.Duration,
+	logger *logging.Logger) (*NotificationQueue, error) {
+
+	q := &NotificationQueue{
+		filename:           filename,
+		nextSeq:            1,
+		deliver:            deliver,
+		redeliveryInterval: redeliveryInterval,
+		maxAttempts:        maxAttempts,
+		maxAge:             maxAge,
+		log:                logger,
+		pushChan:           make(chan struct{}, 1),
+		closeChan:          make(chan struct{}),
+		doneChan:           make(chan struct{}),
+	}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		return nil, err
+	}
+	if len(q.entries) > 0 {
+		q.log.Info("Loaded pending notifications", "notifications", len(q.entries), "file", filename)
+	}
+
+	go q.run()
+	return q, nil
+}
+
+// load replays the records of the queue file
+// A partially written last record is ignored
+func (q *NotificationQueue) load() error {
+	f, err := os.Open(q.filename)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Unable to open notification queue: %v", err)
+	}
+	defer f.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r queueRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			q.log.Warn("Skipping invalid record of the notification queue", "file", q.filename, "error", err)
+			continue
+		}
+		if r.Seq >= q.nextSeq {
+			q.nextSeq = r.Seq + 1
+		}
+		switch r.Op {
+		case queueOpPush:
+			queuedAt := now
+			if r.QueuedNano > 0 {
+				queuedAt = time.Unix(0, r.QueuedNano)
+			}
+			q.entries = append(q.entries, &queuedNotification{QueueEntry: QueueEntry{
+				Seq:         r.Seq,
+				Kind:        r.Kind,
+				EventType:   r.EventType,
+				ID:          r.ID,
+				Parameters:  r.Parameters,
+				TimeNano:    r.TimeNano,
+				QueuedAt:    queuedAt,
+				Attempts:    r.Attempts,
+				Traceparent: r.Traceparent,
+			}})
+		case queueOpAck:
+			q.remove(r.Seq)
+		case queueOpAttempt:
+			for _, e := range q.entries {
+				if e.Seq == r.Seq {
+					e.Attempts = r.Attempts
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Unable to read notification queue: %v", err)
+	}
+	return nil
+}
+
+// compact rewrites the queue file with the pending notifications only
+func (q *NotificationQueue) compact() error {
+	tmpFilename := q.filename + ".tmp"
+	tmp, err := os.OpenFile(tmpFilename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to compact notification queue: %v", err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, e := range q.entries {
+		if err := writeQueueRecord(w, pushRecord(e.QueueEntry)); err != nil {
+			tmp.Close()
+			return fmt.Errorf("Unable to compact notification queue: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Unable to compact notification queue: %v", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("Unable to compact notification queue: %v", err)
+	}
+	tmp.Close()
+	if err := os.Rename(tmpFilename, q.filename); err != nil {
+		return fmt.Errorf("Unable to compact notification queue: %v", err)
+	}
+
+	if q.file != nil {
+		q.file.Close()
+	}
+	q.file, err = os.OpenFile(q.filename, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to open notification queue: %v", err)
+	}
+	q.staleRecords = 0
+	return nil
+}
+
+// Push stores the notification and returns a channel that receives the
+// result of its first delivery attempt
+func (q *NotificationQueue) Push(kind string, n Notification) (<-chan QueueResult, error) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	select {
+	case <-q.closeChan:
+		return nil, errQueueClosed
+	default:
+	}
+
+	e := &queuedNotification{
+		QueueEntry: QueueEntry{
+			Seq:         q.nextSeq,
+			Kind:        kind,
+			EventType:   n.EventType,
+			ID:          n.ID,
+			Parameters:  n.Parameters,
+			TimeNano:    n.TimeNano,
+			QueuedAt:    time.Now(),
+			Traceparent: n.SpanContext.Traceparent(),
+		},
+		waiter:   make(chan QueueResult, 1),
+		delivery: &EndpointDelivery{},
+	}
+	if err := q.append(pushRecord(e.QueueEntry)); err != nil {
+		return nil, err
+	}
+	q.nextSeq++
+	q.entries = append(q.entries, e)
+	q.supersede(e)
+
+	select {
+	case q.pushChan <- struct{}{}:
+	default:
+	}
+	return e.waiter, nil
+}
+
+// supersede drops pending notifications of the same kind and ID as `latest`
+// The notification being delivered is canceled
+func (q *NotificationQueue) supersede(latest *queuedNotification) {
+	superseded := []*queuedNotification{}
+	for _, e := range q.entries {
+		if e == latest || e.Kind != latest.Kind || e.ID != latest.ID {
+			continue
+		}
+		if e.cancel != nil {
+			e.superseded = true
+			e.cancel()
+			continue
+		}
+		superseded = append(superseded, e)
+	}
+	for _, e := range superseded {
+		q.ack(e)
+	}
+}
+
+// Entries returns the pending notifications in delivery order
+func (q *NotificationQueue) Entries() []QueueEntry {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	entries := make([]QueueEntry, 0, len(q.entries))
+	for _, e := range q.entries {
+		entries = append(entries, e.QueueEntry)
+	}
+	return entries
+}
+
+// Close stops delivery and closes the queue file
+// Pending notifications stay in the file
+func (q *NotificationQueue) Close() {
+	q.closeOnce.Do(func() {
+		q.mux.Lock()
+		close(q.closeChan)
+		for _, e := range q.entries {
+			if e.cancel != nil {
+				e.cancel()
+			}
+			q.signal(e, QueueResult{Err: errQueueClosed})
+		}
+		q.mux.Unlock()
+
+		<-q.doneChan
+
+		q.mux.Lock()
+		q.file.Close()
+		q.mux.Unlock()
+	})
+}
+
+func (q *NotificationQueue) run() {
+	defer close(q.doneChan)
+
+	for {
+		q.mux.Lock()
+		if len(q.entries) == 0 {
+			q.mux.Unlock()
+			select {
+			case <-q.pushChan:
+				continue
+			case <-q.closeChan:
+				return
+			}
+		}
+		e := q.entries[0]
+		ctx, cancel := context.WithCancel(context.Background())
+		e.cancel = cancel
+		if e.waiter != nil {
+			ctx = withEndpointDelivery(ctx, e.delivery)
+		}
+		q.mux.Unlock()
+
+		err := q.deliver(ctx, e.QueueEntry)
+		cancel()
+
+		q.mux.Lock()
+		e.cancel = nil
+		select {
+		case <-q.closeChan:
+			q.mux.Unlock()
+			return
+		default:
+		}
+		e.Attempts++
+		if err == nil || e.superseded {
+			q.signal(e, e.result(nil))
+			q.ack(e)
+			q.mux.Unlock()
+			continue
+		}
+		e.LastError = err.Error()
+		q.signal(e, e.result(err))
+		if reason := q.dropReason(e, err); len(reason) > 0 {
+			q.log.Error("Dropping notification that cannot be delivered",
+				e.Kind+"_id", e.ID, "event_type", e.EventType, "request_id", e.TimeNano,
+				"attempts", e.Attempts, "reason", reason, "error", err)
+			q.ack(e)
+			q.mux.Unlock()
+			continue
+		}
+		q.recordAttempt(e)
+		q.mux.Unlock()
+
+		select {
+		case <-time.After(q.redeliveryInterval):
+		case <-q.closeChan:
+			return
+		}
+	}
+}
+
+// dropReason returns why the notification that failed with `err` is not
+// delivered again, or an empty string when it is redelivered
+func (q *NotificationQueue) dropReason(e *queuedNotification, err error) string {
+	if isPermanentNotificationError(err) {
+		return "permanent failure"
+	}
+	if q.maxAttempts > 0 && e.Attempts >= q.maxAttempts {
+		return "maximum attempts reached"
+	}
+	if q.maxAge > 0 && time.Since(e.QueuedAt) >= q.maxAge {
+		return "maximum age reached"
+	}
+	return ""
+}
+
+// recordAttempt stores the number of attempts of the notification in the
+// file, so that they are counted across restarts
+func (q *NotificationQueue) recordAttempt(e *queuedNotification) {
+	r := queueRecord{Op: queueOpAttempt, Seq: e.Seq, Attempts: e.Attempts}
+	if err := q.append(r); err != nil {
+		q.log.Error("Unable to record delivery attempt", "file", q.filename, "error", err)
+		return
+	}
+	q.addStaleRecord()
+}
+
+// result returns the result of the delivery attempt that failed with
+// `err`
+func (e *queuedNotification) result(err error) QueueResult {
+	r := QueueResult{Err: err}
+	if e.delivery != nil {
+		r.Delivery = *e.delivery
+	}
+	return r
+}
+
+// signal sends the result of the first delivery attempt to the waiter
+func (q *NotificationQueue) signal(e *queuedNotification, result QueueResult) {
+	if e.waiter == nil {
+		return
+	}
+	e.waiter <- result
+	e.waiter = nil
+	e.delivery = nil
+}
+
+// ack removes the notification from the queue and records it in the file
+func (q *NotificationQueue) ack(e *queuedNotification) {
+	q.signal(e, QueueResult{})
+	q.remove(e.Seq)
+
+	if len(q.entries) == 0 {
+		if err := q.file.Truncate(0); err != nil {
+			q.log.Error("Unable to truncate the notification queue", "file", q.filename, "error", err)
+		}
+		q.staleRecords = 0
+		return
+	}
+	if err := q.append(queueRecord{Op: queueOpAck, Seq: e.Seq}); err != nil {
+		q.log.Error("Unable to acknowledge notification", "file", q.filename, "error", err)
+		return
+	}
+	q.addStaleRecord()
+}
+
+// addStaleRecord counts a record that compaction removes and compacts the
+// file once there are enough of them
+func (q *NotificationQueue) addStaleRecord() {
+	q.staleRecords++
+	if q.staleRecords >= queueCompactRecords {
+		if err := q.compact(); err != nil {
+			q.log.Error("Unable to compact the notification queue", "file", q.filename, "error", err)
+		}
+	}
+}
+
+func (q *NotificationQueue) remove(seq uint64) {
+	for i, e := range q.entries {
+		if e.Seq == seq {
+			q.entries = append(q.entries[:i], q.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// append writes `r` to the queue file and flushes it to disk
+func (q *NotificationQueue) append(r queueRecord) error {
+	if err := writeQueueRecord(q.file, r); err != nil {
+		return fmt.Errorf("Unable to write to notification queue %s: %v", q.filename, err)
+	}
+	if err := q.file.Sync(); err != nil {
+		return fmt.Errorf("Unable to write to notification queue %s: %v", q.filename, err)
+	}
+	return nil
+}
+
+func pushRecord(e QueueEntry) queueRecord {
+	return queueRecord{
+		Op:          queueOpPush,
+		Seq:         e.Seq,
+		Kind:        e.Kind,
+		EventType:   e.EventType,
+		ID:          e.ID,
+		Parameters:  e.Parameters,
+		TimeNano:    e.TimeNano,
+		QueuedNano:  e.QueuedAt.UnixNano(),
+		Attempts:    e.Attempts,
+		Traceparent: e.Traceparent,
+	}
+}
+
+func writeQueueRecord(w io.Writer, r queueRecord) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
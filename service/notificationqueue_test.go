@@ -0,0 +1,315 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+)
+
+type NotificationQueueTestSuite struct {
+	suite.Suite
+	log      *logging.Logger
+	tempDir  string
+	filename string
+}
+
+func TestNotificationQueueUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(NotificationQueueTestSuite))
+}
+
+func (s *NotificationQueueTestSuite) SetupTest() {
+	s.log = logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelDebug)
+	tempDir, err := ioutil.TempDir("", "dfsl-queue")
+	s.Require().NoError(err)
+	s.tempDir = tempDir
+	s.filename = queueFilename(tempDir, "proxy:8080")
+}
+
+func (s *NotificationQueueTestSuite) TearDownTest() {
+	os.RemoveAll(s.tempDir)
+}
+
+func (s *NotificationQueueTestSuite) Test_QueueFilename_ReplacesUnsafeCharacters() {
+	s.Equal(filepath.Join(s.tempDir, "proxy_8080.queue"), s.filename)
+}
+
+func (s *NotificationQueueTestSuite) Test_Push_DeliversInOrder() {
+	delivered := make(chan string, 2)
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		delivered <- e.ID
+		return nil
+	}, time.Millisecond, 0, 0, s.log)
+	s.Require().NoError(err)
+	defer q.Close()
+
+	result1, err := q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid1"})
+	s.Require().NoError(err)
+	result2, err := q.Push(QueueKindNode, Notification{EventType: EventTypeRemove, ID: "nid1"})
+	s.Require().NoError(err)
+
+	s.NoError(s.waitFor(result1))
+	s.NoError(s.waitFor(result2))
+	s.Equal("sid1", <-delivered)
+	s.Equal("nid1", <-delivered)
+	s.Empty(q.Entries())
+
+	info, err := os.Stat(s.filename)
+	s.Require().NoError(err)
+	s.Equal(int64(0), info.Size())
+}
+
+func (s *NotificationQueueTestSuite) Test_Push_RedeliversUntilAcknowledged() {
+	attempts := 0
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}, time.Millisecond, 0, 0, s.log)
+	s.Require().NoError(err)
+	defer q.Close()
+
+	result, err := q.Push(QueueKindService, Notification{EventType: EventTypeRemove, ID: "sid1"})
+	s.Require().NoError(err)
+
+	s.EqualError(s.waitFor(result), "connection refused")
+	s.waitUntilEmpty(q)
+	s.Equal(3, attempts)
+}
+
+func (s *NotificationQueueTestSuite) Test_Push_ReturnsDeliveryOfTheFirstAttempt() {
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		delivery := endpointDeliveryFromContext(ctx)
+		s.Require().NotNil(delivery)
+		delivery.Attempts = 3
+		delivery.StatusCode = 503
+		return fmt.Errorf("service unavailable")
+	}, time.Hour, 0, 0, s.log)
+	s.Require().NoError(err)
+	defer q.Close()
+
+	result, err := q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid1"})
+	s.Require().NoError(err)
+
+	select {
+	case r := <-result:
+		s.EqualError(r.Err, "service unavailable")
+		s.Equal(EndpointDelivery{Attempts: 3, StatusCode: 503}, r.Delivery)
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+}
+
+func (s *NotificationQueueTestSuite) Test_Open_RedeliversPendingNotifications() {
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		return fmt.Errorf("connection refused")
+	}, time.Hour, 0, 0, s.log)
+	s.Require().NoError(err)
+
+	result, err := q.Push(QueueKindService, Notification{
+		EventType: EventTypeRemove, ID: "sid1", Parameters: "serviceName=demo", TimeNano: 10})
+	s.Require().NoError(err)
+	s.Error(s.waitFor(result))
+	_, err = q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid2"})
+	s.Require().NoError(err)
+	q.Close()
+
+	delivered := make(chan QueueEntry, 2)
+	q, err = OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		delivered <- e
+		return nil
+	}, time.Millisecond, 0, 0, s.log)
+	s.Require().NoError(err)
+	defer q.Close()
+
+	s.waitUntilEmpty(q)
+	e := <-delivered
+	s.Equal(uint64(1), e.Seq)
+	s.Equal(QueueKindService, e.Kind)
+	s.Equal(EventTypeRemove, e.EventType)
+	s.Equal("sid1", e.ID)
+	s.Equal("serviceName=demo", e.Parameters)
+	s.Equal(int64(10), e.TimeNano)
+	s.Equal("sid2", (<-delivered).ID)
+
+	result, err = q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid3"})
+	s.Require().NoError(err)
+	s.NoError(s.waitFor(result))
+	s.Equal(uint64(3), (<-delivered).Seq)
+}
+
+func (s *NotificationQueueTestSuite) Test_Push_SupersedesPendingNotificationsWithTheSameID() {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	delivered := []string{}
+	mux := sync.Mutex{}
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		if e.ID == "sid1" {
+			started <- struct{}{}
+			<-release
+		}
+		mux.Lock()
+		delivered = append(delivered, e.Parameters)
+		mux.Unlock()
+		return nil
+	}, time.Millisecond, 0, 0, s.log)
+	s.Require().NoError(err)
+	defer q.Close()
+
+	q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid1", Parameters: "a"})
+	<-started
+	q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid2", Parameters: "b"})
+	q.Push(QueueKindNode, Notification{EventType: EventTypeCreate, ID: "sid2", Parameters: "c"})
+	q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid2", Parameters: "d"})
+
+	entries := q.Entries()
+	s.Require().Len(entries, 3)
+	s.Equal("a", entries[0].Parameters)
+	s.Equal("c", entries[1].Parameters)
+	s.Equal("d", entries[2].Parameters)
+
+	close(release)
+	s.waitUntilEmpty(q)
+	mux.Lock()
+	defer mux.Unlock()
+	s.Equal([]string{"a", "c", "d"}, delivered)
+}
+
+func (s *NotificationQueueTestSuite) Test_Push_DropsNotificationsThatFailPermanently() {
+	logBytes := new(bytes.Buffer)
+	delivered := make(chan string, 2)
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		if e.ID == "sid1" {
+			return &notificationStatusError{statusCode: 400, message: "bad request", permanent: true}
+		}
+		delivered <- e.ID
+		return nil
+	}, time.Hour, 0, 0, logging.New(logBytes, logging.FormatLogfmt, logging.LevelDebug))
+	s.Require().NoError(err)
+	defer q.Close()
+
+	result1, err := q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid1"})
+	s.Require().NoError(err)
+	result2, err := q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid2"})
+	s.Require().NoError(err)
+	result3, err := q.Push(QueueKindNode, Notification{EventType: EventTypeRemove, ID: "nid1"})
+	s.Require().NoError(err)
+
+	s.EqualError(s.waitFor(result1), "bad request")
+	s.NoError(s.waitFor(result2))
+	s.NoError(s.waitFor(result3))
+	s.waitUntilEmpty(q)
+	s.Equal("sid2", <-delivered)
+	s.Equal("nid1", <-delivered)
+	s.Contains(logBytes.String(),
+		`msg="Dropping notification that cannot be delivered" service_id=sid1 event_type=create`)
+}
+
+func (s *NotificationQueueTestSuite) Test_Push_DropsNotifications_WhenMaxAttemptsIsReached() {
+	attempts := 0
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		if e.ID == "sid1" {
+			attempts++
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}, time.Millisecond, 3, 0, s.log)
+	s.Require().NoError(err)
+	defer q.Close()
+
+	q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid1"})
+	result, err := q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid2"})
+	s.Require().NoError(err)
+
+	s.NoError(s.waitFor(result))
+	s.waitUntilEmpty(q)
+	s.Equal(3, attempts)
+}
+
+func (s *NotificationQueueTestSuite) Test_Push_DropsNotifications_WhenMaxAgeIsReached() {
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		if e.ID == "sid1" {
+			time.Sleep(time.Millisecond * 20)
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}, time.Millisecond, 0, time.Millisecond*10, s.log)
+	s.Require().NoError(err)
+	defer q.Close()
+
+	q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid1"})
+	result, err := q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid2"})
+	s.Require().NoError(err)
+
+	s.NoError(s.waitFor(result))
+	s.waitUntilEmpty(q)
+}
+
+func (s *NotificationQueueTestSuite) Test_Open_RestoresAttemptsAndQueuedAt() {
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		return fmt.Errorf("connection refused")
+	}, time.Hour, 0, 0, s.log)
+	s.Require().NoError(err)
+
+	result, err := q.Push(QueueKindService, Notification{EventType: EventTypeCreate, ID: "sid1"})
+	s.Require().NoError(err)
+	s.Error(s.waitFor(result))
+	queuedAt := q.Entries()[0].QueuedAt
+	q.Close()
+
+	delivered := make(chan QueueEntry, 1)
+	q, err = OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		delivered <- e
+		return fmt.Errorf("connection refused")
+	}, time.Hour, 2, 0, s.log)
+	s.Require().NoError(err)
+	defer q.Close()
+
+	e := <-delivered
+	s.Equal(1, e.Attempts)
+	s.Equal(queuedAt.UnixNano(), e.QueuedAt.UnixNano())
+	s.waitUntilEmpty(q)
+}
+
+func (s *NotificationQueueTestSuite) Test_Push_ReturnsError_WhenQueueIsClosed() {
+	q, err := OpenNotificationQueue(s.filename, func(ctx context.Context, e QueueEntry) error {
+		return nil
+	}, time.Millisecond, 0, 0, s.log)
+	s.Require().NoError(err)
+	q.Close()
+
+	_, err = q.Push(QueueKindService, Notification{ID: "sid1"})
+	s.Equal(errQueueClosed, err)
+}
+
+func (s *NotificationQueueTestSuite) waitFor(result <-chan QueueResult) error {
+	select {
+	case r := <-result:
+		return r.Err
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+	return nil
+}
+
+func (s *NotificationQueueTestSuite) waitUntilEmpty(q *NotificationQueue) {
+	timeout := time.After(time.Second * 5)
+	for len(q.Entries()) > 0 {
+		select {
+		case <-timeout:
+			s.FailNow("Timeout")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -1,21 +1,43 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+	"github.com/docker-flow/docker-flow-swarm-listener/signature"
+	"github.com/docker-flow/docker-flow-swarm-listener/tracing"
 )
 
 // NotifyType is the type of notification to send
 type NotifyType string
 
+// PayloadType is the format used to send notification parameters
+type PayloadType string
+
+const (
+	// PayloadTypeQuery sends parameters in the url query
+	PayloadTypeQuery PayloadType = "query"
+	// PayloadTypeJSON sends parameters as a JSON request body
+	PayloadTypeJSON PayloadType = "json"
+	// PayloadTypeForm sends parameters as a form-encoded request body
+	PayloadTypeForm PayloadType = "form"
+)
+
+// structuredParameters are parameters that hold JSON values. They are
+// embedded as JSON instead of strings when sending JSON payloads
+var structuredParameters = map[string]struct{}{
+	"nodeInfo": {},
+}
+
 // NotificationSender sends notifications to listeners
 type NotificationSender interface {
 	Create(ctx context.Context, params string) error
@@ -30,27 +52,48 @@ type Notifier struct {
 	createHTTPMethod  string
 	removeAddr        string
 	removeHTTPMethod  string
+	createPayload     PayloadType
+	removePayload     PayloadType
 	notifyType        string
-	retries           int
-	interval          int
+	createRetryPolicy RetryPolicy
+	removeRetryPolicy RetryPolicy
 	createErrorMetric string
 	removeErrorMetric string
-	log               *log.Logger
+	client            *http.Client
+	circuitBreaker    *CircuitBreaker
+	headers           http.Header
+	signingSecret     []byte
+	log               *logging.Logger
+}
+
+// notificationAction describes how create or remove notifications are sent
+type notificationAction struct {
+	name        string
+	pastTense   string
+	addr        string
+	httpMethod  string
+	payload     PayloadType
+	retryPolicy RetryPolicy
+	errorMetric string
+	isSuccess   func(statusCode int) bool
 }
 
 // NewNotifier returns a `Notifier`
 func NewNotifier(
 	createAddr, removeAddr, createHTTPMethod,
-	removeHTTPMethod, notifyType string,
-	retries int, interval int, logger *log.Logger) *Notifier {
+	removeHTTPMethod string, createPayload, removePayload PayloadType,
+	notifyType string, createRetryPolicy, removeRetryPolicy RetryPolicy,
+	logger *logging.Logger) *Notifier {
 	return &Notifier{
 		createAddr:        createAddr,
 		createHTTPMethod:  createHTTPMethod,
 		removeAddr:        removeAddr,
 		removeHTTPMethod:  removeHTTPMethod,
+		createPayload:     createPayload,
+		removePayload:     removePayload,
 		notifyType:        notifyType,
-		retries:           retries,
-		interval:          interval,
+		createRetryPolicy: createRetryPolicy,
+		removeRetryPolicy: removeRetryPolicy,
 		createErrorMetric: fmt.Sprintf("notificationSendCreate%sRequest", notifyType),
 		removeErrorMetric: fmt.Sprintf("notificationSendRemove%sRequest", notifyType),
 		log:               logger,
@@ -69,171 +112,332 @@ func (n Notifier) GetRemoveAddr() string {
 
 // Create sends create notifications to listeners
 func (n Notifier) Create(ctx context.Context, params string) error {
-	if len(n.createAddr) == 0 {
+	return n.send(ctx, notificationAction{
+		name:        "create",
+		pastTense:   "created",
+		addr:        n.createAddr,
+		httpMethod:  n.createHTTPMethod,
+		payload:     n.createPayload,
+		retryPolicy: n.createRetryPolicy,
+		errorMetric: n.createErrorMetric,
+		isSuccess: func(statusCode int) bool {
+			return statusCode == http.StatusOK || statusCode == http.StatusConflict
+		},
+	}, params)
+}
+
+// Remove sends remove notifications to listeners
+func (n Notifier) Remove(ctx context.Context, params string) error {
+	return n.send(ctx, notificationAction{
+		name:        "remove",
+		pastTense:   "removed",
+		addr:        n.removeAddr,
+		httpMethod:  n.removeHTTPMethod,
+		payload:     n.removePayload,
+		retryPolicy: n.removeRetryPolicy,
+		errorMetric: n.removeErrorMetric,
+		isSuccess: func(statusCode int) bool {
+			return statusCode == http.StatusOK
+		},
+	}, params)
+}
+
+// send sends a notification and retries it according to the retry policy
+// of `action`. Canceled notifications return nil.
+func (n Notifier) send(ctx context.Context, action notificationAction, params string) error {
+	if len(action.addr) == 0 {
 		return nil
 	}
 
-	urlObj, err := url.Parse(n.createAddr)
+	req, err := newNotificationRequest(
+		ctx, action.httpMethod, action.addr, action.payload, params)
 	if err != nil {
-		n.log.Printf("ERROR: %v", err)
-		metrics.RecordError(n.createErrorMetric)
+		n.log.WithContext(ctx).Error("Unable to create notification request",
+			"notify_type", n.notifyType, "action", action.name, "error", err)
+		metrics.RecordError(action.errorMetric)
 		return err
 	}
-
-	if len(params) > 0 {
-		if currentParams := urlObj.Query().Encode(); len(currentParams) > 0 {
-			newParams := fmt.Sprintf("%s&%s", currentParams, params)
-			urlObj.RawQuery = newParams
-		} else {
-			urlObj.RawQuery = params
-		}
+	for name, values := range n.headers {
+		req.Header[name] = values
 	}
 
-	fullURL := urlObj.String()
-	req, err := http.NewRequest(n.createHTTPMethod, fullURL, nil)
-	if err != nil {
-		n.log.Printf("ERROR: Incorrect fullURL: %s", fullURL)
-		metrics.RecordError(n.createErrorMetric)
-		return err
+	delivery := endpointDeliveryFromContext(ctx)
+	if delivery == nil {
+		delivery = &EndpointDelivery{}
 	}
-	req = req.WithContext(ctx)
 
-	n.log.Printf("Sending %s created notification to %s", n.notifyType, fullURL)
-	retryChan := make(chan int, 1)
-	retryChan <- 1
-	for {
-		select {
-		case i := <-retryChan:
-			resp, err := http.DefaultClient.Do(req)
-			if err != nil {
-				if strings.Contains(err.Error(), "context") {
-					n.log.Printf("Canceling %s create notification to %s", n.notifyType, fullURL)
-					return nil
-				}
-				if i <= n.retries && n.interval > 0 {
-					n.log.Printf("Retrying %s created notification to %s (%d try)", n.notifyType, fullURL, i)
-					time.Sleep(time.Second * time.Duration(n.interval))
-					retryChan <- i + 1
-					continue
-				} else {
-					n.log.Printf("ERROR: %v", err)
-					metrics.RecordError(n.createErrorMetric)
-					return err
-				}
+	endpoint := req.URL.Host
+	metrics.AddNotificationsInFlight(n.notifyType, 1)
+	defer metrics.AddNotificationsInFlight(n.notifyType, -1)
+
+	// The query holds the parameters of the notification, which are only
+	// logged at the debug level. They are left out of errors and spans.
+	target := urlWithoutQuery(req.URL)
+	log := n.log.WithContext(ctx).With("endpoint", endpoint, "url", target)
+	log.Info(fmt.Sprintf("Sending %s %s notification", n.notifyType, action.pastTense))
+	if log.Enabled(logging.LevelDebug) {
+		query, _ := url.ParseQuery(params)
+		log.Debug("Notification parameters", "params", query)
+	}
+	start := time.Now()
+	for retry := 1; ; retry++ {
+		if n.circuitBreaker != nil {
+			if err := n.circuitBreaker.Allow(); err != nil {
+				log.Error(fmt.Sprintf("Not sending %s %s notification", n.notifyType, action.name),
+					"error", err)
+				metrics.RecordError(action.errorMetric)
+				return err
 			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusConflict {
-				return nil
-			} else if i <= n.retries && n.interval > 0 {
-				n.log.Printf("Retrying %s created notification to %s (%d try)", n.notifyType, fullURL, i)
-				time.Sleep(time.Second * time.Duration(n.interval))
-				retryChan <- i + 1
-				continue
-			} else if resp.StatusCode == http.StatusConflict || resp.StatusCode != http.StatusOK {
-				body, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					err = fmt.Errorf("Failed at retrying request to %s returned status code %d", fullURL, resp.StatusCode)
-					n.log.Printf("ERROR: %v", err)
-					metrics.RecordError(n.createErrorMetric)
-					return err
-				}
-				err = fmt.Errorf("Failed at retrying request to %s returned status code %d\n%s", fullURL, resp.StatusCode, string(body[:]))
-				n.log.Printf("ERROR: %v", err)
-				metrics.RecordError(n.createErrorMetric)
+		}
+		if req.GetBody != nil {
+			req.Body, _ = req.GetBody()
+		}
+		if len(n.signingSecret) > 0 {
+			if err := signature.SignRequest(req, n.signingSecret, time.Now()); err != nil {
+				log.Error(fmt.Sprintf("Unable to sign %s %s notification", n.notifyType, action.name),
+					"error", err)
+				metrics.RecordError(action.errorMetric)
+				n.releaseCircuitBreaker()
 				return err
 			}
-			err = fmt.Errorf("Failed at retrying request to %s returned status code %d", fullURL, resp.StatusCode)
-			n.log.Printf("ERROR: %v", err)
-			metrics.RecordError(n.createErrorMetric)
+		}
+		delivery.Attempts++
+		attemptCtx, span := tracing.Start(ctx, fmt.Sprintf("HTTP %s", req.Method),
+			tracing.WithKind(tracing.SpanKindClient),
+			tracing.WithAttributes(
+				tracing.Attribute{Key: "http.method", Value: req.Method},
+				tracing.Attribute{Key: "http.url", Value: target},
+				tracing.Attribute{Key: "notification.attempt", Value: retry},
+			))
+		tracing.Inject(attemptCtx, req.Header)
+		sentAt := time.Now()
+		delivery.StatusCode, delivery.ResponseBody, err = n.do(req, target, action)
+		if delivery.StatusCode != 0 {
+			span.SetAttribute("http.status_code", delivery.StatusCode)
+		}
+		endSpan(span, err, ctx.Err() != nil)
+		n.recordCircuitBreakerResult(ctx, err)
+		if ctx.Err() == nil {
+			metrics.RecordNotificationLatency(endpoint, n.notifyType, action.name, time.Since(sentAt))
+		}
+		if err == nil {
+			metrics.RecordNotification(endpoint, n.notifyType, action.name,
+				metrics.NotificationSuccess, delivery.StatusCode)
+			return nil
+		}
+		if ctx.Err() != nil {
+			log.Debug(fmt.Sprintf("Canceling %s %s notification", n.notifyType, action.name), "attempt", retry)
+			metrics.RecordNotification(endpoint, n.notifyType, action.name,
+				metrics.NotificationCancel, delivery.StatusCode)
+			delivery.Canceled = true
+			return nil
+		}
+
+		retryable := true
+		if statusErr, ok := err.(*notificationStatusError); ok {
+			retryable = action.retryPolicy.IsRetryableStatusCode(statusErr.statusCode)
+			if !retryable {
+				statusErr.permanent = true
+			}
+		}
+		delay := action.retryPolicy.Delay(retry)
+		if !retryable || !action.retryPolicy.CanRetry(retry, time.Since(start)+delay) {
+			log.Error(fmt.Sprintf("Unable to send %s %s notification", n.notifyType, action.name),
+				"attempt", retry, "error", err)
+			metrics.RecordError(action.errorMetric)
+			metrics.RecordNotification(endpoint, n.notifyType, action.name,
+				metrics.NotificationFailure, delivery.StatusCode)
 			return err
+		}
+		metrics.RecordNotification(endpoint, n.notifyType, action.name,
+			metrics.NotificationRetry, delivery.StatusCode)
+
+		log.Warn(fmt.Sprintf("Retrying %s %s notification", n.notifyType, action.pastTense),
+			"attempt", retry, "delay", delay, "error", err)
+		select {
+		case <-time.After(delay):
 		case <-ctx.Done():
-			n.log.Printf("Canceling %s create notification to %s", n.notifyType, fullURL)
+			log.Debug(fmt.Sprintf("Canceling %s %s notification", n.notifyType, action.name), "attempt", retry)
+			metrics.RecordNotification(endpoint, n.notifyType, action.name,
+				metrics.NotificationCancel, delivery.StatusCode)
+			delivery.Canceled = true
 			return nil
 		}
+	}
+}
 
+// releaseCircuitBreaker ends a request allowed by the circuit breaker
+// that was not sent
+func (n Notifier) releaseCircuitBreaker() {
+	if n.circuitBreaker != nil {
+		n.circuitBreaker.Release()
 	}
 }
 
-// Remove sends remove notifications to listeners
-func (n Notifier) Remove(ctx context.Context, params string) error {
-	if len(n.removeAddr) == 0 {
-		return nil
+// recordCircuitBreakerResult records the result of a request in the
+// circuit breaker. Connection errors and server errors are failures,
+// all other responses show that the endpoint is available.
+func (n Notifier) recordCircuitBreakerResult(ctx context.Context, err error) {
+	if n.circuitBreaker == nil {
+		return
+	}
+	if err == nil {
+		n.circuitBreaker.RecordSuccess()
+		return
+	}
+	if ctx.Err() != nil {
+		n.circuitBreaker.Release()
+		return
 	}
+	if statusErr, ok := err.(*notificationStatusError); ok &&
+		statusErr.statusCode < http.StatusInternalServerError {
+		n.circuitBreaker.RecordSuccess()
+		return
+	}
+	n.circuitBreaker.RecordFailure()
+}
 
-	urlObj, err := url.Parse(n.removeAddr)
-	if err != nil {
-		n.log.Printf("ERROR: %v", err)
-		metrics.RecordError(n.removeErrorMetric)
-		return err
+// notificationStatusError is returned when a notification is answered
+// with an unsuccessful status code
+type notificationStatusError struct {
+	statusCode int
+	message    string
+	// permanent is set when sending the notification again cannot succeed
+	permanent bool
+}
+
+func (e *notificationStatusError) Error() string {
+	return e.message
+}
+
+// isPermanentNotificationError returns true when `err` is a status error
+// that sending the notification again cannot resolve
+func isPermanentNotificationError(err error) bool {
+	statusErr, ok := err.(*notificationStatusError)
+	return ok && statusErr.permanent
+}
+
+// isPermanentStatusCode returns true for client errors other than timeouts
+// and rate limiting
+func isPermanentStatusCode(statusCode int) bool {
+	return statusCode >= http.StatusBadRequest &&
+		statusCode < http.StatusInternalServerError &&
+		statusCode != http.StatusRequestTimeout &&
+		statusCode != http.StatusTooManyRequests
+}
+
+// do sends `req` once and returns the status code and an excerpt of the
+// body of the response. Errors refer to the request as `target`.
+func (n Notifier) do(req *http.Request, target string, action notificationAction) (int, string, error) {
+	client := n.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if urlErr, ok := err.(*url.Error); ok {
+		urlErr.URL = target
+		return 0, "", urlErr
+	} else if err != nil {
+		return 0, "", err
 	}
+	defer resp.Body.Close()
 
-	if len(params) > 0 {
-		if currentParams := urlObj.Query().Encode(); len(currentParams) > 0 {
-			newParams := fmt.Sprintf("%s&%s", currentParams, params)
-			urlObj.RawQuery = newParams
-		} else {
-			urlObj.RawQuery = params
+	if action.isSuccess(resp.StatusCode) {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, auditResponseBodyLimit+1))
+		return resp.StatusCode, responseExcerpt(body), nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, auditResponseBodyLimit+1))
+	if err != nil {
+		return resp.StatusCode, "", &notificationStatusError{
+			statusCode: resp.StatusCode,
+			message:    fmt.Sprintf("Failed at retrying request to %s returned status code %d", target, resp.StatusCode),
+			permanent:  isPermanentStatusCode(resp.StatusCode),
 		}
 	}
+	excerpt := responseExcerpt(body)
+	return resp.StatusCode, excerpt, &notificationStatusError{
+		statusCode: resp.StatusCode,
+		message:    fmt.Sprintf("Failed at retrying request to %s returned status code %d\n%s", target, resp.StatusCode, excerpt),
+		permanent:  isPermanentStatusCode(resp.StatusCode),
+	}
+}
 
-	fullURL := urlObj.String()
-	req, err := http.NewRequest(n.removeHTTPMethod, fullURL, nil)
+// newNotificationRequest creates a request to `addr` with `params` encoded
+// according to `payload`
+func newNotificationRequest(
+	ctx context.Context, method, addr string,
+	payload PayloadType, params string) (*http.Request, error) {
+
+	urlObj, err := url.Parse(addr)
 	if err != nil {
-		n.log.Printf("ERROR: Incorrect fullURL: %s", fullURL)
-		metrics.RecordError(n.removeErrorMetric)
-		return err
+		return nil, err
 	}
-	req = req.WithContext(ctx)
 
-	n.log.Printf("Sending %s removed notification to %s", n.notifyType, fullURL)
-	retryChan := make(chan int, 1)
-	retryChan <- 1
-	for {
-		select {
-		case i := <-retryChan:
-			resp, err := http.DefaultClient.Do(req)
-			if err != nil {
-				if strings.Contains(err.Error(), "context") {
-					n.log.Printf("Canceling %s remove notification to %s", n.notifyType, fullURL)
-					return nil
-				}
-				if i <= n.retries && n.interval > 0 {
-					n.log.Printf("Retrying %s removed notification to %s (%d try)", n.notifyType, fullURL, i)
-					time.Sleep(time.Second * time.Duration(n.interval))
-					retryChan <- i + 1
-					continue
-				} else {
-					n.log.Printf("ERROR: %v", err)
-					metrics.RecordError(n.removeErrorMetric)
-					return err
-				}
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode == http.StatusOK {
-				return nil
-			} else if i <= n.retries && n.interval > 0 {
-				n.log.Printf("Retrying %s removed notification to %s (%d try)", n.notifyType, fullURL, i)
-				time.Sleep(time.Second * time.Duration(n.interval))
-				retryChan <- i + 1
-				continue
+	var body []byte
+	var contentType string
+
+	switch payload {
+	case PayloadTypeJSON:
+		body, err = convertParametersToJSON(params)
+		if err != nil {
+			return nil, err
+		}
+		contentType = "application/json"
+	case PayloadTypeForm:
+		body = []byte(params)
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		if len(params) > 0 {
+			if currentParams := urlObj.Query().Encode(); len(currentParams) > 0 {
+				newParams := fmt.Sprintf("%s&%s", currentParams, params)
+				urlObj.RawQuery = newParams
 			} else {
-				body, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					err = fmt.Errorf("Failed at retrying request to %s returned status code %d", fullURL, resp.StatusCode)
-					n.log.Printf("ERROR: %v", err)
-					metrics.RecordError(n.removeErrorMetric)
-					return err
-				}
-				err = fmt.Errorf("Failed at retrying request to %s returned status code %d\n%s", fullURL, resp.StatusCode, string(body[:]))
-				n.log.Printf("ERROR: %v", err)
-				metrics.RecordError(n.removeErrorMetric)
-				return err
+				urlObj.RawQuery = params
 			}
-		case <-ctx.Done():
-			n.log.Printf("Canceling %s remove notification to %s", n.notifyType, fullURL)
-			return nil
 		}
 	}
+
+	fullURL := urlObj.String()
+
+	var req *http.Request
+	if body != nil {
+		req, err = http.NewRequest(method, fullURL, bytes.NewReader(body))
+	} else {
+		req, err = http.NewRequest(method, fullURL, nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Incorrect fullURL: %s", urlWithoutQuery(urlObj))
+	}
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req.WithContext(ctx), nil
+}
+
+// urlWithoutQuery returns `u` without the query, which holds the
+// parameters of notifications
+func urlWithoutQuery(u *url.URL) string {
+	target := *u
+	target.RawQuery = ""
+	return target.String()
+}
+
+// convertParametersToJSON converts url encoded `params` into a JSON object
+// Values of `structuredParameters` are embedded as JSON
+func convertParametersToJSON(params string) ([]byte, error) {
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return nil, err
+	}
+
+	output := map[string]interface{}{}
+	for k := range values {
+		v := values.Get(k)
+		if _, ok := structuredParameters[k]; ok && json.Valid([]byte(v)) {
+			output[k] = json.RawMessage(v)
+			continue
+		}
+		output[k] = v
+	}
+	return json.Marshal(output)
 }
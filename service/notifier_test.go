@@ -3,20 +3,26 @@ package service
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/docker-flow/docker-flow-swarm-listener/signature"
+	"github.com/docker-flow/docker-flow-swarm-listener/tracing"
 	"github.com/stretchr/testify/suite"
 )
 
 type NotifierTestSuite struct {
 	suite.Suite
-	Logger   *log.Logger
+	Logger   *logging.Logger
 	LogBytes *bytes.Buffer
 	Params   string
 }
@@ -27,7 +33,7 @@ func TestNotifierUnitTestSuite(t *testing.T) {
 
 func (s *NotifierTestSuite) SetupSuite() {
 	s.LogBytes = new(bytes.Buffer)
-	s.Logger = log.New(s.LogBytes, "", 0)
+	s.Logger = logging.New(s.LogBytes, logging.FormatLogfmt, logging.LevelDebug)
 
 	cParams := url.Values{}
 	cParams.Add("serviceName", "hello")
@@ -64,7 +70,8 @@ func (s *NotifierTestSuite) Test_Create_SendsRequests() {
 
 	n := NewNotifier(
 		url1, "", createMethod, http.MethodGet,
-		"service", 5, 1, s.Logger)
+		PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(5, 1), NewRetryPolicy(5, 1), s.Logger)
 	s.Equal(url1, n.GetCreateAddr())
 	err := n.Create(context.Background(), s.Params)
 	s.Require().NoError(err)
@@ -74,10 +81,12 @@ func (s *NotifierTestSuite) Test_Create_SendsRequests() {
 	urlObj1, err := url.Parse(url1)
 	s.Require().NoError(err)
 
-	urlObj1.RawQuery = s.Params
+	urlObj1.RawQuery = ""
 
 	logMsgs := s.LogBytes.String()
-	s.Contains(logMsgs, fmt.Sprintf("Sending service created notification to %s", urlObj1.String()))
+	s.Contains(logMsgs, fmt.Sprintf(`msg="Sending service created notification" endpoint=%s url=%s`,
+		urlObj1.Host, urlObj1))
+	s.Contains(logMsgs, fmt.Sprintf("params=%q", s.Params))
 }
 
 func (s *NotifierTestSuite) Test_Create_SendsRequestsWithParams() {
@@ -101,7 +110,8 @@ func (s *NotifierTestSuite) Test_Create_SendsRequestsWithParams() {
 	url1 := fmt.Sprintf("%s/v1/docker-flow-proxy/reconfigure?hello=world", httpSrv.URL)
 
 	n := NewNotifier(url1, "", http.MethodGet,
-		http.MethodGet, "service", 5, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(5, 1), NewRetryPolicy(5, 1), s.Logger)
 	s.Equal(url1, n.GetCreateAddr())
 	err := n.Create(context.Background(), s.Params)
 	s.Require().NoError(err)
@@ -113,20 +123,204 @@ func (s *NotifierTestSuite) Test_Create_SendsRequestsWithParams() {
 	urlObj1, err := url.Parse(url1)
 	s.Require().NoError(err)
 
-	urlObj1.RawQuery = newParams
+	urlObj1.RawQuery = ""
 
 	logMsgs := s.LogBytes.String()
-	s.Contains(logMsgs, fmt.Sprintf("Sending service created notification to %s", urlObj1.String()))
+	s.Contains(logMsgs, fmt.Sprintf(`msg="Sending service created notification" endpoint=%s url=%s`,
+		urlObj1.Host, urlObj1))
+	s.Contains(logMsgs, fmt.Sprintf("params=%q", s.Params))
+}
+
+func (s *NotifierTestSuite) Test_Create_SendsJSONPayload() {
+
+	var query1, contentType1 string
+	var body1 map[string]interface{}
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(
+		w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			query1 = r.URL.Query().Encode()
+			contentType1 = r.Header.Get("Content-Type")
+			json.NewDecoder(r.Body).Decode(&body1)
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer httpSrv.Close()
+
+	url1 := fmt.Sprintf("%s/v1/docker-flow-proxy/reconfigure?hello=world", httpSrv.URL)
+
+	params := url.Values{}
+	params.Add("serviceName", "hello")
+	params.Add("nodeInfo", `[["node-1","10.0.0.1","id1"]]`)
+
+	n := NewNotifier(url1, "", http.MethodPost,
+		http.MethodGet, PayloadTypeJSON, PayloadTypeQuery,
+		"service", NewRetryPolicy(5, 1), NewRetryPolicy(5, 1), s.Logger)
+	err := n.Create(context.Background(), params.Encode())
+	s.Require().NoError(err)
+
+	s.Equal("hello=world", query1)
+	s.Equal("application/json", contentType1)
+	s.Equal("hello", body1["serviceName"])
+	s.Equal([]interface{}{
+		[]interface{}{"node-1", "10.0.0.1", "id1"},
+	}, body1["nodeInfo"])
+
+	logMsgs := s.LogBytes.String()
+	s.Contains(logMsgs, fmt.Sprintf(`msg="Sending service created notification" endpoint=%s url=%s`,
+		strings.TrimPrefix(httpSrv.URL, "http://"), strings.TrimSuffix(url1, "?hello=world")))
+}
+
+func (s *NotifierTestSuite) Test_Create_SendsHeadersAndSignature() {
+	secret := []byte("my-secret")
+	headers := []http.Header{}
+	verifyErrs := []error{}
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header)
+		verifyErrs = append(verifyErrs, signature.VerifyRequest(r, secret, signature.DefaultTolerance))
+		if len(headers) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	testCases := []PayloadType{PayloadTypeQuery, PayloadTypeJSON}
+	for _, payload := range testCases {
+		headers = []http.Header{}
+		verifyErrs = []error{}
+		n := NewNotifier(
+			httpSrv.URL+"/reconfigure?hello=world", "", http.MethodPost,
+			http.MethodGet, payload, PayloadTypeQuery,
+			"service", NewRetryPolicy(2, 0), NewRetryPolicy(2, 0), s.Logger)
+		n.headers = http.Header{
+			"Authorization": []string{"Bearer my-token"},
+			"X-Env":         []string{"prod"},
+		}
+		n.signingSecret = secret
+
+		err := n.Create(context.Background(), s.Params)
+		s.Require().NoError(err)
+
+		s.Require().Len(headers, 2)
+		for idx, h := range headers {
+			s.Equal("Bearer my-token", h.Get("Authorization"))
+			s.Equal("prod", h.Get("X-Env"))
+			s.NotEmpty(h.Get(signature.SignatureHeader))
+			s.NoError(verifyErrs[idx], string(payload))
+		}
+	}
+}
+
+func (s *NotifierTestSuite) Test_Create_ResendsPayloadOnRetry() {
+	bodies := []string{}
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 2 {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodPost,
+		http.MethodGet, PayloadTypeForm, PayloadTypeQuery,
+		"service", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
+	err := n.Create(context.Background(), s.Params)
+	s.Require().NoError(err)
+
+	s.Equal([]string{s.Params, s.Params}, bodies)
+}
+
+func (s *NotifierTestSuite) Test_Create_PropagatesTraceContext() {
+	traceparents := []string{}
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents = append(traceparents, r.Header.Get(tracing.TraceparentHeader))
+		if len(traceparents) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer httpSrv.Close()
+
+	tracing.SetTracer(tracing.NewTracer(&spanRecorder{}, 1, s.Logger))
+	defer tracing.SetTracer(nil)
+	ctx, span := tracing.Start(context.Background(), "notification.deliver")
+
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodPost,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
+	err := n.Create(ctx, s.Params)
+	s.Require().NoError(err)
+
+	s.Require().Len(traceparents, 2)
+	for _, value := range traceparents {
+		sc, err := tracing.ParseTraceparent(value)
+		s.Require().NoError(err)
+		s.Equal(span.SpanContext().TraceID, sc.TraceID)
+		s.NotEqual(span.SpanContext().SpanID, sc.SpanID)
+	}
+	s.NotEqual(traceparents[0], traceparents[1])
+}
+
+func (s *NotifierTestSuite) Test_Create_DoesNotSendTraceparent_WhenTracingIsDisabled() {
+	var header http.Header
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodPost,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
+	err := n.Create(context.Background(), s.Params)
+	s.Require().NoError(err)
+
+	s.Empty(header.Get(tracing.TraceparentHeader))
+}
+
+func (s *NotifierTestSuite) Test_Create_LogsFieldsOfContext_WithoutSensitiveValues() {
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+	createURL := strings.Replace(httpSrv.URL, "http://", "http://user:secret@", 1) + "/reconfigure"
+	n := NewNotifier(
+		createURL, "", http.MethodGet, http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(1, 1), NewRetryPolicy(1, 1),
+		s.Logger.WithRedactedLabels([]string{"com.df.usersPasswords"}))
+	ctx := logging.ContextWithFields(context.Background(),
+		"service_id", "sid1", "event_type", EventTypeCreate, "request_id", int64(10))
+
+	err := n.Create(ctx, "serviceName=hello&usersPasswords=admin:pass")
+	s.Require().NoError(err)
+
+	logMsgs := s.LogBytes.String()
+	s.Contains(logMsgs, fmt.Sprintf(
+		`level=info msg="Sending service created notification" service_id=sid1 event_type=create request_id=10 endpoint=%s url=http://user:REDACTED@%s/reconfigure`,
+		strings.TrimPrefix(httpSrv.URL, "http://"), strings.TrimPrefix(httpSrv.URL, "http://")))
+	s.Contains(logMsgs, `level=debug msg="Notification parameters"`)
+	s.Contains(logMsgs, `params="serviceName=hello&usersPasswords=REDACTED"`)
+	s.NotContains(logMsgs, "secret")
+	s.NotContains(logMsgs, "admin:pass")
 }
 
 func (s *NotifierTestSuite) Test_Create_ReturnsAndLogsError_WhenUrlCannotBeParsed() {
 	n := NewNotifier("%%%", "", http.MethodGet,
-		http.MethodGet, "service", 5, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(5, 1), NewRetryPolicy(5, 1), s.Logger)
 	err := n.Create(context.Background(), s.Params)
 	s.Error(err)
 
 	logMsgs := s.LogBytes.String()
-	s.True(strings.HasPrefix(logMsgs, "ERROR: "))
+	s.True(strings.Contains(logMsgs, "level=error"))
 }
 
 func (s *NotifierTestSuite) Test_Create_ReturnsAndLogsError_WhenHttpStatusIsNot200() {
@@ -137,12 +331,41 @@ func (s *NotifierTestSuite) Test_Create_ReturnsAndLogsError_WhenHttpStatusIsNot2
 
 	n := NewNotifier(
 		httpSrv.URL, "", http.MethodGet,
-		http.MethodGet, "node", 1, 0, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"node", NewRetryPolicy(1, 0), NewRetryPolicy(1, 0), s.Logger)
 	err := n.Create(context.Background(), s.Params)
 	s.Error(err)
 
 	logMsgs := s.LogBytes.String()
-	s.Contains(logMsgs, "ERROR: ")
+	s.Contains(logMsgs, "level=error")
+}
+
+func (s *NotifierTestSuite) Test_Create_LeavesParametersOutOfErrorsAndSpans() {
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer httpSrv.Close()
+	recorder := &spanRecorder{}
+	tracer := tracing.NewTracer(recorder, 1, s.Logger)
+	tracing.SetTracer(tracer)
+	defer tracing.SetTracer(nil)
+
+	for _, createURL := range []string{httpSrv.URL + "/reconfigure", "http://this-does-not-exist/reconfigure"} {
+		n := NewNotifier(
+			createURL, "", http.MethodGet,
+			http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+			"service", NewRetryPolicy(1, 0), NewRetryPolicy(1, 0), s.Logger)
+		err := n.Create(context.Background(), "serviceName=hello&usersPasswords=admin:pass")
+		s.Require().Error(err)
+		s.Contains(err.Error(), createURL)
+		s.NotContains(err.Error(), "admin")
+	}
+	tracer.Flush()
+
+	spans := recorder.Spans()
+	s.Require().Contains(spans, "HTTP GET")
+	s.Contains(spans["HTTP GET"].Attributes,
+		tracing.Attribute{Key: "http.url", Value: "http://this-does-not-exist/reconfigure"})
 }
 
 func (s *NotifierTestSuite) Test_Create_ReturnsNoError_WhenHttpStatusIs409() {
@@ -153,7 +376,8 @@ func (s *NotifierTestSuite) Test_Create_ReturnsNoError_WhenHttpStatusIs409() {
 
 	n := NewNotifier(
 		httpSrv.URL, "", http.MethodGet,
-		http.MethodGet, "node", 1, 0, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"node", NewRetryPolicy(1, 0), NewRetryPolicy(1, 0), s.Logger)
 	err := n.Create(context.Background(), s.Params)
 	s.Require().NoError(err)
 }
@@ -161,13 +385,14 @@ func (s *NotifierTestSuite) Test_Create_ReturnsNoError_WhenHttpStatusIs409() {
 func (s *NotifierTestSuite) Test_Create_ReturnsAndLogsError_WhenHttpRequestErrors() {
 	n := NewNotifier(
 		"this-does-not-exist", "", http.MethodGet,
-		http.MethodGet, "node", 2, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"node", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
 
 	err := n.Create(context.Background(), s.Params)
 	s.Require().Error(err)
 
 	logMsgs := s.LogBytes.String()
-	s.Contains(logMsgs, "ERROR: ")
+	s.Contains(logMsgs, "level=error")
 }
 
 func (s *NotifierTestSuite) Test_Create_RetriesRequests() {
@@ -184,31 +409,219 @@ func (s *NotifierTestSuite) Test_Create_RetriesRequests() {
 
 	n := NewNotifier(
 		httpSrv.URL, "", http.MethodGet,
-		http.MethodGet, "service", 2, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
 	n.Create(context.Background(), s.Params)
 
 	s.Equal(2, attempt)
 
 	logMsgs := s.LogBytes.String()
-	expMsg := fmt.Sprintf("Retrying service created notification to %s", httpSrv.URL)
+	expMsg := fmt.Sprintf(`level=warn msg="Retrying service created notification" endpoint=%s url=%s attempt=1`,
+		strings.TrimPrefix(httpSrv.URL, "http://"), httpSrv.URL)
 	s.Contains(logMsgs, expMsg)
 }
 
+func (s *NotifierTestSuite) Test_Create_RecordsDelivery() {
+	attempt := 0
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempt < 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(strings.Repeat("x", auditResponseBodyLimit+10)))
+		}
+		attempt++
+	}))
+
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodGet,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
+	delivery := EndpointDelivery{}
+	err := n.Create(withEndpointDelivery(context.Background(), &delivery), s.Params)
+
+	s.Require().NoError(err)
+	s.Equal(EndpointDelivery{
+		StatusCode:   http.StatusOK,
+		Attempts:     2,
+		ResponseBody: strings.Repeat("x", auditResponseBodyLimit) + "...",
+	}, delivery)
+}
+
+func (s *NotifierTestSuite) Test_Create_LimitsResponseBody_WhenRequestFails() {
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(strings.Repeat("x", auditResponseBodyLimit+10)))
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodGet,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(0, 1), NewRetryPolicy(0, 1), s.Logger)
+	delivery := EndpointDelivery{}
+	err := n.Create(withEndpointDelivery(context.Background(), &delivery), s.Params)
+
+	excerpt := strings.Repeat("x", auditResponseBodyLimit) + "..."
+	s.Require().Error(err)
+	s.Equal(fmt.Sprintf("Failed at retrying request to %s returned status code %d\n%s",
+		httpSrv.URL, http.StatusBadRequest, excerpt), err.Error())
+	s.Equal(excerpt, delivery.ResponseBody)
+}
+
+func (s *NotifierTestSuite) Test_Create_RetriesRequests_WhenIntervalIsZero() {
+	attempt := 0
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		if attempt < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodGet,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(2, 0), NewRetryPolicy(2, 0), s.Logger)
+	err := n.Create(context.Background(), s.Params)
+	s.Require().NoError(err)
+
+	s.Equal(3, attempt)
+}
+
+func (s *NotifierTestSuite) Test_Create_DoesNotRetryStatusCodesThatAreNotRetryable() {
+	attempt := 0
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer httpSrv.Close()
+
+	policy := RetryPolicy{
+		MaxRetries:           5,
+		InitialDelay:         time.Millisecond,
+		Multiplier:           2,
+		RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+	}
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodGet,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", policy, policy, s.Logger)
+	err := n.Create(context.Background(), s.Params)
+	s.Require().Error(err)
+
+	s.Equal(1, attempt)
+	s.True(isPermanentNotificationError(err))
+}
+
+func (s *NotifierTestSuite) Test_Send_ReturnsPermanentErrors_ForClientErrors() {
+	statusCode := http.StatusNotFound
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier(
+		httpSrv.URL, httpSrv.URL, http.MethodGet,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(0, 0), NewRetryPolicy(0, 0), s.Logger)
+	err := n.Remove(context.Background(), s.Params)
+	s.True(isPermanentNotificationError(err))
+
+	for _, statusCode = range []int{
+		http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		err = n.Create(context.Background(), s.Params)
+		s.Require().Error(err)
+		s.False(isPermanentNotificationError(err), statusCode)
+	}
+}
+
+func (s *NotifierTestSuite) Test_Create_StopsRetrying_WhenMaxElapsedTimeIsReached() {
+	attempt := 0
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer httpSrv.Close()
+
+	policy := RetryPolicy{
+		MaxRetries:     50,
+		InitialDelay:   time.Millisecond * 20,
+		Multiplier:     1,
+		MaxElapsedTime: time.Millisecond * 50,
+	}
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodGet,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", policy, policy, s.Logger)
+	err := n.Create(context.Background(), s.Params)
+	s.Require().Error(err)
+
+	s.True(attempt >= 2 && attempt <= 3, attempt)
+}
+
+func (s *NotifierTestSuite) Test_Create_FailsFast_WhenCircuitBreakerIsOpen() {
+	attempt := 0
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempt++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodGet,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(5, 0), NewRetryPolicy(5, 0), s.Logger)
+	n.circuitBreaker = NewCircuitBreaker("proxy", 2, time.Hour, s.Logger)
+
+	err := n.Create(context.Background(), s.Params)
+	s.EqualError(err, "Circuit breaker for proxy is open")
+	s.Equal(2, attempt)
+
+	err = n.Create(context.Background(), s.Params)
+	s.EqualError(err, "Circuit breaker for proxy is open")
+	s.Equal(2, attempt)
+	s.Contains(s.LogBytes.String(), `level=warn msg="Opening circuit breaker" endpoint=proxy failures=2`)
+}
+
+func (s *NotifierTestSuite) Test_Create_ClosesCircuitBreaker_WhenEndpointResponds() {
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier(
+		httpSrv.URL, "", http.MethodGet,
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(3, 0), NewRetryPolicy(3, 0), s.Logger)
+	n.circuitBreaker = NewCircuitBreaker("proxy", 2, time.Hour, s.Logger)
+
+	err := n.Create(context.Background(), s.Params)
+	s.Error(err)
+	s.Equal(CircuitClosed, n.circuitBreaker.Status().State)
+}
+
 func (s *NotifierTestSuite) Test_Create_Cancels() {
 	httpSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 	}))
 	n := NewNotifier(
 		httpSrv.URL, "", http.MethodGet,
-		http.MethodGet, "service", 2, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
-	n.Create(ctx, s.Params)
+	delivery := EndpointDelivery{}
+	n.Create(withEndpointDelivery(ctx, &delivery), s.Params)
 
 	logMsgs := s.LogBytes.String()
-	expMsg := fmt.Sprintf("Canceling service create notification to %s", httpSrv.URL)
+	expMsg := fmt.Sprintf(`level=debug msg="Canceling service create notification" endpoint=%s url=%s attempt=1`,
+		strings.TrimPrefix(httpSrv.URL, "http://"), httpSrv.URL)
 	s.Contains(logMsgs, expMsg)
+	s.True(delivery.Canceled)
 }
 
 // Remove
@@ -235,7 +648,8 @@ func (s *NotifierTestSuite) Test_Remove_SendsRequests() {
 	url1 := fmt.Sprintf("%s/v1/docker-flow-proxy/remove", httpSrv.URL)
 
 	n := NewNotifier("", url1, http.MethodGet,
-		removeMethod, "node", 5, 1, s.Logger)
+		removeMethod, PayloadTypeQuery, PayloadTypeQuery,
+		"node", NewRetryPolicy(5, 1), NewRetryPolicy(5, 1), s.Logger)
 	s.Equal(url1, n.GetRemoveAddr())
 	err := n.Remove(context.Background(), s.Params)
 	s.Require().NoError(err)
@@ -245,20 +659,46 @@ func (s *NotifierTestSuite) Test_Remove_SendsRequests() {
 	urlObj1, err := url.Parse(url1)
 	s.Require().NoError(err)
 
-	urlObj1.RawQuery = s.Params
+	urlObj1.RawQuery = ""
 
 	logMsgs := s.LogBytes.String()
-	s.Contains(logMsgs, fmt.Sprintf("Sending node removed notification to %s", urlObj1.String()))
+	s.Contains(logMsgs, fmt.Sprintf(`msg="Sending node removed notification" endpoint=%s url=%s`,
+		urlObj1.Host, urlObj1))
+	s.Contains(logMsgs, fmt.Sprintf("params=%q", s.Params))
+}
+
+func (s *NotifierTestSuite) Test_Remove_SendsFormPayload() {
+	var query1, contentType1, body1 string
+	httpSrv := httptest.NewServer(http.HandlerFunc(func(
+		w http.ResponseWriter, r *http.Request) {
+		query1 = r.URL.Query().Encode()
+		contentType1 = r.Header.Get("Content-Type")
+		body, _ := ioutil.ReadAll(r.Body)
+		body1 = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer httpSrv.Close()
+
+	n := NewNotifier("", httpSrv.URL, http.MethodGet,
+		http.MethodPost, PayloadTypeQuery, PayloadTypeForm,
+		"service", NewRetryPolicy(5, 1), NewRetryPolicy(5, 1), s.Logger)
+	err := n.Remove(context.Background(), s.Params)
+	s.Require().NoError(err)
+
+	s.Empty(query1)
+	s.Equal("application/x-www-form-urlencoded", contentType1)
+	s.Equal(s.Params, body1)
 }
 
 func (s *NotifierTestSuite) Test_Remove_ReturnsAndLogsError_WhenUrlCannotBeParsed() {
 	n := NewNotifier("", "%%%", http.MethodGet,
-		http.MethodGet, "node", 5, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"node", NewRetryPolicy(5, 1), NewRetryPolicy(5, 1), s.Logger)
 	err := n.Remove(context.Background(), s.Params)
 	s.Error(err)
 
 	logMsgs := s.LogBytes.String()
-	s.Contains(logMsgs, "ERROR: ")
+	s.Contains(logMsgs, "level=error")
 }
 
 func (s *NotifierTestSuite) Test_Remove_ReturnsAndLogsError_WhenHttpStatusIsNot200() {
@@ -269,23 +709,25 @@ func (s *NotifierTestSuite) Test_Remove_ReturnsAndLogsError_WhenHttpStatusIsNot2
 
 	n := NewNotifier(
 		"", httpSrv.URL, http.MethodGet,
-		http.MethodGet, "service", 1, 0, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(1, 0), NewRetryPolicy(1, 0), s.Logger)
 	err := n.Remove(context.Background(), s.Params)
 	s.Error(err)
 
 	logMsgs := s.LogBytes.String()
-	s.Contains(logMsgs, "ERROR: ")
+	s.Contains(logMsgs, "level=error")
 }
 
 func (s *NotifierTestSuite) Test_Remove_ReturnsAndLogsError_WhenHttpRequestReturnsError() {
 	n := NewNotifier(
 		"", "this-does-not-exist", http.MethodGet,
-		http.MethodGet, "service", 2, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
 	err := n.Remove(context.Background(), s.Params)
 	s.Error(err)
 
 	logMsgs := s.LogBytes.String()
-	s.Contains(logMsgs, "ERROR: ")
+	s.Contains(logMsgs, "level=error")
 }
 
 func (s *NotifierTestSuite) Test_Remove_RetriesRequests() {
@@ -302,14 +744,16 @@ func (s *NotifierTestSuite) Test_Remove_RetriesRequests() {
 
 	n := NewNotifier(
 		"", httpSrv.URL, http.MethodGet,
-		http.MethodGet, "node", 2, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"node", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
 	err := n.Remove(context.Background(), s.Params)
 	s.Require().NoError(err)
 
 	s.Equal(2, attempt)
 
 	logMsgs := s.LogBytes.String()
-	expMsg := fmt.Sprintf("Retrying node removed notification to %s", httpSrv.URL)
+	expMsg := fmt.Sprintf(`level=warn msg="Retrying node removed notification" endpoint=%s url=%s attempt=1`,
+		strings.TrimPrefix(httpSrv.URL, "http://"), httpSrv.URL)
 	s.Contains(logMsgs, expMsg)
 }
 
@@ -320,14 +764,16 @@ func (s *NotifierTestSuite) Test_Remove_Cancels() {
 	}))
 	n := NewNotifier(
 		"", httpSrv.URL, http.MethodGet,
-		http.MethodGet, "service", 2, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(2, 1), NewRetryPolicy(2, 1), s.Logger)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 	n.Remove(ctx, s.Params)
 
 	logMsgs := s.LogBytes.String()
-	expMsg := fmt.Sprintf("Canceling service remove notification to %s", httpSrv.URL)
+	expMsg := fmt.Sprintf(`level=debug msg="Canceling service remove notification" endpoint=%s url=%s attempt=1`,
+		strings.TrimPrefix(httpSrv.URL, "http://"), httpSrv.URL)
 	s.Contains(logMsgs, expMsg)
 }
 func (s *NotifierTestSuite) Test_Remove_SendsRequestsWithParams() {
@@ -351,7 +797,8 @@ func (s *NotifierTestSuite) Test_Remove_SendsRequestsWithParams() {
 	url1 := fmt.Sprintf("%s/v1/docker-flow-proxy/remove?hello=world", httpSrv.URL)
 
 	n := NewNotifier("", url1, http.MethodGet,
-		http.MethodGet, "service", 5, 1, s.Logger)
+		http.MethodGet, PayloadTypeQuery, PayloadTypeQuery,
+		"service", NewRetryPolicy(5, 1), NewRetryPolicy(5, 1), s.Logger)
 	s.Equal(url1, n.GetRemoveAddr())
 	err := n.Remove(context.Background(), s.Params)
 	s.Require().NoError(err)
@@ -363,10 +810,12 @@ func (s *NotifierTestSuite) Test_Remove_SendsRequestsWithParams() {
 	urlObj1, err := url.Parse(url1)
 	s.Require().NoError(err)
 
-	urlObj1.RawQuery = newParams
+	urlObj1.RawQuery = ""
 
 	logMsgs := s.LogBytes.String()
-	s.Contains(logMsgs, fmt.Sprintf("Sending service removed notification to %s", urlObj1.String()))
+	s.Contains(logMsgs, fmt.Sprintf(`msg="Sending service removed notification" endpoint=%s url=%s`,
+		urlObj1.Host, urlObj1))
+	s.Contains(logMsgs, fmt.Sprintf("params=%q", s.Params))
 }
 
 func (s *NotifierTestSuite) EqualURLValues(expected, actual url.Values) {
@@ -377,3 +826,27 @@ func (s *NotifierTestSuite) EqualURLValues(expected, actual url.Values) {
 		s.Equal(expected.Get(k), actual.Get(k))
 	}
 }
+
+// spanRecorder keeps exported spans
+type spanRecorder struct {
+	spans []tracing.SpanData
+	mux   sync.Mutex
+}
+
+func (r *spanRecorder) Export(ctx context.Context, spans []tracing.SpanData) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+// Spans returns the exported spans by name
+func (r *spanRecorder) Spans() map[string]tracing.SpanData {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	spans := map[string]tracing.SpanData{}
+	for _, span := range r.spans {
+		spans[span.Name] = span
+	}
+	return spans
+}
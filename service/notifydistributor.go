@@ -3,12 +3,18 @@ package service
 import (
 	"context"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/docker-flow/docker-flow-swarm-listener/tracing"
 )
 
 // Notification is a node notification
@@ -19,6 +25,14 @@ type Notification struct {
 	TimeNano   int64
 	Context    context.Context
 	ErrorChan  chan error
+	// Hosts limits the endpoints the notification is sent to
+	// When empty, the notification is sent to all endpoints
+	Hosts []string
+	// Report collects the result of the delivery to each endpoint
+	// It is complete when the notification is signaled on `ErrorChan`.
+	Report *DeliveryReport
+	// SpanContext is the span of the event that caused the notification
+	SpanContext tracing.SpanContext
 }
 
 type internalNotification struct {
@@ -30,6 +44,8 @@ type internalNotification struct {
 type NotifyEndpoint struct {
 	ServiceNotifier NotificationSender
 	NodeNotifier    NotificationSender
+	// CircuitBreaker is shared by the notifiers and is nil when disabled
+	CircuitBreaker *CircuitBreaker
 }
 
 // NotifyDistributing takes a stream of `Notification` and
@@ -38,6 +54,12 @@ type NotifyDistributing interface {
 	Run(serviceChan <-chan Notification, nodeChan <-chan Notification)
 	HasServiceListeners() bool
 	HasNodeListeners() bool
+	UpdateEndpoints(notifyEndpoints map[string]NotifyEndpoint) []string
+	QueuedNotifications() map[string][]QueueEntry
+	CircuitBreakers() map[string]CircuitBreakerStatus
+	EndpointHealth() map[string]EndpointHealth
+	Hosts() []string
+	NotificationHistory(filter AuditFilter) ([]AuditRecord, error)
 }
 
 // NotifyDistributor distributes service and node notifications to `NotifyEndpoints`
@@ -46,165 +68,365 @@ type NotifyDistributor struct {
 	NotifyEndpoints      map[string]NotifyEndpoint
 	ServiceCancelManager CancelManaging
 	NodeCancelManager    CancelManaging
-	log                  *log.Logger
+	log                  *logging.Logger
 	interval             int
+	inFlight             map[string]*sync.WaitGroup
+	queueDir             string
+	queueMaxAttempts     int
+	queueMaxAge          time.Duration
+	queues               map[string]*NotificationQueue
+	mux                  sync.RWMutex
+	health               map[string]*EndpointHealth
+	healthMux            sync.Mutex
+	audit                *AuditLog
+}
+
+// EndpointHealth describes the delivery of notifications to an endpoint
+type EndpointHealth struct {
+	LastSuccessAt       *time.Time   `json:"lastSuccessAt,omitempty"`
+	LastFailureAt       *time.Time   `json:"lastFailureAt,omitempty"`
+	LastError           string       `json:"lastError,omitempty"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	Queued              int          `json:"queued"`
+	CircuitBreaker      CircuitState `json:"circuitBreaker,omitempty"`
 }
 
 func newNotifyDistributor(notifyEndpoints map[string]NotifyEndpoint,
 	serviceCancelManager CancelManaging, nodeCancelManager CancelManaging,
-	interval int, logger *log.Logger) *NotifyDistributor {
+	interval int, logger *logging.Logger) *NotifyDistributor {
 	return &NotifyDistributor{
 		NotifyEndpoints:      notifyEndpoints,
 		ServiceCancelManager: serviceCancelManager,
 		NodeCancelManager:    nodeCancelManager,
 		interval:             interval,
 		log:                  logger,
+		inFlight:             map[string]*sync.WaitGroup{},
+		queues:               map[string]*NotificationQueue{},
+		health:               map[string]*EndpointHealth{},
 	}
 }
 
 func newNotifyDistributorfromStrings(
 	serviceCreateAddrs, serviceRemoveAddrs, nodeCreateAddrs, nodeRemoveAddrs,
-	serviceCreateMethods, serviceRemoveMethods string,
-	retries, interval int, logger *log.Logger) *NotifyDistributor {
-	tempNotifyEP := map[string]map[string]string{}
-
-	insertAddrStringIntoMap(
-		tempNotifyEP, "createService", serviceCreateAddrs,
-		"createServiceMethod", serviceCreateMethods)
-	insertAddrStringIntoMap(
-		tempNotifyEP, "removeService", serviceRemoveAddrs,
-		"removeServiceMethod", serviceRemoveMethods)
-	insertAddrStringIntoMap(
-		tempNotifyEP, "createNode", nodeCreateAddrs,
-		"createNodeMethod", http.MethodGet)
-	insertAddrStringIntoMap(
-		tempNotifyEP, "removeNode", nodeRemoveAddrs,
-		"removeNodeMethod", http.MethodGet)
+	serviceCreateMethods, serviceRemoveMethods,
+	serviceCreatePayloads, serviceRemovePayloads string,
+	retries, interval int, logger *logging.Logger) (*NotifyDistributor, error) {
+
+	endpoints := config.EndpointsFromStrings(
+		serviceCreateAddrs, serviceRemoveAddrs, nodeCreateAddrs, nodeRemoveAddrs,
+		serviceCreateMethods, serviceRemoveMethods,
+		serviceCreatePayloads, serviceRemovePayloads)
+
+	return newNotifyDistributorFromEndpoints(endpoints, retries, interval, logger)
+}
+
+func newNotifyDistributorFromEndpoints(
+	endpoints []config.Endpoint, retries, interval int,
+	logger *logging.Logger) (*NotifyDistributor, error) {
 
+	c := config.Default()
+	c.Endpoints = endpoints
+	c.Retry = retries
+	c.RetryInterval = interval
+	return NewNotifyDistributorFromConfig(c, logger)
+}
+
+// newNotifyEndpoints creates `NotifyEndpoint`s keyed by host from the
+// endpoints of `c`
+// An error is returned when the client of an endpoint cannot be created
+func newNotifyEndpoints(c *config.Config, logger *logging.Logger) (map[string]NotifyEndpoint, error) {
 	notifyEndpoints := map[string]NotifyEndpoint{}
 
-	for hostname, addrMap := range tempNotifyEP {
-		ep := NotifyEndpoint{}
-		if len(addrMap["createService"]) > 0 || len(addrMap["removeService"]) > 0 {
-			ep.ServiceNotifier = NewNotifier(
-				addrMap["createService"],
-				addrMap["removeService"],
-				addrMap["createServiceMethod"],
-				addrMap["removeServiceMethod"],
-				"service",
-				retries,
-				interval,
-				logger,
-			)
-		}
-		if len(addrMap["createNode"]) > 0 || len(addrMap["removeNode"]) > 0 {
-			ep.NodeNotifier = NewNotifier(
-				addrMap["createNode"],
-				addrMap["removeNode"],
-				addrMap["createNodeMethod"],
-				addrMap["removeNodeMethod"],
-				"node",
-				retries,
-				interval,
-				logger,
-			)
+	for _, epConfig := range c.Endpoints {
+		ep, err := newNotifyEndpoint(epConfig, c, logger)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", epConfig.Host(), err)
 		}
 		if ep.ServiceNotifier != nil || ep.NodeNotifier != nil {
-			notifyEndpoints[hostname] = ep
+			notifyEndpoints[epConfig.Host()] = ep
 		}
 	}
-
-	return newNotifyDistributor(
-		notifyEndpoints,
-		NewCancelManager(),
-		NewCancelManager(),
-		interval,
-		logger)
+	return notifyEndpoints, nil
 }
 
-func insertAddrStringIntoMap(
-	tempEP map[string]map[string]string, key, addrs, methodsKey, methods string) {
+// newNotifyEndpoint creates the notifiers of `epConfig`
+// The retry, circuit breaker, request, and connection settings of `c` are
+// used when they are not set in `epConfig`
+func newNotifyEndpoint(
+	epConfig config.Endpoint, c *config.Config, logger *logging.Logger) (NotifyEndpoint, error) {
 
-	addrsList := strings.Split(addrs, ",")
-	methodsList := strings.Split(methods, ",")
-	maxMethodsIdx := len(methodsList) - 1
+	tlsSettings := c.TLS
+	if epConfig.TLS != nil {
+		tlsSettings = *epConfig.TLS
+	}
+	connectTimeout, responseTimeout := c.ConnectTimeout, c.ResponseTimeout
+	if epConfig.ConnectTimeout != nil {
+		connectTimeout = *epConfig.ConnectTimeout
+	}
+	if epConfig.ResponseTimeout != nil {
+		responseTimeout = *epConfig.ResponseTimeout
+	}
+	client, err := newHTTPClient(tlsSettings, connectTimeout.Duration, responseTimeout.Duration)
+	if err != nil {
+		return NotifyEndpoint{}, err
+	}
 
-	for addrsIdx, v := range addrsList {
-		urlObj, err := url.Parse(v)
-		if err != nil {
-			continue
-		}
-		host := urlObj.Host
-		if len(host) == 0 {
-			continue
-		}
-		if tempEP[host] == nil {
-			tempEP[host] = map[string]string{}
+	retries, interval := c.Retry, c.RetryInterval
+	if epConfig.Retry != nil {
+		retries = *epConfig.Retry
+	}
+	if epConfig.RetryInterval != nil {
+		interval = *epConfig.RetryInterval
+	}
+	policy := NewRetryPolicy(retries, interval).
+		withConfig(&c.RetryPolicy).
+		withConfig(epConfig.RetryPolicy)
+	eventPolicy := func(key string) RetryPolicy {
+		if p, ok := epConfig.RetryPolicies[key]; ok {
+			return policy.withEventConfig(p)
 		}
-		tempEP[host][key] = v
+		return policy
+	}
 
-		if addrsIdx <= maxMethodsIdx {
-			tempEP[host][methodsKey] = methodsList[addrsIdx]
-		} else {
-			tempEP[host][methodsKey] = methodsList[maxMethodsIdx]
+	breakerConfig := config.MergeCircuitBreakers(c.CircuitBreaker, epConfig.CircuitBreaker)
+	breaker := newCircuitBreakerFromConfig(epConfig.Host(), breakerConfig, logger)
+
+	options := config.MergeRequestOptions(c.RequestOptions, epConfig.RequestOptions)
+	headers := newRequestHeaders(options)
+	configure := func(n *Notifier) {
+		n.client = client
+		n.circuitBreaker = breaker
+		n.headers = headers
+		if len(options.SigningSecret) > 0 {
+			n.signingSecret = []byte(options.SigningSecret)
 		}
 	}
+
+	ep := NotifyEndpoint{CircuitBreaker: breaker}
+	if len(epConfig.CreateServiceURL) > 0 || len(epConfig.RemoveServiceURL) > 0 {
+		notifier := NewNotifier(
+			epConfig.CreateServiceURL,
+			epConfig.RemoveServiceURL,
+			httpMethodOrDefault(epConfig.CreateServiceMethod),
+			httpMethodOrDefault(epConfig.RemoveServiceMethod),
+			payloadTypeOrDefault(epConfig.CreateServicePayload),
+			payloadTypeOrDefault(epConfig.RemoveServicePayload),
+			"service",
+			eventPolicy("createService"),
+			eventPolicy("removeService"),
+			logger,
+		)
+		configure(notifier)
+		ep.ServiceNotifier = notifier
+	}
+	if len(epConfig.CreateNodeURL) > 0 || len(epConfig.RemoveNodeURL) > 0 {
+		notifier := NewNotifier(
+			epConfig.CreateNodeURL,
+			epConfig.RemoveNodeURL,
+			httpMethodOrDefault(epConfig.CreateNodeMethod),
+			httpMethodOrDefault(epConfig.RemoveNodeMethod),
+			payloadTypeOrDefault(epConfig.CreateNodePayload),
+			payloadTypeOrDefault(epConfig.RemoveNodePayload),
+			"node",
+			eventPolicy("createNode"),
+			eventPolicy("removeNode"),
+			logger,
+		)
+		configure(notifier)
+		ep.NodeNotifier = notifier
+	}
+	return ep, nil
+}
+
+// newRequestHeaders returns the static headers of notification requests
+func newRequestHeaders(options config.RequestOptions) http.Header {
+	headers := http.Header{}
+	for name, value := range options.Headers {
+		headers.Set(name, value)
+	}
+	if len(options.BearerToken) > 0 {
+		headers.Set("Authorization", "Bearer "+options.BearerToken)
+	}
+	return headers
+}
+
+func httpMethodOrDefault(method string) string {
+	if len(method) == 0 {
+		return http.MethodGet
+	}
+	return strings.ToUpper(method)
+}
+
+func payloadTypeOrDefault(payload string) PayloadType {
+	if len(payload) == 0 {
+		return PayloadTypeQuery
+	}
+	return PayloadType(strings.ToLower(payload))
 }
 
 // NewNotifyDistributorFromEnv creates `NotifyDistributor` from environment variables
 func NewNotifyDistributorFromEnv(retries, interval int,
 	extraCreateServiceAddr, extraRemoveServiceAddr,
 	extraCreateNodeAddr, extraRemoveNodeAddr string,
-	logger *log.Logger) *NotifyDistributor {
-	var createServiceAddr, removeServiceAddr string
-	if len(os.Getenv("DF_NOTIF_CREATE_SERVICE_URL")) > 0 {
-		createServiceAddr = os.Getenv("DF_NOTIF_CREATE_SERVICE_URL")
-	} else if len(os.Getenv("DF_NOTIFY_CREATE_SERVICE_URL")) > 0 {
-		createServiceAddr = os.Getenv("DF_NOTIFY_CREATE_SERVICE_URL")
-	} else {
-		createServiceAddr = os.Getenv("DF_NOTIFICATION_URL")
+	logger *logging.Logger) (*NotifyDistributor, error) {
+
+	endpoints := config.EndpointsFromEnv(
+		extraCreateServiceAddr, extraRemoveServiceAddr,
+		extraCreateNodeAddr, extraRemoveNodeAddr)
+
+	return newNotifyDistributorFromEndpoints(endpoints, retries, interval, logger)
+}
+
+// NewNotifyDistributorFromConfig creates `NotifyDistributor` from `Config`
+func NewNotifyDistributorFromConfig(c *config.Config, logger *logging.Logger) (*NotifyDistributor, error) {
+	notifyEndpoints, err := newNotifyEndpoints(c, logger)
+	if err != nil {
+		return nil, err
 	}
-	if len(os.Getenv("DF_NOTIF_REMOVE_SERVICE_URL")) > 0 {
-		removeServiceAddr = os.Getenv("DF_NOTIF_REMOVE_SERVICE_URL")
-	} else if len(os.Getenv("DF_NOTIFY_REMOVE_SERVICE_URL")) > 0 {
-		removeServiceAddr = os.Getenv("DF_NOTIFY_REMOVE_SERVICE_URL")
-	} else {
-		removeServiceAddr = os.Getenv("DF_NOTIFICATION_URL")
+	d := newNotifyDistributor(
+		notifyEndpoints,
+		NewCancelManager(),
+		NewCancelManager(),
+		c.RetryInterval,
+		logger)
+	if len(c.QueueDir) > 0 {
+		if err := d.EnableQueues(c.QueueDir, c.QueueMaxAttempts, c.QueueMaxAge.Duration); err != nil {
+			return nil, err
+		}
+	}
+	if c.Audit.IsEnabled() {
+		if err := d.EnableAudit(c.Audit.File, c.Audit.MaxSize, c.Audit.MaxBackups); err != nil {
+			return nil, err
+		}
 	}
-	createNodeAddr := os.Getenv("DF_NOTIFY_CREATE_NODE_URL")
-	removeNodeAddr := os.Getenv("DF_NOTIFY_REMOVE_NODE_URL")
+	return d, nil
+}
 
-	createServiceMethods := strings.ToUpper(os.Getenv("DF_NOTIFY_CREATE_SERVICE_METHOD"))
-	removeServiceMethods := strings.ToUpper(os.Getenv("DF_NOTIFY_REMOVE_SERVICE_METHOD"))
+// EnableAudit appends the result of every delivery to the audit log
+// stored in `filename`
+func (d *NotifyDistributor) EnableAudit(filename string, maxSize, maxBackups int) error {
+	audit, err := OpenAuditLog(filename, maxSize, maxBackups)
+	if err != nil {
+		return err
+	}
+	d.audit = audit
+	return nil
+}
 
-	if len(extraCreateServiceAddr) > 0 {
-		createServiceAddr = fmt.Sprintf("%s,%s", createServiceAddr, extraCreateServiceAddr)
+// NotificationHistory returns the audited deliveries selected by `filter`,
+// newest first
+func (d *NotifyDistributor) NotificationHistory(filter AuditFilter) ([]AuditRecord, error) {
+	if d.audit == nil {
+		return nil, ErrAuditDisabled
 	}
-	if len(extraRemoveServiceAddr) > 0 {
-		removeServiceAddr = fmt.Sprintf("%s,%s", removeServiceAddr, extraRemoveServiceAddr)
+	return d.audit.Query(filter)
+}
+
+// EnableQueues stores notifications in durable queues in `dir` before
+// they are delivered. Each endpoint has its own queue.
+// Queued notifications are dropped after `maxAttempts` deliveries or once
+// they are older than `maxAge`, unless these are zero.
+func (d *NotifyDistributor) EnableQueues(dir string, maxAttempts int, maxAge time.Duration) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Unable to create queue directory: %v", err)
 	}
-	if len(extraCreateNodeAddr) > 0 {
-		createNodeAddr = fmt.Sprintf("%s,%s", createNodeAddr, extraCreateNodeAddr)
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.queueDir = dir
+	d.queueMaxAttempts = maxAttempts
+	d.queueMaxAge = maxAge
+	for host := range d.NotifyEndpoints {
+		queue, err := d.openQueue(host)
+		if err != nil {
+			return err
+		}
+		d.queues[host] = queue
 	}
-	if len(extraRemoveNodeAddr) > 0 {
-		removeNodeAddr = fmt.Sprintf("%s,%s", removeNodeAddr, extraRemoveNodeAddr)
+	return nil
+}
+
+func (d *NotifyDistributor) openQueue(host string) (*NotificationQueue, error) {
+	redeliveryInterval := time.Second
+	if d.interval > 1 {
+		redeliveryInterval = time.Second * time.Duration(d.interval)
 	}
+	return OpenNotificationQueue(
+		queueFilename(d.queueDir, host), d.deliverQueued(host), redeliveryInterval,
+		d.queueMaxAttempts, d.queueMaxAge, d.log.With("endpoint", host))
+}
 
-	if len(createServiceMethods) == 0 {
-		createServiceMethods = http.MethodGet
+// deliverQueued returns the function used by the queue of `host` to
+// deliver notifications to the current endpoint of `host`
+func (d *NotifyDistributor) deliverQueued(host string) QueueDeliverFunc {
+	return func(ctx context.Context, entry QueueEntry) error {
+		d.mux.RLock()
+		endpoint, ok := d.NotifyEndpoints[host]
+		d.mux.RUnlock()
+		if !ok {
+			return fmt.Errorf("%s is not a notification endpoint", host)
+		}
+
+		n := Notification{
+			EventType:  entry.EventType,
+			ID:         entry.ID,
+			Parameters: entry.Parameters,
+			TimeNano:   entry.TimeNano,
+		}
+		if sc, err := tracing.ParseTraceparent(entry.Traceparent); err == nil {
+			ctx = tracing.ContextWithSpanContext(ctx, sc)
+		}
+		ctx = logging.ContextWithFields(ctx, notificationLogFields(entry.Kind, n)...)
+		if entry.Kind == QueueKindNode {
+			err := d.auditDelivery(ctx, QueueKindNode, n, host, endpoint.NodeNotifier,
+				func(ctx context.Context) error {
+					return d.processNodeNotification(ctx, n, endpoint)
+				})
+			if endpoint.NodeNotifier != nil {
+				d.recordDelivery(ctx, host, err)
+			}
+			return err
+		}
+		err := d.auditDelivery(ctx, QueueKindService, n, host, endpoint.ServiceNotifier,
+			func(ctx context.Context) error {
+				return d.processServiceNotification(ctx, n, endpoint)
+			})
+		if endpoint.ServiceNotifier != nil {
+			d.recordDelivery(ctx, host, err)
+		}
+		return err
 	}
-	if len(removeServiceMethods) == 0 {
-		removeServiceMethods = http.MethodGet
+}
+
+// CircuitBreakers returns the status of the circuit breakers keyed by host
+func (d *NotifyDistributor) CircuitBreakers() map[string]CircuitBreakerStatus {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
+	statuses := map[string]CircuitBreakerStatus{}
+	for host, ep := range d.NotifyEndpoints {
+		if ep.CircuitBreaker != nil {
+			statuses[host] = ep.CircuitBreaker.Status()
+		}
 	}
+	return statuses
+}
 
-	return newNotifyDistributorfromStrings(
-		createServiceAddr, removeServiceAddr, createNodeAddr, removeNodeAddr,
-		createServiceMethods, removeServiceMethods, retries, interval, logger)
+// QueuedNotifications returns the pending notifications keyed by host
+func (d *NotifyDistributor) QueuedNotifications() map[string][]QueueEntry {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
 
+	queued := map[string][]QueueEntry{}
+	for host, queue := range d.queues {
+		queued[host] = queue.Entries()
+	}
+	return queued
 }
 
 // Run starts the distributor
-func (d NotifyDistributor) Run(serviceChan <-chan Notification, nodeChan <-chan Notification) {
+func (d *NotifyDistributor) Run(serviceChan <-chan Notification, nodeChan <-chan Notification) {
 
 	if serviceChan != nil {
 		go func() {
@@ -222,89 +444,496 @@ func (d NotifyDistributor) Run(serviceChan <-chan Notification, nodeChan <-chan
 	}
 }
 
-func (d NotifyDistributor) distributeServiceNotification(n Notification) {
-	// Use time as request id
-	ctx := d.ServiceCancelManager.Add(context.Background(), n.ID, n.TimeNano)
-	defer d.ServiceCancelManager.Delete(n.ID, n.TimeNano)
+// UpdateEndpoints replaces the endpoints notifications are distributed to
+// and returns the hosts that were added. Notifications in flight to removed
+// endpoints are allowed to finish.
+func (d *NotifyDistributor) UpdateEndpoints(notifyEndpoints map[string]NotifyEndpoint) []string {
+	added, removedQueues := d.updateEndpoints(notifyEndpoints)
+
+	// Pending notifications stay on disk until the endpoint is added again
+	for _, queue := range removedQueues {
+		queue.Close()
+	}
+	return added
+}
+
+func (d *NotifyDistributor) updateEndpoints(
+	notifyEndpoints map[string]NotifyEndpoint) ([]string, []*NotificationQueue) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	added := []string{}
+	removedQueues := []*NotificationQueue{}
+	for host := range notifyEndpoints {
+		if _, ok := d.NotifyEndpoints[host]; !ok {
+			added = append(added, host)
+		}
+	}
+	sort.Strings(added)
+
+	for host := range d.NotifyEndpoints {
+		if _, ok := notifyEndpoints[host]; ok {
+			continue
+		}
+		d.log.Info("Removing notification endpoint", "endpoint", host)
+		if queue, ok := d.queues[host]; ok {
+			delete(d.queues, host)
+			removedQueues = append(removedQueues, queue)
+		}
+		if wg, ok := d.inFlight[host]; ok {
+			delete(d.inFlight, host)
+			go func(host string, wg *sync.WaitGroup) {
+				wg.Wait()
+				d.log.Info("Finished draining notifications", "endpoint", host)
+			}(host, wg)
+		}
+	}
+	for _, host := range added {
+		d.log.Info("Adding notification endpoint", "endpoint", host)
+		if len(d.queueDir) == 0 {
+			continue
+		}
+		queue, err := d.openQueue(host)
+		if err != nil {
+			d.log.Error("Unable to open notification queue", "endpoint", host, "error", err)
+			continue
+		}
+		d.queues[host] = queue
+	}
+
+	d.NotifyEndpoints = notifyEndpoints
+	return added, removedQueues
+}
+
+// acquireEndpoints returns the endpoints notification `n` is sent to and
+// marks a notification in flight for each of them. The returned function
+// must be called with the host when the notification to it is finished.
+func (d *NotifyDistributor) acquireEndpoints(
+	n Notification, service bool) (map[string]NotifyEndpoint, func(host string)) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	endpoints := d.NotifyEndpoints
+	if len(n.Hosts) > 0 {
+		endpoints = map[string]NotifyEndpoint{}
+		for _, host := range n.Hosts {
+			if endpoint, ok := d.NotifyEndpoints[host]; ok {
+				endpoints[host] = endpoint
+			}
+		}
+	}
+	if service {
+		endpoints = filterServiceEndpoints(endpoints, n)
+	}
+
+	inFlight := map[string]*sync.WaitGroup{}
+	for host := range endpoints {
+		wg, ok := d.inFlight[host]
+		if !ok {
+			wg = &sync.WaitGroup{}
+			d.inFlight[host] = wg
+		}
+		wg.Add(1)
+		inFlight[host] = wg
+	}
+
+	return endpoints, func(host string) {
+		inFlight[host].Done()
+	}
+}
+
+func (d *NotifyDistributor) distributeServiceNotification(n Notification) {
+	distributeCtx, span := startDistributionSpan(n, QueueKindService)
+	distributeCtx = logging.ContextWithFields(distributeCtx, notificationLogFields(QueueKindService, n)...)
+	endpoints, done := d.acquireEndpoints(n, true)
+	span.SetAttribute("notification.endpoints", len(endpoints))
 
 	var wg sync.WaitGroup
-	for _, endpoint := range d.NotifyEndpoints {
+	for host, endpoint := range endpoints {
 		wg.Add(1)
-		go func(endpoint NotifyEndpoint) {
+		go func(host string, endpoint NotifyEndpoint) {
 			defer wg.Done()
-			d.processServiceNotification(ctx, n, endpoint)
-		}(endpoint)
+			defer done(host)
+
+			// Use time as request id
+			cancelID := endpointCancelID(host, n.ID)
+			ctx := d.ServiceCancelManager.Add(distributeCtx, cancelID, n.TimeNano)
+			defer d.ServiceCancelManager.Delete(cancelID, n.TimeNano)
+
+			ctx, deliverySpan := tracing.Start(ctx, "notification.deliver",
+				tracing.WithAttributes(tracing.Attribute{Key: "endpoint.host", Value: host}))
+			ctx, report := d.reportDelivery(ctx, n, host, endpoint.ServiceNotifier)
+			deliver := func(ctx context.Context) error {
+				return d.processServiceNotification(ctx, n, endpoint)
+			}
+			if queue := d.queue(host); queue != nil {
+				err := d.queueNotification(ctx, queue, QueueKindService, n, func() error {
+					return d.auditDelivery(ctx, QueueKindService, n, host, endpoint.ServiceNotifier, deliver)
+				})
+				report(err, true)
+				deliverySpan.SetAttribute("notification.queued", true)
+				endSpan(deliverySpan, err, ctx.Err() != nil)
+				return
+			}
+			err := d.auditDelivery(ctx, QueueKindService, n, host, endpoint.ServiceNotifier, deliver)
+			report(err, false)
+			endSpan(deliverySpan, err, ctx.Err() != nil)
+		}(host, endpoint)
 	}
 	wg.Wait()
+	span.End()
 
 	if n.ErrorChan != nil {
 		n.ErrorChan <- nil
 	}
 }
 
-func (d NotifyDistributor) distributeNodeNotification(n Notification) {
-	// Use time as request id
-	ctx := d.NodeCancelManager.Add(context.Background(), n.ID, n.TimeNano)
-	defer d.NodeCancelManager.Delete(n.ID, n.TimeNano)
+func (d *NotifyDistributor) queue(host string) *NotificationQueue {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+	return d.queues[host]
+}
+
+// queueNotification stores `n` in `queue` and waits for the first delivery
+// attempt, whose error is returned and which is recorded in the delivery
+// of `ctx`. `n` is delivered with `deliver` when it cannot be stored.
+func (d *NotifyDistributor) queueNotification(
+	ctx context.Context, queue *NotificationQueue, kind string,
+	n Notification, deliver func() error) error {
+
+	n.SpanContext = tracing.SpanContextFromContext(ctx)
+	result, err := queue.Push(kind, n)
+	if err == errQueueClosed {
+		return err
+	} else if err != nil {
+		d.log.WithContext(ctx).Error("Unable to queue notification", "error", err)
+		return deliver()
+	}
+	select {
+	case r := <-result:
+		if delivery := endpointDeliveryFromContext(ctx); delivery != nil {
+			delivery.StatusCode = r.Delivery.StatusCode
+			delivery.Attempts = r.Delivery.Attempts
+			delivery.ResponseBody = r.Delivery.ResponseBody
+		}
+		return r.Err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// reportDelivery returns the context notification `n` is sent to `host`
+// with and a function recording the result of the delivery
+// Nothing is recorded when the endpoint has no `notifier` for `n`.
+// Otherwise, the result is recorded in the health of the endpoint unless
+// `n` was queued, and in `n.Report` unless `n` has no report or was not
+// sent.
+func (d *NotifyDistributor) reportDelivery(
+	ctx context.Context, n Notification, host string,
+	notifier NotificationSender) (context.Context, func(err error, queued bool)) {
+	if notifier == nil {
+		return ctx, func(error, bool) {}
+	}
+	if n.Report == nil {
+		return ctx, func(err error, queued bool) {
+			if !queued {
+				d.recordDelivery(ctx, host, err)
+			}
+		}
+	}
+	delivery := &EndpointDelivery{Host: host}
+	return withEndpointDelivery(ctx, delivery), func(err error, queued bool) {
+		if !queued {
+			d.recordDelivery(ctx, host, err)
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if ctx.Err() != nil {
+			delivery.Canceled = true
+		}
+		delivery.Queued = queued
+		if delivery.Attempts == 0 && err == nil && !delivery.Queued && !delivery.Canceled {
+			return
+		}
+		n.Report.add(*delivery)
+	}
+}
+
+// auditDelivery sends `n` to `host` with `deliver` and appends the result
+// to the audit log. Nothing is audited when the audit log is disabled, the
+// endpoint has no `notifier` for `n`, or nothing was sent.
+func (d *NotifyDistributor) auditDelivery(
+	ctx context.Context, kind string, n Notification, host string,
+	notifier NotificationSender, deliver func(ctx context.Context) error) error {
+	if d.audit == nil || notifier == nil {
+		return deliver(ctx)
+	}
+	delivery := endpointDeliveryFromContext(ctx)
+	if delivery == nil {
+		delivery = &EndpointDelivery{Host: host}
+		ctx = withEndpointDelivery(ctx, delivery)
+	}
+	attempts := delivery.Attempts
+	err := deliver(ctx)
+	if delivery.Attempts == attempts && err == nil {
+		return err
+	}
+
+	record := AuditRecord{
+		Time:       time.Now().UTC(),
+		RequestID:  n.TimeNano,
+		Kind:       kind,
+		EventType:  n.EventType,
+		ID:         n.ID,
+		Endpoint:   host,
+		Attempts:   delivery.Attempts - attempts,
+		StatusCode: delivery.StatusCode,
+		Status:     AuditStatusSuccess,
+	}
+	if params, parseErr := url.ParseQuery(n.Parameters); parseErr == nil {
+		record.Parameters = map[string]string{}
+		for key, values := range d.log.RedactValues(params) {
+			record.Parameters[key] = values[0]
+		}
+		if kind == QueueKindNode {
+			record.Name = params.Get("hostname")
+		} else {
+			record.Name = params.Get("serviceName")
+		}
+	}
+	if len(delivery.ResponseBody) > 0 {
+		record.ResponseBody = d.log.RedactString(delivery.ResponseBody)
+	}
+	if delivery.Canceled || ctx.Err() != nil {
+		record.Status = AuditStatusCanceled
+	} else if err != nil {
+		record.Status = AuditStatusFailure
+		record.Error = d.log.RedactString(err.Error())
+	}
+	if auditErr := d.audit.Record(record); auditErr != nil {
+		d.log.WithContext(ctx).Error("Unable to record notification in the audit log", "error", auditErr)
+	}
+	return err
+}
+
+// recordDelivery records the result of delivering a notification to
+// `host` in the health of the endpoint. Canceled notifications are not
+// recorded.
+func (d *NotifyDistributor) recordDelivery(ctx context.Context, host string, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	d.healthMux.Lock()
+	defer d.healthMux.Unlock()
+
+	health, ok := d.health[host]
+	if !ok {
+		health = &EndpointHealth{}
+		d.health[host] = health
+	}
+	now := time.Now().UTC()
+	if err == nil {
+		health.LastSuccessAt = &now
+		health.ConsecutiveFailures = 0
+		return
+	}
+	health.LastFailureAt = &now
+	health.LastError = err.Error()
+	health.ConsecutiveFailures++
+}
+
+// EndpointHealth returns the delivery health of the endpoints keyed by host
+func (d *NotifyDistributor) EndpointHealth() map[string]EndpointHealth {
+	queued := d.QueuedNotifications()
+	breakers := d.CircuitBreakers()
+
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+	d.healthMux.Lock()
+	defer d.healthMux.Unlock()
+
+	endpoints := map[string]EndpointHealth{}
+	for host := range d.NotifyEndpoints {
+		health := EndpointHealth{}
+		if h, ok := d.health[host]; ok {
+			health = *h
+		}
+		health.Queued = len(queued[host])
+		if breaker, ok := breakers[host]; ok {
+			health.CircuitBreaker = breaker.State
+		}
+		endpoints[host] = health
+	}
+	return endpoints
+}
+
+// filterServiceEndpoints returns the endpoints service notification `n` is sent to
+// When the service has the `com.df.notifyService` label, only endpoints
+// with a matching host are returned
+func filterServiceEndpoints(
+	endpoints map[string]NotifyEndpoint, n Notification) map[string]NotifyEndpoint {
+	params, err := url.ParseQuery(n.Parameters)
+	if err != nil {
+		return endpoints
+	}
+	notifyService := params.Get("notifyService")
+	if len(notifyService) == 0 {
+		return endpoints
+	}
+
+	filtered := map[string]NotifyEndpoint{}
+	for _, target := range strings.Split(notifyService, ",") {
+		target = strings.TrimSpace(target)
+		if len(target) == 0 {
+			continue
+		}
+		for host, endpoint := range endpoints {
+			if matchEndpointHost(host, target) {
+				filtered[host] = endpoint
+			}
+		}
+	}
+	return filtered
+}
+
+// matchEndpointHost returns true when `target` is equal to `host` or
+// the hostname of `host` without the port
+func matchEndpointHost(host, target string) bool {
+	if strings.EqualFold(host, target) {
+		return true
+	}
+	hostname, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(hostname, target)
+}
+
+// notificationLogFields returns the log fields that identify notification
+// `n` of `kind`. The time of the event is the request id.
+func notificationLogFields(kind string, n Notification) []interface{} {
+	fields := []interface{}{
+		kind + "_id", n.ID,
+		"event_type", n.EventType,
+		"request_id", n.TimeNano,
+	}
+	params, err := url.ParseQuery(n.Parameters)
+	if err != nil {
+		return fields
+	}
+	if kind == QueueKindService && len(params.Get("serviceName")) > 0 {
+		fields = append(fields, "service_name", params.Get("serviceName"))
+	} else if kind == QueueKindNode && len(params.Get("hostname")) > 0 {
+		fields = append(fields, "node_hostname", params.Get("hostname"))
+	}
+	return fields
+}
+
+// endpointCancelID is the id used to cancel notifications about `id`
+// sent to `host`
+func endpointCancelID(host, id string) string {
+	return host + "/" + id
+}
+
+func (d *NotifyDistributor) distributeNodeNotification(n Notification) {
+	distributeCtx, span := startDistributionSpan(n, QueueKindNode)
+	distributeCtx = logging.ContextWithFields(distributeCtx, notificationLogFields(QueueKindNode, n)...)
+	endpoints, done := d.acquireEndpoints(n, false)
+	span.SetAttribute("notification.endpoints", len(endpoints))
 
 	var wg sync.WaitGroup
-	for _, endpoint := range d.NotifyEndpoints {
+	for host, endpoint := range endpoints {
 		wg.Add(1)
-		go func(endpoint NotifyEndpoint) {
+		go func(host string, endpoint NotifyEndpoint) {
 			defer wg.Done()
-			d.processNodeNotification(ctx, n, endpoint)
-		}(endpoint)
+			defer done(host)
+
+			// Use time as request id
+			cancelID := endpointCancelID(host, n.ID)
+			ctx := d.NodeCancelManager.Add(distributeCtx, cancelID, n.TimeNano)
+			defer d.NodeCancelManager.Delete(cancelID, n.TimeNano)
+
+			ctx, deliverySpan := tracing.Start(ctx, "notification.deliver",
+				tracing.WithAttributes(tracing.Attribute{Key: "endpoint.host", Value: host}))
+			ctx, report := d.reportDelivery(ctx, n, host, endpoint.NodeNotifier)
+			deliver := func(ctx context.Context) error {
+				return d.processNodeNotification(ctx, n, endpoint)
+			}
+			if queue := d.queue(host); queue != nil {
+				err := d.queueNotification(ctx, queue, QueueKindNode, n, func() error {
+					return d.auditDelivery(ctx, QueueKindNode, n, host, endpoint.NodeNotifier, deliver)
+				})
+				report(err, true)
+				deliverySpan.SetAttribute("notification.queued", true)
+				endSpan(deliverySpan, err, ctx.Err() != nil)
+				return
+			}
+			err := d.auditDelivery(ctx, QueueKindNode, n, host, endpoint.NodeNotifier, deliver)
+			report(err, false)
+			endSpan(deliverySpan, err, ctx.Err() != nil)
+		}(host, endpoint)
 	}
 	wg.Wait()
+	span.End()
 	if n.ErrorChan != nil {
 		n.ErrorChan <- nil
 	}
 }
 
-func (d NotifyDistributor) processServiceNotification(
-	ctx context.Context, n Notification, endpoint NotifyEndpoint) {
+func (d *NotifyDistributor) processServiceNotification(
+	ctx context.Context, n Notification, endpoint NotifyEndpoint) error {
 
 	if endpoint.ServiceNotifier == nil {
-		return
+		return nil
 	}
 
+	var err error
 	if n.EventType == EventTypeCreate {
-		err := endpoint.ServiceNotifier.Create(ctx, n.Parameters)
+		err = endpoint.ServiceNotifier.Create(ctx, n.Parameters)
 		if err != nil && !strings.Contains(err.Error(), "context canceled") {
-			d.log.Printf("ERROR: Unable to send ServiceCreateNotify to %s, params: %s", endpoint.ServiceNotifier.GetCreateAddr(), n.Parameters)
+			d.log.WithContext(ctx).Error("Unable to send service create notification",
+				"url", endpoint.ServiceNotifier.GetCreateAddr(), "error", err)
 		}
 	} else if n.EventType == EventTypeRemove {
-		err := endpoint.ServiceNotifier.Remove(ctx, n.Parameters)
+		err = endpoint.ServiceNotifier.Remove(ctx, n.Parameters)
 		if err != nil && !strings.Contains(err.Error(), "context canceled") {
-			d.log.Printf("ERROR: Unable to send ServiceRemoveNotify to %s, params: %s", endpoint.ServiceNotifier.GetRemoveAddr(), n.Parameters)
+			d.log.WithContext(ctx).Error("Unable to send service remove notification",
+				"url", endpoint.ServiceNotifier.GetRemoveAddr(), "error", err)
 		}
 	}
+	return err
 }
 
-func (d NotifyDistributor) processNodeNotification(
-	ctx context.Context, n Notification, endpoint NotifyEndpoint) {
+func (d *NotifyDistributor) processNodeNotification(
+	ctx context.Context, n Notification, endpoint NotifyEndpoint) error {
 
 	if endpoint.NodeNotifier == nil {
-		return
+		return nil
 	}
 
+	var err error
 	if n.EventType == EventTypeCreate {
-		err := endpoint.NodeNotifier.Create(ctx, n.Parameters)
+		err = endpoint.NodeNotifier.Create(ctx, n.Parameters)
 		if err != nil {
-			d.log.Printf("ERROR: Unable to send NodeCreateNotify to %s, params: %s",
-				endpoint.NodeNotifier.GetCreateAddr(), n.Parameters)
+			d.log.WithContext(ctx).Error("Unable to send node create notification",
+				"url", endpoint.NodeNotifier.GetCreateAddr(), "error", err)
 		}
 	} else if n.EventType == EventTypeRemove {
-		err := endpoint.NodeNotifier.Remove(ctx, n.Parameters)
+		err = endpoint.NodeNotifier.Remove(ctx, n.Parameters)
 		if err != nil {
-			d.log.Printf("ERROR: Unable to send NodeRemoveNotify to %s, params: %s",
-				endpoint.NodeNotifier.GetRemoveAddr(), n.Parameters)
+			d.log.WithContext(ctx).Error("Unable to send node remove notification",
+				"url", endpoint.NodeNotifier.GetRemoveAddr(), "error", err)
 		}
 	}
+	return err
 }
 
 // HasServiceListeners when there exists service listeners
-func (d NotifyDistributor) HasServiceListeners() bool {
+func (d *NotifyDistributor) HasServiceListeners() bool {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
 	for _, endpoint := range d.NotifyEndpoints {
 		if endpoint.ServiceNotifier != nil {
 			return true
@@ -314,7 +943,10 @@ func (d NotifyDistributor) HasServiceListeners() bool {
 }
 
 // HasNodeListeners when there exists node listeners
-func (d NotifyDistributor) HasNodeListeners() bool {
+func (d *NotifyDistributor) HasNodeListeners() bool {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
 	for _, endpoint := range d.NotifyEndpoints {
 		if endpoint.NodeNotifier != nil {
 			return true
@@ -322,3 +954,17 @@ func (d NotifyDistributor) HasNodeListeners() bool {
 	}
 	return false
 }
+
+// Hosts returns the sorted hosts of the endpoints notifications are
+// distributed to
+func (d *NotifyDistributor) Hosts() []string {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+
+	hosts := make([]string, 0, len(d.NotifyEndpoints))
+	for host := range d.NotifyEndpoints {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
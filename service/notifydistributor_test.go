@@ -3,18 +3,24 @@ package service
 import (
 	"bytes"
 	"context"
-	"log"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/docker-flow/docker-flow-swarm-listener/tracing"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
 type NotifyDistributorTestSuite struct {
 	suite.Suite
-	log      *log.Logger
+	log      *logging.Logger
 	logBytes *bytes.Buffer
 	ctx      context.Context
 }
@@ -25,7 +31,7 @@ func TestNotifyDistributorUnitTestSuite(t *testing.T) {
 
 func (s *NotifyDistributorTestSuite) SetupSuite() {
 	s.logBytes = new(bytes.Buffer)
-	s.log = log.New(s.logBytes, "", 0)
+	s.log = logging.New(s.logBytes, logging.FormatLogfmt, logging.LevelDebug)
 	s.ctx = context.Background()
 }
 func (s *NotifyDistributorTestSuite) SetupTest() {
@@ -33,13 +39,15 @@ func (s *NotifyDistributorTestSuite) SetupTest() {
 }
 
 func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings() {
-	notifyD := newNotifyDistributorfromStrings(
+	notifyD, err := newNotifyDistributorfromStrings(
 		"http://host1:8080/recofigureservice,http://host2:8080/recofigureservice",
 		"http://host1:8080/removeservice,http://host2:8080/removeservice",
 		"http://host1:8080/reconfigurenode",
 		"http://host2:8080/removenode",
 		"GET", "GET",
+		"query", "query",
 		5, 10, s.log)
+	s.Require().NoError(err)
 
 	s.Len(notifyD.NotifyEndpoints, 2)
 	host1EP, ok := notifyD.NotifyEndpoints["host1:8080"]
@@ -70,13 +78,15 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings() {
 	s.True(notifyD.HasNodeListeners())
 }
 func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_CommaSeparatedMethods() {
-	notifyD := newNotifyDistributorfromStrings(
+	notifyD, err := newNotifyDistributorfromStrings(
 		"http://host1:8080/recofigureservice,http://host2:8080/recofigureservice",
 		"http://host1:8080/removeservice,http://host2:8080/removeservice",
 		"http://host1:8080/reconfigurenode",
 		"http://host2:8080/removenode",
 		"GET,POST", "POST",
+		"query", "query",
 		5, 10, s.log)
+	s.Require().NoError(err)
 
 	s.Len(notifyD.NotifyEndpoints, 2)
 	host1EP, ok := notifyD.NotifyEndpoints["host1:8080"]
@@ -106,14 +116,214 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_CommaS
 	s.True(notifyD.HasServiceListeners())
 	s.True(notifyD.HasNodeListeners())
 }
+func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_CommaSeparatedPayloads() {
+	notifyD, err := newNotifyDistributorfromStrings(
+		"http://host1:8080/recofigureservice,http://host2:8080/recofigureservice",
+		"http://host1:8080/removeservice,http://host2:8080/removeservice",
+		"http://host1:8080/reconfigurenode", "",
+		"POST", "POST",
+		"json,form", "form",
+		5, 10, s.log)
+	s.Require().NoError(err)
+
+	s.Len(notifyD.NotifyEndpoints, 2)
+	host1EP, ok := notifyD.NotifyEndpoints["host1:8080"]
+	s.Require().True(ok)
+	host1Notifier := host1EP.ServiceNotifier.(*Notifier)
+	s.Equal(PayloadTypeJSON, host1Notifier.createPayload)
+	s.Equal(PayloadTypeForm, host1Notifier.removePayload)
+	s.Equal(PayloadTypeQuery, host1EP.NodeNotifier.(*Notifier).createPayload)
+
+	host2EP, ok := notifyD.NotifyEndpoints["host2:8080"]
+	s.Require().True(ok)
+	host2Notifier := host2EP.ServiceNotifier.(*Notifier)
+	s.Equal(PayloadTypeForm, host2Notifier.createPayload)
+	s.Equal(PayloadTypeForm, host2Notifier.removePayload)
+}
+func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromConfig_RetryPolicies() {
+	retry := 3
+	multiplier := 2.0
+	jitter := 0.5
+	c := config.Default()
+	c.RetryPolicy.Multiplier = &multiplier
+	c.Endpoints = []config.Endpoint{
+		{
+			CreateServiceURL: "http://host1/create",
+			RemoveServiceURL: "http://host1/remove",
+			RetryPolicy:      &config.RetryPolicy{Jitter: &jitter},
+			RetryPolicies: map[string]config.EventRetryPolicy{
+				"removeService": {Retry: &retry},
+			},
+		},
+	}
+
+	notifyD, err := NewNotifyDistributorFromConfig(c, s.log)
+	s.Require().NoError(err)
+
+	notifier := notifyD.NotifyEndpoints["host1"].ServiceNotifier.(*Notifier)
+	s.Equal(RetryPolicy{
+		MaxRetries:   50,
+		InitialDelay: time.Second * 5,
+		Multiplier:   2,
+		Jitter:       0.5,
+	}, notifier.createRetryPolicy)
+	s.Equal(3, notifier.removeRetryPolicy.MaxRetries)
+	s.Equal(2.0, notifier.removeRetryPolicy.Multiplier)
+}
+
+func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromConfig_CircuitBreakers() {
+	threshold := 5
+	disabled := 0
+	c := config.Default()
+	c.CircuitBreaker.FailureThreshold = &threshold
+	c.Endpoints = []config.Endpoint{
+		{
+			CreateServiceURL: "http://host1/create",
+			CreateNodeURL:    "http://host1/node/create",
+		},
+		{
+			CreateServiceURL: "http://host2/create",
+			CircuitBreaker:   &config.CircuitBreaker{FailureThreshold: &disabled},
+		},
+		{
+			CreateServiceURL: "http://host3/create",
+			CircuitBreaker:   &config.CircuitBreaker{OpenTimeout: &config.Duration{Duration: time.Minute}},
+		},
+	}
+
+	notifyD, err := NewNotifyDistributorFromConfig(c, s.log)
+	s.Require().NoError(err)
+
+	host1 := notifyD.NotifyEndpoints["host1"]
+	s.Require().NotNil(host1.CircuitBreaker)
+	s.Equal(host1.CircuitBreaker, host1.ServiceNotifier.(*Notifier).circuitBreaker)
+	s.Equal(host1.CircuitBreaker, host1.NodeNotifier.(*Notifier).circuitBreaker)
+	s.Nil(notifyD.NotifyEndpoints["host2"].CircuitBreaker)
+	host3 := notifyD.NotifyEndpoints["host3"]
+	s.Require().NotNil(host3.CircuitBreaker)
+	s.Equal(5, host3.CircuitBreaker.failureThreshold)
+	s.Equal(time.Minute, host3.CircuitBreaker.openTimeout)
+	s.Equal(map[string]CircuitBreakerStatus{
+		"host1": {State: CircuitClosed},
+		"host3": {State: CircuitClosed},
+	}, notifyD.CircuitBreakers())
+}
+
+func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromConfig_RequestOptions() {
+	c := config.Default()
+	c.Headers = map[string]string{"X-Env": "prod", "X-Team": "ops"}
+	c.BearerToken = "global-token"
+	c.SigningSecret = "global-secret"
+	c.Endpoints = []config.Endpoint{
+		{
+			CreateServiceURL: "http://host1/create",
+		},
+		{
+			CreateServiceURL: "http://host2/create",
+			CreateNodeURL:    "http://host2/node/create",
+			RequestOptions: config.RequestOptions{
+				Headers:       map[string]string{"x-team": "dev"},
+				BearerToken:   "host2-token",
+				SigningSecret: "host2-secret",
+			},
+		},
+	}
+
+	notifyD, err := NewNotifyDistributorFromConfig(c, s.log)
+	s.Require().NoError(err)
+
+	host1 := notifyD.NotifyEndpoints["host1"].ServiceNotifier.(*Notifier)
+	s.Equal(http.Header{
+		"Authorization": []string{"Bearer global-token"},
+		"X-Env":         []string{"prod"},
+		"X-Team":        []string{"ops"},
+	}, host1.headers)
+	s.Equal([]byte("global-secret"), host1.signingSecret)
+
+	for _, sender := range []NotificationSender{
+		notifyD.NotifyEndpoints["host2"].ServiceNotifier,
+		notifyD.NotifyEndpoints["host2"].NodeNotifier,
+	} {
+		notifier := sender.(*Notifier)
+		s.Equal("Bearer host2-token", notifier.headers.Get("Authorization"))
+		s.Equal("prod", notifier.headers.Get("X-Env"))
+		s.Equal("dev", notifier.headers.Get("X-Team"))
+		s.Equal([]byte("host2-secret"), notifier.signingSecret)
+	}
+}
+
+func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromConfig_CreatesClientPerEndpoint() {
+	c := config.Default()
+	c.Endpoints = []config.Endpoint{
+		{
+			CreateServiceURL: "http://host1/create",
+			CreateNodeURL:    "http://host1/node/create",
+		},
+		{
+			CreateServiceURL: "https://host2/create",
+			TLS:              &config.TLS{ServerName: "proxy.example.com", MinVersion: "1.2"},
+			ResponseTimeout:  &config.Duration{Duration: time.Minute},
+		},
+	}
+
+	notifyD, err := NewNotifyDistributorFromConfig(c, s.log)
+	s.Require().NoError(err)
+
+	host1 := notifyD.NotifyEndpoints["host1"]
+	client1 := host1.ServiceNotifier.(*Notifier).client
+	s.Require().NotNil(client1)
+	s.True(client1 == host1.NodeNotifier.(*Notifier).client)
+	transport1 := client1.Transport.(*http.Transport)
+	s.Nil(transport1.TLSClientConfig)
+	s.Equal(time.Second*10, transport1.TLSHandshakeTimeout)
+	s.Equal(time.Second*30, transport1.ResponseHeaderTimeout)
+
+	client2 := notifyD.NotifyEndpoints["host2"].ServiceNotifier.(*Notifier).client
+	s.False(client1 == client2)
+	transport2 := client2.Transport.(*http.Transport)
+	s.Require().NotNil(transport2.TLSClientConfig)
+	s.Equal("proxy.example.com", transport2.TLSClientConfig.ServerName)
+	s.Equal(uint16(tls.VersionTLS12), transport2.TLSClientConfig.MinVersion)
+	s.Equal(time.Minute, transport2.ResponseHeaderTimeout)
+}
+
+func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromConfig_ReturnsError_WhenTLSFilesCannotBeRead() {
+	c := config.Default()
+	c.Endpoints = []config.Endpoint{
+		{
+			CreateServiceURL: "https://host1/create",
+			TLS:              &config.TLS{CAFile: "/does/not/exist.pem"},
+		},
+	}
+
+	_, err := NewNotifyDistributorFromConfig(c, s.log)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "host1: Unable to read CA bundle")
+}
+
+func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEndpoints_ReturnsError_WhenTLSFilesCannotBeRead() {
+	endpoints := []config.Endpoint{
+		{
+			CreateServiceURL: "https://host1/create",
+			TLS:              &config.TLS{CAFile: "/does/not/exist.pem"},
+		},
+	}
+
+	notifyD, err := newNotifyDistributorFromEndpoints(endpoints, 5, 10, s.log)
+	s.Require().Error(err)
+	s.Nil(notifyD)
+}
+
 func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStringsWithParameters() {
-	notifyD := newNotifyDistributorfromStrings(
+	notifyD, err := newNotifyDistributorfromStrings(
 		"http://host1:8080/recofigureservice?hello=world,http://host2:8080/recofigureservice",
 		"http://host1:8080/removeservice,http://host2:8080/removeservice",
 		"http://host1:8080/reconfigurenode?dog=cat&bear=fox",
 		"http://host2:8080/removenode?service=aws",
 		"GET", "GET",
+		"query", "query",
 		5, 10, s.log)
+	s.Require().NoError(err)
 
 	s.Len(notifyD.NotifyEndpoints, 2)
 	host1EP, ok := notifyD.NotifyEndpoints["host1:8080"]
@@ -144,13 +354,15 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStringsWithPar
 	s.True(notifyD.HasNodeListeners())
 }
 func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_SeparateListeners() {
-	notifyD := newNotifyDistributorfromStrings(
+	notifyD, err := newNotifyDistributorfromStrings(
 		"http://host1:8080/recofigure1",
 		"http://host1:8080/removeservice",
 		"http://host2:8080/reconfigurenode",
 		"http://host2/removenode1,http://host2:8080/removenode2",
 		"GET", "GET",
+		"query", "query",
 		5, 10, s.log)
+	s.Require().NoError(err)
 
 	s.Len(notifyD.NotifyEndpoints, 3)
 	host1EP, ok := notifyD.NotifyEndpoints["host1:8080"]
@@ -192,11 +404,13 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_Separa
 }
 
 func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_JustSwarmListeners() {
-	notifyD := newNotifyDistributorfromStrings(
+	notifyD, err := newNotifyDistributorfromStrings(
 		"http://host1:8080/recofigure1",
 		"http://host1:8080/removeservice", "", "",
 		"GET", "GET",
+		"query", "query",
 		5, 10, s.log)
+	s.Require().NoError(err)
 
 	s.Len(notifyD.NotifyEndpoints, 1)
 	host1EP, ok := notifyD.NotifyEndpoints["host1:8080"]
@@ -214,13 +428,26 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_JustSw
 	s.True(notifyD.HasServiceListeners())
 	s.False(notifyD.HasNodeListeners())
 }
+
+func (s *NotifyDistributorTestSuite) Test_Hosts_ReturnsSortedEndpointHosts() {
+	notifyD, err := newNotifyDistributorfromStrings(
+		"http://host2/reconfigure,http://host1:8080/reconfigure", "", "http://host3/node", "",
+		"GET", "GET",
+		"query", "query",
+		5, 10, s.log)
+	s.Require().NoError(err)
+
+	s.Equal([]string{"host1:8080", "host2", "host3"}, notifyD.Hosts())
+}
 func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromStrings_JustNodeListeners() {
-	notifyD := newNotifyDistributorfromStrings(
+	notifyD, err := newNotifyDistributorfromStrings(
 		"", "",
 		"http://host2:8080/reconfigurenode",
 		"http://host2:8080/removenode1,http://host2/removenode2",
 		"GET", "GET",
+		"query", "query",
 		5, 10, s.log)
+	s.Require().NoError(err)
 
 	s.Len(notifyD.NotifyEndpoints, 2)
 	host28080EP, ok := notifyD.NotifyEndpoints["host2:8080"]
@@ -257,7 +484,8 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_ServiceCre
 		oldHost := os.Getenv(envKey)
 		os.Setenv(envKey, "http://host1,http://host2")
 
-		notifyD := NewNotifyDistributorFromEnv(5, 10, "", "", "", "", s.log)
+		notifyD, err := NewNotifyDistributorFromEnv(5, 10, "", "", "", "", s.log)
+		s.Require().NoError(err)
 
 		if notifyD == nil {
 			s.Fail("%s returns nil", envKey)
@@ -292,7 +520,8 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_ServiceCre
 }
 
 func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_ExtraServiceCreate() {
-	notifyD := NewNotifyDistributorFromEnv(5, 10, "http://host1,http://host2", "", "", "", s.log)
+	notifyD, err := NewNotifyDistributorFromEnv(5, 10, "http://host1,http://host2", "", "", "", s.log)
+	s.Require().NoError(err)
 	s.Require().NotNil(notifyD)
 	s.Len(notifyD.NotifyEndpoints, 2)
 
@@ -315,7 +544,8 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_ServiceRem
 		oldHost := os.Getenv(envKey)
 		os.Setenv(envKey, "http://host1,http://host2")
 
-		notifyD := NewNotifyDistributorFromEnv(5, 10, "", "", "", "", s.log)
+		notifyD, err := NewNotifyDistributorFromEnv(5, 10, "", "", "", "", s.log)
+		s.Require().NoError(err)
 
 		if notifyD == nil {
 			s.Fail("%s returns nil", envKey)
@@ -349,7 +579,8 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_ServiceRem
 	}
 }
 func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_ExtraServiceRemove() {
-	notifyD := NewNotifyDistributorFromEnv(5, 10, "", "http://host1,http://host2", "", "", s.log)
+	notifyD, err := NewNotifyDistributorFromEnv(5, 10, "", "http://host1,http://host2", "", "", s.log)
+	s.Require().NoError(err)
 	s.Require().NotNil(notifyD)
 	s.Len(notifyD.NotifyEndpoints, 2)
 
@@ -372,7 +603,8 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_Node() {
 	os.Setenv("DF_NOTIFY_CREATE_NODE_URL", "http://host1/create,http://host2/create")
 	os.Setenv("DF_NOTIFY_REMOVE_NODE_URL", "http://host1/remove,http://host2/remove")
 
-	notifyD := NewNotifyDistributorFromEnv(5, 10, "", "", "", "", s.log)
+	notifyD, err := NewNotifyDistributorFromEnv(5, 10, "", "", "", "", s.log)
+	s.Require().NoError(err)
 	s.Require().NotNil(notifyD)
 
 	s.Len(notifyD.NotifyEndpoints, 2)
@@ -399,8 +631,9 @@ func (s *NotifyDistributorTestSuite) Test_NewNotifyDistributorFromEnv_ExtraNode(
 	os.Setenv("DF_NOTIFY_CREATE_NODE_URL", "http://host1/create")
 	os.Setenv("DF_NOTIFY_REMOVE_NODE_URL", "http://host1/remove")
 
-	notifyD := NewNotifyDistributorFromEnv(5, 10, "", "", "http://host2/create",
+	notifyD, err := NewNotifyDistributorFromEnv(5, 10, "", "", "http://host2/create",
 		"http://host2/remove", s.log)
+	s.Require().NoError(err)
 	s.Require().NotNil(notifyD)
 
 	s.Len(notifyD.NotifyEndpoints, 2)
@@ -496,6 +729,594 @@ func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints
 	serviceNotifyMock2.AssertExpectations(s.T())
 }
 
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints_NotifyServiceLabel() {
+
+	service1ErrChan := make(chan error)
+	service2ErrChan := make(chan error)
+
+	createParams := "notifyService=host1&serviceName=hello"
+	removeParams := "notifyService=host2%3A8080%2Chost3&serviceName=hello"
+
+	serviceNotifyMock1 := notificationSenderMock{}
+	serviceNotifyMock1.On("Create", mock.AnythingOfType("*context.cancelCtx"), createParams).
+		Return(nil)
+	serviceNotifyMock2 := notificationSenderMock{}
+	serviceNotifyMock2.On("Remove", mock.AnythingOfType("*context.cancelCtx"), removeParams).
+		Return(nil)
+	serviceNotifyMock3 := notificationSenderMock{}
+
+	endpoints := map[string]NotifyEndpoint{
+		"host1:8080": {
+			ServiceNotifier: &serviceNotifyMock1,
+		},
+		"host2:8080": {
+			ServiceNotifier: &serviceNotifyMock2,
+		},
+		"host4": {
+			ServiceNotifier: &serviceNotifyMock3,
+		},
+	}
+
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(),
+		NewCancelManager(), 1, s.log)
+	serviceChan := make(chan Notification)
+
+	notifyD.Run(serviceChan, nil)
+
+	go func() {
+		serviceChan <- Notification{
+			EventType:  EventTypeCreate,
+			ID:         "sid1",
+			Parameters: createParams,
+			TimeNano:   int64(1),
+			Context:    s.ctx,
+			ErrorChan:  service1ErrChan,
+		}
+	}()
+	go func() {
+		serviceChan <- Notification{
+			EventType:  EventTypeRemove,
+			ID:         "sid2",
+			Parameters: removeParams,
+			TimeNano:   int64(2),
+			Context:    s.ctx,
+			ErrorChan:  service2ErrChan,
+		}
+	}()
+
+	timer := time.NewTimer(time.Second * 5).C
+
+	for {
+		if service1ErrChan == nil && service2ErrChan == nil {
+			break
+		}
+		select {
+		case <-service1ErrChan:
+			service1ErrChan = nil
+		case <-service2ErrChan:
+			service2ErrChan = nil
+		case <-timer:
+			s.Fail("Timeout")
+			return
+		}
+	}
+
+	serviceNotifyMock1.AssertExpectations(s.T())
+	serviceNotifyMock2.AssertExpectations(s.T())
+	serviceNotifyMock3.AssertNotCalled(s.T(), "Create", mock.Anything, mock.Anything)
+	serviceNotifyMock3.AssertNotCalled(s.T(), "Remove", mock.Anything, mock.Anything)
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotifications_InTraceOfEvent() {
+	recorder := &spanRecorder{}
+	tracer := tracing.NewTracer(recorder, 1, s.log)
+	tracing.SetTracer(tracer)
+	defer tracing.SetTracer(nil)
+	eventCtx, eventSpan := tracing.Start(context.Background(), "service.create")
+
+	var deliveryCtx context.Context
+	serviceNotifyMock := notificationSenderMock{}
+	serviceNotifyMock.On("Create", mock.Anything, "serviceName=hello").
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			deliveryCtx = args.Get(0).(context.Context)
+		})
+	endpoints := map[string]NotifyEndpoint{
+		"host1": {ServiceNotifier: &serviceNotifyMock},
+	}
+
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(),
+		NewCancelManager(), 1, s.log)
+	serviceChan := make(chan Notification)
+	errChan := make(chan error)
+
+	notifyD.Run(serviceChan, nil)
+
+	go func() {
+		serviceChan <- Notification{
+			EventType:   EventTypeCreate,
+			ID:          "sid1",
+			Parameters:  "serviceName=hello",
+			TimeNano:    int64(1),
+			ErrorChan:   errChan,
+			SpanContext: tracing.SpanContextFromContext(eventCtx),
+		}
+	}()
+
+	select {
+	case <-errChan:
+	case <-time.After(time.Second * 5):
+		s.Fail("Timeout")
+		return
+	}
+	tracer.Flush()
+
+	spans := recorder.Spans()
+	s.Require().Contains(spans, "notification.distribute")
+	s.Require().Contains(spans, "notification.deliver")
+	distribute := spans["notification.distribute"]
+	deliver := spans["notification.deliver"]
+	s.Equal(eventSpan.SpanContext().TraceID, distribute.SpanContext.TraceID)
+	s.Equal(eventSpan.SpanContext().SpanID, distribute.ParentSpanID)
+	s.Equal(distribute.SpanContext.SpanID, deliver.ParentSpanID)
+	s.Equal(deliver.SpanContext, tracing.SpanContextFromContext(deliveryCtx))
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotifications_WithLogFields() {
+	var deliveryCtx context.Context
+	nodeNotifyMock := notificationSenderMock{}
+	nodeNotifyMock.On("Remove", mock.AnythingOfType("*context.cancelCtx"), "hostname=node1&id=nid1").
+		Return(nil).
+		Run(func(args mock.Arguments) {
+			deliveryCtx = args.Get(0).(context.Context)
+		})
+	notifyD := newNotifyDistributor(map[string]NotifyEndpoint{
+		"host1": {NodeNotifier: &nodeNotifyMock},
+	}, NewCancelManager(), NewCancelManager(), 1, s.log)
+	nodeChan := make(chan Notification)
+	errChan := make(chan error)
+
+	notifyD.Run(nil, nodeChan)
+
+	go func() {
+		nodeChan <- Notification{
+			EventType:  EventTypeRemove,
+			ID:         "nid1",
+			Parameters: "hostname=node1&id=nid1",
+			TimeNano:   int64(10),
+			ErrorChan:  errChan,
+		}
+	}()
+
+	select {
+	case <-errChan:
+	case <-time.After(time.Second * 5):
+		s.Fail("Timeout")
+		return
+	}
+	s.Equal([]interface{}{
+		"node_id", "nid1",
+		"event_type", EventTypeRemove,
+		"request_id", int64(10),
+		"node_hostname", "node1",
+	}, logging.FieldsFromContext(deliveryCtx))
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints_Hosts() {
+	serviceNotifyMock1 := notificationSenderMock{}
+	serviceNotifyMock1.On("Create", mock.AnythingOfType("*context.cancelCtx"), "serviceName=hello").
+		Return(nil)
+	serviceNotifyMock2 := notificationSenderMock{}
+
+	endpoints := map[string]NotifyEndpoint{
+		"host1": {
+			ServiceNotifier: &serviceNotifyMock1,
+		},
+		"host2": {
+			ServiceNotifier: &serviceNotifyMock2,
+		},
+	}
+
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(),
+		NewCancelManager(), 1, s.log)
+	serviceChan := make(chan Notification)
+	errChan := make(chan error)
+
+	notifyD.Run(serviceChan, nil)
+
+	go func() {
+		serviceChan <- Notification{
+			EventType:  EventTypeCreate,
+			ID:         "sid1",
+			Parameters: "serviceName=hello",
+			TimeNano:   int64(1),
+			Context:    s.ctx,
+			ErrorChan:  errChan,
+			Hosts:      []string{"host1", "host3"},
+		}
+	}()
+
+	select {
+	case <-errChan:
+	case <-time.After(time.Second * 5):
+		s.Fail("Timeout")
+		return
+	}
+
+	serviceNotifyMock1.AssertExpectations(s.T())
+	serviceNotifyMock2.AssertNotCalled(s.T(), "Create", mock.Anything, mock.Anything)
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints_Report() {
+	serviceNotifyMock1 := notificationSenderMock{}
+	serviceNotifyMock1.On("Create", mock.Anything, "serviceName=hello").Return(nil).
+		Run(func(args mock.Arguments) {
+			delivery := endpointDeliveryFromContext(args.Get(0).(context.Context))
+			delivery.Attempts = 2
+			delivery.StatusCode = 200
+		})
+	serviceNotifyMock2 := notificationSenderMock{}
+	serviceNotifyMock2.On("Create", mock.Anything, "serviceName=hello").Return(fmt.Errorf("failed")).
+		Run(func(args mock.Arguments) {
+			delivery := endpointDeliveryFromContext(args.Get(0).(context.Context))
+			delivery.Attempts = 1
+			delivery.StatusCode = 500
+		}).
+		On("GetCreateAddr").Return("http://host2")
+	nodeNotifyMock := notificationSenderMock{}
+
+	endpoints := map[string]NotifyEndpoint{
+		"host1": {ServiceNotifier: &serviceNotifyMock1},
+		"host2": {ServiceNotifier: &serviceNotifyMock2},
+		"host3": {NodeNotifier: &nodeNotifyMock},
+	}
+
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(),
+		NewCancelManager(), 1, s.log)
+	serviceChan := make(chan Notification)
+	errChan := make(chan error)
+	report := &DeliveryReport{}
+
+	notifyD.Run(serviceChan, nil)
+
+	go func() {
+		serviceChan <- Notification{
+			EventType:  EventTypeCreate,
+			ID:         "sid1",
+			Parameters: "serviceName=hello",
+			TimeNano:   int64(1),
+			Context:    s.ctx,
+			ErrorChan:  errChan,
+			Report:     report,
+		}
+	}()
+
+	select {
+	case <-errChan:
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+
+	s.Equal([]EndpointDelivery{
+		{Host: "host1", StatusCode: 200, Attempts: 2, Retries: 1},
+		{Host: "host2", StatusCode: 500, Attempts: 1, Error: "failed"},
+	}, report.Deliveries())
+	s.Equal(DeliveryStatusFailed, report.Status())
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotifications_AuditsDeliveries() {
+	auditDir, err := ioutil.TempDir("", "dfsl-audit")
+	s.Require().NoError(err)
+	defer os.RemoveAll(auditDir)
+
+	serviceNotifyMock1 := notificationSenderMock{}
+	serviceNotifyMock1.On("Create", mock.Anything, "serviceName=hello&usersPasswords=admin:pass").Return(nil).
+		Run(func(args mock.Arguments) {
+			delivery := endpointDeliveryFromContext(args.Get(0).(context.Context))
+			delivery.Attempts = 2
+			delivery.StatusCode = 200
+			delivery.ResponseBody = "reconfigured"
+		})
+	serviceNotifyMock2 := notificationSenderMock{}
+	serviceNotifyMock2.On("Create", mock.Anything, "serviceName=hello&usersPasswords=admin:pass").Return(fmt.Errorf("failed")).
+		Run(func(args mock.Arguments) {
+			delivery := endpointDeliveryFromContext(args.Get(0).(context.Context))
+			delivery.Attempts = 1
+			delivery.StatusCode = 500
+		}).
+		On("GetCreateAddr").Return("http://host2")
+	nodeNotifyMock := notificationSenderMock{}
+
+	endpoints := map[string]NotifyEndpoint{
+		"host1:8080": {ServiceNotifier: &serviceNotifyMock1},
+		"host2:8080": {ServiceNotifier: &serviceNotifyMock2},
+		"host3:8080": {NodeNotifier: &nodeNotifyMock},
+	}
+
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(),
+		NewCancelManager(), 1, s.log.WithRedactedLabels([]string{"com.df.usersPasswords"}))
+	s.Require().NoError(notifyD.EnableAudit(auditDir+"/audit.log", 0, 0))
+	serviceChan := make(chan Notification)
+	errChan := make(chan error)
+
+	notifyD.Run(serviceChan, nil)
+
+	go func() {
+		serviceChan <- Notification{
+			EventType:  EventTypeCreate,
+			ID:         "sid1",
+			Parameters: "serviceName=hello&usersPasswords=admin:pass",
+			TimeNano:   int64(1),
+			Context:    s.ctx,
+			ErrorChan:  errChan,
+		}
+	}()
+
+	select {
+	case <-errChan:
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+
+	records, err := notifyD.NotificationHistory(AuditFilter{Service: "hello", Endpoint: "host1"})
+	s.Require().NoError(err)
+	s.Require().Len(records, 1)
+	s.NotZero(records[0].Time)
+	records[0].Time = time.Time{}
+	s.Equal(AuditRecord{
+		RequestID:    1,
+		Kind:         QueueKindService,
+		EventType:    EventTypeCreate,
+		ID:           "sid1",
+		Name:         "hello",
+		Parameters:   map[string]string{"serviceName": "hello", "usersPasswords": "REDACTED"},
+		Endpoint:     "host1:8080",
+		Attempts:     2,
+		StatusCode:   200,
+		Status:       AuditStatusSuccess,
+		ResponseBody: "reconfigured",
+	}, records[0])
+
+	records, err = notifyD.NotificationHistory(AuditFilter{Status: AuditStatusFailure})
+	s.Require().NoError(err)
+	s.Require().Len(records, 1)
+	s.Equal("host2:8080", records[0].Endpoint)
+	s.Equal(500, records[0].StatusCode)
+	s.Equal("failed", records[0].Error)
+}
+
+func (s *NotifyDistributorTestSuite) Test_NotificationHistory_ReturnsError_WhenAuditIsDisabled() {
+	notifyD := newNotifyDistributor(map[string]NotifyEndpoint{}, NewCancelManager(),
+		NewCancelManager(), 1, s.log)
+
+	_, err := notifyD.NotificationHistory(AuditFilter{})
+
+	s.Equal(ErrAuditDisabled, err)
+}
+
+func (s *NotifyDistributorTestSuite) Test_EndpointHealth_RecordsDeliveries() {
+	serviceNotifyMock1 := notificationSenderMock{}
+	serviceNotifyMock1.On("Create", mock.Anything, "serviceName=hello").Return(nil)
+	serviceNotifyMock2 := notificationSenderMock{}
+	serviceNotifyMock2.On("Create", mock.Anything, "serviceName=hello").Return(fmt.Errorf("failed")).
+		On("GetCreateAddr").Return("http://host2")
+	nodeNotifyMock := notificationSenderMock{}
+
+	endpoints := map[string]NotifyEndpoint{
+		"host1": {ServiceNotifier: &serviceNotifyMock1},
+		"host2": {ServiceNotifier: &serviceNotifyMock2},
+		"host3": {NodeNotifier: &nodeNotifyMock},
+	}
+
+	notifyD := newNotifyDistributor(endpoints, NewCancelManager(),
+		NewCancelManager(), 1, s.log)
+	serviceChan := make(chan Notification)
+	errChan := make(chan error)
+
+	notifyD.Run(serviceChan, nil)
+
+	for i := int64(1); i <= 2; i++ {
+		go func(timeNano int64) {
+			serviceChan <- Notification{
+				EventType:  EventTypeCreate,
+				ID:         "sid1",
+				Parameters: "serviceName=hello",
+				TimeNano:   timeNano,
+				Context:    s.ctx,
+				ErrorChan:  errChan,
+			}
+		}(i)
+		select {
+		case <-errChan:
+		case <-time.After(time.Second * 5):
+			s.FailNow("Timeout")
+		}
+	}
+
+	health := notifyD.EndpointHealth()
+	s.Len(health, 3)
+	s.NotNil(health["host1"].LastSuccessAt)
+	s.Nil(health["host1"].LastFailureAt)
+	s.Equal(0, health["host1"].ConsecutiveFailures)
+	s.Nil(health["host2"].LastSuccessAt)
+	s.NotNil(health["host2"].LastFailureAt)
+	s.Equal("failed", health["host2"].LastError)
+	s.Equal(2, health["host2"].ConsecutiveFailures)
+	s.Equal(EndpointHealth{}, health["host3"])
+}
+
+func (s *NotifyDistributorTestSuite) Test_UpdateEndpoints_ReturnsAddedHosts_DrainsRemovedEndpoints() {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	serviceNotifyMock1 := notificationSenderMock{}
+	serviceNotifyMock1.On("Create", mock.AnythingOfType("*context.cancelCtx"), "serviceName=hello").
+		Return(nil).Run(func(mock.Arguments) {
+		close(started)
+		<-release
+	})
+	serviceNotifyMock2 := notificationSenderMock{}
+	serviceNotifyMock2.On("Create", mock.AnythingOfType("*context.cancelCtx"), "serviceName=world").
+		Return(nil)
+	serviceNotifyMock3 := notificationSenderMock{}
+	serviceNotifyMock3.On("Create", mock.AnythingOfType("*context.cancelCtx"), "serviceName=world").
+		Return(nil)
+
+	notifyD := newNotifyDistributor(map[string]NotifyEndpoint{
+		"host1": {ServiceNotifier: &serviceNotifyMock1},
+		"host2": {ServiceNotifier: &serviceNotifyMock2},
+	}, NewCancelManager(), NewCancelManager(), 1, logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelDebug))
+	serviceChan := make(chan Notification)
+	notifyD.Run(serviceChan, nil)
+
+	errChan1 := make(chan error)
+	serviceChan <- Notification{
+		EventType:  EventTypeCreate,
+		ID:         "sid1",
+		Parameters: "serviceName=hello",
+		TimeNano:   int64(1),
+		ErrorChan:  errChan1,
+		Hosts:      []string{"host1"},
+	}
+	<-started
+
+	added := notifyD.UpdateEndpoints(map[string]NotifyEndpoint{
+		"host2": {ServiceNotifier: &serviceNotifyMock2},
+		"host3": {ServiceNotifier: &serviceNotifyMock3},
+	})
+	s.Equal([]string{"host3"}, added)
+
+	errChan2 := make(chan error)
+	serviceChan <- Notification{
+		EventType:  EventTypeCreate,
+		ID:         "sid2",
+		Parameters: "serviceName=world",
+		TimeNano:   int64(2),
+		ErrorChan:  errChan2,
+	}
+
+	for _, errChan := range []chan error{errChan2, errChan1} {
+		if errChan == errChan1 {
+			close(release)
+		}
+		select {
+		case <-errChan:
+		case <-time.After(time.Second * 5):
+			s.Fail("Timeout")
+			return
+		}
+	}
+
+	serviceNotifyMock1.AssertExpectations(s.T())
+	serviceNotifyMock2.AssertExpectations(s.T())
+	serviceNotifyMock3.AssertExpectations(s.T())
+	serviceNotifyMock1.AssertNumberOfCalls(s.T(), "Create", 1)
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints_Queues() {
+	queueDir, err := ioutil.TempDir("", "dfsl-queue")
+	s.Require().NoError(err)
+	defer os.RemoveAll(queueDir)
+
+	serviceNotifyMock := notificationSenderMock{}
+	serviceNotifyMock.On("Remove", mock.AnythingOfType("*context.valueCtx"), "serviceName=hello").
+		Return(fmt.Errorf("connection refused")).Once()
+	serviceNotifyMock.On("Remove", mock.AnythingOfType("*context.valueCtx"), "serviceName=hello").
+		Return(nil).Once()
+	serviceNotifyMock.On("GetRemoveAddr").Return("http://host1/remove")
+	nodeNotifyMock := notificationSenderMock{}
+	nodeNotifyMock.On("Create", mock.AnythingOfType("*context.valueCtx"), "id=nid1").
+		Return(nil)
+
+	notifyD := newNotifyDistributor(map[string]NotifyEndpoint{
+		"host1": {ServiceNotifier: &serviceNotifyMock, NodeNotifier: &nodeNotifyMock},
+	}, NewCancelManager(), NewCancelManager(), 0, logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelDebug))
+	s.Require().NoError(notifyD.EnableQueues(queueDir, 0, 0))
+	serviceChan := make(chan Notification)
+	nodeChan := make(chan Notification)
+	notifyD.Run(serviceChan, nodeChan)
+
+	errChan := make(chan error)
+	serviceChan <- Notification{
+		EventType:  EventTypeRemove,
+		ID:         "sid1",
+		Parameters: "serviceName=hello",
+		TimeNano:   int64(1),
+		ErrorChan:  errChan,
+	}
+	nodeChan <- Notification{
+		EventType:  EventTypeCreate,
+		ID:         "nid1",
+		Parameters: "id=nid1",
+		TimeNano:   int64(2),
+		ErrorChan:  errChan,
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-errChan:
+		case <-time.After(time.Second * 5):
+			s.FailNow("Timeout")
+		}
+	}
+
+	s.FileExists(queueFilename(queueDir, "host1"))
+	timeout := time.After(time.Second * 5)
+	for len(notifyD.QueuedNotifications()["host1"]) > 0 {
+		select {
+		case <-timeout:
+			s.FailNow("Timeout")
+		case <-time.After(time.Millisecond * 10):
+		}
+	}
+	serviceNotifyMock.AssertExpectations(s.T())
+	nodeNotifyMock.AssertExpectations(s.T())
+
+	notifyD.UpdateEndpoints(map[string]NotifyEndpoint{})
+	s.Empty(notifyD.QueuedNotifications())
+}
+
+func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints_ReportsQueuedDeliveries() {
+	queueDir, err := ioutil.TempDir("", "dfsl-queue")
+	s.Require().NoError(err)
+	defer os.RemoveAll(queueDir)
+
+	serviceNotifyMock := notificationSenderMock{}
+	serviceNotifyMock.On("Create", mock.Anything, "serviceName=hello").Return(nil).
+		Run(func(args mock.Arguments) {
+			delivery := endpointDeliveryFromContext(args.Get(0).(context.Context))
+			delivery.Attempts = 2
+			delivery.StatusCode = 200
+		})
+
+	notifyD := newNotifyDistributor(map[string]NotifyEndpoint{
+		"host1": {ServiceNotifier: &serviceNotifyMock},
+	}, NewCancelManager(), NewCancelManager(), 0, s.log)
+	s.Require().NoError(notifyD.EnableQueues(queueDir, 0, 0))
+	serviceChan := make(chan Notification)
+	notifyD.Run(serviceChan, nil)
+
+	errChan := make(chan error)
+	report := &DeliveryReport{}
+	serviceChan <- Notification{
+		EventType:  EventTypeCreate,
+		ID:         "sid1",
+		Parameters: "serviceName=hello",
+		TimeNano:   int64(1),
+		Context:    s.ctx,
+		ErrorChan:  errChan,
+		Report:     report,
+	}
+	select {
+	case <-errChan:
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+
+	s.Equal([]EndpointDelivery{
+		{Host: "host1", StatusCode: 200, Attempts: 2, Retries: 1, Queued: true},
+	}, report.Deliveries())
+	s.Equal(DeliveryStatusDelivered, report.Status())
+}
+
 func (s *NotifyDistributorTestSuite) Test_RunDistributesNotificationsToEndpoints_Nodes1() {
 	node1ErrChan := make(chan error)
 	node2ErrChan := make(chan error)
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+)
+
+// maxRetryDelay bounds delays that grow without a `MaxDelay`, so that they
+// cannot overflow when they are added to the elapsed time
+const maxRetryDelay = time.Duration(math.MaxInt64 / 2)
+
+// RetryPolicy decides if and when a failed notification is retried
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// InitialDelay is the delay before the first retry
+	InitialDelay time.Duration
+	// Multiplier increases the delay after each retry
+	Multiplier float64
+	// MaxDelay caps the delay between retries when it is greater than zero
+	MaxDelay time.Duration
+	// Jitter randomizes each delay by up to the given fraction of it
+	Jitter float64
+	// MaxElapsedTime stops retrying once the time since the first attempt
+	// would exceed it. It is unlimited when zero.
+	MaxElapsedTime time.Duration
+	// RetryableStatusCodes are the response status codes that are retried
+	// All unsuccessful status codes are retried when empty
+	RetryableStatusCodes []int
+}
+
+// NewRetryPolicy returns a policy that retries `retries` times every
+// `interval` seconds
+func NewRetryPolicy(retries, interval int) RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:   retries,
+		InitialDelay: time.Second * time.Duration(interval),
+		Multiplier:   1,
+	}
+}
+
+// Delay returns the time to wait before retry `retry`, starting with 1
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(retry-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if delay > float64(maxRetryDelay) {
+		return maxRetryDelay
+	}
+	return time.Duration(delay)
+}
+
+// CanRetry returns true when retry `retry` may be sent `elapsed` after the
+// first attempt
+func (p RetryPolicy) CanRetry(retry int, elapsed time.Duration) bool {
+	if retry > p.MaxRetries {
+		return false
+	}
+	return p.MaxElapsedTime <= 0 || elapsed <= p.MaxElapsedTime
+}
+
+// IsRetryableStatusCode returns true when a response with `statusCode`
+// is retried
+func (p RetryPolicy) IsRetryableStatusCode(statusCode int) bool {
+	if len(p.RetryableStatusCodes) == 0 {
+		return true
+	}
+	for _, code := range p.RetryableStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// withConfig returns `p` overridden by the values set in `c`
+func (p RetryPolicy) withConfig(c *config.RetryPolicy) RetryPolicy {
+	if c == nil {
+		return p
+	}
+	if c.Multiplier != nil {
+		p.Multiplier = *c.Multiplier
+	}
+	if c.MaxDelay != nil {
+		p.MaxDelay = c.MaxDelay.Duration
+	}
+	if c.Jitter != nil {
+		p.Jitter = *c.Jitter
+	}
+	if c.MaxElapsedTime != nil {
+		p.MaxElapsedTime = c.MaxElapsedTime.Duration
+	}
+	if len(c.RetryableStatusCodes) > 0 {
+		p.RetryableStatusCodes = c.RetryableStatusCodes
+	}
+	return p
+}
+
+// withEventConfig returns `p` overridden by the values set in `c`
+func (p RetryPolicy) withEventConfig(c config.EventRetryPolicy) RetryPolicy {
+	if c.Retry != nil {
+		p.MaxRetries = *c.Retry
+	}
+	if c.InitialDelay != nil {
+		p.InitialDelay = c.InitialDelay.Duration
+	}
+	return p.withConfig(&c.RetryPolicy)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type RetryPolicyTestSuite struct {
+	suite.Suite
+}
+
+func TestRetryPolicyUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(RetryPolicyTestSuite))
+}
+
+func (s *RetryPolicyTestSuite) Test_NewRetryPolicy_UsesFixedDelay() {
+	p := NewRetryPolicy(3, 2)
+
+	s.Equal(3, p.MaxRetries)
+	s.Equal(time.Second*2, p.Delay(1))
+	s.Equal(time.Second*2, p.Delay(3))
+}
+
+func (s *RetryPolicyTestSuite) Test_Delay_GrowsExponentiallyUpToMaxDelay() {
+	p := RetryPolicy{
+		InitialDelay: time.Millisecond * 100,
+		Multiplier:   2,
+		MaxDelay:     time.Millisecond * 500,
+	}
+
+	s.Equal(time.Millisecond*100, p.Delay(1))
+	s.Equal(time.Millisecond*200, p.Delay(2))
+	s.Equal(time.Millisecond*400, p.Delay(3))
+	s.Equal(time.Millisecond*500, p.Delay(4))
+}
+
+func (s *RetryPolicyTestSuite) Test_Delay_AddsJitter() {
+	p := RetryPolicy{
+		InitialDelay: time.Second,
+		Multiplier:   1,
+		Jitter:       0.5,
+	}
+
+	different := false
+	for i := 0; i < 20; i++ {
+		delay := p.Delay(1)
+		s.True(delay >= time.Millisecond*500 && delay <= time.Millisecond*1500, delay)
+		if delay != time.Second {
+			different = true
+		}
+	}
+	s.True(different)
+}
+
+func (s *RetryPolicyTestSuite) Test_Delay_IsBounded_WithoutMaxDelay() {
+	p := RetryPolicy{
+		InitialDelay: time.Second,
+		Multiplier:   2,
+		Jitter:       0.5,
+	}
+
+	for retry := 1; retry <= 100; retry++ {
+		s.True(p.Delay(retry) > 0, retry)
+	}
+	s.Equal(maxRetryDelay, RetryPolicy{InitialDelay: time.Second, Multiplier: 2}.Delay(100))
+}
+
+func (s *RetryPolicyTestSuite) Test_CanRetry_StopsAfterMaxRetriesOrMaxElapsedTime() {
+	p := RetryPolicy{MaxRetries: 2, MaxElapsedTime: time.Second}
+
+	s.True(p.CanRetry(1, 0))
+	s.True(p.CanRetry(2, time.Second))
+	s.False(p.CanRetry(3, 0))
+	s.False(p.CanRetry(1, time.Second+1))
+}
+
+func (s *RetryPolicyTestSuite) Test_IsRetryableStatusCode() {
+	s.True(RetryPolicy{}.IsRetryableStatusCode(400))
+
+	p := RetryPolicy{RetryableStatusCodes: []int{502, 503}}
+	s.True(p.IsRetryableStatusCode(503))
+	s.False(p.IsRetryableStatusCode(400))
+}
+
+func (s *RetryPolicyTestSuite) Test_WithEventConfig_OverridesSetValues() {
+	retry := 7
+	multiplier := 3.0
+	maxDelay := config.Duration{Duration: time.Minute}
+	initialDelay := config.Duration{Duration: time.Millisecond * 250}
+
+	p := NewRetryPolicy(50, 5).
+		withConfig(&config.RetryPolicy{Multiplier: &multiplier}).
+		withEventConfig(config.EventRetryPolicy{
+			Retry:        &retry,
+			InitialDelay: &initialDelay,
+			RetryPolicy: config.RetryPolicy{
+				MaxDelay:             &maxDelay,
+				RetryableStatusCodes: []int{503},
+			},
+		})
+
+	s.Equal(RetryPolicy{
+		MaxRetries:           7,
+		InitialDelay:         time.Millisecond * 250,
+		Multiplier:           3,
+		MaxDelay:             time.Minute,
+		RetryableStatusCodes: []int{503},
+	}, p)
+}
@@ -3,13 +3,14 @@ package service
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 )
 
 // SwarmServiceInspector is able to inspect services
@@ -28,13 +29,13 @@ type SwarmServiceClient struct {
 	ScrapeNetLabel               string
 	ServiceNamePrefix            string
 	IncludeTaskAddressInNodeInfo bool
-	Log                          *log.Logger
+	Log                          *logging.Logger
 }
 
 // NewSwarmServiceClient creates a `SwarmServiceClient`
 func NewSwarmServiceClient(
 	c *client.Client, filterLabel, scrapNetLabel string, serviceNamePrefix string, includeAddressInNodeInfo bool,
-	logger *log.Logger) *SwarmServiceClient {
+	logger *logging.Logger) *SwarmServiceClient {
 	key := strings.SplitN(filterLabel, "=", 2)[0]
 	return &SwarmServiceClient{DockerClient: c,
 		FilterLabel:                  filterLabel,
@@ -3,11 +3,12 @@ package service
 import (
 	"bytes"
 	"context"
-	"log"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/suite"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 )
 
 type SwarmServiceClientTestSuite struct {
@@ -17,7 +18,7 @@ type SwarmServiceClientTestSuite struct {
 	Util2ID  string
 	Util3ID  string
 	Util4ID  string
-	Logger   *log.Logger
+	Logger   *logging.Logger
 	LogBytes *bytes.Buffer
 }
 
@@ -56,7 +57,7 @@ func (s *SwarmServiceClientTestSuite) SetupTest() {
 	s.Require().NoError(err)
 
 	s.LogBytes = new(bytes.Buffer)
-	s.Logger = log.New(s.LogBytes, "", 0)
+	s.Logger = logging.New(s.LogBytes, logging.FormatLogfmt, logging.LevelDebug)
 
 	s.SClient = NewSwarmServiceClient(c, "com.df.notify=true", "com.df.scrapeNetwork", "", true, s.Logger)
 }
@@ -1,6 +1,9 @@
 package service
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 // SwarmServiceCacher caches sevices
 type SwarmServiceCacher interface {
@@ -10,12 +13,15 @@ type SwarmServiceCacher interface {
 	Get(ID string) (SwarmServiceMini, bool)
 	Len() int
 	Keys() map[string]struct{}
+	UpdatedAt() time.Time
+	Flush() int
 }
 
 // SwarmServiceCache implements `SwarmServiceCacher`
 type SwarmServiceCache struct {
-	cache map[string]SwarmServiceMini
-	mux   sync.RWMutex
+	cache     map[string]SwarmServiceMini
+	updatedAt time.Time
+	mux       sync.RWMutex
 }
 
 // NewSwarmServiceCache creates a new `NewSwarmServiceCache`
@@ -33,6 +39,7 @@ func (c *SwarmServiceCache) InsertAndCheck(ss SwarmServiceMini) bool {
 
 	cachedService, ok := c.cache[ss.ID]
 	c.cache[ss.ID] = ss
+	c.updatedAt = time.Now().UTC()
 
 	return !ok || !ss.Equal(cachedService)
 
@@ -52,6 +59,7 @@ func (c *SwarmServiceCache) Delete(ID string) {
 	c.mux.Lock()
 	defer c.mux.Unlock()
 	delete(c.cache, ID)
+	c.updatedAt = time.Now().UTC()
 }
 
 // Get gets service from cache
@@ -79,3 +87,21 @@ func (c *SwarmServiceCache) Keys() map[string]struct{} {
 	}
 	return output
 }
+
+// UpdatedAt returns the time a service was last inserted or deleted
+// It is zero when the cache was never updated.
+func (c *SwarmServiceCache) UpdatedAt() time.Time {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.updatedAt
+}
+
+// Flush removes all services from the cache and returns their number
+func (c *SwarmServiceCache) Flush() int {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	count := len(c.cache)
+	c.cache = map[string]SwarmServiceMini{}
+	c.updatedAt = time.Now().UTC()
+	return count
+}
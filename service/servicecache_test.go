@@ -2,6 +2,7 @@ package service
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -145,6 +146,18 @@ func (s *SwarmServiceCacheTestSuite) Test_Keys() {
 
 }
 
+func (s *SwarmServiceCacheTestSuite) Test_UpdatedAt() {
+	s.True(s.Cache.UpdatedAt().IsZero())
+
+	before := time.Now().UTC()
+	s.Cache.InsertAndCheck(s.SSMini)
+	inserted := s.Cache.UpdatedAt()
+	s.False(inserted.Before(before))
+
+	s.Cache.Delete(s.SSMini.ID)
+	s.False(s.Cache.UpdatedAt().Before(inserted))
+}
+
 func (s *SwarmServiceCacheTestSuite) Test_IsNewOrUpdated_ServiceInCache() {
 	s.Cache.InsertAndCheck(s.SSMini)
 	s.AssertInCache(s.SSMini)
@@ -176,6 +189,16 @@ func (s *SwarmServiceCacheTestSuite) Test_GetAndRemove_NotInCache_ReturnsFalse()
 	s.False(ok)
 }
 
+func (s *SwarmServiceCacheTestSuite) Test_Flush_RemovesAllServices() {
+	s.Cache.InsertAndCheck(s.SSMini)
+
+	s.Equal(1, s.Cache.Flush())
+
+	s.AssertNotInCache(s.SSMini)
+	s.Equal(0, s.Cache.Len())
+	s.True(s.Cache.InsertAndCheck(s.SSMini))
+}
+
 func (s *SwarmServiceCacheTestSuite) AssertInCache(ssm SwarmServiceMini) {
 	ss, ok := s.Cache.Get(ssm.ID)
 	s.True(ok)
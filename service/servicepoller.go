@@ -2,8 +2,9 @@ package service
 
 import (
 	"context"
-	"log"
 	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 )
 
 // SwarmServicePolling provides an interface for polling service changes
@@ -18,7 +19,9 @@ type SwarmServicePoller struct {
 	PollingInterval int
 	IncludeNodeInfo bool
 	MinifyFunc      func(SwarmService) SwarmServiceMini
-	Log             *log.Logger
+	Log             *logging.Logger
+	// Health records the last successful poll
+	Health *HealthRecorder
 }
 
 // NewSwarmServicePoller creates a new `SwarmServicePoller`
@@ -28,7 +31,7 @@ func NewSwarmServicePoller(
 	pollingInterval int,
 	includeNodeInfo bool,
 	minifyFunc func(SwarmService) SwarmServiceMini,
-	log *log.Logger,
+	log *logging.Logger,
 ) *SwarmServicePoller {
 	return &SwarmServicePoller{
 		SSClient:        ssClient,
@@ -50,14 +53,17 @@ func (s SwarmServicePoller) Run(
 
 	ctx := context.Background()
 
-	s.Log.Printf("Polling for Service Changes")
+	s.Log.Info("Polling for Service Changes", "interval_seconds", s.PollingInterval)
+	s.Health.pollerStarted(servicesHealthName, s.PollingInterval)
 	time.Sleep(time.Duration(s.PollingInterval) * time.Second)
 
 	for {
 		services, err := s.SSClient.SwarmServiceList(ctx)
 		if err != nil {
-			s.Log.Printf("ERROR (SwarmServicePolling): %v", err)
+			s.Log.Error("Unable to poll services", "error", err)
+			s.Health.pollFailed(servicesHealthName, err)
 		} else {
+			s.Health.pollSucceeded(servicesHealthName)
 			nowTimeNano := time.Now().UTC().UnixNano()
 			keys := s.SSCache.Keys()
 			for _, ss := range services {
@@ -66,7 +72,7 @@ func (s SwarmServicePoller) Run(
 				if s.IncludeNodeInfo {
 					nodeInfo, err := s.SSClient.GetNodeInfo(ctx, ss)
 					if err != nil {
-						s.Log.Printf("ERROR: GetServicesParameters, %v", err)
+						s.Log.Error("Unable to get node info", "service_id", ss.ID, "service_name", ss.Spec.Name, "error", err)
 					} else {
 						ss.NodeInfo = nodeInfo
 					}
@@ -2,13 +2,14 @@ package service
 
 import (
 	"bytes"
-	"log"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 )
 
 type ServicePollerTestSuite struct {
@@ -18,7 +19,7 @@ type ServicePollerTestSuite struct {
 	MinifyFunc   func(ss SwarmService) SwarmServiceMini
 
 	SSPoller *SwarmServicePoller
-	Logger   *log.Logger
+	Logger   *logging.Logger
 	LogBytes *bytes.Buffer
 }
 
@@ -34,7 +35,7 @@ func (s *ServicePollerTestSuite) SetupTest() {
 		return MinifySwarmService(ss, "com.df.notify", "com.df.scrapeNetwork")
 	}
 	s.LogBytes = new(bytes.Buffer)
-	s.Logger = log.New(s.LogBytes, "", 0)
+	s.Logger = logging.New(s.LogBytes, logging.FormatLogfmt, logging.LevelDebug)
 
 	s.SSPoller = NewSwarmServicePoller(
 		s.SSClientMock,
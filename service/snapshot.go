@@ -0,0 +1,180 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+)
+
+// cacheSnapshotVersion is the version of the format of snapshot files
+// Files of other versions are not restored.
+const cacheSnapshotVersion = 1
+
+// cacheSnapshot is the content of the caches at a point in time
+type cacheSnapshot struct {
+	Version  int               `json:"version"`
+	Time     time.Time         `json:"time"`
+	Services []snapshotService `json:"services"`
+	Nodes    []NodeMini        `json:"nodes"`
+}
+
+// snapshotService is a cached service
+// A nil `NodeInfo` is encoded like an empty one, but they are not equal,
+// so whether it is nil is stored as well.
+type snapshotService struct {
+	SwarmServiceMini
+	HasNodeInfo bool
+}
+
+// CacheSnapshotter stores the caches of services and nodes in a file when
+// they change and restores them from it
+// A nil cache is neither stored nor restored.
+type CacheSnapshotter struct {
+	Filename  string
+	Interval  time.Duration
+	SSCache   SwarmServiceCacher
+	NodeCache NodeCacher
+	Log       *logging.Logger
+	// writtenAt is the latest update of the caches that is stored
+	writtenAt time.Time
+}
+
+// NewCacheSnapshotter creates a `CacheSnapshotter` that checks the caches
+// for changes every `interval`
+func NewCacheSnapshotter(
+	filename string, interval time.Duration,
+	ssCache SwarmServiceCacher, nodeCache NodeCacher, log *logging.Logger) *CacheSnapshotter {
+	return &CacheSnapshotter{
+		Filename:  filename,
+		Interval:  interval,
+		SSCache:   ssCache,
+		NodeCache: nodeCache,
+		Log:       log,
+	}
+}
+
+// Restore inserts the services and nodes of the snapshot file into the
+// caches. It returns false when there is no snapshot file.
+func (s *CacheSnapshotter) Restore() (bool, error) {
+	content, err := ioutil.ReadFile(s.Filename)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("Unable to read cache snapshot: %v", err)
+	}
+	var snapshot cacheSnapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return false, fmt.Errorf("Unable to parse cache snapshot %s: %v", s.Filename, err)
+	}
+	if snapshot.Version != cacheSnapshotVersion {
+		return false, fmt.Errorf("Unable to restore cache snapshot %s: unknown version %d",
+			s.Filename, snapshot.Version)
+	}
+
+	if s.SSCache != nil {
+		for _, ss := range snapshot.Services {
+			ssm := ss.SwarmServiceMini
+			if !ss.HasNodeInfo {
+				ssm.NodeInfo = nil
+			}
+			s.SSCache.InsertAndCheck(ssm)
+		}
+	}
+	if s.NodeCache != nil {
+		for _, nm := range snapshot.Nodes {
+			s.NodeCache.InsertAndCheck(nm)
+		}
+	}
+	s.writtenAt = s.updatedAt()
+	s.Log.Info("Restored cache snapshot", "file", s.Filename, "snapshot_time", snapshot.Time,
+		"services", len(snapshot.Services), "nodes", len(snapshot.Nodes))
+	return true, nil
+}
+
+// Run writes the snapshot file whenever the caches changed since it was
+// last written
+func (s *CacheSnapshotter) Run() {
+	for {
+		time.Sleep(s.Interval)
+		if !s.updatedAt().After(s.writtenAt) {
+			continue
+		}
+		if err := s.Write(); err != nil {
+			s.Log.Error("Unable to write cache snapshot", "file", s.Filename, "error", err)
+		}
+	}
+}
+
+// Write stores the caches in the snapshot file
+// The file is replaced atomically, so that it is never read half written.
+func (s *CacheSnapshotter) Write() error {
+	updatedAt := s.updatedAt()
+	snapshot := cacheSnapshot{
+		Version:  cacheSnapshotVersion,
+		Time:     time.Now().UTC(),
+		Services: []snapshotService{},
+		Nodes:    []NodeMini{},
+	}
+	if s.SSCache != nil {
+		for _, ID := range sortedKeys(s.SSCache.Keys()) {
+			if ssm, ok := s.SSCache.Get(ID); ok {
+				snapshot.Services = append(snapshot.Services,
+					snapshotService{SwarmServiceMini: ssm, HasNodeInfo: ssm.NodeInfo != nil})
+			}
+		}
+	}
+	if s.NodeCache != nil {
+		for _, ID := range sortedKeys(s.NodeCache.Keys()) {
+			if nm, ok := s.NodeCache.Get(ID); ok {
+				snapshot.Nodes = append(snapshot.Nodes, nm)
+			}
+		}
+	}
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.Filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Unable to create cache snapshot directory: %v", err)
+	}
+	file, err := ioutil.TempFile(dir, filepath.Base(s.Filename)+".tmp")
+	if err != nil {
+		return fmt.Errorf("Unable to write cache snapshot: %v", err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return fmt.Errorf("Unable to write cache snapshot: %v", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("Unable to write cache snapshot: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("Unable to write cache snapshot: %v", err)
+	}
+	if err := os.Rename(file.Name(), s.Filename); err != nil {
+		return fmt.Errorf("Unable to write cache snapshot: %v", err)
+	}
+	s.writtenAt = updatedAt
+	return nil
+}
+
+// updatedAt returns the time the caches were last updated
+func (s *CacheSnapshotter) updatedAt() time.Time {
+	updatedAt := time.Time{}
+	if s.SSCache != nil {
+		updatedAt = s.SSCache.UpdatedAt()
+	}
+	if s.NodeCache != nil && s.NodeCache.UpdatedAt().After(updatedAt) {
+		updatedAt = s.NodeCache.UpdatedAt()
+	}
+	return updatedAt
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/suite"
+)
+
+type CacheSnapshotterTestSuite struct {
+	suite.Suite
+	tempDir  string
+	filename string
+}
+
+func TestCacheSnapshotterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheSnapshotterTestSuite))
+}
+
+func (s *CacheSnapshotterTestSuite) SetupTest() {
+	tempDir, err := ioutil.TempDir("", "dfsl-snapshot")
+	s.Require().NoError(err)
+	s.tempDir = tempDir
+	s.filename = filepath.Join(tempDir, "snapshot", "caches.json")
+}
+
+func (s *CacheSnapshotterTestSuite) TearDownTest() {
+	os.RemoveAll(s.tempDir)
+}
+
+func (s *CacheSnapshotterTestSuite) Test_Restore_FillsCachesWithWrittenSnapshot() {
+	nodeInfo := NodeIPSet{}
+	nodeInfo.Add("node1", "10.0.0.1", "nodeID1")
+	ssm := SwarmServiceMini{
+		ID:             "serviceID1",
+		Name:           "shop_web",
+		Labels:         map[string]string{"com.df.notify": "true"},
+		Replicas:       2,
+		ContainerImage: "nginx:1.15",
+		NodeInfo:       nodeInfo,
+	}
+	nm := NodeMini{
+		ID:           "nodeID1",
+		Hostname:     "node1",
+		VersionIndex: 12,
+		State:        swarm.NodeStateReady,
+		Addr:         "10.0.0.1",
+		NodeLabels:   map[string]string{"zone": "a"},
+		EngineLabels: map[string]string{},
+		Role:         swarm.NodeRoleManager,
+		Availability: swarm.NodeAvailabilityActive,
+	}
+	ssCache := NewSwarmServiceCache()
+	ssCache.InsertAndCheck(ssm)
+	nodeCache := NewNodeCache()
+	nodeCache.InsertAndCheck(nm)
+	s.Require().NoError(NewCacheSnapshotter(s.filename, time.Second, ssCache, nodeCache, nil).Write())
+
+	restoredSSCache := NewSwarmServiceCache()
+	restoredNodeCache := NewNodeCache()
+	snapshotter := NewCacheSnapshotter(s.filename, time.Second, restoredSSCache, restoredNodeCache, nil)
+	restored, err := snapshotter.Restore()
+
+	s.Require().NoError(err)
+	s.True(restored)
+	s.False(restoredSSCache.IsNewOrUpdated(ssm))
+	s.False(restoredNodeCache.IsNewOrUpdated(nm))
+	s.Equal(1, restoredSSCache.Len())
+	s.False(snapshotter.updatedAt().After(snapshotter.writtenAt))
+}
+
+func (s *CacheSnapshotterTestSuite) Test_Restore_SkipsNilCaches() {
+	ssCache := NewSwarmServiceCache()
+	ssCache.InsertAndCheck(SwarmServiceMini{ID: "serviceID1", Name: "web"})
+	nodeCache := NewNodeCache()
+	nodeCache.InsertAndCheck(NodeMini{ID: "nodeID1", Hostname: "node1"})
+	s.Require().NoError(NewCacheSnapshotter(s.filename, time.Second, nil, nodeCache, nil).Write())
+
+	restoredNodeCache := NewNodeCache()
+	restored, err := NewCacheSnapshotter(s.filename, time.Second, ssCache, restoredNodeCache, nil).Restore()
+
+	s.Require().NoError(err)
+	s.True(restored)
+	s.Equal(map[string]struct{}{"serviceID1": {}}, ssCache.Keys())
+	s.Equal(map[string]struct{}{"nodeID1": {}}, restoredNodeCache.Keys())
+}
+
+func (s *CacheSnapshotterTestSuite) Test_Restore_ReturnsFalse_WhenThereIsNoSnapshot() {
+	ssCache := NewSwarmServiceCache()
+
+	restored, err := NewCacheSnapshotter(s.filename, time.Second, ssCache, nil, nil).Restore()
+
+	s.NoError(err)
+	s.False(restored)
+	s.True(ssCache.UpdatedAt().IsZero())
+}
+
+func (s *CacheSnapshotterTestSuite) Test_Restore_ReturnsError_WhenSnapshotIsInvalid() {
+	s.Require().NoError(os.MkdirAll(filepath.Dir(s.filename), 0755))
+	for _, content := range []string{`{"version": 1, "services": [`, `{"version": 2, "services": []}`} {
+		s.Require().NoError(ioutil.WriteFile(s.filename, []byte(content), 0600))
+		ssCache := NewSwarmServiceCache()
+
+		restored, err := NewCacheSnapshotter(s.filename, time.Second, ssCache, nil, nil).Restore()
+
+		s.Error(err, content)
+		s.False(restored)
+		s.Equal(0, ssCache.Len())
+	}
+}
+
+func (s *CacheSnapshotterTestSuite) Test_Write_ReplacesSnapshot() {
+	ssCache := NewSwarmServiceCache()
+	ssCache.InsertAndCheck(SwarmServiceMini{ID: "serviceID1", Name: "web"})
+	snapshotter := NewCacheSnapshotter(s.filename, time.Second, ssCache, nil, nil)
+	s.Require().NoError(snapshotter.Write())
+	ssCache.Delete("serviceID1")
+	ssCache.InsertAndCheck(SwarmServiceMini{ID: "serviceID2", Name: "api"})
+	s.Require().NoError(snapshotter.Write())
+
+	restoredSSCache := NewSwarmServiceCache()
+	_, err := NewCacheSnapshotter(s.filename, time.Second, restoredSSCache, nil, nil).Restore()
+
+	s.Require().NoError(err)
+	s.Equal(map[string]struct{}{"serviceID2": {}}, restoredSSCache.Keys())
+	s.False(restoredSSCache.IsNewOrUpdated(SwarmServiceMini{ID: "serviceID2", Name: "api"}))
+	files, err := ioutil.ReadDir(filepath.Dir(s.filename))
+	s.Require().NoError(err)
+	s.Len(files, 1)
+}
@@ -2,27 +2,61 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
 	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
+	"github.com/docker-flow/docker-flow-swarm-listener/tracing"
+	"github.com/docker/docker/client"
 )
 
 // SwarmListening provides public api for interacting with swarm listener
 type SwarmListening interface {
 	Run()
+	IsLeader() bool
 	NotifyServices(consultCache bool)
 	NotifyNodes(consultCache bool)
-	GetServicesParameters(ctx context.Context) ([]map[string]string, error)
-	GetNodesParameters(ctx context.Context) ([]map[string]string, error)
+	NotifySelectedServices(ctx context.Context, filter ServiceFilter, hosts []string) ([]DeliveryResult, error)
+	NotifySelectedNodes(ctx context.Context, filter NodeFilter, hosts []string) ([]DeliveryResult, error)
+	Resync()
+	ResolveNotifyHosts(targets []string) ([]string, error)
+	GetServicesParameters(ctx context.Context, filter ServiceFilter) ([]map[string]string, error)
+	GetNodesParameters(ctx context.Context, filter NodeFilter) ([]map[string]string, error)
+	GetCachedServicesParameters(filter ServiceFilter) ([]map[string]string, time.Time, bool)
+	GetCachedNodesParameters(filter NodeFilter) ([]map[string]string, time.Time, bool)
+	GetServices(ctx context.Context, filter ServiceFilter) ([]SwarmServiceMini, error)
+	GetNodes(ctx context.Context, filter NodeFilter) ([]NodeMini, error)
+	GetCachedServices(filter ServiceFilter) ([]SwarmServiceMini, time.Time, bool)
+	GetCachedNodes(filter NodeFilter) ([]NodeMini, time.Time, bool)
+	UpdateNotifyEndpoints(c *config.Config) error
+	GetQueuedNotifications() map[string][]QueueEntry
+	GetNotificationHistory(filter AuditFilter) ([]AuditRecord, error)
+	GetCircuitBreakers() map[string]CircuitBreakerStatus
+	SubscribeEvents(lastEventID string) *EventSubscription
+	GetEventSnapshot() []StreamEvent
+	CheckHealth(ctx context.Context) Health
+	EvictCachedService(nameOrID string) (SwarmServiceMini, bool)
+	EvictCachedNode(nameOrID string) (NodeMini, bool)
+	FlushServiceCache() int
+	FlushNodeCache() int
+	DiffService(ctx context.Context, nameOrID string) (ServiceDiff, error)
 }
 
+// ErrServicesNotCached is returned when services are not cached because
+// no service listeners are configured
+var ErrServicesNotCached = errors.New("Services are not cached")
+
+// ErrServiceNotFound is returned when a service is neither cached nor in
+// Docker
+var ErrServiceNotFound = errors.New("Service was not found")
+
 // SwarmListener provides public api
 type SwarmListener struct {
 	SSListener SwarmServiceListening
@@ -44,6 +78,9 @@ type SwarmListener struct {
 	NodeInteralEventChan chan Event
 
 	NotifyDistributor NotifyDistributing
+	EventStream       *EventStream
+	DockerClient      DockerPinging
+	Health            *HealthRecorder
 
 	ServiceCancelManager           CancelManaging
 	NodeCancelManager              CancelManaging
@@ -55,10 +92,20 @@ type SwarmListener struct {
 	IncludeKey                     string
 	HasServiceListeners            bool
 	HasNodeListeners               bool
-	Log                            *log.Logger
+	Log                            *logging.Logger
 
 	StopServiceEventChan chan struct{}
 	StopNodeEventChan    chan struct{}
+
+	// Elector is set when replicas elect a leader
+	Elector *LeaderElector
+	// CacheRefreshInterval is the time between refreshes of the caches
+	// while following
+	CacheRefreshInterval time.Duration
+	stopRefresh          chan struct{}
+
+	// Snapshotter is set when the caches are stored in a file
+	Snapshotter *CacheSnapshotter
 }
 
 func newSwarmListener(
@@ -91,7 +138,7 @@ func newSwarmListener(
 	includeKey string,
 	hasServiceListeners bool,
 	hasNodeListeners bool,
-	logger *log.Logger,
+	logger *logging.Logger,
 	stopServiceEventChan chan struct{},
 	stopNodeEventChan chan struct{},
 ) *SwarmListener {
@@ -112,6 +159,8 @@ func newSwarmListener(
 		NodeInteralEventChan: nodeInternalEventChan,
 		NodeNotificationChan: nodeNotificationChan,
 		NotifyDistributor:    notifyDistributor,
+		EventStream:          NewEventStream(eventStreamBufferSize),
+		Health:               NewHealthRecorder(),
 		ServiceCancelManager: serviceCancelManager,
 		NodeCancelManager:    nodeCancelManager,
 
@@ -129,51 +178,22 @@ func newSwarmListener(
 	}
 }
 
-// NewSwarmListenerFromEnv creats `SwarmListener` from environment variables
-func NewSwarmListenerFromEnv(
-	retries, interval, servicePollingInterval,
-	nodePollingInterval int, logger *log.Logger) (*SwarmListener, error) {
-	ignoreKey := os.Getenv("DF_NOTIFY_LABEL")
-	includeNodeInfo, err := strconv.ParseBool(os.Getenv("DF_INCLUDE_NODE_IP_INFO"))
-	if err != nil {
-		includeNodeInfo = false
-	}
-	nodeIPInfoIncludesTaskAddress, err := strconv.ParseBool(os.Getenv("DF_NODE_IP_INFO_INCLUDES_TASK_ADDRESS"))
-	if err != nil {
-		nodeIPInfoIncludesTaskAddress = true
-	}
-	useDockerServiceEvents, err := strconv.ParseBool(os.Getenv("DF_USE_DOCKER_SERVICE_EVENTS"))
-	if err != nil {
-		useDockerServiceEvents = false
-	}
-	useDockerNodeEvents, err := strconv.ParseBool(os.Getenv("DF_USE_DOCKER_NODE_EVENTS"))
-	if err != nil {
-		useDockerNodeEvents = false
-	}
-	notifyCreateServiceImmediately, err := strconv.ParseBool(os.Getenv("DF_NOTIFY_CREATE_SERVICE_IMMEDIATELY"))
+// NewSwarmListenerFromConfig creates `SwarmListener` from `Config`
+func NewSwarmListenerFromConfig(c *config.Config, logger *logging.Logger) (*SwarmListener, error) {
+	ignoreKey := c.NotifyLabel
+
+	dockerClient, err := NewDockerClient(c.DockerHost)
 	if err != nil {
-		notifyCreateServiceImmediately = false
+		return nil, err
 	}
 
-	serviceNamePrefix := os.Getenv("DF_SERVICE_NAME_PREFIX")
+	logger.Info("Using Docker Client", "api_version", dockerClient.ClientVersion())
 
-	dockerClient, err := NewDockerClientFromEnv()
+	notifyDistributor, err := NewNotifyDistributorFromConfig(c, logger)
 	if err != nil {
 		return nil, err
 	}
 
-	logger.Printf("Using Docker Client API version: %s", dockerClient.ClientVersion())
-
-	extraCreateServiceAddr := readStringFromFile("/run/secrets/df_notify_create_service_url")
-	extraRemoveServiceAddr := readStringFromFile("/run/secrets/df_notify_remove_service_url")
-	extraCreateNodeAddr := readStringFromFile("/run/secrets/df_notify_create_node_url")
-	extraRemoveNodeAddr := readStringFromFile("/run/secrets/df_notify_remove_node_url")
-
-	notifyDistributor := NewNotifyDistributorFromEnv(
-		retries, interval,
-		extraCreateServiceAddr, extraRemoveServiceAddr,
-		extraCreateNodeAddr, extraRemoveNodeAddr, logger)
-
 	var ssListener *SwarmServiceListener
 	var ssCache *SwarmServiceCache
 	var ssEventChan chan Event
@@ -189,12 +209,13 @@ func NewSwarmListenerFromEnv(
 	var nodeStopEventChan chan struct{}
 
 	ssClient := NewSwarmServiceClient(
-		dockerClient, ignoreKey, "com.df.scrapeNetwork", serviceNamePrefix, nodeIPInfoIncludesTaskAddress, logger)
+		dockerClient, ignoreKey, "com.df.scrapeNetwork", c.ServiceNamePrefix, c.NodeIPInfoIncludesTaskAddress, logger)
 	nodeClient := NewNodeClient(dockerClient)
 
 	nodeInfraCreated := false
 
-	hasServiceListeners := notifyDistributor.HasServiceListeners()
+	// Services are listened to without endpoints for the event stream
+	hasServiceListeners := notifyDistributor.HasServiceListeners() || c.ListenWithoutEndpoints
 	if hasServiceListeners {
 		ssListener = NewSwarmServiceListener(dockerClient, logger)
 		ssCache = NewSwarmServiceCache()
@@ -225,14 +246,24 @@ func NewSwarmListenerFromEnv(
 	}
 
 	ssPoller := NewSwarmServicePoller(
-		ssClient, ssCache, servicePollingInterval, includeNodeInfo,
+		ssClient, ssCache, c.ServicePollingInterval, c.IncludeNodeIPInfo,
 		func(ss SwarmService) SwarmServiceMini {
 			return MinifySwarmService(ss, ignoreKey, "com.docker.stack.namespace")
 		}, logger)
 	nodePoller := NewNodePoller(
-		nodeClient, nodeCache, nodePollingInterval, MinifyNode, logger)
+		nodeClient, nodeCache, c.NodePollingInterval, MinifyNode, logger)
+
+	health := NewHealthRecorder()
+	if ssListener != nil {
+		ssListener.Health = health
+	}
+	if nodeListener != nil {
+		nodeListener.Health = health
+	}
+	ssPoller.Health = health
+	nodePoller.Health = health
 
-	return newSwarmListener(
+	l := newSwarmListener(
 		ssListener,
 		ssClient,
 		ssCache,
@@ -250,10 +281,10 @@ func NewSwarmListenerFromEnv(
 		notifyDistributor,
 		NewCancelManager(),
 		NewCancelManager(),
-		includeNodeInfo,
-		useDockerServiceEvents,
-		useDockerNodeEvents,
-		notifyCreateServiceImmediately,
+		c.IncludeNodeIPInfo,
+		c.UseDockerServiceEvents,
+		c.UseDockerNodeEvents,
+		c.NotifyCreateServiceImmediately,
 		ignoreKey,
 		"com.docker.stack.namespace",
 		hasServiceListeners,
@@ -261,32 +292,64 @@ func NewSwarmListenerFromEnv(
 		logger,
 		ssStopEventChan,
 		nodeStopEventChan,
-	), nil
-
+	)
+	l.DockerClient = dockerClient
+	l.Health = health
+	if c.LeaderElection.IsEnabled() {
+		id := c.LeaderElection.ID
+		if len(id) == 0 {
+			if id, err = os.Hostname(); err != nil {
+				return nil, fmt.Errorf("Unable to identify the replica: %v", err)
+			}
+		}
+		l.Elector = NewLeaderElector(
+			NewDockerConfigLock(dockerClient, c.LeaderElection.Lock()), id,
+			c.LeaderElection.LockTTL(), c.LeaderElection.LockRenewInterval(), logger)
+		l.CacheRefreshInterval = c.LeaderElection.CacheRefresh()
+	}
+	if c.CacheSnapshot.IsEnabled() {
+		// Caches that are not used stay nil, so that they are not stored
+		var ssCacher SwarmServiceCacher
+		var nodeCacher NodeCacher
+		if ssCache != nil {
+			ssCacher = ssCache
+		}
+		if nodeCache != nil {
+			nodeCacher = nodeCache
+		}
+		l.Snapshotter = NewCacheSnapshotter(
+			c.CacheSnapshot.File, c.CacheSnapshot.WriteInterval(), ssCacher, nodeCacher, logger)
+	}
+	return l, nil
 }
 
 // Run starts swarm listener
 func (l *SwarmListener) Run() {
+	l.startEventChannels()
+	l.lead()
+}
+
+// lead starts processing events, the pollers and the notification
+// distributor
+func (l *SwarmListener) lead() {
 
 	if l.HasServiceListeners {
-		l.connectServiceEventChannels()
 		l.connectInternalServiceChannels()
 
 		if l.UseDockerServiceEvents {
 			l.SSListener.ListenForServiceEvents(l.SSEventChan)
-			l.Log.Printf("Listening to Docker Service Events")
+			l.Log.Info("Listening to Docker Service Events")
 		}
 
 		go l.SSPoller.Run(l.SSEventChan)
 	}
 
 	if l.HasServiceListeners || l.HasNodeListeners {
-		l.connectNodeEventChannels()
 		l.connectInternalNodeChannels()
 
 		if l.UseDockerNodeEvents {
 			l.NodeListener.ListenForNodeEvents(l.NodeEventChan)
-			l.Log.Printf("Listening to Docker Node Events")
+			l.Log.Info("Listening to Docker Node Events")
 		}
 
 		go l.NodePoller.Run(l.NodeEventChan)
@@ -295,6 +358,153 @@ func (l *SwarmListener) Run() {
 	l.NotifyDistributor.Run(l.SSNotificationChan, l.NodeNotificationChan)
 }
 
+// Follow keeps the caches warm without sending notifications, as a
+// follower does while another replica is the leader. The caches are
+// refreshed every `CacheRefreshInterval` until `Lead` is called.
+func (l *SwarmListener) Follow() {
+	l.startEventChannels()
+	l.stopRefresh = make(chan struct{})
+	go func(stop <-chan struct{}) {
+		ticker := time.NewTicker(l.CacheRefreshInterval)
+		defer ticker.Stop()
+		for {
+			l.refreshCaches(context.Background())
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}(l.stopRefresh)
+}
+
+// Lead stops following and starts the work of the leader. Services and
+// nodes that differ from the caches are notified, as they are when
+// polling.
+func (l *SwarmListener) Lead() {
+	if l.stopRefresh != nil {
+		close(l.stopRefresh)
+		l.stopRefresh = nil
+	}
+	l.lead()
+	go l.NotifyChanges()
+}
+
+// IsLeader returns true when the listener sends notifications
+// It is always true without leader election.
+func (l SwarmListener) IsLeader() bool {
+	return l.Elector == nil || l.Elector.IsLeader()
+}
+
+// refreshCaches replaces the cached services and nodes with the ones
+// running in Docker
+func (l SwarmListener) refreshCaches(ctx context.Context) {
+	if l.HasServiceListeners {
+		if services, err := l.SSClient.SwarmServiceList(ctx); err != nil {
+			l.Log.Error("Unable to list services to cache", "error", err)
+		} else {
+			keys := l.SSCache.Keys()
+			for _, ss := range services {
+				running, err := l.SSClient.SwarmServiceRunning(ctx, ss.ID)
+				if err != nil || !running {
+					continue
+				}
+				delete(keys, ss.ID)
+				if l.IncludeNodeInfo {
+					nodeInfo, err := l.SSClient.GetNodeInfo(ctx, ss)
+					if err != nil {
+						l.Log.Error("Unable to get node info",
+							"service_id", ss.ID, "service_name", ss.Spec.Name, "error", err)
+					} else {
+						ss.NodeInfo = nodeInfo
+					}
+				}
+				l.SSCache.InsertAndCheck(MinifySwarmService(ss, l.IgnoreKey, l.IncludeKey))
+			}
+			for ID := range keys {
+				l.SSCache.Delete(ID)
+			}
+			metrics.RecordService(l.SSCache.Len())
+		}
+	}
+
+	if l.HasServiceListeners || l.HasNodeListeners {
+		if nodes, err := l.NodeClient.NodeList(ctx); err != nil {
+			l.Log.Error("Unable to list nodes to cache", "error", err)
+		} else {
+			keys := l.NodeCache.Keys()
+			for _, n := range nodes {
+				delete(keys, n.ID)
+				l.NodeCache.InsertAndCheck(MinifyNode(n))
+			}
+			for ID := range keys {
+				l.NodeCache.Delete(ID)
+			}
+			metrics.RecordNode(len(nodes))
+		}
+	}
+}
+
+// RestoreCaches fills the caches from the snapshot file and starts
+// storing them when they change. It returns true when the caches were
+// restored.
+func (l *SwarmListener) RestoreCaches() bool {
+	if l.Snapshotter == nil {
+		return false
+	}
+	restored, err := l.Snapshotter.Restore()
+	if err != nil {
+		l.Log.Error("Unable to restore the caches", "error", err)
+	}
+	if restored && l.HasServiceListeners {
+		metrics.RecordService(l.SSCache.Len())
+	}
+	go l.Snapshotter.Run()
+	return restored
+}
+
+// NotifyChanges places create events for all services and nodes and
+// remove events for the cached ones that no longer exist. The events
+// consult the caches, so only changes are notified.
+func (l *SwarmListener) NotifyChanges() {
+	ctx := context.Background()
+	nowTimeNano := time.Now().UTC().UnixNano()
+
+	if l.HasServiceListeners {
+		if services, err := l.SSClient.SwarmServiceList(ctx); err != nil {
+			l.Log.Error("Unable to list services to notify", "error", err)
+		} else {
+			keys := l.SSCache.Keys()
+			for _, ss := range services {
+				delete(keys, ss.ID)
+				l.placeOnEventChan(l.SSEventChan, EventTypeCreate, ss.ID, nowTimeNano, true)
+			}
+			for ID := range keys {
+				l.placeOnEventChan(l.SSEventChan, EventTypeRemove, ID, nowTimeNano, true)
+			}
+		}
+	}
+
+	if !l.HasNodeListeners {
+		if l.HasServiceListeners {
+			l.cacheNodes()
+		}
+		return
+	}
+	if nodes, err := l.NodeClient.NodeList(ctx); err != nil {
+		l.Log.Error("Unable to list nodes to notify", "error", err)
+	} else {
+		keys := l.NodeCache.Keys()
+		for _, n := range nodes {
+			delete(keys, n.ID)
+			l.placeOnEventChan(l.NodeEventChan, EventTypeCreate, n.ID, nowTimeNano, true)
+		}
+		for ID := range keys {
+			l.placeOnEventChan(l.NodeEventChan, EventTypeRemove, ID, nowTimeNano, true)
+		}
+	}
+}
+
 func (l *SwarmListener) stopEventChannels() {
 	if l.HasServiceListeners {
 		l.StopServiceEventChan <- struct{}{}
@@ -365,39 +575,55 @@ func (l *SwarmListener) connectInternalNodeChannels() {
 }
 
 func (l *SwarmListener) processServiceEventCreate(event Event) {
-	ctx := l.ServiceCancelManager.Add(context.Background(), event.ID, event.TimeNano)
+	spanCtx, span := startEventSpan(QueueKindService, event)
+	ctx := l.ServiceCancelManager.Add(spanCtx, event.ID, event.TimeNano)
 	defer l.ServiceCancelManager.Delete(event.ID, event.TimeNano)
 
+	log := eventLogger(l.Log, QueueKindService, event)
+	log.Debug("Processing service event")
+	// name is set before the result is sent on `errChan`
+	var name string
 	errChan := make(chan error)
 
 	go func() {
-		service, err := l.SSClient.SwarmServiceInspect(ctx, event.ID)
+		inspectCtx, inspectSpan := tracing.Start(ctx, "docker.inspect")
+		service, err := l.SSClient.SwarmServiceInspect(inspectCtx, event.ID)
+		endSpan(inspectSpan, err, false)
 		if err != nil {
 			errChan <- err
 			return
 		}
 		// Ignored service (filtered by `com.df.notify`)
 		if service == nil {
+			span.SetAttribute("service.ignored", true)
 			errChan <- nil
 			return
 		}
+		span.SetAttribute("service.name", service.Spec.Name)
+		name = service.Spec.Name
 
 		if l.NotifyCreateServiceImmediately {
 			ssm := MinifySwarmService(*service, l.IgnoreKey, l.IncludeKey)
+			_, cacheSpan := tracing.Start(ctx, "cache.check")
 			isUpdated := l.SSCache.InsertAndCheck(ssm)
+			cacheSpan.SetAttribute("cache.updated", isUpdated)
+			cacheSpan.End()
 			if event.ConsultCache && !isUpdated {
 				errChan <- nil
 				return
 			}
 			metrics.RecordService(l.SSCache.Len())
 			params := GetSwarmServiceMiniCreateParameters(ssm)
+			l.publishEvent(QueueKindService, event.Type, ssm.ID, event.TimeNano, params)
 			paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-			l.placeOnNotificationChan(
+			l.placeOnNotificationChan(ctx,
 				l.SSNotificationChan, event.Type, event.TimeNano, ssm.ID, paramsEncoded, errChan)
 		}
 
 		// Wait for service to converge
-		nodeInfo, err := l.SSClient.GetNodeInfo(ctx, *service)
+		convergeCtx, convergeSpan := tracing.Start(ctx, "service.converge")
+		nodeInfo, err := l.SSClient.GetNodeInfo(convergeCtx, *service)
+		endSpan(convergeSpan, err, false)
 		if err != nil {
 			errChan <- err
 			return
@@ -409,16 +635,21 @@ func (l *SwarmListener) processServiceEventCreate(event Event) {
 		ssm := MinifySwarmService(*service, l.IgnoreKey, l.IncludeKey)
 
 		// Store in cache
+		_, cacheSpan := tracing.Start(ctx, "cache.check")
 		isUpdated := l.SSCache.InsertAndCheck(ssm)
+		cacheSpan.SetAttribute("cache.updated", isUpdated)
+		cacheSpan.End()
 		if event.ConsultCache && !isUpdated {
+			log.Debug("Service did not change", "service_name", name)
 			errChan <- nil
 			return
 		}
 		metrics.RecordService(l.SSCache.Len())
 
 		params := GetSwarmServiceMiniCreateParameters(ssm)
+		l.publishEvent(QueueKindService, event.Type, ssm.ID, event.TimeNano, params)
 		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-		l.placeOnNotificationChan(
+		l.placeOnNotificationChan(ctx,
 			l.SSNotificationChan, event.Type, event.TimeNano, ssm.ID, paramsEncoded, errChan)
 	}()
 
@@ -427,32 +658,45 @@ func (l *SwarmListener) processServiceEventCreate(event Event) {
 		case err := <-errChan:
 			if err != nil {
 				if !strings.Contains(err.Error(), "context canceled") {
-					l.Log.Printf("ERROR: %v", err)
+					log.Error("Unable to process service event", "service_name", name, "error", err)
 				}
 			}
+			endSpan(span, err, false)
 			return
 		case <-ctx.Done():
+			endSpan(span, nil, true)
 			return
 		}
 	}
 }
 
 func (l *SwarmListener) processServiceEventRemove(event Event) {
-	ctx := l.ServiceCancelManager.Add(context.Background(), event.ID, event.TimeNano)
+	spanCtx, span := startEventSpan(QueueKindService, event)
+	ctx := l.ServiceCancelManager.Add(spanCtx, event.ID, event.TimeNano)
 	defer l.ServiceCancelManager.Delete(event.ID, event.TimeNano)
 
+	log := eventLogger(l.Log, QueueKindService, event)
+	log.Debug("Processing service event")
+	// name is set before the result is sent on `errChan`
+	var name string
 	errChan := make(chan error)
 
 	go func() {
 
+		_, cacheSpan := tracing.Start(ctx, "cache.check")
 		ssm, ok := l.SSCache.Get(event.ID)
+		cacheSpan.SetAttribute("cache.hit", ok)
+		cacheSpan.End()
 		if !ok {
 			errChan <- fmt.Errorf("%s not in cache", event.ID)
 			return
 		}
+		span.SetAttribute("service.name", ssm.Name)
+		name = ssm.Name
 		params := GetSwarmServiceMiniRemoveParameters(ssm)
+		l.publishEvent(QueueKindService, event.Type, ssm.ID, event.TimeNano, params)
 		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-		l.placeOnNotificationChan(
+		l.placeOnNotificationChan(ctx,
 			l.SSNotificationChan, event.Type, event.TimeNano, ssm.ID, paramsEncoded, errChan)
 	}()
 
@@ -461,49 +705,70 @@ func (l *SwarmListener) processServiceEventRemove(event Event) {
 		case err := <-errChan:
 			if err != nil {
 				if !strings.Contains(err.Error(), "not in cache") {
-					l.Log.Printf("ERROR: %v", err)
+					log.Error("Unable to process service event", "service_name", name, "error", err)
+					endSpan(span, err, false)
+				} else {
+					log.Debug("Service is not in the cache")
+					endSpan(span, nil, false)
 				}
 				return
 			}
 			l.SSCache.Delete(event.ID)
 			metrics.RecordService(l.SSCache.Len())
+			endSpan(span, nil, false)
 			return
 		case <-ctx.Done():
+			endSpan(span, nil, true)
 			return
 		}
 	}
 }
 
 func (l *SwarmListener) processNodeEventCreate(event Event) {
-	ctx := l.NodeCancelManager.Add(context.Background(), event.ID, event.TimeNano)
+	spanCtx, span := startEventSpan(QueueKindNode, event)
+	ctx := l.NodeCancelManager.Add(spanCtx, event.ID, event.TimeNano)
 	defer l.NodeCancelManager.Delete(event.ID, event.TimeNano)
 
+	log := eventLogger(l.Log, QueueKindNode, event)
+	log.Debug("Processing node event")
+	// hostname is set before the result is sent on `errChan`
+	var hostname string
 	errChan := make(chan error)
 
 	go func() {
 
+		_, inspectSpan := tracing.Start(ctx, "docker.inspect")
 		node, err := l.NodeClient.NodeInspect(event.ID)
+		endSpan(inspectSpan, err, false)
 		if err != nil {
 			errChan <- err
 			return
 		}
 		nm := MinifyNode(node)
+		span.SetAttribute("node.hostname", nm.Hostname)
+		hostname = nm.Hostname
 
 		// Store in cache
+		_, cacheSpan := tracing.Start(ctx, "cache.check")
 		isUpdated := l.NodeCache.InsertAndCheck(nm)
+		cacheSpan.SetAttribute("cache.updated", isUpdated)
+		cacheSpan.End()
 		if event.ConsultCache && !isUpdated {
+			log.Debug("Node did not change", "node_hostname", hostname)
 			errChan <- nil
 			return
 		}
+		metrics.RecordNode(len(l.NodeCache.Keys()))
 		go l.NotifyServices(false)
+		params := GetNodeMiniCreateParameters(nm)
+		l.publishEvent(QueueKindNode, event.Type, nm.ID, event.TimeNano, params)
 		if !l.HasNodeListeners {
 			errChan <- nil
 			return
 		}
 
-		params := GetNodeMiniCreateParameters(nm)
 		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-		l.placeOnNotificationChan(l.NodeNotificationChan, event.Type, event.TimeNano, nm.ID, paramsEncoded, errChan)
+		l.placeOnNotificationChan(ctx, l.NodeNotificationChan, event.Type, event.TimeNano, nm.ID, paramsEncoded, errChan)
 	}()
 
 	for {
@@ -511,37 +776,49 @@ func (l *SwarmListener) processNodeEventCreate(event Event) {
 		case err := <-errChan:
 			if err != nil {
 				if !strings.Contains(err.Error(), "context canceled") {
-					l.Log.Printf("ERROR: %v", err)
+					log.Error("Unable to process node event", "node_hostname", hostname, "error", err)
 				}
-				return
 			}
+			endSpan(span, err, false)
 			return
 		case <-ctx.Done():
+			endSpan(span, nil, true)
 			return
 		}
 	}
 }
 
 func (l *SwarmListener) processNodeEventRemove(event Event) {
-	ctx := l.NodeCancelManager.Add(context.Background(), event.ID, event.TimeNano)
+	spanCtx, span := startEventSpan(QueueKindNode, event)
+	ctx := l.NodeCancelManager.Add(spanCtx, event.ID, event.TimeNano)
 	defer l.NodeCancelManager.Delete(event.ID, event.TimeNano)
 
+	log := eventLogger(l.Log, QueueKindNode, event)
+	log.Debug("Processing node event")
+	// hostname is set before the result is sent on `errChan`
+	var hostname string
 	errChan := make(chan error)
 	go func() {
+		_, cacheSpan := tracing.Start(ctx, "cache.check")
 		nm, ok := l.NodeCache.Get(event.ID)
+		cacheSpan.SetAttribute("cache.hit", ok)
+		cacheSpan.End()
 		if !ok {
 			errChan <- fmt.Errorf("%s not in cache", event.ID)
 			return
 		}
+		span.SetAttribute("node.hostname", nm.Hostname)
+		hostname = nm.Hostname
 
 		go l.CompletelyNotifyServices()
+		params := GetNodeMiniRemoveParameters(nm)
+		l.publishEvent(QueueKindNode, event.Type, nm.ID, event.TimeNano, params)
 		if !l.HasNodeListeners {
 			errChan <- nil
 			return
 		}
-		params := GetNodeMiniRemoveParameters(nm)
 		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-		l.placeOnNotificationChan(l.NodeNotificationChan, event.Type, event.TimeNano, nm.ID, paramsEncoded, errChan)
+		l.placeOnNotificationChan(ctx, l.NodeNotificationChan, event.Type, event.TimeNano, nm.ID, paramsEncoded, errChan)
 	}()
 
 	for {
@@ -549,13 +826,20 @@ func (l *SwarmListener) processNodeEventRemove(event Event) {
 		case err := <-errChan:
 			if err != nil {
 				if !strings.Contains(err.Error(), "not in cache") {
-					l.Log.Printf("ERROR: %v", err)
+					log.Error("Unable to process node event", "node_hostname", hostname, "error", err)
+					endSpan(span, err, false)
+				} else {
+					log.Debug("Node is not in the cache")
+					endSpan(span, nil, false)
 				}
 				return
 			}
 			l.NodeCache.Delete(event.ID)
+			metrics.RecordNode(len(l.NodeCache.Keys()))
+			endSpan(span, nil, false)
 			return
 		case <-ctx.Done():
+			endSpan(span, nil, true)
 			return
 		}
 	}
@@ -573,7 +857,7 @@ func (l SwarmListener) NotifyServices(consultCache bool) {
 
 	services, err := l.SSClient.SwarmServiceList(context.Background())
 	if err != nil {
-		l.Log.Printf("ERROR: NotifyService, %v", err)
+		l.Log.Error("Unable to list services to notify", "error", err)
 		return
 	}
 
@@ -584,15 +868,19 @@ func (l SwarmListener) NotifyServices(consultCache bool) {
 }
 
 // NotifyNodes places all services on queue to notify serivces on node events
+// Without node listeners, the node cache is filled without notifications.
 func (l SwarmListener) NotifyNodes(consultCache bool) {
 
 	if !l.HasNodeListeners {
+		if l.HasServiceListeners {
+			l.cacheNodes()
+		}
 		return
 	}
 
 	nodes, err := l.NodeClient.NodeList(context.Background())
 	if err != nil {
-		l.Log.Printf("ERROR: NotifyNodes, %v", err)
+		l.Log.Error("Unable to list nodes to notify", "error", err)
 		return
 	}
 
@@ -602,16 +890,209 @@ func (l SwarmListener) NotifyNodes(consultCache bool) {
 	}
 }
 
-func (l SwarmListener) placeOnNotificationChan(notiChan chan<- Notification, eventType EventType, timeNano int64, ID string, parameters string, errorChan chan error) {
+// NotifySelectedServices sends create notifications for the running
+// services selected by `filter` to the endpoints with `hosts`, or to all
+// endpoints when `hosts` is empty. The service cache is not updated, so
+// that other endpoints are still notified of changes.
+// It waits until the notifications are delivered or `ctx` is done and
+// returns the result for each selected service.
+func (l SwarmListener) NotifySelectedServices(
+	ctx context.Context, filter ServiceFilter, hosts []string) ([]DeliveryResult, error) {
+	results := []DeliveryResult{}
+	if !l.HasServiceListeners {
+		return results, nil
+	}
+
+	services, err := l.SSClient.SwarmServiceList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nowTimeNano := time.Now().UTC().UnixNano()
+	pending := []pendingDelivery{}
+	for _, ss := range services {
+		ssm := MinifySwarmService(ss, l.IgnoreKey, l.IncludeKey)
+		if !filter.Match(ssm, l.IncludeKey) {
+			continue
+		}
+		running, err := l.SSClient.SwarmServiceRunning(ctx, ss.ID)
+		if err != nil || !running {
+			results = append(results, DeliveryResult{
+				ID:        ssm.ID,
+				Name:      GetSwarmServiceMiniCreateParameters(ssm)["serviceName"],
+				Status:    DeliveryStatusNotRunning,
+				Endpoints: []EndpointDelivery{},
+			})
+			continue
+		}
+		if l.IncludeNodeInfo {
+			nodeInfo, err := l.SSClient.GetNodeInfo(ctx, ss)
+			if err != nil {
+				l.Log.Error("Unable to get node info", "service_id", ss.ID, "service_name", ss.Spec.Name, "error", err)
+			} else {
+				ss.NodeInfo = nodeInfo
+				ssm = MinifySwarmService(ss, l.IgnoreKey, l.IncludeKey)
+			}
+		}
+		params := GetSwarmServiceMiniCreateParameters(ssm)
+		pending = append(pending, sendWithReport(ctx, l.SSNotificationChan, Notification{
+			EventType:  EventTypeCreate,
+			ID:         ssm.ID,
+			Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+			TimeNano:   nowTimeNano,
+			Hosts:      hosts,
+		}, params["serviceName"]))
+	}
+	return append(waitForDeliveries(ctx, pending), results...), nil
+}
+
+// NotifySelectedNodes sends create notifications for the nodes selected
+// by `filter` to the endpoints with `hosts`, or to all endpoints when
+// `hosts` is empty. The node cache is not updated.
+// It waits until the notifications are delivered or `ctx` is done and
+// returns the result for each selected node.
+func (l SwarmListener) NotifySelectedNodes(
+	ctx context.Context, filter NodeFilter, hosts []string) ([]DeliveryResult, error) {
+	if !l.HasNodeListeners {
+		return []DeliveryResult{}, nil
+	}
+
+	nodes, err := l.NodeClient.NodeList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nowTimeNano := time.Now().UTC().UnixNano()
+	pending := []pendingDelivery{}
+	for _, n := range nodes {
+		nm := MinifyNode(n)
+		if !filter.Match(nm) {
+			continue
+		}
+		params := GetNodeMiniCreateParameters(nm)
+		pending = append(pending, sendWithReport(ctx, l.NodeNotificationChan, Notification{
+			EventType:  EventTypeCreate,
+			ID:         nm.ID,
+			Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+			TimeNano:   nowTimeNano,
+			Hosts:      hosts,
+		}, nm.Hostname))
+	}
+	return waitForDeliveries(ctx, pending), nil
+}
+
+// Resync sends out notifications for all services and nodes as it is done
+// on startup
+func (l *SwarmListener) Resync() {
+	go l.CompletelyNotifyServices()
+	l.NotifyNodes(false)
+}
+
+// pendingDelivery is a notification sent with a report of its delivery
+type pendingDelivery struct {
+	notification Notification
+	name         string
+	sent         bool
+}
+
+// sendWithReport sends `n` to `notificationChan` with a report of its
+// delivery. `n` is not sent when `ctx` is done first.
+func sendWithReport(
+	ctx context.Context, notificationChan chan<- Notification, n Notification, name string) pendingDelivery {
+	n.Report = &DeliveryReport{}
+	n.ErrorChan = make(chan error, 1)
+	p := pendingDelivery{notification: n, name: name}
+	select {
+	case notificationChan <- n:
+		p.sent = true
+	case <-ctx.Done():
+	}
+	return p
+}
+
+// waitForDeliveries waits until the `pending` notifications are delivered
+// or `ctx` is done. Notifications that are not delivered in time are
+// reported as pending.
+func waitForDeliveries(ctx context.Context, pending []pendingDelivery) []DeliveryResult {
+	results := []DeliveryResult{}
+	for _, p := range pending {
+		result := DeliveryResult{
+			ID:     p.notification.ID,
+			Name:   p.name,
+			Status: DeliveryStatusPending,
+		}
+		if p.sent {
+			select {
+			case <-p.notification.ErrorChan:
+				result.Status = p.notification.Report.Status()
+			case <-ctx.Done():
+			}
+		}
+		result.Endpoints = p.notification.Report.Deliveries()
+		results = append(results, result)
+	}
+	return results
+}
+
+// ResolveNotifyHosts returns the hosts of the endpoints matching `targets`
+// A target matches an endpoint host with or without its port.
+func (l SwarmListener) ResolveNotifyHosts(targets []string) ([]string, error) {
+	endpointHosts := l.NotifyDistributor.Hosts()
+	hosts := []string{}
+	resolved := map[string]struct{}{}
+	for _, target := range targets {
+		matched := false
+		for _, host := range endpointHosts {
+			if !matchEndpointHost(host, target) {
+				continue
+			}
+			matched = true
+			if _, ok := resolved[host]; !ok {
+				resolved[host] = struct{}{}
+				hosts = append(hosts, host)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("%s does not match a notification endpoint", target)
+		}
+	}
+	return hosts, nil
+}
+
+// cacheNodes inserts all nodes into the node cache
+func (l SwarmListener) cacheNodes() {
+	nodes, err := l.NodeClient.NodeList(context.Background())
+	if err != nil {
+		l.Log.Error("Unable to list nodes to cache", "error", err)
+		return
+	}
+	for _, n := range nodes {
+		l.NodeCache.InsertAndCheck(MinifyNode(n))
+	}
+	metrics.RecordNode(len(nodes))
+}
+
+func (l SwarmListener) placeOnNotificationChan(ctx context.Context, notiChan chan<- Notification, eventType EventType, timeNano int64, ID string, parameters string, errorChan chan error) {
 	notiChan <- Notification{
-		EventType:  eventType,
-		ID:         ID,
-		Parameters: parameters,
-		TimeNano:   timeNano,
-		ErrorChan:  errorChan,
+		EventType:   eventType,
+		ID:          ID,
+		Parameters:  parameters,
+		TimeNano:    timeNano,
+		ErrorChan:   errorChan,
+		SpanContext: tracing.SpanContextFromContext(ctx),
 	}
 }
 
+// eventLogger returns a logger of the work done for `event` of a service or
+// node. The time of the event is the request id, as it is for canceling.
+func eventLogger(logger *logging.Logger, kind string, event Event) *logging.Logger {
+	return logger.With(
+		kind+"_id", event.ID,
+		"event_type", event.Type,
+		"request_id", event.TimeNano,
+	)
+}
+
 func (l SwarmListener) placeOnEventChan(eventChan chan<- Event, eventType EventType, ID string, timeNano int64, consultCache bool) {
 	eventChan <- Event{
 		Type:         eventType,
@@ -621,13 +1102,156 @@ func (l SwarmListener) placeOnEventChan(eventChan chan<- Event, eventType EventT
 	}
 }
 
+// UpdateNotifyEndpoints replaces the notification endpoints with the ones in `c`
+// Endpoints that were added receive create notifications for all cached
+// services and nodes. Only endpoints are reloaded, other settings require
+// a restart.
+func (l *SwarmListener) UpdateNotifyEndpoints(c *config.Config) error {
+	notifyEndpoints, err := newNotifyEndpoints(c, l.Log)
+	if err != nil {
+		return err
+	}
+
+	for host, endpoint := range notifyEndpoints {
+		if endpoint.ServiceNotifier != nil && !l.HasServiceListeners {
+			return fmt.Errorf(
+				"%s: service notifications were not enabled on startup, a restart is required", host)
+		}
+		if endpoint.NodeNotifier != nil && !l.HasNodeListeners {
+			return fmt.Errorf(
+				"%s: node notifications were not enabled on startup, a restart is required", host)
+		}
+	}
+
+	added := l.NotifyDistributor.UpdateEndpoints(notifyEndpoints)
+	if len(added) == 0 {
+		return nil
+	}
+
+	go l.notifyHostsFromCache(added)
+	return nil
+}
+
+// GetCircuitBreakers returns the status of the circuit breakers of
+// notification endpoints
+func (l *SwarmListener) GetCircuitBreakers() map[string]CircuitBreakerStatus {
+	return l.NotifyDistributor.CircuitBreakers()
+}
+
+// GetQueuedNotifications returns the notifications waiting in durable
+// queues keyed by endpoint host
+func (l *SwarmListener) GetQueuedNotifications() map[string][]QueueEntry {
+	return l.NotifyDistributor.QueuedNotifications()
+}
+
+// GetNotificationHistory returns the audited deliveries of notifications
+// selected by `filter`, newest first
+func (l *SwarmListener) GetNotificationHistory(filter AuditFilter) ([]AuditRecord, error) {
+	return l.NotifyDistributor.NotificationHistory(filter)
+}
+
+// publishEvent sends a change to the subscribers of the event stream
+func (l *SwarmListener) publishEvent(
+	kind string, eventType EventType, ID string, timeNano int64, params map[string]string) {
+	if l.EventStream == nil {
+		return
+	}
+	l.EventStream.Publish(StreamEvent{
+		Kind:       kind,
+		EventType:  eventType,
+		ID:         ID,
+		Parameters: params,
+		TimeNano:   timeNano,
+	})
+}
+
+// SubscribeEvents subscribes to service and node changes
+// Events after `lastEventID` are resumed when they are still buffered.
+func (l *SwarmListener) SubscribeEvents(lastEventID string) *EventSubscription {
+	return l.EventStream.Subscribe(lastEventID)
+}
+
+// GetEventSnapshot returns create events for all cached services and nodes
+func (l *SwarmListener) GetEventSnapshot() []StreamEvent {
+	events := []StreamEvent{}
+	if l.HasServiceListeners && l.SSCache != nil {
+		for ID := range l.SSCache.Keys() {
+			ssm, ok := l.SSCache.Get(ID)
+			if !ok {
+				continue
+			}
+			events = append(events, StreamEvent{
+				Kind:       QueueKindService,
+				EventType:  EventTypeCreate,
+				ID:         ssm.ID,
+				Parameters: GetSwarmServiceMiniCreateParameters(ssm),
+			})
+		}
+	}
+	if (l.HasServiceListeners || l.HasNodeListeners) && l.NodeCache != nil {
+		for ID := range l.NodeCache.Keys() {
+			nm, ok := l.NodeCache.Get(ID)
+			if !ok {
+				continue
+			}
+			events = append(events, StreamEvent{
+				Kind:       QueueKindNode,
+				EventType:  EventTypeCreate,
+				ID:         nm.ID,
+				Parameters: GetNodeMiniCreateParameters(nm),
+			})
+		}
+	}
+	return events
+}
+
+// notifyHostsFromCache sends create notifications for all cached services
+// and nodes to `hosts`
+func (l *SwarmListener) notifyHostsFromCache(hosts []string) {
+	nowTimeNano := time.Now().UTC().UnixNano()
+
+	if l.HasServiceListeners && l.SSCache != nil {
+		for ID := range l.SSCache.Keys() {
+			ssm, ok := l.SSCache.Get(ID)
+			if !ok {
+				continue
+			}
+			params := GetSwarmServiceMiniCreateParameters(ssm)
+			l.SSNotificationChan <- Notification{
+				EventType:  EventTypeCreate,
+				ID:         ssm.ID,
+				Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+				TimeNano:   nowTimeNano,
+				Hosts:      hosts,
+			}
+		}
+	}
+
+	if l.HasNodeListeners && l.NodeCache != nil {
+		for ID := range l.NodeCache.Keys() {
+			nm, ok := l.NodeCache.Get(ID)
+			if !ok {
+				continue
+			}
+			params := GetNodeMiniCreateParameters(nm)
+			l.NodeNotificationChan <- Notification{
+				EventType:  EventTypeCreate,
+				ID:         nm.ID,
+				Parameters: ConvertMapStringStringToURLValues(params).Encode(),
+				TimeNano:   nowTimeNano,
+				Hosts:      hosts,
+			}
+		}
+	}
+}
+
 // CompletelyNotifyServices stops event processing and sends out create AND remove
 // notifications based on if the service is up, down. If the service is starting up
 // and not up get, a remove notification is send, and a create service event is
 // place on the event queue.
 func (l *SwarmListener) CompletelyNotifyServices() {
 
-	l.Log.Printf("CompletelyNotifyServices triggered")
+	l.Log.Info("Notifying all services")
 
 	if !l.HasServiceListeners {
 		return
@@ -638,7 +1262,7 @@ func (l *SwarmListener) CompletelyNotifyServices() {
 	ctx := context.Background()
 	services, err := l.SSClient.SwarmServiceList(ctx)
 	if err != nil {
-		l.Log.Printf("ERROR: CompletelyNotifyServices, %v", err)
+		l.Log.Error("Unable to list services to notify", "error", err)
 		l.startEventChannels()
 		return
 	}
@@ -659,7 +1283,7 @@ func (l *SwarmListener) CompletelyNotifyServices() {
 			ssm := MinifySwarmService(ss, l.IgnoreKey, l.IncludeKey)
 			params := GetSwarmServiceMiniRemoveParameters(ssm)
 			paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-			l.placeOnNotificationChan(
+			l.placeOnNotificationChan(ctx,
 				l.SSNotificationChan, EventTypeRemove, nowTimeNano, ssm.ID, paramsEncoded, errChan)
 			l.placeOnEventChan(l.SSInternalEventChan, EventTypeCreate, ssm.ID, nowTimeNano, false)
 			continue
@@ -670,7 +1294,7 @@ func (l *SwarmListener) CompletelyNotifyServices() {
 		l.SSCache.InsertAndCheck(ssm)
 		params := GetSwarmServiceMiniCreateParameters(ssm)
 		paramsEncoded := ConvertMapStringStringToURLValues(params).Encode()
-		l.placeOnNotificationChan(
+		l.placeOnNotificationChan(ctx,
 			l.SSNotificationChan, EventTypeCreate, nowTimeNano, ssm.ID, paramsEncoded, errChan)
 	}
 	l.startEventChannels()
@@ -678,7 +1302,7 @@ func (l *SwarmListener) CompletelyNotifyServices() {
 	counter := 0
 	for err := range errChan {
 		if err != nil {
-			l.Log.Printf("ERROR: CompletelyNotifyServices, %v", err)
+			l.Log.Error("Unable to notify services", "error", err)
 		}
 		counter++
 		if counter == len(services) {
@@ -688,21 +1312,65 @@ func (l *SwarmListener) CompletelyNotifyServices() {
 	}
 }
 
-// GetServicesParameters get all services
-func (l SwarmListener) GetServicesParameters(ctx context.Context) ([]map[string]string, error) {
-	params := []map[string]string{}
+// CheckHealth returns whether the listener can do its job
+// Docker is pinged with `ctx`.
+func (l SwarmListener) CheckHealth(ctx context.Context) Health {
+	docker := DockerHealth{}
+	if l.DockerClient == nil {
+		docker.Error = "no Docker client"
+	} else {
+		docker.ClientVersion = l.DockerClient.ClientVersion()
+		if ping, err := l.DockerClient.Ping(ctx); err != nil {
+			docker.Error = err.Error()
+		} else {
+			docker.Connected = true
+			docker.APIVersion = ping.APIVersion
+		}
+	}
+
+	health := checkHealth(docker, l.Health.EventStreams(), l.Health.Pollers(), time.Now().UTC())
+	health.Caches = map[string]int{}
+	if l.HasServiceListeners {
+		health.Caches[servicesHealthName] = l.SSCache.Len()
+	}
+	if l.HasServiceListeners || l.HasNodeListeners {
+		health.Caches[nodesHealthName] = len(l.NodeCache.Keys())
+	}
+	health.Endpoints = l.NotifyDistributor.EndpointHealth()
+	if l.Elector != nil {
+		status := l.Elector.Status()
+		health.Leader = &status
+	}
+	return health
+}
+
+// GetServicesParameters get all services selected by `filter`
+func (l SwarmListener) GetServicesParameters(ctx context.Context, filter ServiceFilter) ([]map[string]string, error) {
+	services, err := l.GetServices(ctx, filter)
+	if err != nil {
+		return []map[string]string{}, err
+	}
+	return servicesParameters(services), nil
+}
+
+// GetServices get all running services selected by `filter`
+func (l SwarmListener) GetServices(ctx context.Context, filter ServiceFilter) ([]SwarmServiceMini, error) {
+	minis := []SwarmServiceMini{}
 
 	l.stopEventChannels()
 	services, err := l.SSClient.SwarmServiceList(ctx)
 	if err != nil {
 		l.startEventChannels()
-		return params, err
+		return minis, err
 	}
 
 	runningServices := []SwarmService{}
 	nowTimeNano := time.Now().UTC().UnixNano()
 
 	for _, ss := range services {
+		if !filter.Match(MinifySwarmService(ss, l.IgnoreKey, l.IncludeKey), l.IncludeKey) {
+			continue
+		}
 		if l.HasServiceListeners {
 			running, err := l.SSClient.SwarmServiceRunning(ctx, ss.ID)
 			if err != nil || !running {
@@ -718,7 +1386,7 @@ func (l SwarmListener) GetServicesParameters(ctx context.Context) ([]map[string]
 
 	// concurrent
 	var wg sync.WaitGroup
-	paramsChan := make(chan map[string]string)
+	minisChan := make(chan SwarmServiceMini)
 	done := make(chan struct{})
 
 	for _, ss := range runningServices {
@@ -728,17 +1396,13 @@ func (l SwarmListener) GetServicesParameters(ctx context.Context) ([]map[string]
 			if l.IncludeNodeInfo {
 				nodeInfo, err := l.SSClient.GetNodeInfo(ctx, ss)
 				if err != nil {
-					l.Log.Printf("ERROR: GetServicesParameters, %v", err)
+					l.Log.Error("Unable to get node info", "service_id", ss.ID, "service_name", ss.Spec.Name, "error", err)
 				} else {
 					ss.NodeInfo = nodeInfo
 				}
 
 			}
-			ssm := MinifySwarmService(ss, l.IgnoreKey, l.IncludeKey)
-			newParams := GetSwarmServiceMiniCreateParameters(ssm)
-			if len(newParams) > 0 {
-				paramsChan <- newParams
-			}
+			minisChan <- MinifySwarmService(ss, l.IgnoreKey, l.IncludeKey)
 		}(ss)
 	}
 
@@ -750,37 +1414,216 @@ func (l SwarmListener) GetServicesParameters(ctx context.Context) ([]map[string]
 L:
 	for {
 		select {
-		case p := <-paramsChan:
-			params = append(params, p)
+		case ssm := <-minisChan:
+			minis = append(minis, ssm)
 		case <-done:
 			break L
 		}
 	}
 
-	return params, nil
+	return minis, nil
 }
 
-// GetNodesParameters get all nodes
-func (l SwarmListener) GetNodesParameters(ctx context.Context) ([]map[string]string, error) {
-	nodes, err := l.NodeClient.NodeList(ctx)
+// GetNodesParameters get all nodes selected by `filter`
+func (l SwarmListener) GetNodesParameters(ctx context.Context, filter NodeFilter) ([]map[string]string, error) {
+	nodes, err := l.GetNodes(ctx, filter)
 	if err != nil {
 		return []map[string]string{}, err
 	}
-	params := []map[string]string{}
+	return nodesParameters(nodes), nil
+}
+
+// GetNodes get all nodes selected by `filter`
+func (l SwarmListener) GetNodes(ctx context.Context, filter NodeFilter) ([]NodeMini, error) {
+	nodes, err := l.NodeClient.NodeList(ctx)
+	if err != nil {
+		return []NodeMini{}, err
+	}
+	minis := []NodeMini{}
 	for _, n := range nodes {
 		mn := MinifyNode(n)
-		newParams := GetNodeMiniCreateParameters(mn)
-		if len(newParams) > 0 {
-			params = append(params, newParams)
+		if filter.Match(mn) {
+			minis = append(minis, mn)
 		}
 	}
-	return params, nil
+	return minis, nil
 }
 
-func readStringFromFile(filename string) string {
-	content, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return ""
+// GetCachedServicesParameters returns the parameters of the cached services
+// selected by `filter` and the time the cache was last updated. It returns
+// false when services are not cached.
+func (l SwarmListener) GetCachedServicesParameters(filter ServiceFilter) ([]map[string]string, time.Time, bool) {
+	services, updatedAt, ok := l.GetCachedServices(filter)
+	if !ok {
+		return nil, updatedAt, false
+	}
+	return servicesParameters(services), updatedAt, true
+}
+
+// GetCachedServices returns the cached services selected by `filter`
+// sorted by ID and the time the cache was last updated. It returns false
+// when services are not cached.
+func (l SwarmListener) GetCachedServices(filter ServiceFilter) ([]SwarmServiceMini, time.Time, bool) {
+	if !l.HasServiceListeners || l.SSCache == nil {
+		return nil, time.Time{}, false
+	}
+	updatedAt := l.SSCache.UpdatedAt()
+	if updatedAt.IsZero() {
+		return nil, updatedAt, false
+	}
+
+	services := []SwarmServiceMini{}
+	for _, ID := range sortedKeys(l.SSCache.Keys()) {
+		ssm, ok := l.SSCache.Get(ID)
+		if ok && filter.Match(ssm, l.IncludeKey) {
+			services = append(services, ssm)
+		}
+	}
+	return services, updatedAt, true
+}
+
+// GetCachedNodesParameters returns the parameters of the cached nodes
+// selected by `filter` and the time the cache was last updated. It returns
+// false when nodes are not cached.
+func (l SwarmListener) GetCachedNodesParameters(filter NodeFilter) ([]map[string]string, time.Time, bool) {
+	nodes, updatedAt, ok := l.GetCachedNodes(filter)
+	if !ok {
+		return nil, updatedAt, false
+	}
+	return nodesParameters(nodes), updatedAt, true
+}
+
+// GetCachedNodes returns the cached nodes selected by `filter` sorted by
+// ID and the time the cache was last updated. It returns false when nodes
+// are not cached.
+func (l SwarmListener) GetCachedNodes(filter NodeFilter) ([]NodeMini, time.Time, bool) {
+	if !(l.HasServiceListeners || l.HasNodeListeners) || l.NodeCache == nil {
+		return nil, time.Time{}, false
+	}
+	updatedAt := l.NodeCache.UpdatedAt()
+	if updatedAt.IsZero() {
+		return nil, updatedAt, false
+	}
+
+	nodes := []NodeMini{}
+	for _, ID := range sortedKeys(l.NodeCache.Keys()) {
+		nm, ok := l.NodeCache.Get(ID)
+		if ok && filter.Match(nm) {
+			nodes = append(nodes, nm)
+		}
+	}
+	return nodes, updatedAt, true
+}
+
+// EvictCachedService removes the cached service with the name or ID
+// The next event of the service is notified even when it did not change.
+// It returns false when the service is not cached.
+func (l SwarmListener) EvictCachedService(nameOrID string) (SwarmServiceMini, bool) {
+	services, _, ok := l.GetCachedServices(ServiceFilter{NamesOrIDs: []string{nameOrID}})
+	if !ok || len(services) == 0 {
+		return SwarmServiceMini{}, false
+	}
+	l.SSCache.Delete(services[0].ID)
+	metrics.RecordService(l.SSCache.Len())
+	return services[0], true
+}
+
+// EvictCachedNode removes the cached node with the hostname or ID
+// The next event of the node is notified even when it did not change.
+// It returns false when the node is not cached.
+func (l SwarmListener) EvictCachedNode(nameOrID string) (NodeMini, bool) {
+	nodes, _, ok := l.GetCachedNodes(NodeFilter{NamesOrIDs: []string{nameOrID}})
+	if !ok || len(nodes) == 0 {
+		return NodeMini{}, false
+	}
+	l.NodeCache.Delete(nodes[0].ID)
+	metrics.RecordNode(len(l.NodeCache.Keys()))
+	return nodes[0], true
+}
+
+// FlushServiceCache removes all cached services and returns their number
+// The next event of every service is notified.
+func (l SwarmListener) FlushServiceCache() int {
+	if !l.HasServiceListeners || l.SSCache == nil {
+		return 0
+	}
+	count := l.SSCache.Flush()
+	metrics.RecordService(0)
+	return count
+}
+
+// FlushNodeCache removes all cached nodes and returns their number
+// The next event of every node is notified.
+func (l SwarmListener) FlushNodeCache() int {
+	if !(l.HasServiceListeners || l.HasNodeListeners) || l.NodeCache == nil {
+		return 0
+	}
+	count := l.NodeCache.Flush()
+	metrics.RecordNode(0)
+	return count
+}
+
+// DiffService compares the cached entry of the service with the name or ID
+// with the service in Docker
+// The diff tells whether the next event of the service is notified.
+func (l SwarmListener) DiffService(ctx context.Context, nameOrID string) (ServiceDiff, error) {
+	if !l.HasServiceListeners || l.SSCache == nil {
+		return ServiceDiff{}, ErrServicesNotCached
+	}
+
+	var cached *SwarmServiceMini
+	serviceID := nameOrID
+	services, _, ok := l.GetCachedServices(ServiceFilter{NamesOrIDs: []string{nameOrID}})
+	if ok && len(services) > 0 {
+		cached = &services[0]
+		serviceID = cached.ID
+	}
+
+	var current *SwarmServiceMini
+	service, err := l.SSClient.SwarmServiceInspect(ctx, serviceID)
+	if err != nil && !client.IsErrNotFound(err) {
+		return ServiceDiff{}, err
+	}
+	// The service is nil when it does not have the notify label
+	if err == nil && service != nil {
+		if l.IncludeNodeInfo {
+			nodeInfo, err := l.SSClient.GetNodeInfo(ctx, *service)
+			if err != nil {
+				return ServiceDiff{}, err
+			}
+			service.NodeInfo = nodeInfo
+		}
+		ssm := MinifySwarmService(*service, l.IgnoreKey, l.IncludeKey)
+		current = &ssm
+	}
+
+	if cached == nil && current == nil {
+		return ServiceDiff{}, ErrServiceNotFound
+	}
+	return newServiceDiff(cached, current), nil
+}
+
+func servicesParameters(services []SwarmServiceMini) []map[string]string {
+	params := make([]map[string]string, 0, len(services))
+	for _, ssm := range services {
+		params = append(params, GetSwarmServiceMiniCreateParameters(ssm))
+	}
+	return params
+}
+
+func nodesParameters(nodes []NodeMini) []map[string]string {
+	params := make([]map[string]string, 0, len(nodes))
+	for _, nm := range nodes {
+		params = append(params, GetNodeMiniCreateParameters(nm))
+	}
+	return params
+}
+
+func sortedKeys(keys map[string]struct{}) []string {
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
 	}
-	return strings.TrimSpace(string(content))
+	sort.Strings(sorted)
+	return sorted
 }
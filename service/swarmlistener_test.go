@@ -3,10 +3,13 @@ package service
 import (
 	"bytes"
 	"context"
-	"log"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/docker-flow/docker-flow-swarm-listener/config"
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
@@ -28,7 +31,7 @@ type SwarmListenerTestSuite struct {
 	NotifyDistributorMock *notifyDistributorMock
 
 	SwarmListener *SwarmListener
-	Logger        *log.Logger
+	Logger        *logging.Logger
 	LogBytes      *bytes.Buffer
 }
 
@@ -50,7 +53,7 @@ func (s *SwarmListenerTestSuite) SetupTest() {
 
 	s.NotifyDistributorMock = new(notifyDistributorMock)
 	s.LogBytes = new(bytes.Buffer)
-	s.Logger = log.New(s.LogBytes, "", 0)
+	s.Logger = logging.New(s.LogBytes, logging.FormatLogfmt, logging.LevelDebug)
 
 	s.SwarmListener = newSwarmListener(
 		s.SSListenerMock,
@@ -314,7 +317,8 @@ func (s *SwarmListenerTestSuite) Test_Run_NodeChannel() {
 	s.NodeListeningMock.On("ListenForNodeEvents", mock.AnythingOfType("chan<- service.Event"))
 	s.NodeClientMock.On("NodeInspect", "nodeID1").Return(n1, nil)
 	s.NodeCacheMock.On("InsertAndCheck", n1m).Return(true).
-		On("Get", "nodeID2").Return(n2m, true)
+		On("Get", "nodeID2").Return(n2m, true).
+		On("Keys").Return(map[string]struct{}{"nodeID1": {}})
 	s.NotifyDistributorMock.
 		On("Run", mock.AnythingOfType("<-chan service.Notification"), mock.AnythingOfType("<-chan service.Notification"))
 	s.NodePollerMock.
@@ -542,7 +546,7 @@ func (s *SwarmListenerTestSuite) Test_GetServices_WithoutNodeInfo() {
 	s.SwarmListener.HasServiceListeners = true
 	s.SwarmListener.startEventChannels()
 
-	params, err := s.SwarmListener.GetServicesParameters(context.Background())
+	params, err := s.SwarmListener.GetServicesParameters(context.Background(), ServiceFilter{})
 	s.Require().NoError(err)
 	s.Len(params, 2)
 
@@ -566,7 +570,7 @@ func (s *SwarmListenerTestSuite) Test_GetServices_WithoutNodeInfo_OneServiceNotR
 
 	s.SwarmListener.HasServiceListeners = true
 	s.SwarmListener.startEventChannels()
-	params, err := s.SwarmListener.GetServicesParameters(context.Background())
+	params, err := s.SwarmListener.GetServicesParameters(context.Background(), ServiceFilter{})
 	s.Require().NoError(err)
 	s.Len(params, 1)
 
@@ -616,7 +620,7 @@ func (s *SwarmListenerTestSuite) Test_GetServices_WithNodeInfo() {
 
 	s.SwarmListener.HasServiceListeners = true
 	s.SwarmListener.startEventChannels()
-	params, err := s.SwarmListener.GetServicesParameters(context.Background())
+	params, err := s.SwarmListener.GetServicesParameters(context.Background(), ServiceFilter{})
 	s.Require().NoError(err)
 	s.Len(params, 2)
 
@@ -631,7 +635,7 @@ func (s *SwarmListenerTestSuite) Test_GetNodes() {
 	}
 	s.NodeClientMock.On("NodeList", mock.AnythingOfType("*context.emptyCtx")).Return(expServices, nil)
 
-	params, err := s.SwarmListener.GetNodesParameters(context.Background())
+	params, err := s.SwarmListener.GetNodesParameters(context.Background(), NodeFilter{})
 	s.Require().NoError(err)
 	s.Len(params, 2)
 
@@ -691,3 +695,501 @@ func (s *SwarmListenerTestSuite) Test_CompletelyNotifyServices() {
 	s.SSClientMock.AssertExpectations(s.T())
 	s.SSCacheMock.AssertExpectations(s.T())
 }
+
+func (s *SwarmListenerTestSuite) Test_UpdateNotifyEndpoints_NotifiesAddedHostsFromCache() {
+	ssm := SwarmServiceMini{ID: "serviceID1", Name: "serviceName1", Labels: map[string]string{}}
+	s.SSCacheMock.
+		On("Keys").Return(map[string]struct{}{"serviceID1": {}}).
+		On("Get", "serviceID1").Return(ssm, true)
+	s.NotifyDistributorMock.
+		On("UpdateEndpoints", mock.Anything).Return([]string{"monitor"})
+
+	s.SwarmListener.HasServiceListeners = true
+	c := &config.Config{
+		Endpoints: []config.Endpoint{
+			{CreateServiceURL: "http://proxy/reconfigure"},
+			{CreateServiceURL: "http://monitor/reconfigure"},
+		},
+	}
+	s.Require().NoError(s.SwarmListener.UpdateNotifyEndpoints(c))
+
+	select {
+	case n := <-s.SwarmListener.SSNotificationChan:
+		s.Equal("serviceID1", n.ID)
+		s.Equal(EventTypeCreate, n.EventType)
+		s.Equal([]string{"monitor"}, n.Hosts)
+		s.Nil(n.ErrorChan)
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+
+	endpoints := s.NotifyDistributorMock.Calls[0].Arguments.Get(0).(map[string]NotifyEndpoint)
+	s.Len(endpoints, 2)
+	s.Contains(endpoints, "proxy")
+	s.Contains(endpoints, "monitor")
+}
+
+func (s *SwarmListenerTestSuite) Test_UpdateNotifyEndpoints_ReturnsError_WhenNodeListenersWereNotEnabled() {
+	s.SwarmListener.HasServiceListeners = true
+	c := &config.Config{
+		Endpoints: []config.Endpoint{
+			{CreateNodeURL: "http://dns/create"},
+		},
+	}
+
+	err := s.SwarmListener.UpdateNotifyEndpoints(c)
+
+	s.Error(err)
+	s.NotifyDistributorMock.AssertNotCalled(s.T(), "UpdateEndpoints", mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_ServiceRemoveEvent_IsPublishedToEventStream() {
+	ssm := SwarmServiceMini{ID: "serviceID1", Name: "serviceName1", Labels: map[string]string{}}
+	s.SSCacheMock.
+		On("Get", "serviceID1").Return(ssm, true).
+		On("Delete", "serviceID1").
+		On("Len").Return(0)
+	sub := s.SwarmListener.SubscribeEvents("")
+	defer sub.Close()
+
+	go s.SwarmListener.processServiceEventRemove(Event{ID: "serviceID1", Type: EventTypeRemove, TimeNano: 10})
+
+	select {
+	case n := <-s.SwarmListener.SSNotificationChan:
+		n.ErrorChan <- nil
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+
+	select {
+	case e := <-sub.Events:
+		s.Equal(uint64(1), e.Seq)
+		s.Equal(QueueKindService, e.Kind)
+		s.Equal(EventTypeRemove, e.EventType)
+		s.Equal("serviceID1", e.ID)
+		s.Equal(int64(10), e.TimeNano)
+		s.Equal(GetSwarmServiceMiniRemoveParameters(ssm), e.Parameters)
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+}
+
+func (s *SwarmListenerTestSuite) Test_GetEventSnapshot_ReturnsCachedServicesAndNodes() {
+	ssm := SwarmServiceMini{ID: "serviceID1", Name: "serviceName1", Labels: map[string]string{}}
+	nm := NodeMini{ID: "nodeID1", Hostname: "node1", State: swarm.NodeStateReady}
+	s.SSCacheMock.
+		On("Keys").Return(map[string]struct{}{"serviceID1": {}}).
+		On("Get", "serviceID1").Return(ssm, true)
+	s.NodeCacheMock.
+		On("Keys").Return(map[string]struct{}{"nodeID1": {}}).
+		On("Get", "nodeID1").Return(nm, true)
+	s.SwarmListener.HasServiceListeners = true
+
+	events := s.SwarmListener.GetEventSnapshot()
+
+	s.Equal([]StreamEvent{
+		{Kind: QueueKindService, EventType: EventTypeCreate, ID: "serviceID1",
+			Parameters: GetSwarmServiceMiniCreateParameters(ssm)},
+		{Kind: QueueKindNode, EventType: EventTypeCreate, ID: "nodeID1",
+			Parameters: GetNodeMiniCreateParameters(nm)},
+	}, events)
+}
+
+func (s *SwarmListenerTestSuite) Test_GetCachedServicesParameters_ReturnsCachedServices() {
+	updatedAt := time.Now().UTC()
+	ssm1 := SwarmServiceMini{ID: "serviceID1", Name: "serviceName1", Labels: map[string]string{}}
+	ssm2 := SwarmServiceMini{ID: "serviceID2", Name: "serviceName2", Labels: map[string]string{}}
+	s.SSCacheMock.
+		On("UpdatedAt").Return(updatedAt).
+		On("Keys").Return(map[string]struct{}{"serviceID2": {}, "serviceID1": {}}).
+		On("Get", "serviceID1").Return(ssm1, true).
+		On("Get", "serviceID2").Return(ssm2, true)
+	s.SwarmListener.HasServiceListeners = true
+
+	params, actualUpdatedAt, ok := s.SwarmListener.GetCachedServicesParameters(ServiceFilter{})
+
+	s.True(ok)
+	s.Equal(updatedAt, actualUpdatedAt)
+	s.Equal([]map[string]string{
+		GetSwarmServiceMiniCreateParameters(ssm1),
+		GetSwarmServiceMiniCreateParameters(ssm2),
+	}, params)
+}
+
+func (s *SwarmListenerTestSuite) Test_GetCachedServicesParameters_ReturnsFalse_WhenCacheWasNeverUpdated() {
+	s.SSCacheMock.On("UpdatedAt").Return(time.Time{})
+	s.SwarmListener.HasServiceListeners = true
+
+	_, _, ok := s.SwarmListener.GetCachedServicesParameters(ServiceFilter{})
+
+	s.False(ok)
+	s.SSCacheMock.AssertNotCalled(s.T(), "Keys")
+}
+
+func (s *SwarmListenerTestSuite) Test_GetCachedNodesParameters_ReturnsFalse_WhenNodesAreNotListenedTo() {
+	_, _, ok := s.SwarmListener.GetCachedNodesParameters(NodeFilter{})
+
+	s.False(ok)
+	s.NodeCacheMock.AssertNotCalled(s.T(), "UpdatedAt")
+}
+
+func (s *SwarmListenerTestSuite) Test_GetCachedNodesParameters_ReturnsCachedNodes() {
+	updatedAt := time.Now().UTC()
+	nm := NodeMini{ID: "nodeID1", Hostname: "node1", State: swarm.NodeStateReady}
+	s.NodeCacheMock.
+		On("UpdatedAt").Return(updatedAt).
+		On("Keys").Return(map[string]struct{}{"nodeID1": {}}).
+		On("Get", "nodeID1").Return(nm, true)
+	s.SwarmListener.HasNodeListeners = true
+
+	params, actualUpdatedAt, ok := s.SwarmListener.GetCachedNodesParameters(NodeFilter{})
+
+	s.True(ok)
+	s.Equal(updatedAt, actualUpdatedAt)
+	s.Equal([]map[string]string{GetNodeMiniCreateParameters(nm)}, params)
+}
+
+func (s *SwarmListenerTestSuite) Test_EvictCachedService_DeletesServiceFromCache() {
+	ssm := SwarmServiceMini{ID: "serviceID1", Name: "serviceName1", Labels: map[string]string{}}
+	s.SSCacheMock.
+		On("UpdatedAt").Return(time.Now().UTC()).
+		On("Keys").Return(map[string]struct{}{"serviceID1": {}}).
+		On("Get", "serviceID1").Return(ssm, true).
+		On("Delete", "serviceID1").
+		On("Len").Return(0)
+	s.SwarmListener.HasServiceListeners = true
+
+	evicted, ok := s.SwarmListener.EvictCachedService("serviceName1")
+	s.True(ok)
+	s.Equal(ssm, evicted)
+
+	_, ok = s.SwarmListener.EvictCachedService("serviceName2")
+	s.False(ok)
+	s.SSCacheMock.AssertNumberOfCalls(s.T(), "Delete", 1)
+}
+
+func (s *SwarmListenerTestSuite) Test_FlushNodeCache_FlushesNodes() {
+	s.NodeCacheMock.On("Flush").Return(3)
+
+	s.Equal(0, s.SwarmListener.FlushNodeCache())
+
+	s.SwarmListener.HasNodeListeners = true
+	s.Equal(3, s.SwarmListener.FlushNodeCache())
+	s.NodeCacheMock.AssertNumberOfCalls(s.T(), "Flush", 1)
+}
+
+func (s *SwarmListenerTestSuite) Test_DiffService_ReportsChanges() {
+	cached := SwarmServiceMini{ID: "serviceID1", Name: "serviceName1",
+		Labels: map[string]string{"com.df.port": "8080"}, Replicas: 1}
+	ss := SwarmService{swarm.Service{ID: "serviceID1",
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: "serviceName1",
+				Labels: map[string]string{"com.df.port": "8081"}},
+			Mode: swarm.ServiceMode{Replicated: &swarm.ReplicatedService{Replicas: new(uint64)}},
+		}}, nil}
+	s.SSCacheMock.
+		On("UpdatedAt").Return(time.Now().UTC()).
+		On("Keys").Return(map[string]struct{}{"serviceID1": {}}).
+		On("Get", "serviceID1").Return(cached, true)
+	s.SSClientMock.On("SwarmServiceInspect", mock.Anything, "serviceID1").Return(&ss, nil)
+	s.SwarmListener.HasServiceListeners = true
+
+	diff, err := s.SwarmListener.DiffService(context.Background(), "serviceName1")
+
+	s.Require().NoError(err)
+	current := MinifySwarmService(ss, "com.df.notify", "com.docker.stack.namespace")
+	s.Equal("serviceID1", diff.ID)
+	s.Equal(&cached, diff.Cached)
+	s.Equal(&current, diff.Current)
+	s.Equal([]FieldChange{
+		{Field: "Labels[com.df.port]", Cached: "8080", Current: "8081"},
+		{Field: "Replicas", Cached: uint64(1), Current: uint64(0)},
+	}, diff.Changes)
+	s.True(diff.Notify)
+}
+
+func (s *SwarmListenerTestSuite) Test_DiffService_ReportsCachedService_WhenServiceIsNotInDocker() {
+	cached := SwarmServiceMini{ID: "serviceID1", Name: "serviceName1", Labels: map[string]string{}}
+	s.SSCacheMock.
+		On("UpdatedAt").Return(time.Now().UTC()).
+		On("Keys").Return(map[string]struct{}{"serviceID1": {}}).
+		On("Get", "serviceID1").Return(cached, true)
+	s.SSClientMock.
+		On("SwarmServiceInspect", mock.Anything, "serviceID1").Return((*SwarmService)(nil), notFoundError{}).
+		On("SwarmServiceInspect", mock.Anything, "serviceName2").Return((*SwarmService)(nil), notFoundError{})
+	s.SwarmListener.HasServiceListeners = true
+
+	diff, err := s.SwarmListener.DiffService(context.Background(), "serviceID1")
+
+	s.Require().NoError(err)
+	s.Equal(&cached, diff.Cached)
+	s.Nil(diff.Current)
+	s.True(diff.Notify)
+
+	_, err = s.SwarmListener.DiffService(context.Background(), "serviceName2")
+	s.Equal(ErrServiceNotFound, err)
+}
+
+func (s *SwarmListenerTestSuite) Test_DiffService_ReturnsError_WhenServicesAreNotCached() {
+	_, err := s.SwarmListener.DiffService(context.Background(), "serviceName1")
+
+	s.Equal(ErrServicesNotCached, err)
+}
+
+func (s *SwarmListenerTestSuite) Test_NotifyNodes_FillsNodeCache_WithoutNodeListeners() {
+	nodes := []swarm.Node{{ID: "nodeID1", Description: swarm.NodeDescription{Hostname: "node1"}}}
+	s.NodeClientMock.On("NodeList", mock.Anything).Return(nodes, nil)
+	s.NodeCacheMock.On("InsertAndCheck", MinifyNode(nodes[0])).Return(true)
+	s.SwarmListener.HasServiceListeners = true
+
+	s.SwarmListener.NotifyNodes(false)
+
+	s.NodeCacheMock.AssertExpectations(s.T())
+}
+
+func (s *SwarmListenerTestSuite) Test_NotifySelectedServices_NotifiesRunningSelectedServicesToHosts() {
+	services := []SwarmService{
+		{swarm.Service{ID: "serviceID1",
+			Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "shop_web",
+				Labels: map[string]string{"com.df.notify": "true", "com.docker.stack.namespace": "shop"}}}}, nil},
+		{swarm.Service{ID: "serviceID2",
+			Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "shop_api",
+				Labels: map[string]string{"com.df.notify": "true", "com.docker.stack.namespace": "shop"}}}}, nil},
+		{swarm.Service{ID: "serviceID3",
+			Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "blog_web",
+				Labels: map[string]string{"com.df.notify": "true", "com.docker.stack.namespace": "blog"}}}}, nil},
+	}
+	s.SSClientMock.
+		On("SwarmServiceList", mock.Anything).Return(services, nil).
+		On("SwarmServiceRunning", mock.Anything, "serviceID1").Return(true, nil).
+		On("SwarmServiceRunning", mock.Anything, "serviceID2").Return(false, nil)
+	s.SwarmListener.HasServiceListeners = true
+
+	type notifyResult struct {
+		results []DeliveryResult
+		err     error
+	}
+	done := make(chan notifyResult)
+	go func() {
+		results, err := s.SwarmListener.NotifySelectedServices(
+			context.Background(), ServiceFilter{Stack: "shop"}, []string{"proxy:8080"})
+		done <- notifyResult{results, err}
+	}()
+
+	select {
+	case n := <-s.SwarmListener.SSNotificationChan:
+		s.Equal("serviceID1", n.ID)
+		s.Equal(EventTypeCreate, n.EventType)
+		s.Equal([]string{"proxy:8080"}, n.Hosts)
+		s.Contains(n.Parameters, "serviceName=shop_web")
+		n.Report.add(EndpointDelivery{Host: "proxy:8080", StatusCode: 200, Attempts: 1})
+		n.ErrorChan <- nil
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+	result := <-done
+	s.Require().NoError(result.err)
+	s.Equal([]DeliveryResult{
+		{ID: "serviceID1", Name: "shop_web", Status: DeliveryStatusDelivered,
+			Endpoints: []EndpointDelivery{{Host: "proxy:8080", StatusCode: 200, Attempts: 1}}},
+		{ID: "serviceID2", Name: "shop_api", Status: DeliveryStatusNotRunning, Endpoints: []EndpointDelivery{}},
+	}, result.results)
+	s.SSClientMock.AssertNotCalled(s.T(), "SwarmServiceRunning", mock.Anything, "serviceID3")
+	s.SSCacheMock.AssertNotCalled(s.T(), "InsertAndCheck", mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_NotifySelectedServices_ReportsPendingDeliveries_WhenContextIsDone() {
+	services := []SwarmService{
+		{swarm.Service{ID: "serviceID1",
+			Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "web",
+				Labels: map[string]string{"com.df.notify": "true"}}}}, nil},
+	}
+	s.SSClientMock.
+		On("SwarmServiceList", mock.Anything).Return(services, nil).
+		On("SwarmServiceRunning", mock.Anything, "serviceID1").Return(true, nil)
+	s.SwarmListener.HasServiceListeners = true
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	results, err := s.SwarmListener.NotifySelectedServices(ctx, ServiceFilter{}, nil)
+
+	s.Require().NoError(err)
+	s.Equal([]DeliveryResult{
+		{ID: "serviceID1", Name: "web", Status: DeliveryStatusPending, Endpoints: []EndpointDelivery{}},
+	}, results)
+}
+
+func (s *SwarmListenerTestSuite) Test_NotifySelectedNodes_NotifiesSelectedNodesToHosts() {
+	nodes := []swarm.Node{
+		{ID: "nodeID1", Description: swarm.NodeDescription{Hostname: "node1"},
+			Spec: swarm.NodeSpec{Role: swarm.NodeRoleManager}},
+		{ID: "nodeID2", Description: swarm.NodeDescription{Hostname: "node2"},
+			Spec: swarm.NodeSpec{Role: swarm.NodeRoleWorker}},
+	}
+	s.NodeClientMock.On("NodeList", mock.Anything).Return(nodes, nil)
+	s.SwarmListener.HasNodeListeners = true
+
+	type notifyResult struct {
+		results []DeliveryResult
+		err     error
+	}
+	done := make(chan notifyResult)
+	go func() {
+		results, err := s.SwarmListener.NotifySelectedNodes(
+			context.Background(), NodeFilter{Role: swarm.NodeRoleManager}, []string{"dns"})
+		done <- notifyResult{results, err}
+	}()
+
+	select {
+	case n := <-s.SwarmListener.NodeNotificationChan:
+		s.Equal("nodeID1", n.ID)
+		s.Equal(EventTypeCreate, n.EventType)
+		s.Equal([]string{"dns"}, n.Hosts)
+		s.Contains(n.Parameters, "hostname=node1")
+		n.Report.add(EndpointDelivery{Host: "dns", Error: "failed"})
+		n.ErrorChan <- nil
+	case <-time.After(time.Second * 5):
+		s.FailNow("Timeout")
+	}
+	result := <-done
+	s.Require().NoError(result.err)
+	s.Equal([]DeliveryResult{
+		{ID: "nodeID1", Name: "node1", Status: DeliveryStatusFailed,
+			Endpoints: []EndpointDelivery{{Host: "dns", Error: "failed"}}},
+	}, result.results)
+	s.NodeCacheMock.AssertNotCalled(s.T(), "InsertAndCheck", mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_CheckHealth_ReportsDockerCachesAndEndpoints() {
+	dockerMock := new(dockerPingingMock)
+	dockerMock.On("ClientVersion").Return("1.37").
+		On("Ping", mock.Anything).Return(types.Ping{APIVersion: "1.38"}, nil)
+	endpoints := map[string]EndpointHealth{"proxy": {ConsecutiveFailures: 2, LastError: "failed"}}
+	s.NotifyDistributorMock.On("EndpointHealth").Return(endpoints)
+	s.SSCacheMock.On("Len").Return(3)
+	s.NodeCacheMock.On("Keys").Return(map[string]struct{}{"nodeID1": {}})
+	s.SwarmListener.DockerClient = dockerMock
+	s.SwarmListener.HasServiceListeners = true
+	s.SwarmListener.Health.streamConnected(servicesHealthName)
+
+	health := s.SwarmListener.CheckHealth(context.Background())
+
+	s.True(health.Healthy)
+	s.True(health.Ready)
+	s.Equal(DockerHealth{Connected: true, APIVersion: "1.38", ClientVersion: "1.37"}, health.Docker)
+	s.Equal(map[string]int{"services": 3, "nodes": 1}, health.Caches)
+	s.Equal(endpoints, health.Endpoints)
+	s.True(health.EventStreams["services"].Connected)
+}
+
+func (s *SwarmListenerTestSuite) Test_CheckHealth_IsNotReady_WhenDockerCannotBePinged() {
+	dockerMock := new(dockerPingingMock)
+	dockerMock.On("ClientVersion").Return("1.37").
+		On("Ping", mock.Anything).Return(types.Ping{}, fmt.Errorf("connection refused"))
+	s.NotifyDistributorMock.On("EndpointHealth").Return(map[string]EndpointHealth{})
+	s.SwarmListener.DockerClient = dockerMock
+
+	health := s.SwarmListener.CheckHealth(context.Background())
+
+	s.False(health.Healthy)
+	s.False(health.Ready)
+	s.Equal("connection refused", health.Docker.Error)
+	s.Equal([]string{"Unable to connect to Docker: connection refused"}, health.Problems)
+}
+
+func (s *SwarmListenerTestSuite) Test_ResolveNotifyHosts_MatchesHostsWithoutPort() {
+	s.NotifyDistributorMock.On("Hosts").Return([]string{"monitor", "proxy:8080"})
+
+	hosts, err := s.SwarmListener.ResolveNotifyHosts([]string{"proxy", "proxy:8080", "monitor"})
+	s.Require().NoError(err)
+	s.Equal([]string{"proxy:8080", "monitor"}, hosts)
+
+	_, err = s.SwarmListener.ResolveNotifyHosts([]string{"dns"})
+	s.EqualError(err, "dns does not match a notification endpoint")
+}
+
+type notFoundError struct{}
+
+func (e notFoundError) Error() string {
+	return "not found"
+}
+
+func (e notFoundError) NotFound() bool {
+	return true
+}
+
+func (s *SwarmListenerTestSuite) Test_RefreshCaches_ReplacesCachedServicesAndNodes() {
+	services := []SwarmService{
+		{swarm.Service{ID: "serviceID1",
+			Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "web",
+				Labels: map[string]string{"com.df.notify": "true"}}}}, nil},
+		{swarm.Service{ID: "serviceID2",
+			Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "api",
+				Labels: map[string]string{"com.df.notify": "true"}}}}, nil},
+	}
+	nodes := []swarm.Node{{ID: "nodeID1", Description: swarm.NodeDescription{Hostname: "node1"}}}
+	s.SSClientMock.
+		On("SwarmServiceList", mock.Anything).Return(services, nil).
+		On("SwarmServiceRunning", mock.Anything, "serviceID1").Return(true, nil).
+		On("SwarmServiceRunning", mock.Anything, "serviceID2").Return(false, nil)
+	s.NodeClientMock.On("NodeList", mock.Anything).Return(nodes, nil)
+	ssCache := NewSwarmServiceCache()
+	ssCache.InsertAndCheck(SwarmServiceMini{ID: "serviceID3", Name: "removed"})
+	nodeCache := NewNodeCache()
+	nodeCache.InsertAndCheck(NodeMini{ID: "nodeID2", Hostname: "node2"})
+	s.SwarmListener.SSCache = ssCache
+	s.SwarmListener.NodeCache = nodeCache
+	s.SwarmListener.HasServiceListeners = true
+
+	s.SwarmListener.refreshCaches(context.Background())
+
+	s.Equal(map[string]struct{}{"serviceID1": {}}, ssCache.Keys())
+	cached, _ := ssCache.Get("serviceID1")
+	s.Equal(MinifySwarmService(services[0], "com.df.notify", "com.docker.stack.namespace"), cached)
+	s.Equal(map[string]struct{}{"nodeID1": {}}, nodeCache.Keys())
+	s.NotifyDistributorMock.AssertNotCalled(s.T(), "Run", mock.Anything, mock.Anything)
+}
+
+func (s *SwarmListenerTestSuite) Test_NotifyChanges_PlacesEventsConsultingCaches() {
+	services := []SwarmService{
+		{swarm.Service{ID: "serviceID1"}, nil},
+	}
+	nodes := []swarm.Node{{ID: "nodeID1"}}
+	s.SSClientMock.On("SwarmServiceList", mock.Anything).Return(services, nil)
+	s.NodeClientMock.On("NodeList", mock.Anything).Return(nodes, nil)
+	s.SSCacheMock.On("Keys").Return(map[string]struct{}{"serviceID1": {}, "serviceID2": {}})
+	s.NodeCacheMock.On("Keys").Return(map[string]struct{}{"nodeID2": {}})
+	s.SwarmListener.HasServiceListeners = true
+	s.SwarmListener.HasNodeListeners = true
+
+	go s.SwarmListener.NotifyChanges()
+
+	serviceEvents := map[string]Event{}
+	nodeEvents := map[string]Event{}
+	for i := 0; i < 4; i++ {
+		select {
+		case e := <-s.SwarmListener.SSEventChan:
+			serviceEvents[e.ID] = e
+		case e := <-s.SwarmListener.NodeEventChan:
+			nodeEvents[e.ID] = e
+		case <-time.After(5 * time.Second):
+			s.FailNow("Timeout")
+		}
+	}
+	s.Equal(EventTypeCreate, serviceEvents["serviceID1"].Type)
+	s.Equal(EventTypeRemove, serviceEvents["serviceID2"].Type)
+	s.Equal(EventTypeCreate, nodeEvents["nodeID1"].Type)
+	s.Equal(EventTypeRemove, nodeEvents["nodeID2"].Type)
+	for _, e := range []Event{serviceEvents["serviceID1"], serviceEvents["serviceID2"],
+		nodeEvents["nodeID1"], nodeEvents["nodeID2"]} {
+		s.True(e.ConsultCache)
+	}
+}
+
+func (s *SwarmListenerTestSuite) Test_IsLeader_ReturnsTrue_WithoutElection() {
+	s.True(s.SwarmListener.IsLeader())
+
+	s.SwarmListener.Elector = NewLeaderElector(nil, "replica1", 15*time.Second, 5*time.Second, s.Logger)
+
+	s.False(s.SwarmListener.IsLeader())
+}
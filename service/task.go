@@ -10,6 +10,8 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/metrics"
 )
 
 // https://github.com/docker/cli/blob/master/cli/command/service/progress/progress.go
@@ -87,7 +89,19 @@ type progressUpdater interface {
 }
 
 // GetTaskList returns tasks when it is the service is converged
+// The time until the service converged is recorded as metric.
 func GetTaskList(ctx context.Context, client *client.Client, serviceID string) ([]swarm.Task, error) {
+	start := time.Now()
+	taskList, err := getConvergedTaskList(ctx, client, serviceID)
+	if err != nil {
+		metrics.RecordConverge("failed", time.Since(start))
+	} else {
+		metrics.RecordConverge("converged", time.Since(start))
+	}
+	return taskList, err
+}
+
+func getConvergedTaskList(ctx context.Context, client *client.Client, serviceID string) ([]swarm.Task, error) {
 
 	taskFilter := filters.NewArgs()
 	taskFilter.Add("service", serviceID)
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/tracing"
+)
+
+// startEventSpan starts the root span of the work done for `event` of a
+// service or node
+// The span starts when the event happened, so that delays of the Docker
+// event stream are part of the trace.
+func startEventSpan(kind string, event Event) (context.Context, *tracing.Span) {
+	opts := []tracing.SpanOption{tracing.WithAttributes(
+		tracing.Attribute{Key: "event.id", Value: event.ID},
+		tracing.Attribute{Key: "event.consult_cache", Value: event.ConsultCache},
+	)}
+	if event.TimeNano > 0 && event.TimeNano <= time.Now().UnixNano() {
+		opts = append(opts, tracing.WithStartTime(time.Unix(0, event.TimeNano)))
+	}
+	ctx, span := tracing.Start(
+		context.Background(), fmt.Sprintf("%s.%s", kind, event.Type), opts...)
+	span.AddEvent("received")
+	return ctx, span
+}
+
+// endSpan ends `span` with the result `err` of its work
+// Work that is canceled because a newer event of the same service or node
+// arrived is not a failure.
+func endSpan(span *tracing.Span, err error, canceled bool) {
+	if canceled || (err != nil && strings.Contains(err.Error(), "context canceled")) {
+		span.AddEvent("canceled")
+		span.SetAttribute("canceled", true)
+	} else {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+// startDistributionSpan starts the span of the distribution of `n` to the
+// notification endpoints
+func startDistributionSpan(n Notification, kind string) (context.Context, *tracing.Span) {
+	return tracing.Start(
+		tracing.ContextWithSpanContext(context.Background(), n.SpanContext),
+		"notification.distribute",
+		tracing.WithAttributes(
+			tracing.Attribute{Key: "notification.kind", Value: kind},
+			tracing.Attribute{Key: "notification.event_type", Value: string(n.EventType)},
+			tracing.Attribute{Key: "notification.id", Value: n.ID},
+		))
+}
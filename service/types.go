@@ -154,6 +154,9 @@ func (ns *NodeIPSet) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	if *ns == nil {
+		*ns = NodeIPSet{}
+	}
 	for _, item := range items {
 		nodeIP := NodeIP{Name: item[0], Addr: item[1]}
 		if len(item) == 3 {
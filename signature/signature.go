@@ -0,0 +1,180 @@
+// Package signature signs notification requests sent by the swarm listener
+// and verifies them in the services that receive them.
+//
+// The signature is an HMAC-SHA256 of the unix timestamp in the
+// `X-Df-Timestamp` header, a dot, and the canonical request. The canonical
+// request is the method, the escaped url path, the raw url query, and the
+// body of the request, separated by newlines:
+//
+//	POST\n/v1/docker-flow-proxy/reconfigure\nhello=world\n{"serviceName":"demo"}
+//
+// The signature is sent hex encoded and prefixed with `sha256=` in the
+// `X-Df-Signature` header.
+//
+// A service verifies notifications with
+//
+//	func handler(w http.ResponseWriter, req *http.Request) {
+//		if err := signature.VerifyRequest(req, secret, signature.DefaultTolerance); err != nil {
+//			w.WriteHeader(http.StatusUnauthorized)
+//			return
+//		}
+//		...
+//	}
+package signature
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// TimestampHeader holds the unix time the request was signed at
+	TimestampHeader = "X-Df-Timestamp"
+	// SignatureHeader holds the signature of the request
+	SignatureHeader = "X-Df-Signature"
+	// DefaultTolerance is the maximum age of a signature accepted by
+	// `VerifyRequest` in most setups
+	DefaultTolerance = time.Minute * 5
+	// DefaultMaxBodySize is the size of the largest body read by
+	// `VerifyRequest`
+	DefaultMaxBodySize = 1 << 20
+
+	signaturePrefix = "sha256="
+)
+
+var (
+	// ErrMissingSignature is returned when a signature header is missing
+	ErrMissingSignature = errors.New("signature: missing signature or timestamp header")
+	// ErrInvalidTimestamp is returned when the timestamp is not a unix time
+	ErrInvalidTimestamp = errors.New("signature: invalid timestamp")
+	// ErrExpired is returned when the timestamp is outside of the tolerance
+	ErrExpired = errors.New("signature: timestamp is outside of the tolerance")
+	// ErrInvalidSignature is returned when the signature does not match
+	ErrInvalidSignature = errors.New("signature: signature does not match")
+	// ErrBodyTooLarge is returned when the body exceeds the maximum size
+	ErrBodyTooLarge = errors.New("signature: request body is too large")
+)
+
+// Sign returns the signature of `payload` sent at the unix time `timestamp`
+func Sign(secret []byte, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// CanonicalRequest returns the payload that is signed for a request with
+// `method`, the escaped url path `path`, the raw url query `rawQuery`, and
+// `body`
+func CanonicalRequest(method, path, rawQuery string, body []byte) []byte {
+	payload := make([]byte, 0, len(method)+len(path)+len(rawQuery)+len(body)+3)
+	payload = append(payload, method...)
+	payload = append(payload, '\n')
+	payload = append(payload, path...)
+	payload = append(payload, '\n')
+	payload = append(payload, rawQuery...)
+	payload = append(payload, '\n')
+	return append(payload, body...)
+}
+
+// SignRequest sets the timestamp and signature headers of `req`
+func SignRequest(req *http.Request, secret []byte, now time.Time) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+	payload := CanonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, body)
+	timestamp := now.Unix()
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, payload))
+	return nil
+}
+
+// Verify returns nil when `signature` is the signature of `payload` sent at
+// `timestamp`, and `timestamp` is no further than `tolerance` from `now`.
+// A `tolerance` of zero disables the timestamp check.
+func Verify(
+	secret []byte, timestamp, signature string, payload []byte,
+	now time.Time, tolerance time.Duration) error {
+
+	if len(timestamp) == 0 || len(signature) == 0 {
+		return ErrMissingSignature
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+	if tolerance > 0 {
+		age := now.Sub(time.Unix(ts, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrExpired
+		}
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	expected := Sign(secret, ts, payload)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// VerifyRequest verifies the signature headers of `req` against the
+// canonical request
+//
+//	<method>\n<escaped path>\n<raw query>\n<body>
+//
+// Bodies larger than `DefaultMaxBodySize` are rejected with
+// `ErrBodyTooLarge`. The body of `req` is read and replaced, so that it can
+// be read again.
+func VerifyRequest(req *http.Request, secret []byte, tolerance time.Duration) error {
+	return VerifyRequestWithLimit(req, secret, tolerance, DefaultMaxBodySize)
+}
+
+// VerifyRequestWithLimit verifies the signature headers of `req` like
+// `VerifyRequest`, but rejects bodies larger than `maxBodySize` bytes
+func VerifyRequestWithLimit(
+	req *http.Request, secret []byte, tolerance time.Duration, maxBodySize int64) error {
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) > maxBodySize {
+			return ErrBodyTooLarge
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	payload := CanonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, body)
+	return Verify(
+		secret, req.Header.Get(TimestampHeader), req.Header.Get(SignatureHeader),
+		payload, time.Now(), tolerance)
+}
+
+// requestBody returns the body of `req` without consuming it
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
@@ -0,0 +1,156 @@
+package signature
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SignatureTestSuite struct {
+	suite.Suite
+	secret []byte
+	now    time.Time
+}
+
+func TestSignatureUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(SignatureTestSuite))
+}
+
+func (s *SignatureTestSuite) SetupTest() {
+	s.secret = []byte("my-secret")
+	s.now = time.Unix(1514764800, 0)
+}
+
+func (s *SignatureTestSuite) Test_Sign_ReturnsHexEncodedHMAC() {
+	s.Equal(
+		"sha256=52a668e308c0ddadb91c5ae51dacde787b35d19f3bdece997325391ddb07e555",
+		Sign(s.secret, s.now.Unix(), []byte("serviceName=demo")))
+}
+
+func (s *SignatureTestSuite) Test_Verify_ReturnsNil_WhenSignatureMatches() {
+	sig := Sign(s.secret, s.now.Unix(), []byte("payload"))
+
+	err := Verify(s.secret, s.timestamp(s.now), sig, []byte("payload"), s.now.Add(time.Minute), DefaultTolerance)
+	s.NoError(err)
+}
+
+func (s *SignatureTestSuite) Test_Verify_ReturnsError_WhenSignatureDoesNotMatch() {
+	sig := Sign(s.secret, s.now.Unix(), []byte("payload"))
+
+	s.Equal(ErrInvalidSignature,
+		Verify(s.secret, s.timestamp(s.now), sig, []byte("other"), s.now, DefaultTolerance))
+	s.Equal(ErrInvalidSignature,
+		Verify([]byte("other"), s.timestamp(s.now), sig, []byte("payload"), s.now, DefaultTolerance))
+	s.Equal(ErrInvalidSignature,
+		Verify(s.secret, s.timestamp(s.now.Add(time.Second)), sig, []byte("payload"), s.now, DefaultTolerance))
+	s.Equal(ErrInvalidSignature,
+		Verify(s.secret, s.timestamp(s.now), strings.TrimPrefix(sig, "sha256="), []byte("payload"), s.now, DefaultTolerance))
+}
+
+func (s *SignatureTestSuite) Test_Verify_ReturnsError_WhenTimestampIsOutsideOfTolerance() {
+	sig := Sign(s.secret, s.now.Unix(), []byte("payload"))
+
+	s.Equal(ErrExpired,
+		Verify(s.secret, s.timestamp(s.now), sig, []byte("payload"), s.now.Add(time.Hour), DefaultTolerance))
+	s.Equal(ErrExpired,
+		Verify(s.secret, s.timestamp(s.now), sig, []byte("payload"), s.now.Add(-time.Hour), DefaultTolerance))
+	s.NoError(
+		Verify(s.secret, s.timestamp(s.now), sig, []byte("payload"), s.now.Add(time.Hour), 0))
+}
+
+func (s *SignatureTestSuite) Test_Verify_ReturnsError_WhenHeadersAreInvalid() {
+	s.Equal(ErrMissingSignature, Verify(s.secret, "", "sha256=00", nil, s.now, 0))
+	s.Equal(ErrMissingSignature, Verify(s.secret, "1", "", nil, s.now, 0))
+	s.Equal(ErrInvalidTimestamp, Verify(s.secret, "yesterday", "sha256=00", nil, s.now, 0))
+}
+
+func (s *SignatureTestSuite) Test_VerifyRequest_VerifiesSignedRequests() {
+	testCases := []struct {
+		method string
+		url    string
+		body   string
+	}{
+		{http.MethodGet, "http://proxy/reconfigure?serviceName=demo&port=8080", ""},
+		{http.MethodPost, "http://proxy/reconfigure", `{"serviceName":"demo"}`},
+	}
+
+	for _, tc := range testCases {
+		var verifyErr error
+		var body string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			verifyErr = VerifyRequest(r, s.secret, DefaultTolerance)
+			content := make([]byte, len(tc.body))
+			r.Body.Read(content)
+			body = string(content)
+		}))
+
+		req, err := http.NewRequest(tc.method, strings.Replace(tc.url, "http://proxy", srv.URL, 1), strings.NewReader(tc.body))
+		s.Require().NoError(err)
+		s.Require().NoError(SignRequest(req, s.secret, time.Now()))
+		resp, err := http.DefaultClient.Do(req)
+		s.Require().NoError(err)
+		resp.Body.Close()
+		srv.Close()
+
+		s.NoError(verifyErr, tc.url)
+		s.Equal(tc.body, body)
+	}
+}
+
+func (s *SignatureTestSuite) Test_CanonicalRequest_JoinsMethodPathQueryAndBody() {
+	s.Equal(
+		"POST\n/reconfigure\nport=8080\n{\"serviceName\":\"demo\"}",
+		string(CanonicalRequest(http.MethodPost, "/reconfigure", "port=8080", []byte(`{"serviceName":"demo"}`))))
+}
+
+func (s *SignatureTestSuite) Test_VerifyRequest_ReturnsError_WhenRequestIsModified() {
+	body := `{"serviceName":"demo"}`
+	s.NoError(VerifyRequest(
+		s.signedRequest(http.MethodPost, "/reconfigure?port=8080", body), s.secret, DefaultTolerance))
+
+	req := s.signedRequest(http.MethodPost, "/reconfigure?port=8080", body)
+	req.URL.Path = "/remove"
+	s.Equal(ErrInvalidSignature, VerifyRequest(req, s.secret, DefaultTolerance), "path")
+
+	req = s.signedRequest(http.MethodPost, "/reconfigure?port=8080", body)
+	req.URL.RawQuery = "port=9090"
+	s.Equal(ErrInvalidSignature, VerifyRequest(req, s.secret, DefaultTolerance), "query")
+
+	req = s.signedRequest(http.MethodPost, "/reconfigure?port=8080", body)
+	req.Method = http.MethodPut
+	s.Equal(ErrInvalidSignature, VerifyRequest(req, s.secret, DefaultTolerance), "method")
+}
+
+func (s *SignatureTestSuite) Test_VerifyRequestWithLimit_ReturnsError_WhenBodyIsTooLarge() {
+	req := s.signedRequest(http.MethodPost, "/reconfigure", "0123456789")
+	s.Equal(ErrBodyTooLarge, VerifyRequestWithLimit(req, s.secret, DefaultTolerance, 9))
+
+	req = s.signedRequest(http.MethodPost, "/reconfigure", "0123456789")
+	s.NoError(VerifyRequestWithLimit(req, s.secret, DefaultTolerance, 10))
+}
+
+func (s *SignatureTestSuite) Test_VerifyRequest_ReturnsError_WhenRequestIsNotSigned() {
+	req := httptest.NewRequest(http.MethodGet, "/reconfigure?serviceName=demo", nil)
+
+	s.Equal(ErrMissingSignature, VerifyRequest(req, s.secret, DefaultTolerance))
+}
+
+// signedRequest returns a request, as received by a server, that is signed
+// with the current time
+func (s *SignatureTestSuite) signedRequest(method, target, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	now := time.Now()
+	payload := CanonicalRequest(req.Method, req.URL.EscapedPath(), req.URL.RawQuery, []byte(body))
+	req.Header.Set(TimestampHeader, s.timestamp(now))
+	req.Header.Set(SignatureHeader, Sign(s.secret, now.Unix(), payload))
+	return req
+}
+
+func (s *SignatureTestSuite) timestamp(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
@@ -0,0 +1,196 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultServiceName is the `service.name` of exported spans
+	DefaultServiceName = "docker-flow-swarm-listener"
+
+	scopeName = "github.com/docker-flow/docker-flow-swarm-listener/tracing"
+
+	statusCodeError = 2
+)
+
+// OTLPExporter exports spans with the JSON encoding of OTLP over HTTP
+type OTLPExporter struct {
+	endpoint    string
+	serviceName string
+	headers     http.Header
+	client      *http.Client
+}
+
+// NewOTLPExporter returns an `OTLPExporter` that posts spans to the traces
+// url `endpoint` of a collector with `headers`
+func NewOTLPExporter(endpoint, serviceName string, headers map[string]string) *OTLPExporter {
+	h := http.Header{}
+	for name, value := range headers {
+		h.Set(name, value)
+	}
+	return &OTLPExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		headers:     h,
+		client:      &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// Export posts `spans` to the collector
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, values := range e.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status code %d", e.endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string `json:"timeUnixNano"`
+	Name         string `json:"name"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+// otlpValue holds one of its values. Integers are encoded as strings.
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+// request converts `spans` into an OTLP export request
+func (e *OTLPExporter) request(spans []SpanData) otlpRequest {
+	converted := make([]otlpSpan, 0, len(spans))
+	for _, data := range spans {
+		span := otlpSpan{
+			TraceID:           data.SpanContext.TraceID.String(),
+			SpanID:            data.SpanContext.SpanID.String(),
+			Name:              data.Name,
+			Kind:              data.Kind,
+			StartTimeUnixNano: unixNano(data.StartTime),
+			EndTimeUnixNano:   unixNano(data.EndTime),
+		}
+		if data.ParentSpanID != (SpanID{}) {
+			span.ParentSpanID = data.ParentSpanID.String()
+		}
+		for _, attr := range data.Attributes {
+			span.Attributes = append(span.Attributes, newOTLPAttribute(attr.Key, attr.Value))
+		}
+		for _, event := range data.Events {
+			span.Events = append(span.Events, otlpEvent{
+				TimeUnixNano: unixNano(event.Time),
+				Name:         event.Name,
+			})
+		}
+		if len(data.Error) > 0 {
+			span.Status = otlpStatus{Code: statusCodeError, Message: data.Error}
+		}
+		converted = append(converted, span)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttribute{
+			newOTLPAttribute("service.name", e.serviceName),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: scopeName},
+			Spans: converted,
+		}},
+	}}}
+}
+
+// newOTLPAttribute converts an attribute value. Values that are not
+// booleans or integers are sent as strings.
+func newOTLPAttribute(key string, value interface{}) otlpAttribute {
+	attr := otlpAttribute{Key: key}
+	switch v := value.(type) {
+	case bool:
+		attr.Value.BoolValue = &v
+	case int:
+		s := strconv.Itoa(v)
+		attr.Value.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		attr.Value.IntValue = &s
+	case string:
+		attr.Value.StringValue = &v
+	default:
+		s := fmt.Sprint(v)
+		attr.Value.StringValue = &s
+	}
+	return attr
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type OTLPExporterTestSuite struct {
+	suite.Suite
+}
+
+func TestOTLPExporterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(OTLPExporterTestSuite))
+}
+
+func (s *OTLPExporterTestSuite) Test_Export_PostsSpansAsJSON() {
+	var req *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	span := SpanData{
+		Name: "HTTP GET",
+		SpanContext: SpanContext{
+			TraceID: parent.TraceID,
+			SpanID:  SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+			Sampled: true,
+		},
+		ParentSpanID: parent.SpanID,
+		Kind:         SpanKindClient,
+		StartTime:    time.Unix(1, 0),
+		EndTime:      time.Unix(2, 0),
+		Attributes: []Attribute{
+			{Key: "http.url", Value: "http://proxy/reconfigure"},
+			{Key: "http.status_code", Value: 500},
+			{Key: "canceled", Value: false},
+		},
+		Events: []SpanEvent{{Name: "received", Time: time.Unix(1, 5)}},
+		Error:  "returned status code 500",
+	}
+	exporter := NewOTLPExporter(srv.URL+"/v1/traces", "dfsl", map[string]string{"Authorization": "Bearer token"})
+
+	err := exporter.Export(context.Background(), []SpanData{span})
+	s.Require().NoError(err)
+
+	s.Require().NotNil(req)
+	s.Equal(http.MethodPost, req.Method)
+	s.Equal("/v1/traces", req.URL.Path)
+	s.Equal("application/json", req.Header.Get("Content-Type"))
+	s.Equal("Bearer token", req.Header.Get("Authorization"))
+	s.JSONEq(`{"resourceSpans": [{
+		"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "dfsl"}}]},
+		"scopeSpans": [{
+			"scope": {"name": "github.com/docker-flow/docker-flow-swarm-listener/tracing"},
+			"spans": [{
+				"traceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+				"spanId": "0102030405060708",
+				"parentSpanId": "00f067aa0ba902b7",
+				"name": "HTTP GET",
+				"kind": 3,
+				"startTimeUnixNano": "1000000000",
+				"endTimeUnixNano": "2000000000",
+				"attributes": [
+					{"key": "http.url", "value": {"stringValue": "http://proxy/reconfigure"}},
+					{"key": "http.status_code", "value": {"intValue": "500"}},
+					{"key": "canceled", "value": {"boolValue": false}}
+				],
+				"events": [{"timeUnixNano": "1000000005", "name": "received"}],
+				"status": {"code": 2, "message": "returned status code 500"}
+			}]
+		}]
+	}]}`, string(body))
+}
+
+func (s *OTLPExporterTestSuite) Test_Export_ReturnsError_WhenCollectorFails() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	exporter := NewOTLPExporter(srv.URL, "dfsl", nil)
+
+	err := exporter.Export(context.Background(), []SpanData{{Name: "service.create"}})
+
+	s.Require().Error(err)
+	s.Contains(err.Error(), "returned status code 503")
+}
+
+func (s *OTLPExporterTestSuite) Test_Export_OmitsParentAndStatus_OfSucceededRootSpans() {
+	exporter := NewOTLPExporter("http://collector", "dfsl", nil)
+
+	request := exporter.request([]SpanData{{Name: "service.create", Kind: SpanKindInternal}})
+
+	body, err := json.Marshal(request.ResourceSpans[0].ScopeSpans[0].Spans[0])
+	s.Require().NoError(err)
+	s.NotContains(string(body), "parentSpanId")
+	s.Contains(string(body), `"status":{}`)
+}
@@ -0,0 +1,156 @@
+package tracing
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+)
+
+const (
+	// DefaultFlushInterval is the longest time a finished span waits to be
+	// exported
+	DefaultFlushInterval = time.Second * 5
+	// DefaultBatchSize is the number of spans exported at once
+	DefaultBatchSize = 512
+
+	// queueSize is the number of finished spans kept for export. Spans
+	// that do not fit are dropped.
+	queueSize = 4096
+)
+
+// Exporter sends finished spans to a tracing backend
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Tracer starts spans and exports them in batches
+type Tracer struct {
+	exporter      Exporter
+	sampleRatio   float64
+	batchSize     int
+	flushInterval time.Duration
+	spans         chan SpanData
+	flushChan     chan chan struct{}
+	dropped       int
+	droppedMux    sync.Mutex
+	log           *logging.Logger
+}
+
+// NewTracer returns a `Tracer` that exports spans with `exporter`
+// Traces are started for a `sampleRatio` share of the events, between 0
+// and 1. Spans of traces started elsewhere follow the sampling decision
+// of their parent.
+func NewTracer(exporter Exporter, sampleRatio float64, logger *logging.Logger) *Tracer {
+	t := &Tracer{
+		exporter:      exporter,
+		sampleRatio:   sampleRatio,
+		batchSize:     DefaultBatchSize,
+		flushInterval: DefaultFlushInterval,
+		spans:         make(chan SpanData, queueSize),
+		flushChan:     make(chan chan struct{}),
+		log:           logger,
+	}
+	go t.run()
+	return t
+}
+
+// Flush exports the finished spans and waits until they are exported
+func (t *Tracer) Flush() {
+	done := make(chan struct{})
+	t.flushChan <- done
+	<-done
+}
+
+// newSpan starts a span that is a child of `parent`, or the root of a new
+// trace when `parent` is not valid
+func (t *Tracer) newSpan(parent SpanContext, name string, opts []SpanOption) *Span {
+	data := SpanData{
+		Name:      name,
+		Kind:      SpanKindInternal,
+		StartTime: time.Now(),
+	}
+	if parent.IsValid() {
+		data.SpanContext = SpanContext{
+			TraceID: parent.TraceID,
+			Sampled: parent.Sampled,
+		}
+		data.ParentSpanID = parent.SpanID
+	} else {
+		data.SpanContext = SpanContext{
+			TraceID: newTraceID(),
+			Sampled: t.sampleRatio >= 1 || rand.Float64() < t.sampleRatio,
+		}
+	}
+	data.SpanContext.SpanID = newSpanID()
+	for _, opt := range opts {
+		opt(&data)
+	}
+	return &Span{tracer: t, data: data}
+}
+
+// enqueue places a finished span in the export queue
+// The span is dropped when the queue is full, so that tracing never blocks
+// notifications.
+func (t *Tracer) enqueue(data SpanData) {
+	select {
+	case t.spans <- data:
+	default:
+		t.droppedMux.Lock()
+		t.dropped++
+		t.droppedMux.Unlock()
+	}
+}
+
+func (t *Tracer) run() {
+	ticker := time.NewTicker(t.flushInterval)
+	defer ticker.Stop()
+
+	batch := []SpanData{}
+	for {
+		select {
+		case data := <-t.spans:
+			batch = append(batch, data)
+			if len(batch) >= t.batchSize {
+				batch = t.export(batch)
+			}
+		case <-ticker.C:
+			batch = t.export(batch)
+		case done := <-t.flushChan:
+			for drained := false; !drained; {
+				select {
+				case data := <-t.spans:
+					batch = append(batch, data)
+				default:
+					drained = true
+				}
+			}
+			batch = t.export(batch)
+			close(done)
+		}
+	}
+}
+
+// export sends `batch` to the exporter and returns an empty batch
+// Spans that cannot be exported are dropped.
+func (t *Tracer) export(batch []SpanData) []SpanData {
+	t.droppedMux.Lock()
+	dropped := t.dropped
+	t.dropped = 0
+	t.droppedMux.Unlock()
+	if dropped > 0 {
+		t.log.Warn("Dropped spans, the export queue is full", "spans", dropped)
+	}
+
+	if len(batch) == 0 {
+		return batch
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.flushInterval)
+	defer cancel()
+	if err := t.exporter.Export(ctx, batch); err != nil {
+		t.log.Error("Unable to export spans", "spans", len(batch), "error", err)
+	}
+	return []SpanData{}
+}
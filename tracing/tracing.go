@@ -0,0 +1,332 @@
+// Package tracing records spans of the work done for Docker events and
+// exports them to an OpenTelemetry collector with OTLP over HTTP.
+//
+// Spans are started with `Start`, which creates a child of the span in the
+// context. Nothing is recorded until a `Tracer` is installed with
+// `SetTracer`, so instrumented code does not check whether tracing is
+// enabled:
+//
+//	ctx, span := tracing.Start(ctx, "docker.inspect")
+//	defer span.End()
+//
+// The trace context is propagated to the receivers of notifications with
+// the W3C `traceparent` header. A service continues the trace with
+//
+//	parent, err := tracing.ParseTraceparent(req.Header.Get(tracing.TraceparentHeader))
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader is the header the trace context is propagated with
+const TraceparentHeader = "traceparent"
+
+const traceparentVersion = "00"
+
+// ErrInvalidTraceparent is returned when a `traceparent` header cannot be
+// parsed
+var ErrInvalidTraceparent = errors.New("tracing: invalid traceparent")
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// String returns the hex encoding of the id
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the hex encoding of the id
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext identifies a span and the trace it belongs to
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns true when both ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent returns the value of the `traceparent` header of the span
+// An empty string is returned when `sc` is not valid.
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("%s-%s-%s-%s", traceparentVersion, sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses the value of a `traceparent` header
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	// Later versions may append fields
+	var version [1]byte
+	if err := decodeHex(version[:], parts[0]); err != nil {
+		return SpanContext{}, err
+	}
+	if version[0] == 0xff || (parts[0] == traceparentVersion && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	sc := SpanContext{}
+	if err := decodeHex(sc.TraceID[:], parts[1]); err != nil {
+		return SpanContext{}, err
+	}
+	if err := decodeHex(sc.SpanID[:], parts[2]); err != nil {
+		return SpanContext{}, err
+	}
+	var flags [1]byte
+	if err := decodeHex(flags[:], parts[3]); err != nil {
+		return SpanContext{}, err
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// decodeHex decodes the lowercase hex `value` into `id`
+func decodeHex(id []byte, value string) error {
+	if len(value) != hex.EncodedLen(len(id)) || strings.ToLower(value) != value {
+		return ErrInvalidTraceparent
+	}
+	if _, err := hex.Decode(id, []byte(value)); err != nil {
+		return ErrInvalidTraceparent
+	}
+	return nil
+}
+
+// SpanKind describes the relationship of a span to remote services
+type SpanKind int
+
+const (
+	// SpanKindInternal is used for work done within the swarm listener
+	SpanKindInternal SpanKind = 1
+	// SpanKindClient is used for requests to other services
+	SpanKindClient SpanKind = 3
+)
+
+// Attribute is a key-value pair describing a span
+// Values are strings, booleans, or integers.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// SpanEvent is something that happened at a point in time during a span
+type SpanEvent struct {
+	Name string
+	Time time.Time
+}
+
+// SpanData is a finished span handed to an `Exporter`
+type SpanData struct {
+	Name         string
+	SpanContext  SpanContext
+	ParentSpanID SpanID
+	Kind         SpanKind
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   []Attribute
+	Events       []SpanEvent
+	// Error is the description of the failure of the span, if any
+	Error string
+}
+
+// Span is an operation within a trace
+// Methods of a nil span do nothing, which is what `Start` returns when
+// tracing is disabled.
+type Span struct {
+	tracer *Tracer
+	data   SpanData
+	ended  bool
+	mux    sync.Mutex
+}
+
+// SpanContext returns the ids of the span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// SetAttribute sets the attribute `key` of the span to `value`
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for idx, attr := range s.data.Attributes {
+		if attr.Key == key {
+			s.data.Attributes[idx].Value = value
+			return
+		}
+	}
+	s.data.Attributes = append(s.data.Attributes, Attribute{Key: key, Value: value})
+}
+
+// AddEvent records that `name` happened now
+func (s *Span) AddEvent(name string) {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.data.Events = append(s.data.Events, SpanEvent{Name: name, Time: time.Now()})
+}
+
+// SetError marks the span as failed with `err`
+// A nil `err` does nothing.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End finishes the span and hands it to the exporter when it is sampled
+// Calls after the first one do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mux.Lock()
+	if s.ended {
+		s.mux.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	s.mux.Unlock()
+
+	if data.SpanContext.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+// SpanOption configures a span started with `Start`
+type SpanOption func(data *SpanData)
+
+// WithStartTime starts the span at `t` instead of now
+func WithStartTime(t time.Time) SpanOption {
+	return func(data *SpanData) {
+		data.StartTime = t
+	}
+}
+
+// WithKind sets the kind of the span, which is `SpanKindInternal` by
+// default
+func WithKind(kind SpanKind) SpanOption {
+	return func(data *SpanData) {
+		data.Kind = kind
+	}
+}
+
+// WithAttributes sets attributes of the span
+func WithAttributes(attrs ...Attribute) SpanOption {
+	return func(data *SpanData) {
+		data.Attributes = append(data.Attributes, attrs...)
+	}
+}
+
+type spanContextKey struct{}
+
+// Start starts a span named `name` that is a child of the span in `ctx`
+// The returned context holds the new span. When tracing is disabled, `ctx`
+// and a nil span are returned.
+func Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	tracer := GetTracer()
+	if tracer == nil {
+		return ctx, nil
+	}
+	span := tracer.newSpan(SpanContextFromContext(ctx), name, opts)
+	return context.WithValue(ctx, spanContextKey{}, span.data.SpanContext), span
+}
+
+// ContextWithSpanContext returns a context that starts spans as children
+// of `sc`. `ctx` is returned when `sc` is not valid.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context held by `ctx`
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// Inject sets the `traceparent` header to the span held by `ctx`
+// The header is not changed when `ctx` does not hold a span.
+func Inject(ctx context.Context, header http.Header) {
+	if value := SpanContextFromContext(ctx).Traceparent(); len(value) > 0 {
+		header.Set(TraceparentHeader, value)
+	}
+}
+
+var (
+	globalTracer    *Tracer
+	globalTracerMux sync.RWMutex
+)
+
+// SetTracer installs the tracer used by `Start`
+// A nil tracer disables tracing.
+func SetTracer(tracer *Tracer) {
+	globalTracerMux.Lock()
+	defer globalTracerMux.Unlock()
+	globalTracer = tracer
+}
+
+// GetTracer returns the tracer installed with `SetTracer`
+func GetTracer() *Tracer {
+	globalTracerMux.RLock()
+	defer globalTracerMux.RUnlock()
+	return globalTracer
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	rand.Read(id[:])
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	rand.Read(id[:])
+	return id
+}
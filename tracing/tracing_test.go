@@ -0,0 +1,178 @@
+package tracing
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker-flow/docker-flow-swarm-listener/logging"
+	"github.com/stretchr/testify/suite"
+)
+
+type TracingTestSuite struct {
+	suite.Suite
+	exporter *recordingExporter
+	tracer   *Tracer
+}
+
+func TestTracingUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
+
+func (s *TracingTestSuite) SetupTest() {
+	s.exporter = &recordingExporter{}
+	s.tracer = NewTracer(s.exporter, 1, logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelInfo))
+	SetTracer(s.tracer)
+}
+
+func (s *TracingTestSuite) TearDownTest() {
+	SetTracer(nil)
+}
+
+// Traceparent
+
+func (s *TracingTestSuite) Test_ParseTraceparent_ReturnsSpanContext() {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	s.Require().NoError(err)
+
+	s.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	s.Equal("00f067aa0ba902b7", sc.SpanID.String())
+	s.True(sc.Sampled)
+	s.Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	sc, err = ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	s.Require().NoError(err)
+	s.False(sc.Sampled)
+}
+
+func (s *TracingTestSuite) Test_ParseTraceparent_AcceptsFieldsOfLaterVersions() {
+	sc, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+	s.Require().NoError(err)
+	s.Equal("00f067aa0ba902b7", sc.SpanID.String())
+}
+
+func (s *TracingTestSuite) Test_ParseTraceparent_ReturnsError_WhenValueIsInvalid() {
+	values := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	}
+	for _, value := range values {
+		_, err := ParseTraceparent(value)
+		s.Equal(ErrInvalidTraceparent, err, value)
+	}
+}
+
+// Start
+
+func (s *TracingTestSuite) Test_Start_ReturnsNilSpan_WhenTracingIsDisabled() {
+	SetTracer(nil)
+	ctx := context.Background()
+
+	spanCtx, span := Start(ctx, "docker.inspect")
+
+	s.Nil(span)
+	s.Equal(ctx, spanCtx)
+	span.SetAttribute("key", "value")
+	span.AddEvent("received")
+	span.End()
+}
+
+func (s *TracingTestSuite) Test_Start_StartsChildOfSpanInContext() {
+	ctx, root := Start(context.Background(), "service.create",
+		WithStartTime(time.Unix(10, 0)),
+		WithAttributes(Attribute{Key: "event.id", Value: "serviceID1"}))
+	_, child := Start(ctx, "docker.inspect", WithKind(SpanKindClient))
+	child.SetError(context.DeadlineExceeded)
+	child.End()
+	root.AddEvent("received")
+	root.End()
+	root.End()
+	s.tracer.Flush()
+
+	spans := s.exporter.Spans()
+	s.Require().Len(spans, 2)
+	s.Equal("docker.inspect", spans[0].Name)
+	s.Equal(SpanKindClient, spans[0].Kind)
+	s.Equal(root.SpanContext().TraceID, spans[0].SpanContext.TraceID)
+	s.Equal(root.SpanContext().SpanID, spans[0].ParentSpanID)
+	s.Equal("context deadline exceeded", spans[0].Error)
+
+	s.Equal("service.create", spans[1].Name)
+	s.Equal(SpanKindInternal, spans[1].Kind)
+	s.Equal(SpanID{}, spans[1].ParentSpanID)
+	s.Equal(time.Unix(10, 0), spans[1].StartTime)
+	s.Equal([]Attribute{{Key: "event.id", Value: "serviceID1"}}, spans[1].Attributes)
+	s.Require().Len(spans[1].Events, 1)
+	s.Equal("received", spans[1].Events[0].Name)
+}
+
+func (s *TracingTestSuite) Test_Start_ContinuesRemoteSpanContext() {
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	_, span := Start(ContextWithSpanContext(context.Background(), parent), "notification.deliver")
+
+	s.Equal(parent.TraceID, span.SpanContext().TraceID)
+	s.NotEqual(parent.SpanID, span.SpanContext().SpanID)
+	s.Equal(parent.SpanID, span.data.ParentSpanID)
+}
+
+func (s *TracingTestSuite) Test_Start_DoesNotExportUnsampledSpans() {
+	SetTracer(NewTracer(s.exporter, 0, logging.New(ioutil.Discard, logging.FormatLogfmt, logging.LevelInfo)))
+
+	ctx, root := Start(context.Background(), "service.create")
+	_, child := Start(ctx, "docker.inspect")
+	child.End()
+	root.End()
+	GetTracer().Flush()
+
+	s.False(root.SpanContext().Sampled)
+	s.False(child.SpanContext().Sampled)
+	s.Empty(s.exporter.Spans())
+}
+
+// Inject
+
+func (s *TracingTestSuite) Test_Inject_SetsTraceparentHeader() {
+	ctx, span := Start(context.Background(), "HTTP POST")
+	header := http.Header{}
+
+	Inject(ctx, header)
+
+	s.Equal(span.SpanContext().Traceparent(), header.Get(TraceparentHeader))
+}
+
+func (s *TracingTestSuite) Test_Inject_DoesNothing_WithoutSpan() {
+	header := http.Header{}
+
+	Inject(context.Background(), header)
+
+	s.Empty(header)
+}
+
+type recordingExporter struct {
+	spans []SpanData
+	mux   sync.Mutex
+}
+
+func (e *recordingExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Spans() []SpanData {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	return e.spans
+}